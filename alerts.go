@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Severity ranks how urgent a failed alert rule is
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarn     Severity = "warn"
+	SeverityCritical Severity = "critical"
+)
+
+// AlertRule is an assertion about a dataset or column metric, e.g. "Price min >= 0".
+// The rule passes when the comparison holds; a failing rule raises an alert of the given severity.
+type AlertRule struct {
+	Name     string   `json:"name,omitempty"`
+	Column   string   `json:"column,omitempty"` // empty for dataset-level metrics such as row_count
+	Metric   string   `json:"metric"`
+	Op       string   `json:"op"`
+	Value    float64  `json:"value"`
+	Severity Severity `json:"severity,omitempty"` // defaults to warn
+}
+
+// AlertResult is the outcome of evaluating one alert rule
+type AlertResult struct {
+	Rule    AlertRule `json:"rule"`
+	Passed  bool      `json:"passed"`
+	Actual  *float64  `json:"actual,omitempty"` // nil when the metric could not be computed
+	Message string    `json:"message"`
+	// Suppressed is set on failures covered by an active suppression; they are reported but never page
+	Suppressed        bool   `json:"suppressed,omitempty"`
+	SuppressionReason string `json:"suppression_reason,omitempty"`
+}
+
+// datasetMetrics are the metrics that describe the whole dataset rather than one column
+var datasetMetrics = map[string]bool{"row_count": true, "column_count": true, "duplicate_rows": true, "schema_mismatches": true, "rule_violations": true}
+
+// columnMetrics are the metrics that can be asserted about a single column
+var columnMetrics = map[string]bool{
+	"count": true, "missing_count": true, "missing_pct": true, "unique_count": true, "uniqueness_ratio": true,
+	"sum": true, "mean": true, "median": true, "std_dev": true, "min": true, "max": true,
+	// duplicate_keys counts the key tuples held by more than one row; its column is a comma-separated key
+	"duplicate_keys": true,
+}
+
+// normalize fills in defaults and rejects rules that can never be evaluated
+func (r *AlertRule) normalize() error {
+	// Severity defaults to warn and is matched case-insensitively.
+	r.Severity = Severity(strings.ToLower(string(r.Severity)))
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityWarn
+	case SeverityInfo, SeverityWarn, SeverityCritical:
+	default:
+		return fmt.Errorf("unknown severity %q (expected info, warn or critical)", r.Severity)
+	}
+	// The metric must exist at the level the rule is written for.
+	if r.Column == "" && !datasetMetrics[r.Metric] {
+		return fmt.Errorf("metric %q needs a column (dataset metrics: row_count, column_count, duplicate_rows, schema_mismatches, rule_violations)", r.Metric)
+	}
+	if r.Column != "" && !columnMetrics[r.Metric] {
+		return fmt.Errorf("unknown column metric %q", r.Metric)
+	}
+	// The comparison operator must be one we can apply.
+	if _, ok := compareOps[r.Op]; !ok {
+		return fmt.Errorf("unknown operator %q", r.Op)
+	}
+	// Gives unnamed rules a readable name for reports and notifications.
+	if r.Name == "" {
+		r.Name = strings.TrimSpace(fmt.Sprintf("%s %s %s %v", r.Column, r.Metric, r.Op, r.Value))
+	}
+	return nil
+}
+
+// compareOps maps the supported operators to their comparison functions
+var compareOps = map[string]func(a, b float64) bool{
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// The EvaluateAlerts method is part of the CSVAnalyzer struct. It computes the metric named by every configured alert
+// rule - for the whole dataset or for one column - compares it with the rule's threshold, and returns one result per
+// rule in configuration order. A rule whose column is missing, or whose metric does not apply to the column's type
+// (for example "mean" on a text column), fails with an explanatory message rather than being skipped.
+// EvaluateAlerts checks every configured alert rule against the loaded data
+func (ca *CSVAnalyzer) EvaluateAlerts() []AlertResult {
+	// Declares the slice that collects one result per rule.
+	var results []AlertResult
+	// Evaluates the rules in the order they were configured.
+	for _, rule := range ca.config.Alerts {
+		result := AlertResult{Rule: rule}
+		// Looks up the metric value for the rule.
+		actual, err := ca.alertMetric(rule)
+		if err != nil {
+			result.Message = err.Error()
+		} else {
+			// Applies the rule's comparison to the measured value.
+			result.Actual = &actual
+			result.Passed = compareOps[rule.Op](actual, rule.Value)
+			if result.Passed {
+				result.Message = fmt.Sprintf("%s is %s (expected %s %s)", rule.Metric, formatMetric(actual), rule.Op, formatMetric(rule.Value))
+			} else {
+				result.Message = fmt.Sprintf("%s is %s, expected %s %s", rule.Metric, formatMetric(actual), rule.Op, formatMetric(rule.Value))
+			}
+		}
+		results = append(results, result)
+	}
+	// Marks failures that are known and accepted.
+	ca.applySuppressions(results, time.Now())
+	// Returns all results, passed and failed.
+	return results
+}
+
+// alertMetric computes the value a rule refers to
+func (ca *CSVAnalyzer) alertMetric(rule AlertRule) (float64, error) {
+	// Dataset-level metrics need no column lookup.
+	switch rule.Metric {
+	case "row_count":
+		return float64(len(ca.dataset.Rows)), nil
+	case "column_count":
+		return float64(len(ca.dataset.Headers)), nil
+	case "duplicate_rows":
+		return float64(ca.duplicateRows()), nil
+	case "schema_mismatches":
+		if ca.expectedSchema == nil {
+			return 0, fmt.Errorf("metric %q needs an expected schema (--expect-schema)", rule.Metric)
+		}
+		return float64(len(ca.SchemaMismatches())), nil
+	case "rule_violations":
+		if len(ca.valueRules) == 0 {
+			return 0, fmt.Errorf("metric %q needs a rules file (--rules)", rule.Metric)
+		}
+		return float64(ca.ruleViolations()), nil
+	}
+	// Key uniqueness names a combination of columns, so it is measured before looking up a single column.
+	if rule.Metric == "duplicate_keys" {
+		var key columnList
+		key.Set(rule.Column)
+		if err := ca.checkColumnsExist("key", key); err != nil {
+			return 0, err
+		}
+		return float64(ca.checkKey(key).DuplicateKeys), nil
+	}
+	// Column metrics start by finding the column.
+	colIndex := ca.columnIndex(rule.Column)
+	if colIndex < 0 {
+		return 0, fmt.Errorf("column %q not found", rule.Column)
+	}
+	// Completeness metrics apply to every column type.
+	nonEmpty := ca.countNonEmptyValues(colIndex)
+	switch rule.Metric {
+	case "count":
+		return float64(nonEmpty), nil
+	case "missing_count":
+		return float64(len(ca.dataset.Rows) - nonEmpty), nil
+	case "missing_pct":
+		if len(ca.dataset.Rows) == 0 {
+			return 0, nil
+		}
+		return 100 * float64(len(ca.dataset.Rows)-nonEmpty) / float64(len(ca.dataset.Rows)), nil
+	case "unique_count":
+		return float64(len(ca.extractUniqueValues(colIndex))), nil
+	case "uniqueness_ratio":
+		return ca.calculateCardinality(colIndex).UniquenessRatio, nil
+	}
+	// The remaining metrics are only defined for numeric columns.
+	stats, ok := ca.calculateColumnStats(colIndex)
+	if !ok {
+		return 0, fmt.Errorf("metric %q needs a numeric column, %q is %s", rule.Metric, rule.Column, ca.columnTypeName(colIndex))
+	}
+	switch rule.Metric {
+	case "sum":
+		return stats.Sum, nil
+	case "mean":
+		return stats.Mean, nil
+	case "median":
+		return stats.Median, nil
+	case "std_dev":
+		return stats.StdDev, nil
+	case "min":
+		return stats.Min, nil
+	case "max":
+		return stats.Max, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q", rule.Metric)
+}
+
+// columnIndex finds a column by header name, returning -1 when it does not exist
+func (ca *CSVAnalyzer) columnIndex(name string) int {
+	return ca.dataset.headerIndex(name)
+}
+
+// formatMetric prints whole numbers without decimals and everything else with three
+func formatMetric(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.3f", v)
+}
+
+// failedAlerts returns the results that did not pass, including suppressed ones
+func failedAlerts(results []AlertResult) []AlertResult {
+	var failed []AlertResult
+	for _, result := range results {
+		if !result.Passed {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// activeFailures returns the failed results that are not suppressed; only these are notified
+func activeFailures(results []AlertResult) []AlertResult {
+	var active []AlertResult
+	for _, result := range failedAlerts(results) {
+		if !result.Suppressed {
+			active = append(active, result)
+		}
+	}
+	return active
+}
+
+// hasCriticalFailure reports whether any unsuppressed critical rule failed; only these affect the exit code
+func hasCriticalFailure(results []AlertResult) bool {
+	for _, result := range activeFailures(results) {
+		if result.Rule.Severity == SeverityCritical {
+			return true
+		}
+	}
+	return false
+}
+
+// printAlerts writes the alert section of the text report
+func printAlerts(results []AlertResult) {
+	// Nothing is printed when no rules were configured.
+	if len(results) == 0 {
+		return
+	}
+	failed := failedAlerts(results)
+	fmt.Println("\n\nAlerts:")
+	fmt.Println("-------")
+	fmt.Printf("%d of %d rules passed\n", len(results)-len(failed), len(results))
+	// Lists every failure with its severity so on-call can triage at a glance.
+	for _, result := range failed {
+		label := strings.ToUpper(string(result.Rule.Severity))
+		// Suppressed failures stay visible, with the reason they were accepted.
+		if result.Suppressed {
+			label = "SUPPRESSED " + label
+		}
+		fmt.Printf("  [%s] %s: %s", label, result.Rule.Name, result.Message)
+		if result.SuppressionReason != "" {
+			fmt.Printf(" (%s)", result.SuppressionReason)
+		}
+		fmt.Println()
+	}
+}
+
+// AlertNotification is the JSON body posted to the alert webhook
+type AlertNotification struct {
+	Dataset  string        `json:"dataset"`
+	Time     string        `json:"time"`
+	Critical bool          `json:"critical"`
+	Alerts   []AlertResult `json:"alerts"`
+}
+
+// notifyAlerts posts every failed alert, whatever its severity, to a webhook
+func notifyAlerts(webhookURL, dataset string, results []AlertResult) error {
+	// Only failures that are not suppressed are worth a notification.
+	failed := activeFailures(results)
+	if len(failed) == 0 {
+		return nil
+	}
+	// Builds and encodes the notification body.
+	body, err := json.Marshal(AlertNotification{
+		Dataset:  dataset,
+		Time:     time.Now().UTC().Format(time.RFC3339),
+		Critical: hasCriticalFailure(failed),
+		Alerts:   failed,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding alert notification: %v", err)
+	}
+	// Sends the notification with a timeout so a slow receiver cannot stall the run.
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error sending alert notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,633 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arrowBatchRows is the number of rows per record batch written to an Arrow stream
+const arrowBatchRows = 65536
+
+// arrowContinuation marks the start of every message of an Arrow IPC stream
+const arrowContinuation = 0xFFFFFFFF
+
+// arrowFileMagic opens (and closes) files in the Arrow random-access file format
+const arrowFileMagic = "ARROW1"
+
+// arrowMetadataV5 is the Arrow metadata version written and expected
+const arrowMetadataV5 = 4
+
+// Message header types of the Arrow IPC format
+const (
+	arrowSchemaMessage      = 1
+	arrowDictionaryMessage  = 2
+	arrowRecordBatchMessage = 3
+)
+
+// Arrow logical types the analyzer reads (ids of the flatbuffer Type union); it writes Utf8, Bool and FloatingPoint
+const (
+	arrowInt           = 2
+	arrowFloatingPoint = 3
+	arrowUtf8          = 5
+	arrowBool          = 6
+	arrowDate          = 8
+	arrowTimestamp     = 10
+	arrowLargeUtf8     = 20
+)
+
+// isArrowInput reports whether a file should be read as an Arrow IPC stream or file, judging by its extension
+func isArrowInput(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".arrow", ".arrows":
+		return true
+	}
+	return false
+}
+
+// fbBuilder writes flatbuffers front to back: every table is preceded by its vtable and followed by the objects it
+// refers to, so all offsets point forward as the format requires
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbObject is anything that can be written into a flatbuffer; write returns the position that refers to it
+type fbObject interface {
+	write(b *fbBuilder) int
+}
+
+// fbField is one field of a flatbuffer table: an inline scalar of size bytes, or an offset to child
+type fbField struct {
+	slot   int
+	size   int
+	scalar uint64
+	child  fbObject
+}
+
+// fbTable is a flatbuffer table
+type fbTable []fbField
+
+// fbString is a flatbuffer string
+type fbString string
+
+// fbVector is a flatbuffer vector of tables
+type fbVector []fbObject
+
+// fbStructs is a flatbuffer vector of structs made of 64-bit integers, like Arrow's FieldNode and Buffer
+type fbStructs [][]int64
+
+// pad appends zeros until the buffer length is a multiple of align
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// putOffset patches the offset at pos to point at target
+func (b *fbBuilder) putOffset(pos, target int) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+// scalar returns an inline table field holding value in size bytes
+func scalar(slot, size int, value uint64) fbField {
+	return fbField{slot: slot, size: size, scalar: value}
+}
+
+// offset returns a table field referring to child
+func offset(slot int, child fbObject) fbField {
+	return fbField{slot: slot, size: 4, child: child}
+}
+
+// The write method is part of the fbTable type. It lays the table out with each inline field aligned to its size
+// (flatbuffer readers verify alignment), writes the vtable in front of it and then the child objects after it.
+// write appends the table and returns its position
+func (t fbTable) write(b *fbBuilder) int {
+	slots := 0
+	for _, field := range t {
+		if field.slot >= slots {
+			slots = field.slot + 1
+		}
+	}
+	b.pad(2)
+	vtable := len(b.buf)
+	table := vtable + 4 + 2*slots
+	table += (4 - table%4) % 4
+
+	// Places the fields after the vtable offset, largest first so padding is rare.
+	positions := make([]int, len(t))
+	end := 4
+	for _, size := range []int{8, 4, 2, 1} {
+		for i, field := range t {
+			if field.size == size {
+				end += (size - (table+end)%size) % size
+				positions[i] = end
+				end += size
+			}
+		}
+	}
+	fieldOffsets := make([]uint16, slots)
+	for i, field := range t {
+		fieldOffsets[field.slot] = uint16(positions[i])
+	}
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*slots))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(end))
+	for _, fieldOffset := range fieldOffsets {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, fieldOffset)
+	}
+	b.pad(4)
+	b.buf = append(b.buf, make([]byte, end)...)
+	binary.LittleEndian.PutUint32(b.buf[table:], uint32(table-vtable))
+	for i, field := range t {
+		pos := table + positions[i]
+		switch {
+		case field.child != nil:
+		case field.size == 1:
+			b.buf[pos] = byte(field.scalar)
+		case field.size == 2:
+			binary.LittleEndian.PutUint16(b.buf[pos:], uint16(field.scalar))
+		case field.size == 4:
+			binary.LittleEndian.PutUint32(b.buf[pos:], uint32(field.scalar))
+		case field.size == 8:
+			binary.LittleEndian.PutUint64(b.buf[pos:], field.scalar)
+		}
+	}
+	for i, field := range t {
+		if field.child != nil {
+			b.putOffset(table+positions[i], field.child.write(b))
+		}
+	}
+	return table
+}
+
+// write appends the string, zero-terminated, and returns its position
+func (s fbString) write(b *fbBuilder) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(append(b.buf, s...), 0)
+	return pos
+}
+
+// write appends the vector and then its tables, and returns its position
+func (v fbVector) write(b *fbBuilder) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, item := range v {
+		b.putOffset(pos+4+4*i, item.write(b))
+	}
+	return pos
+}
+
+// write appends the vector with its elements 8-byte aligned, and returns its position
+func (s fbStructs) write(b *fbBuilder) int {
+	b.pad(4)
+	if len(b.buf)%8 == 0 {
+		b.buf = append(b.buf, 0, 0, 0, 0)
+	}
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	for _, item := range s {
+		for _, value := range item {
+			b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(value))
+		}
+	}
+	return pos
+}
+
+// fbRoot serializes a root table into a flatbuffer padded to 8 bytes
+func fbRoot(root fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	b.putOffset(0, root.write(b))
+	b.pad(8)
+	return b.buf
+}
+
+// fbReader looks up the fields of a flatbuffer table
+type fbReader struct {
+	buf []byte
+	pos int
+}
+
+// field returns the position of a table field, or 0 when the field is absent
+func (r fbReader) field(slot int) int {
+	vtable := r.pos - int(int32(binary.LittleEndian.Uint32(r.buf[r.pos:])))
+	entry := 4 + 2*slot
+	if entry >= int(binary.LittleEndian.Uint16(r.buf[vtable:])) {
+		return 0
+	}
+	if offset := int(binary.LittleEndian.Uint16(r.buf[vtable+entry:])); offset != 0 {
+		return r.pos + offset
+	}
+	return 0
+}
+
+// uint reads an unsigned scalar field of size bytes, or def when it is absent
+func (r fbReader) uint(slot, size int, def uint64) uint64 {
+	pos := r.field(slot)
+	switch {
+	case pos == 0:
+		return def
+	case size == 1:
+		return uint64(r.buf[pos])
+	case size == 2:
+		return uint64(binary.LittleEndian.Uint16(r.buf[pos:]))
+	case size == 4:
+		return uint64(binary.LittleEndian.Uint32(r.buf[pos:]))
+	}
+	return binary.LittleEndian.Uint64(r.buf[pos:])
+}
+
+// follow returns the position an offset at pos points to
+func (r fbReader) follow(pos int) int {
+	return pos + int(binary.LittleEndian.Uint32(r.buf[pos:]))
+}
+
+// table returns the table a field refers to; ok is false when the field is absent
+func (r fbReader) table(slot int) (fbReader, bool) {
+	pos := r.field(slot)
+	if pos == 0 {
+		return fbReader{}, false
+	}
+	return fbReader{buf: r.buf, pos: r.follow(pos)}, true
+}
+
+// string returns a string field, or "" when it is absent
+func (r fbReader) string(slot int) string {
+	pos := r.field(slot)
+	if pos == 0 {
+		return ""
+	}
+	start := r.follow(pos)
+	length := int(binary.LittleEndian.Uint32(r.buf[start:]))
+	return string(r.buf[start+4 : start+4+length])
+}
+
+// vector returns the position of the first element of a vector field and its length
+func (r fbReader) vector(slot int) (int, int) {
+	pos := r.field(slot)
+	if pos == 0 {
+		return 0, 0
+	}
+	start := r.follow(pos)
+	return start + 4, int(binary.LittleEndian.Uint32(r.buf[start:]))
+}
+
+// arrowMessage frames flatbuffer metadata and a body as an encapsulated IPC message
+func arrowMessage(w io.Writer, metadata, body []byte) error {
+	header := binary.LittleEndian.AppendUint32(nil, arrowContinuation)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(metadata)))
+	for _, part := range [][]byte{header, metadata, body} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// arrowMessageTable wraps a message header in the Message table
+func arrowMessageTable(headerType int, header fbTable, bodyLength int) fbTable {
+	return fbTable{
+		scalar(0, 2, arrowMetadataV5),
+		scalar(1, 1, uint64(headerType)),
+		offset(2, header),
+		scalar(3, 8, uint64(bodyLength)),
+	}
+}
+
+// arrowColumnType returns the Arrow type id and type table a column is written as
+func (ca *CSVAnalyzer) arrowColumnType(colIndex int) (int, fbTable) {
+	switch ca.columnType(colIndex) {
+	case TypeNumeric:
+		// FloatingPoint with DOUBLE precision.
+		return arrowFloatingPoint, fbTable{scalar(0, 2, 2)}
+	case TypeBoolean:
+		return arrowBool, fbTable{}
+	}
+	return arrowUtf8, fbTable{}
+}
+
+// arrowBody accumulates the buffers of a record batch, each padded to 8 bytes
+type arrowBody struct {
+	data    []byte
+	buffers fbStructs
+}
+
+// add appends one buffer
+func (a *arrowBody) add(buffer []byte) {
+	a.buffers = append(a.buffers, []int64{int64(len(a.data)), int64(len(buffer))})
+	a.data = append(a.data, buffer...)
+	for len(a.data)%8 != 0 {
+		a.data = append(a.data, 0)
+	}
+}
+
+// The WriteArrow method is part of the CSVAnalyzer struct. It writes the dataset in the Apache Arrow IPC streaming
+// format: a schema message, record batches of arrowBatchRows rows and the end-of-stream marker, readable by pyarrow
+// (pyarrow.ipc.open_stream), arrow-go (ipc.NewReader, which maps the column buffers without copying them), Polars,
+// DuckDB and the other Arrow-based tools. Numeric columns become float64, boolean columns bool and every other column
+// utf8, with the original text; empty cells, and cells that do not parse as their column's type, become nulls.
+// WriteArrow writes the dataset as an Arrow IPC stream
+func (ca *CSVAnalyzer) WriteArrow(w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	fields := make(fbVector, len(ca.dataset.Headers))
+	for colIndex, header := range ca.dataset.Headers {
+		typeID, typeTable := ca.arrowColumnType(colIndex)
+		fields[colIndex] = fbTable{
+			offset(0, fbString(header)),
+			scalar(1, 1, 1),
+			scalar(2, 1, uint64(typeID)),
+			offset(3, typeTable),
+			// Readers expect the children vector even for flat types.
+			offset(5, fbVector{}),
+		}
+	}
+	schema := fbTable{scalar(0, 2, 0), offset(1, fields)}
+	if err := arrowMessage(buffered, fbRoot(arrowMessageTable(arrowSchemaMessage, schema, 0)), nil); err != nil {
+		return fmt.Errorf("error writing Arrow stream: %v", err)
+	}
+
+	for start := 0; start < len(ca.dataset.Rows); start += arrowBatchRows {
+		end := start + arrowBatchRows
+		if end > len(ca.dataset.Rows) {
+			end = len(ca.dataset.Rows)
+		}
+		rows := ca.dataset.Rows[start:end]
+		body := &arrowBody{}
+		var nodes fbStructs
+		for colIndex := range ca.dataset.Headers {
+			typeID, _ := ca.arrowColumnType(colIndex)
+			validity := make([]byte, (len(rows)+7)/8)
+			values := make([]byte, 0, 8*len(rows))
+			if typeID == arrowBool {
+				values = make([]byte, (len(rows)+7)/8)
+			}
+			offsets := binary.LittleEndian.AppendUint32(nil, 0)
+			var text []byte
+			nulls := 0
+			for i, row := range rows {
+				cell := ""
+				if colIndex < len(row) {
+					cell = row[colIndex]
+				}
+				valid := strings.TrimSpace(cell) != ""
+				switch typeID {
+				case arrowFloatingPoint:
+					num, err := ca.parseNumber(strings.TrimSpace(cell))
+					valid = valid && err == nil
+					values = binary.LittleEndian.AppendUint64(values, math.Float64bits(num))
+				case arrowBool:
+					truth, ok := parseBoolean(cell)
+					valid = valid && ok
+					if truth {
+						values[i/8] |= 1 << (i % 8)
+					}
+				default:
+					if valid {
+						text = append(text, cell...)
+					}
+					if len(text) > math.MaxInt32 {
+						return fmt.Errorf("error writing Arrow stream: column %s holds more than 2 GB of text in one batch", ca.dataset.Headers[colIndex])
+					}
+					offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(text)))
+				}
+				if valid {
+					validity[i/8] |= 1 << (i % 8)
+				} else {
+					nulls++
+				}
+			}
+			nodes = append(nodes, []int64{int64(len(rows)), int64(nulls)})
+			body.add(validity)
+			if typeID == arrowUtf8 {
+				body.add(offsets)
+				body.add(text)
+			} else {
+				body.add(values)
+			}
+		}
+		batch := fbTable{scalar(0, 8, uint64(len(rows))), offset(1, nodes), offset(2, body.buffers)}
+		if err := arrowMessage(buffered, fbRoot(arrowMessageTable(arrowRecordBatchMessage, batch, len(body.data))), body.data); err != nil {
+			return fmt.Errorf("error writing Arrow stream: %v", err)
+		}
+	}
+	// Ends the stream with a continuation marker and an empty message.
+	if err := arrowMessage(buffered, nil, nil); err != nil {
+		return fmt.Errorf("error writing Arrow stream: %v", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("error writing Arrow stream: %v", err)
+	}
+	return nil
+}
+
+// arrowColumn describes how the values of one column of an Arrow stream are stored
+type arrowColumn struct {
+	name      string
+	typeID    int
+	bitWidth  int
+	signed    bool
+	precision int // FloatingPoint: 1 single, 2 double
+	unit      int // Date: 0 days, 1 milliseconds; Timestamp: 0 seconds to 3 nanoseconds
+}
+
+// arrowSchema reads the columns of a schema message
+func arrowSchema(schema fbReader) ([]arrowColumn, error) {
+	start, count := schema.vector(1)
+	columns := make([]arrowColumn, count)
+	for i := range columns {
+		field := fbReader{buf: schema.buf, pos: schema.follow(start + 4*i)}
+		column := arrowColumn{name: field.string(0), typeID: int(field.uint(2, 1, 0))}
+		if _, ok := field.table(4); ok {
+			return nil, fmt.Errorf("column %s is dictionary-encoded, which is not supported", column.name)
+		}
+		typeTable, _ := field.table(3)
+		switch column.typeID {
+		case arrowInt:
+			column.bitWidth, column.signed = int(int32(typeTable.uint(0, 4, 0))), typeTable.uint(1, 1, 0) != 0
+		case arrowFloatingPoint:
+			column.precision = int(typeTable.uint(0, 2, 0))
+			if column.precision == 0 {
+				return nil, fmt.Errorf("column %s holds half-precision floats, which are not supported", column.name)
+			}
+		case arrowDate:
+			column.unit = int(typeTable.uint(0, 2, 1))
+		case arrowTimestamp:
+			column.unit = int(typeTable.uint(0, 2, 0))
+		case arrowUtf8, arrowLargeUtf8, arrowBool:
+		default:
+			return nil, fmt.Errorf("column %s has Arrow type %d, which is not supported (only integers, floats, bools, dates, timestamps and strings)", column.name, column.typeID)
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+// arrowValue renders value i of a column from its data buffers as the text a CSV cell would hold
+func arrowValue(column arrowColumn, buffers [][]byte, i int) string {
+	values := buffers[1]
+	switch column.typeID {
+	case arrowInt:
+		size := column.bitWidth / 8
+		raw := make([]byte, 8)
+		copy(raw, values[i*size:(i+1)*size])
+		n := binary.LittleEndian.Uint64(raw)
+		if !column.signed {
+			return strconv.FormatUint(n, 10)
+		}
+		// Sign-extends values narrower than 64 bits.
+		shift := 64 - column.bitWidth
+		return strconv.FormatInt(int64(n<<shift)>>shift, 10)
+	case arrowFloatingPoint:
+		if column.precision == 1 {
+			return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(values[4*i:]))), 'g', -1, 32)
+		}
+		return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(values[8*i:])), 'g', -1, 64)
+	case arrowBool:
+		return strconv.FormatBool(values[i/8]&(1<<(i%8)) != 0)
+	case arrowDate:
+		if column.unit == 0 {
+			return time.Unix(86400*int64(int32(binary.LittleEndian.Uint32(values[4*i:]))), 0).UTC().Format("2006-01-02")
+		}
+		return time.UnixMilli(int64(binary.LittleEndian.Uint64(values[8*i:]))).UTC().Format("2006-01-02")
+	case arrowTimestamp:
+		n := int64(binary.LittleEndian.Uint64(values[8*i:]))
+		scale := []int64{1e9, 1e6, 1e3, 1}[column.unit]
+		return time.Unix(0, n*scale).UTC().Format(time.RFC3339Nano)
+	case arrowLargeUtf8:
+		start, end := binary.LittleEndian.Uint64(values[8*i:]), binary.LittleEndian.Uint64(values[8*i+8:])
+		return string(buffers[2][start:end])
+	}
+	start, end := binary.LittleEndian.Uint32(values[4*i:]), binary.LittleEndian.Uint32(values[4*i+4:])
+	return string(buffers[2][start:end])
+}
+
+// arrowBatch turns a record batch message into records
+func arrowBatch(columns []arrowColumn, batch fbReader, body []byte) ([][]string, error) {
+	if _, ok := batch.table(3); ok {
+		return nil, fmt.Errorf("compressed record batches are not supported")
+	}
+	length := int(batch.uint(0, 8, 0))
+	nodeStart, nodeCount := batch.vector(1)
+	bufferStart, bufferCount := batch.vector(2)
+	if nodeCount != len(columns) {
+		return nil, fmt.Errorf("record batch has %d columns, the schema %d", nodeCount, len(columns))
+	}
+	records := make([][]string, length)
+	for i := range records {
+		records[i] = make([]string, len(columns))
+	}
+	next := 0
+	for c, column := range columns {
+		nullCount := int64(binary.LittleEndian.Uint64(batch.buf[nodeStart+16*c+8:]))
+		// Strings have validity, offsets and data buffers; the other types validity and values.
+		need := 2
+		if column.typeID == arrowUtf8 || column.typeID == arrowLargeUtf8 {
+			need = 3
+		}
+		if next+need > bufferCount {
+			return nil, fmt.Errorf("record batch is missing buffers of column %s", column.name)
+		}
+		buffers := make([][]byte, need)
+		for b := range buffers {
+			pos := bufferStart + 16*(next+b)
+			start := binary.LittleEndian.Uint64(batch.buf[pos:])
+			size := binary.LittleEndian.Uint64(batch.buf[pos+8:])
+			buffers[b] = body[start : start+size]
+		}
+		next += need
+		for i := range records {
+			// An empty validity buffer means no nulls.
+			if nullCount > 0 && len(buffers[0]) > 0 && buffers[0][i/8]&(1<<(i%8)) == 0 {
+				continue
+			}
+			records[i][c] = arrowValue(column, buffers, i)
+		}
+	}
+	return records, nil
+}
+
+// The readArrowRecords function reads an Arrow IPC stream, or a file in the Arrow file format (whose record batches
+// are stored as a stream after the magic bytes), and passes the column names and then every row to visit, rendered
+// as text the way a CSV export of the table would be, so type detection works as for any other input. Nulls become
+// empty cells. Dictionary-encoded and nested columns and compressed batches are not supported and fail the load.
+// readArrowRecords passes the header and then every row of an Arrow stream to visit
+func readArrowRecords(input io.Reader, filename string, visit func(record []string) error) (err error) {
+	// Bounds are not checked field by field; malformed metadata panics and is reported as an error here.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("error reading Arrow file %s: malformed data (%v)", filename, recovered)
+		}
+	}()
+	reader := bufio.NewReader(input)
+	if magic, _ := reader.Peek(len(arrowFileMagic)); string(magic) == arrowFileMagic {
+		reader.Discard(8)
+	}
+	var columns []arrowColumn
+	word := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, word); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading Arrow file %s: %v", filename, err)
+		}
+		length := binary.LittleEndian.Uint32(word)
+		// Streams written before Arrow 0.15 have no continuation marker.
+		if length == arrowContinuation {
+			if _, err := io.ReadFull(reader, word); err != nil {
+				return fmt.Errorf("error reading Arrow file %s: %v", filename, err)
+			}
+			length = binary.LittleEndian.Uint32(word)
+		}
+		if length == 0 {
+			return nil
+		}
+		metadata := make([]byte, length)
+		if _, err := io.ReadFull(reader, metadata); err != nil {
+			return fmt.Errorf("error reading Arrow file %s: %v", filename, err)
+		}
+		message := fbReader{buf: metadata, pos: int(binary.LittleEndian.Uint32(metadata))}
+		body := make([]byte, message.uint(3, 8, 0))
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return fmt.Errorf("error reading Arrow file %s: %v", filename, err)
+		}
+		header, _ := message.table(2)
+		switch message.uint(1, 1, 0) {
+		case arrowSchemaMessage:
+			if columns, err = arrowSchema(header); err != nil {
+				return fmt.Errorf("error reading Arrow file %s: %v", filename, err)
+			}
+			names := make([]string, len(columns))
+			for i, column := range columns {
+				names[i] = column.name
+			}
+			if err := visit(names); err != nil {
+				return err
+			}
+		case arrowRecordBatchMessage:
+			if columns == nil {
+				return fmt.Errorf("error reading Arrow file %s: record batch before the schema", filename)
+			}
+			records, err := arrowBatch(columns, header, body)
+			if err != nil {
+				return fmt.Errorf("error reading Arrow file %s: %v", filename, err)
+			}
+			for _, record := range records {
+				if err := visit(record); err != nil {
+					return err
+				}
+			}
+		case arrowDictionaryMessage:
+			return fmt.Errorf("error reading Arrow file %s: dictionary batches are not supported", filename)
+		default:
+			return fmt.Errorf("error reading Arrow file %s: unexpected message type %d", filename, message.uint(1, 1, 0))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// arrowRoundTrip writes the dataset of analyzer as an Arrow stream and reads the stream back
+func arrowRoundTrip(t *testing.T, analyzer *CSVAnalyzer) [][]string {
+	t.Helper()
+	var stream bytes.Buffer
+	if err := analyzer.WriteArrow(&stream); err != nil {
+		t.Fatal(err)
+	}
+	var records [][]string
+	if err := readArrowRecords(&stream, "test.arrow", func(record []string) error {
+		records = append(records, append([]string(nil), record...))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestArrowRoundTrip(t *testing.T) {
+	analyzer := loadTestCSV(t, "price,active,name\n1.5,true,Widget\n,false,\"Gadget, large\"\n-3,,Ünïcode ✓\n2e3,yes,\n")
+	want := [][]string{
+		{"price", "active", "name"},
+		// Numbers come back in their shortest form, booleans as true/false and empty cells as nulls.
+		{"1.5", "true", "Widget"},
+		{"", "false", "Gadget, large"},
+		{"-3", "", "Ünïcode ✓"},
+		{"2000", "true", ""},
+	}
+	if got := arrowRoundTrip(t, analyzer); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestArrowRoundTripUnparsableCellsBecomeNulls(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("n,label\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "%d,x\n", i)
+	}
+	b.WriteString("abc,y\n")
+	records := arrowRoundTrip(t, loadTestCSV(t, b.String(), "--type-threshold", "0.9"))
+	if last := records[len(records)-1]; !reflect.DeepEqual(last, []string{"", "y"}) {
+		t.Errorf("unparsable cells read back as %q, want a null number", last)
+	}
+}
+
+func TestArrowRoundTripSeveralBatches(t *testing.T) {
+	rows := 2*arrowBatchRows + 3
+	var b strings.Builder
+	b.WriteString("id,label\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "%d,row %d\n", i, i)
+	}
+	records := arrowRoundTrip(t, loadTestCSV(t, b.String()))
+	if len(records) != rows+1 {
+		t.Fatalf("read %d records, want %d", len(records), rows+1)
+	}
+	for _, i := range []int{0, arrowBatchRows - 1, arrowBatchRows, rows - 1} {
+		if want := []string{fmt.Sprint(i), fmt.Sprint("row ", i)}; !reflect.DeepEqual(records[i+1], want) {
+			t.Errorf("row %d = %q, want %q", i, records[i+1], want)
+		}
+	}
+}
+
+func TestArrowFileLoadsWithTheSameTypes(t *testing.T) {
+	original := loadTestCSV(t, "price,active,name,day\n1.5,true,Widget,2024-01-05\n2,false,Gadget,2024-02-01\n")
+	path := filepath.Join(t.TempDir(), "data.arrow")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := original.WriteArrow(file); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	loaded := NewCSVAnalyzerWithOptions(testOptions(t))
+	if err := loaded.LoadCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	for colIndex, header := range original.dataset.Headers {
+		if got, want := loaded.columnType(colIndex), original.columnType(colIndex); got != want {
+			t.Errorf("%s loaded as %s, written as %s", header, got, want)
+		}
+	}
+}
+
+func TestReadArrowRecordsRejectsTruncatedStreams(t *testing.T) {
+	var stream bytes.Buffer
+	if err := loadTestCSV(t, "a,b\n1,x\n2,y\n").WriteArrow(&stream); err != nil {
+		t.Fatal(err)
+	}
+	truncated := stream.Bytes()[:stream.Len()-20]
+	err := readArrowRecords(bytes.NewReader(truncated), "test.arrow", func([]string) error { return nil })
+	if err == nil {
+		t.Error("truncated stream read without an error")
+	}
+}
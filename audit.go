@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Limits that keep the parsing audit readable on badly broken columns
+const (
+	maxAuditValues = 20 // distinct failing values listed per column
+	maxAuditRows   = 10 // row numbers listed per failing value
+)
+
+// ParseFailure is one distinct value of a numeric column that could not be parsed as a number
+type ParseFailure struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+	Rows  []int  `json:"rows"` // 1-based data row numbers (header excluded), at most maxAuditRows
+}
+
+// ParseAudit lists the values of one numeric column that were left out of its statistics
+type ParseAudit struct {
+	Column         string         `json:"column"`
+	FailedCount    int            `json:"failed_count"`
+	DistinctValues int            `json:"distinct_values"`
+	Failures       []ParseFailure `json:"failures"` // most frequent first, at most maxAuditValues
+}
+
+// The AuditNumericParsing method is part of the CSVAnalyzer struct. Numeric statistics silently skip cells that do
+// not parse as numbers (a "N/A", a stray unit, a typo), which can hide real data-quality problems. This audit walks
+// every numeric column and lists each distinct failing value with how often it occurs and the rows it occurs on, so
+// the problem can be traced back to the source. Empty cells are missing values, not parse failures, and are not listed.
+// Row numbers count data rows of the combined dataset; with sampling enabled they refer to the sample.
+// AuditNumericParsing returns the parse failures of every numeric column that has any, in header order
+func (ca *CSVAnalyzer) AuditNumericParsing() []ParseAudit {
+	var audits []ParseAudit
+	for colIndex, header := range ca.dataset.Headers {
+		if !ca.dataset.NumericCols[colIndex] {
+			continue
+		}
+		// Groups the failing cells by value, remembering the first rows of each.
+		failures := make(map[string]*ParseFailure)
+		audit := ParseAudit{Column: header}
+		for rowIndex, row := range ca.dataset.Rows {
+			if colIndex >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[colIndex])
+			if value == "" {
+				continue
+			}
+			if _, err := ca.parseNumber(value); err == nil {
+				continue
+			}
+			audit.FailedCount++
+			failure, ok := failures[value]
+			if !ok {
+				failure = &ParseFailure{Value: value}
+				failures[value] = failure
+			}
+			failure.Count++
+			if len(failure.Rows) < maxAuditRows {
+				failure.Rows = append(failure.Rows, rowIndex+1)
+			}
+		}
+		if audit.FailedCount == 0 {
+			continue
+		}
+
+		// Lists the most frequent values first, breaking ties by value so the output is stable.
+		audit.DistinctValues = len(failures)
+		for _, failure := range failures {
+			audit.Failures = append(audit.Failures, *failure)
+		}
+		sort.Slice(audit.Failures, func(i, j int) bool {
+			if audit.Failures[i].Count != audit.Failures[j].Count {
+				return audit.Failures[i].Count > audit.Failures[j].Count
+			}
+			return audit.Failures[i].Value < audit.Failures[j].Value
+		})
+		if len(audit.Failures) > maxAuditValues {
+			audit.Failures = audit.Failures[:maxAuditValues]
+		}
+		audits = append(audits, audit)
+	}
+	return audits
+}
+
+// printParseAudit shows the numeric parsing audit in the text report
+func printParseAudit(audits []ParseAudit) {
+	fmt.Println("\n\nNumeric Parsing Audit:")
+	fmt.Println("----------------------")
+	if len(audits) == 0 {
+		fmt.Println("  Every non-empty value of every numeric column parsed as a number.")
+		return
+	}
+	for _, audit := range audits {
+		fmt.Printf("\n%s: %d failed to parse (%d distinct)\n", audit.Column, audit.FailedCount, audit.DistinctValues)
+		for _, failure := range audit.Failures {
+			// Shows the row numbers, marking when there are more than were kept.
+			rows := make([]string, len(failure.Rows))
+			for i, row := range failure.Rows {
+				rows[i] = fmt.Sprint(row)
+			}
+			more := ""
+			if failure.Count > len(failure.Rows) {
+				more = ", ..."
+			}
+			fmt.Printf("  %q x%d (row %s%s)\n", failure.Value, failure.Count, strings.Join(rows, ", "), more)
+		}
+		if audit.DistinctValues > len(audit.Failures) {
+			fmt.Printf("  ... and %d more distinct values\n", audit.DistinctValues-len(audit.Failures))
+		}
+	}
+}
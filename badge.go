@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"strings"
+)
+
+// Badge colours, matching the usual shields.io palette
+const (
+	badgeGreen  = "#4c1"
+	badgeYellow = "#dfb317"
+	badgeRed    = "#e05d44"
+	badgeGrey   = "#555"
+)
+
+// badgeWarnScore is the quality score below which a passing badge is shown in yellow
+const badgeWarnScore = 80.0
+
+// badgeTemplate is a flat shields.io style badge with a label on the left and the message on the right
+const badgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[2]s: %[3]s">
+  <title>%[2]s: %[3]s</title>
+  <linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+  <clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+  <g clip-path="url(#r)">
+    <rect width="%[4]d" height="20" fill="%[6]s"/>
+    <rect x="%[4]d" width="%[5]d" height="20" fill="%[7]s"/>
+    <rect width="%[1]d" height="20" fill="url(#s)"/>
+  </g>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[2]s</text>
+    <text x="%[8]d" y="14">%[2]s</text>
+    <text x="%[9]d" y="15" fill="#010101" fill-opacity=".3">%[3]s</text>
+    <text x="%[9]d" y="14">%[3]s</text>
+  </g>
+</svg>
+`
+
+// QualityScore rates the dataset from 0 to 100 with the composite score of Quality
+func (ca *CSVAnalyzer) QualityScore() float64 {
+	if quality := ca.Quality(); quality != nil {
+		return quality.Score
+	}
+	return 0
+}
+
+// The WriteBadge method is part of the CSVAnalyzer struct. It renders a small shields-style SVG summarizing the run -
+// row count, quality score and whether the alert rules passed - that can be embedded in dashboards or the README of
+// a data-drop repository. The badge is red when a critical rule failed, yellow when other rules failed or the quality
+// score is low, and green otherwise.
+// WriteBadge writes an SVG summary badge to path
+func (ca *CSVAnalyzer) WriteBadge(path, label string) error {
+	// Works out the pass/fail status from the unsuppressed alert failures.
+	alerts := ca.EvaluateAlerts()
+	active := activeFailures(alerts)
+	status := "pass"
+	if hasCriticalFailure(alerts) {
+		status = "fail"
+	}
+	score := ca.QualityScore()
+	message := fmt.Sprintf("%d rows | quality %.0f%% | %s", len(ca.dataset.Rows), score, status)
+
+	// Picks the colour for the message half of the badge.
+	color := badgeGreen
+	switch {
+	case status == "fail":
+		color = badgeRed
+	case len(active) > 0 || score < badgeWarnScore:
+		color = badgeYellow
+	}
+
+	// Sizes each half from its text, roughly as shields.io does for 11px Verdana.
+	labelWidth := badgeTextWidth(label)
+	messageWidth := badgeTextWidth(message)
+	svg := fmt.Sprintf(badgeTemplate,
+		labelWidth+messageWidth, html.EscapeString(label), html.EscapeString(message),
+		labelWidth, messageWidth, badgeGrey, color,
+		labelWidth/2, labelWidth+messageWidth/2)
+	// Writes the badge to disk.
+	if err := os.WriteFile(path, []byte(svg), 0644); err != nil {
+		return fmt.Errorf("error writing badge: %v", err)
+	}
+	return nil
+}
+
+// badgeTextWidth estimates the pixel width of badge text, including padding
+func badgeTextWidth(text string) int {
+	// Narrow characters take noticeably less room than the average glyph.
+	width := 0.0
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune("il.,:;|!' ", r):
+			width += 3.5
+		case r >= 'A' && r <= 'Z', r == 'm', r == 'w', r == '%':
+			width += 8.5
+		default:
+			width += 7
+		}
+	}
+	return int(width) + 12
+}
@@ -0,0 +1,107 @@
+package main
+
+import "strings"
+
+// booleanTokens maps the accepted spellings of boolean values (lower-cased) to their truth value
+var booleanTokens = map[string]bool{
+	"true": true, "false": false,
+	"yes": true, "no": false,
+	"y": true, "n": false,
+	"t": true, "f": false,
+	"1": true, "0": false,
+}
+
+// BooleanColumnStats holds the true/false breakdown of a boolean column
+type BooleanColumnStats struct {
+	Name       string  `json:"name"`
+	TrueCount  int     `json:"true_count"`
+	FalseCount int     `json:"false_count"`
+	EmptyCount int     `json:"empty_count"`
+	TrueRatio  float64 `json:"true_ratio"` // share of non-empty values that are true
+}
+
+// parseBoolean converts a boolean-ish cell value, reporting false in ok when it is not one
+func parseBoolean(value string) (result bool, ok bool) {
+	result, ok = booleanTokens[strings.ToLower(strings.TrimSpace(value))]
+	return result, ok
+}
+
+// The isBooleanColumn method is part of the CSVAnalyzer struct. It decides whether a column holds boolean-ish values
+// (true/false, yes/no, Y/N, T/F or 0/1) by checking every populated cell, not just the first rows, because a single
+// "2" or "maybe" further down means the column is something else. A column made only of digits must contain both 0
+// and 1, so that a column of ones (a quantity, say) stays numeric.
+// isBooleanColumn reports whether every non-empty value in the column is a boolean token
+func (ca *CSVAnalyzer) isBooleanColumn(colIndex int) bool {
+	// Tracks whether any value was seen, and which numeric tokens appeared.
+	seen, sawZero, sawOne, sawWord := false, false, false, false
+	// Iterates through every row of the dataset.
+	for _, row := range ca.dataset.Rows {
+		// Skips rows too short to contain this column.
+		if colIndex >= len(row) {
+			continue
+		}
+		// Trims the value and ignores empty cells.
+		value := strings.TrimSpace(row[colIndex])
+		if value == "" {
+			continue
+		}
+		// Any non-boolean value rules the column out immediately.
+		if _, ok := parseBoolean(value); !ok {
+			return false
+		}
+		// Remembers which kind of token was used.
+		seen = true
+		switch value {
+		case "0":
+			sawZero = true
+		case "1":
+			sawOne = true
+		default:
+			sawWord = true
+		}
+	}
+	// Word tokens are unambiguous; pure 0/1 columns need both values to count as boolean.
+	return seen && (sawWord || (sawZero && sawOne))
+}
+
+// CalculateBooleanStats computes the true/false breakdown of every boolean column, in header order
+func (ca *CSVAnalyzer) CalculateBooleanStats() []BooleanColumnStats {
+	var stats []BooleanColumnStats
+	for colIndex := range ca.dataset.Headers {
+		if colStats, ok := ca.calculateBooleanColumnStats(colIndex); ok {
+			stats = append(stats, ca.publishedBooleanStats(colStats))
+		}
+	}
+	return stats
+}
+
+// calculateBooleanColumnStats counts the true, false and empty cells of a single boolean column.
+// The boolean result is false when the column is not boolean.
+func (ca *CSVAnalyzer) calculateBooleanColumnStats(colIndex int) (BooleanColumnStats, bool) {
+	// Only columns detected as boolean have a breakdown.
+	if ca.columnType(colIndex) != TypeBoolean {
+		return BooleanColumnStats{}, false
+	}
+	colStats := BooleanColumnStats{Name: ca.dataset.Headers[colIndex]}
+	// Classifies every cell; short rows count as empty.
+	for _, row := range ca.dataset.Rows {
+		value := ""
+		if colIndex < len(row) {
+			value = row[colIndex]
+		}
+		truth, ok := parseBoolean(value)
+		switch {
+		case !ok:
+			colStats.EmptyCount++
+		case truth:
+			colStats.TrueCount++
+		default:
+			colStats.FalseCount++
+		}
+	}
+	// The ratio is taken over populated cells so missing values do not dilute it.
+	if total := colStats.TrueCount + colStats.FalseCount; total > 0 {
+		colStats.TrueRatio = float64(colStats.TrueCount) / float64(total)
+	}
+	return colStats, true
+}
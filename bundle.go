@@ -0,0 +1,130 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaColumn describes one column in the schema file of a run bundle
+type SchemaColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Nullable    bool   `json:"nullable"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+}
+
+// Schema lists the columns of the dataset in header order
+func (ca *CSVAnalyzer) Schema() []SchemaColumn {
+	var schema []SchemaColumn
+	for colIndex, header := range ca.dataset.Headers {
+		annotation := ca.annotation(header)
+		schema = append(schema, SchemaColumn{
+			Name:        header,
+			Type:        ca.columnTypeName(colIndex),
+			Nullable:    ca.countNonEmptyValues(colIndex) < len(ca.dataset.Rows),
+			Description: annotation.Description,
+			Unit:        annotation.Unit,
+		})
+	}
+	return schema
+}
+
+// The QuarantineRows method is part of the CSVAnalyzer struct. It collects the rows holding a value that does not fit
+// its column's type - text in a numeric column, or an unrecognizable date in a date column - which are the rows left
+// out of that column's statistics. Each row is returned as its 1-based data row number, the reasons it was
+// quarantined and the original cells, ready to be reviewed and fixed at the source.
+// QuarantineRows returns the rows whose values do not match their column types, as CSV records with a header
+func (ca *CSVAnalyzer) QuarantineRows() [][]string {
+	records := [][]string{append([]string{"_row", "_reason"}, ca.dataset.Headers...)}
+	for rowIndex, row := range ca.dataset.Rows {
+		// Checks every typed cell of the row.
+		var reasons []string
+		for colIndex, header := range ca.dataset.Headers {
+			if colIndex >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[colIndex])
+			if value == "" {
+				continue
+			}
+			switch ca.columnType(colIndex) {
+			case TypeNumeric:
+				if _, err := ca.parseNumber(value); err != nil {
+					reasons = append(reasons, header+": not a number")
+				}
+			case TypeDate:
+				if _, ok := ca.parseDate(value); !ok {
+					reasons = append(reasons, header+": not a date")
+				}
+			}
+		}
+		if len(reasons) > 0 {
+			record := append([]string{strconv.Itoa(rowIndex + 1), strings.Join(reasons, "; ")}, row...)
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// The WriteBundle method is part of the CSVAnalyzer struct. It packs everything a run produces into one .tar.gz
+// artifact, so a run can be archived or attached to a ticket as a single file: the full JSON report, the schema of
+// the dataset, and a quarantine CSV with the rows whose values did not match their column types.
+// WriteBundle writes the report, schema and quarantine files of this run into a gzip-compressed tar archive
+func (ca *CSVAnalyzer) WriteBundle(path string) error {
+	// Renders each member in memory; reports are small and the quarantine only holds rejected rows.
+	report, err := json.MarshalIndent(ca.BuildReport(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding bundle report: %v", err)
+	}
+	schema, err := json.MarshalIndent(ca.Schema(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding bundle schema: %v", err)
+	}
+	var quarantine bytes.Buffer
+	writer := csv.NewWriter(&quarantine)
+	writer.WriteAll(ca.QuarantineRows())
+
+	// Writes the members into the compressed archive.
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating bundle: %v", err)
+	}
+	defer file.Close()
+	compressed := gzip.NewWriter(file)
+	archive := tar.NewWriter(compressed)
+	modTime := time.Now()
+	members := []struct {
+		name string
+		data []byte
+	}{
+		{"report.json", append(report, '\n')},
+		{"schema.json", append(schema, '\n')},
+		{"quarantine.csv", quarantine.Bytes()},
+	}
+	for _, member := range members {
+		header := &tar.Header{Name: member.name, Mode: 0644, Size: int64(len(member.data)), ModTime: modTime}
+		if err := archive.WriteHeader(header); err != nil {
+			return fmt.Errorf("error writing bundle: %v", err)
+		}
+		if _, err := archive.Write(member.data); err != nil {
+			return fmt.Errorf("error writing bundle: %v", err)
+		}
+	}
+	// Both layers must be closed for the archive to be complete.
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error writing bundle: %v", err)
+	}
+	if err := compressed.Close(); err != nil {
+		return fmt.Errorf("error writing bundle: %v", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// reportCacheMaxAge is how long a cached report is kept after it was last computed
+const reportCacheMaxAge = 30 * 24 * time.Hour
+
+// CachedReport is one entry of the report cache: what a run printed on stdout and stderr and the exit status it
+// ended with
+type CachedReport struct {
+	Created  time.Time `json:"created"`
+	Input    string    `json:"input"`
+	ExitCode int       `json:"exit_code"`
+	Output   []byte    `json:"output"`
+	Errors   []byte    `json:"errors,omitempty"` // the data warnings and other messages on stderr
+}
+
+// fileDigest is a remembered content hash, valid while the file keeps its size and modification time
+type fileDigest struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// The reportCache type stores the reports of earlier runs in the user cache directory (~/.cache/csv-analyzer on
+// Linux, or $XDG_CACHE_HOME/csv-analyzer), one JSON file per key. The content hashes of the input files are
+// remembered by path, size and modification time in digests.json, so a repeated run on an unchanged file skips
+// hashing it as well as profiling it.
+type reportCache struct {
+	dir     string
+	digests map[string]fileDigest
+}
+
+// openReportCache opens the cache directory, creating it when needed
+func openReportCache() (*reportCache, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("error locating cache directory: %v", err)
+	}
+	cache := &reportCache{dir: filepath.Join(base, "csv-analyzer"), digests: make(map[string]fileDigest)}
+	if err := os.MkdirAll(cache.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %v", err)
+	}
+	// A missing or corrupt digest file only means the inputs are hashed again.
+	if data, err := os.ReadFile(filepath.Join(cache.dir, "digests.json")); err == nil {
+		json.Unmarshal(data, &cache.digests)
+	}
+	return cache, nil
+}
+
+// digest returns the SHA-256 of a file's content, hashing it only when it changed since it was last hashed
+func (c *reportCache) digest(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %v", err)
+	}
+	if known, ok := c.digests[abs]; ok && known.Size == info.Size() && known.ModTime.Equal(info.ModTime()) {
+		return known.SHA256, nil
+	}
+	file, err := os.Open(abs)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("error hashing file: %v", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	c.digests[abs] = fileDigest{Size: info.Size(), ModTime: info.ModTime(), SHA256: sum}
+	return sum, nil
+}
+
+// uncacheableOptions lists the options a cached report cannot stand in for: files written and notifications sent
+// besides the report, timings and figures drawn from a time-based seed
+func uncacheableOptions(opts *Options) []string {
+	checks := []struct {
+		flag string
+		set  bool
+	}{
+		{"--check", opts.Check},
+		{"--cards-dir", opts.CardsDir != ""},
+		{"--alert-webhook", opts.AlertWebhook != ""},
+		{"--explain", opts.Explain},
+		{"--stats-internal", opts.StatsInternal},
+		{"--fill-output", opts.FillOutput != ""},
+		{"--outlier-output", opts.OutlierOutput != ""},
+		{"--correlation-png", opts.CorrelationPNG != ""},
+		{"--charts-dir", opts.ChartsDir != ""},
+		{"--split-dir", opts.SplitDir != ""},
+		{"--bundle", opts.BundlePath != ""},
+		{"--ge-suite", opts.GESuitePath != ""},
+		{"--badge", opts.BadgePath != ""},
+		{"--openlineage-url", opts.Lineage.URL != ""},
+		{"--sample without --sample-seed", (opts.Sampling.Size > 0 || opts.Sampling.Fraction > 0) && opts.Sampling.Seed == 0},
+		{"--bootstrap without --bootstrap-seed", opts.Bootstrap > 0 && opts.BootstrapSeed == 0},
+	}
+	var found []string
+	for _, check := range checks {
+		if check.set {
+			found = append(found, check.flag)
+		}
+	}
+	return found
+}
+
+// The key method is part of the reportCache struct. It derives the cache key of a run from everything its report
+// depends on: the binary (its build information, size and modification time, so a rebuilt analyzer never reuses the
+// reports of the old one), the input as named and the content hash of every file it expands to, the value of every
+// analyzer flag but --no-cache and --progress, the content of the configuration, dictionary, rules, schema and template files,
+// whether the report is coloured, and the date when a result depends on it (today() in --derive, suppressions with
+// an expiry in the configuration).
+// key returns the cache key of a run
+func (c *reportCache) key(input string, fs *flag.FlagSet, opts *Options, config Config) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "analyzer\x00%s\x00", currentBuildInfo())
+	if exe, err := os.Executable(); err == nil {
+		if info, err := os.Stat(exe); err == nil {
+			fmt.Fprintf(hash, "%d\x00%d\x00", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	paths, err := expandInputPattern(input)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(hash, "input\x00%s\x00", input)
+	for _, path := range paths {
+		sum, err := c.digest(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x00", path, sum)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name != "no-cache" && f.Name != "progress" {
+			fmt.Fprintf(hash, "flag\x00%s\x00%s\x00", f.Name, f.Value.String())
+		}
+	})
+	for _, path := range []string{opts.ConfigPath, opts.DictionaryPath, opts.RulesPath, opts.ExpectSchema, opts.TemplatePath} {
+		if path == "" {
+			continue
+		}
+		sum, err := c.digest(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "file\x00%s\x00%s\x00", path, sum)
+	}
+	fmt.Fprintf(hash, "color\x00%t\x00", useColor())
+	datedDerive := false
+	for _, derived := range opts.Derive {
+		for _, arg := range derived.args {
+			datedDerive = datedDerive || arg.today
+		}
+	}
+	if datedDerive || len(config.Suppressions) > 0 {
+		fmt.Fprintf(hash, "date\x00%s\x00", time.Now().Format("2006-01-02"))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// entryPath is the file a cache entry is stored in
+func (c *reportCache) entryPath(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// load returns the cached report of a key, if there is a readable one
+func (c *reportCache) load(key string) (*CachedReport, bool) {
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry CachedReport
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// store saves a report under its key, along with the file digests, and removes entries past reportCacheMaxAge
+func (c *reportCache) store(key string, entry CachedReport) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding cache entry: %v", err)
+	}
+	// Writes through a temporary file, so a concurrent run never reads half an entry.
+	temp := c.entryPath(key) + ".tmp"
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing cache entry: %v", err)
+	}
+	if err := os.Rename(temp, c.entryPath(key)); err != nil {
+		return fmt.Errorf("error writing cache entry: %v", err)
+	}
+	c.prune()
+	digests, err := json.Marshal(c.digests)
+	if err != nil {
+		return fmt.Errorf("error encoding file digests: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, "digests.json"), digests, 0o644); err != nil {
+		return fmt.Errorf("error writing file digests: %v", err)
+	}
+	return nil
+}
+
+// prune removes the entries older than reportCacheMaxAge and the digests of files that no longer exist
+func (c *reportCache) prune() {
+	entries, _ := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	for _, path := range entries {
+		if filepath.Base(path) == "digests.json" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > reportCacheMaxAge {
+			os.Remove(path)
+		}
+	}
+	for path := range c.digests {
+		if _, err := os.Stat(path); err != nil {
+			delete(c.digests, path)
+		}
+	}
+}
+
+// outputCapture copies everything written to stdout or stderr into a buffer while still passing it through
+type outputCapture struct {
+	stream   **os.File
+	original *os.File
+	writer   *os.File
+	buffer   bytes.Buffer
+	done     chan struct{}
+}
+
+// captureOutput redirects a stream, &os.Stdout or &os.Stderr, through a pipe until stop is called
+func captureOutput(stream **os.File) (*outputCapture, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("error capturing the report: %v", err)
+	}
+	capture := &outputCapture{stream: stream, original: *stream, writer: writer, done: make(chan struct{})}
+	go func() {
+		io.Copy(io.MultiWriter(capture.original, &capture.buffer), reader)
+		reader.Close()
+		close(capture.done)
+	}()
+	*stream = writer
+	return capture, nil
+}
+
+// stop restores the stream and returns everything that was written to it
+func (c *outputCapture) stop() []byte {
+	*c.stream = c.original
+	c.writer.Close()
+	<-c.done
+	return c.buffer.Bytes()
+}
+
+// describeCacheHit is the status line of a report served from the cache
+func describeCacheHit(entry *CachedReport) string {
+	age := time.Since(entry.Created).Round(time.Second)
+	return fmt.Sprintf("Report served from cache (computed %s ago for the same content and options; --no-cache recomputes it)", age)
+}
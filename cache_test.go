@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCaptureOutputStderr(t *testing.T) {
+	original := os.Stderr
+	capture, err := captureOutput(&os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(os.Stderr, "Warning: coercion (column v)\n")
+	if got := string(capture.stop()); got != "Warning: coercion (column v)\n" {
+		t.Errorf("captured %q", got)
+	}
+	if os.Stderr != original {
+		t.Error("stderr not restored")
+	}
+}
+
+// runTestMain runs main with args in a child process of the test binary, with its cache under cacheDir, and returns
+// what it wrote to stderr and whether it failed
+func runTestMain(t *testing.T, cacheDir string, args ...string) (stderr string, failed bool) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMainProcess$")
+	cmd.Env = append(os.Environ(), "TEST_MAIN_ARGS="+strings.Join(args, "\n"), "XDG_CACHE_HOME="+cacheDir, "HOME="+cacheDir)
+	var b strings.Builder
+	cmd.Stderr = &b
+	err := cmd.Run()
+	if _, exited := err.(*exec.ExitError); err != nil && !exited {
+		t.Fatal(err)
+	}
+	return b.String(), err != nil
+}
+
+// TestMainProcess runs main with the arguments runTestMain passes and is skipped in any other run
+func TestMainProcess(t *testing.T) {
+	args, ok := os.LookupEnv("TEST_MAIN_ARGS")
+	if !ok {
+		t.Skip("only run by runTestMain")
+	}
+	os.Args = append([]string{"csv-analyzer"}, strings.Split(args, "\n")...)
+	main()
+	os.Exit(0)
+}
+
+func TestFatalErrorAfterCaptureKeepsWarnings(t *testing.T) {
+	path := writeTestCSV(t, "id,name\n1,a\n2\n3,c\n")
+	// The template parses but fails while the report is rendered, after the output capture has started.
+	template := filepath.Join(t.TempDir(), "report.tmpl")
+	if err := os.WriteFile(template, []byte("{{index .Columns 100}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stderr, failed := runTestMain(t, t.TempDir(), "--template", template, path)
+	if !failed {
+		t.Fatal("the failing template did not fail the run")
+	}
+	for _, want := range []string{"Warning: ragged_rows", "Error writing report:"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr lacks %q:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestNoCacheLeavesTheCacheAlone(t *testing.T) {
+	path := writeTestCSV(t, "id,name\n1,a\n2\n")
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	stderr, failed := runTestMain(t, cacheDir, "--no-cache", "--dictionary", filepath.Join(cacheDir, "missing.yaml"), path)
+	if !failed || !strings.Contains(stderr, "Warning: ragged_rows") {
+		t.Errorf("failed %t with stderr:\n%s\nwant the warning before the dictionary error", failed, stderr)
+	}
+	if strings.Contains(stderr, "report cache") {
+		t.Errorf("--no-cache reported on the cache:\n%s", stderr)
+	}
+	if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+		t.Errorf("--no-cache created the cache directory: %v", err)
+	}
+}
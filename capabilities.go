@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// inputFormats lists the kinds of input file the analyzer reads, recognized by extension (CSV otherwise)
+var inputFormats = []string{"csv", "json", "jsonl", "arrow", "sqlite"}
+
+// subcommands lists the commands accepted in place of an input file
+var subcommands = []string{"sample", "convert", "version", "capabilities", "self-update", "check-refs", "serve", "mask", "drift", "dashboard", "rank-features", "index", "concat", "chi-square", "compare-groups", "watch", "generate"}
+
+// Capabilities describes what this build of the analyzer supports, for tools that feature-detect instead of
+// parsing help text
+type Capabilities struct {
+	Analyzer      BuildInfo           `json:"analyzer"`
+	Subcommands   []string            `json:"subcommands"`
+	InputFormats  []string            `json:"input_formats"`
+	OutputFormats []string            `json:"output_formats"`
+	ConvertTo     []string            `json:"convert_formats"`
+	Compression   []string            `json:"compression"`
+	ColumnTypes   []string            `json:"column_types"`
+	Statistics    map[string][]string `json:"statistics"` // per column type, plus "all" for those of every column
+	AlertMetrics  AlertMetricNames    `json:"alert_metrics"`
+	Locales       []string            `json:"locales"`
+	Presets       []string            `json:"presets"`
+	Limits        map[string]int      `json:"limits"`
+}
+
+// AlertMetricNames lists the metrics alert rules can refer to
+type AlertMetricNames struct {
+	Dataset []string `json:"dataset"`
+	Column  []string `json:"column"`
+}
+
+// currentCapabilities collects the capabilities of this build
+func currentCapabilities() Capabilities {
+	return Capabilities{
+		Analyzer:      currentBuildInfo(),
+		Subcommands:   subcommands,
+		InputFormats:  inputFormats,
+		OutputFormats: outputFormats,
+		ConvertTo:     []string{ConvertJSON, ConvertJSONL, ConvertArrow, ConvertSQLite, ConvertSQL},
+		Compression:   []string{CompressGzip},
+		ColumnTypes:   []string{string(TypeText), string(TypeNumeric), string(TypeBoolean), string(TypeDate)},
+		// The statistic names match the fields of the JSON report.
+		Statistics: map[string][]string{
+			string(TypeNumeric): {"count", "missing", "na_policy", "sum", "mean", "mean_ci", "geometric_mean", "harmonic_mean", "trimmed_mean", "median", "std_dev", "min", "max", "format", "max_integer_digits", "max_decimal_places", "fits_int64", "sql_type"},
+			string(TypeText):    {"total_count", "unique_count", "unique_values"},
+			string(TypeBoolean): {"true_count", "false_count", "empty_count", "true_ratio"},
+			string(TypeDate):    {"count", "invalid_count", "earliest", "latest", "span_days"},
+			"all":               {"non_empty", "distinct", "approximate", "uniqueness_ratio", "primary_key_candidate", "entropy", "normalized_entropy", "gini_impurity", "embedded_characters", "quality_score"},
+		},
+		AlertMetrics: AlertMetricNames{Dataset: sortedKeys(datasetMetrics), Column: sortedKeys(columnMetrics)},
+		Locales:      knownLocales(),
+		Presets:      presetNames(),
+		Limits: map[string]int{
+			"type_detection_rows":     typeDetectionRows,
+			"exact_distinct_limit":    exactDistinctLimit,
+			"exact_quantile_limit":    exactQuantileLimit,
+			"value_set_max_size":      maxValueSetSize,
+			"parse_audit_values":      maxAuditValues,
+			"parse_audit_rows":        maxAuditRows,
+			"rolling_preview_points":  rollingPreviewPoints,
+			"default_k_threshold":     defaultKThreshold,
+			"html_card_unique_values": maxCardValues,
+		},
+	}
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runCapabilities implements the capabilities subcommand; --json prints the full description as JSON
+func runCapabilities(args []string) {
+	capabilities := currentCapabilities()
+	if len(args) > 0 && (args[0] == "--json" || args[0] == "-json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(capabilities)
+		return
+	}
+	fmt.Println(capabilities.Analyzer)
+	fmt.Printf("Subcommands:    %s\n", strings.Join(capabilities.Subcommands, ", "))
+	fmt.Printf("Input formats:  %s\n", strings.Join(capabilities.InputFormats, ", "))
+	fmt.Printf("Output formats: %s\n", strings.Join(capabilities.OutputFormats, ", "))
+	fmt.Printf("Column types:   %s\n", strings.Join(capabilities.ColumnTypes, ", "))
+	fmt.Printf("Locales:        %s\n", strings.Join(capabilities.Locales, ", "))
+	fmt.Println("Use --json for statistics, alert metrics and limits.")
+}
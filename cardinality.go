@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// exactDistinctLimit is how many distinct values of a column are counted exactly before switching to HyperLogLog
+const exactDistinctLimit = 10000
+
+// hllPrecision is the number of hash bits that pick a HyperLogLog register (2^14 registers, about 0.8% error)
+const hllPrecision = 14
+
+// ColumnCardinality describes how many different values a column holds
+type ColumnCardinality struct {
+	NonEmpty        int     `json:"non_empty"`
+	Distinct        int     `json:"distinct"`
+	Approximate     bool    `json:"approximate"`      // set when Distinct is a HyperLogLog estimate
+	UniquenessRatio float64 `json:"uniqueness_ratio"` // distinct / non-empty values
+	PrimaryKey      bool    `json:"primary_key_candidate"`
+	// Entropy (in bits), entropy as a share of its maximum log2(distinct) and Gini impurity measure how evenly the
+	// values of a text or boolean column are spread; nil for other types and for estimated distinct counts
+	Entropy           *float64 `json:"entropy,omitempty"`
+	NormalizedEntropy *float64 `json:"normalized_entropy,omitempty"`
+	GiniImpurity      *float64 `json:"gini_impurity,omitempty"`
+}
+
+// hyperLogLog estimates the number of distinct values in a stream using a fixed 2^hllPrecision bytes of memory
+type hyperLogLog struct {
+	registers []uint8
+}
+
+// newHyperLogLog returns an empty estimator
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+// add records one value
+func (h *hyperLogLog) add(value string) {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(value))
+	// FNV mixes its low bits poorly, so the splitmix64 finalizer spreads them before the hash is split up.
+	x := hasher.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	// The top bits choose the register; the rank is the position of the first 1 bit in the rest.
+	index := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// estimate returns the approximate number of distinct values added
+func (h *hyperLogLog) estimate() int {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// Linear counting is more accurate while many registers are still empty.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(estimate))
+}
+
+// The calculateCardinality method is part of the CSVAnalyzer struct. It counts the distinct non-empty values of a
+// column of any type. Values are counted exactly in a set until the column has more than exactDistinctLimit of them;
+// the set is then replaced by a HyperLogLog sketch, so a column of millions of IDs costs 16KiB instead of a copy of
+// every value. A column is a primary-key candidate when no cell is empty and every value is different. For estimated
+// counts that means the estimate is within the sketch's error of the row count, so the flag is a strong hint rather
+// than a guarantee. Text and boolean columns counted exactly also get the entropy and Gini impurity of their values
+// (see valueConcentration).
+// calculateCardinality computes the distinct count and uniqueness of one column
+func (ca *CSVAnalyzer) calculateCardinality(colIndex int) ColumnCardinality {
+	var result ColumnCardinality
+	seen := make(map[string]int)
+	var sketch *hyperLogLog
+	for _, row := range ca.dataset.Rows {
+		if colIndex >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[colIndex])
+		if value == "" {
+			continue
+		}
+		result.NonEmpty++
+		if sketch != nil {
+			sketch.add(value)
+			continue
+		}
+		seen[value]++
+		// Moves to the sketch once the exact set grows too large.
+		if len(seen) > exactDistinctLimit {
+			sketch = newHyperLogLog()
+			for known := range seen {
+				sketch.add(known)
+			}
+			seen = nil
+		}
+	}
+
+	result.Distinct = len(seen)
+	if sketch != nil {
+		result.Distinct, result.Approximate = sketch.estimate(), true
+		// An estimate can overshoot, but there are never more distinct values than values.
+		if result.Distinct > result.NonEmpty {
+			result.Distinct = result.NonEmpty
+		}
+	}
+	if result.NonEmpty > 0 {
+		result.UniquenessRatio = float64(result.Distinct) / float64(result.NonEmpty)
+	}
+
+	complete := result.NonEmpty > 0 && result.NonEmpty == len(ca.dataset.Rows)
+	if result.Approximate {
+		// Three standard errors of the sketch, 1.04/sqrt(registers).
+		tolerance := 3 * 1.04 / math.Sqrt(float64(int(1)<<hllPrecision))
+		result.PrimaryKey = complete && result.UniquenessRatio >= 1-tolerance
+	} else {
+		result.PrimaryKey = complete && result.Distinct == result.NonEmpty
+	}
+	if colType := ca.columnType(colIndex); !result.Approximate && result.NonEmpty > 0 && (colType == TypeText || colType == TypeBoolean) {
+		entropy, normalized, gini := valueConcentration(seen, result.NonEmpty)
+		result.Entropy, result.NormalizedEntropy, result.GiniImpurity = &entropy, &normalized, &gini
+	}
+	return result
+}
+
+// The valueConcentration function measures how concentrated the values of a categorical column are from their
+// counts. Entropy is -sum(p log2 p) in bits: 0 when every value is the same and log2(distinct) when all are equally
+// common, so normalized entropy (entropy over that maximum) lies between 0 and 1 whatever the number of values. Gini
+// impurity, 1 - sum(p²), is the chance that two values drawn at random differ. Both near 0 mark a column dominated by
+// one value that carries little information for a model, and near their maximum with as many values as rows a
+// column that behaves like a key.
+// valueConcentration returns the entropy, normalized entropy and Gini impurity of value counts
+func valueConcentration(counts map[string]int, total int) (entropy, normalized, gini float64) {
+	// Sums in a fixed order, since map order would change the last digits from run to run.
+	sorted := make([]int, 0, len(counts))
+	for _, count := range counts {
+		sorted = append(sorted, count)
+	}
+	sort.Ints(sorted)
+	gini = 1
+	for _, count := range sorted {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+		gini -= p * p
+	}
+	// A single value has no spread at all, and rounding should not leave a tiny negative impurity.
+	if len(counts) > 1 {
+		normalized = entropy / math.Log2(float64(len(counts)))
+	}
+	return entropy, normalized, math.Max(gini, 0)
+}
+
+// The Cardinality method is part of the CSVAnalyzer struct. It returns the cardinality of every column in header
+// order. Distinct counts and key candidates describe individual values, so like the value lists they are withheld
+// when differential privacy is enabled.
+// Cardinality profiles the distinct values of every column, or returns nil under differential privacy
+func (ca *CSVAnalyzer) Cardinality() []ColumnCardinality {
+	if ca.privacy != nil {
+		return nil
+	}
+	var result []ColumnCardinality
+	for colIndex := range ca.dataset.Headers {
+		result = append(result, ca.calculateCardinality(colIndex))
+	}
+	return result
+}
+
+// primaryKeyCandidates names the columns flagged as primary-key candidates
+func (ca *CSVAnalyzer) primaryKeyCandidates(cardinality []ColumnCardinality) []string {
+	var names []string
+	for colIndex, column := range cardinality {
+		if column.PrimaryKey {
+			names = append(names, ca.dataset.Headers[colIndex])
+		}
+	}
+	return names
+}
+
+// printCardinality shows the cardinality of every column in the text report
+func (ca *CSVAnalyzer) printCardinality(cardinality []ColumnCardinality) {
+	if len(cardinality) == 0 {
+		return
+	}
+	fmt.Println("\n\nCardinality (All Columns):")
+	fmt.Println("--------------------------")
+	fmt.Printf("  %-24s %10s %10s %10s %11s %8s\n", "Column", "Non-empty", "Distinct", "Unique", "Entropy", "Gini")
+	for colIndex, column := range cardinality {
+		distinct := fmt.Sprintf("%d", column.Distinct)
+		if column.Approximate {
+			distinct = "~" + distinct
+		}
+		// Entropy is shown with its normalized share, which compares across columns with different numbers of values.
+		entropy, gini := "-", "-"
+		if column.Entropy != nil {
+			entropy = fmt.Sprintf("%.2f (%.0f%%)", *column.Entropy, 100**column.NormalizedEntropy)
+			gini = fmt.Sprintf("%.3f", *column.GiniImpurity)
+		}
+		fmt.Printf("  %-24s %10d %10s %9.1f%% %11s %8s\n", ca.dataset.Headers[colIndex], column.NonEmpty, distinct, 100*column.UniquenessRatio, entropy, gini)
+	}
+	if candidates := ca.primaryKeyCandidates(cardinality); len(candidates) > 0 {
+		fmt.Printf("  Primary-key candidates: %s\n", strings.Join(candidates, ", "))
+	} else {
+		fmt.Println("  Primary-key candidates: none (no column is complete and fully unique)")
+	}
+}
+
+// topValues counts the most frequent non-empty values of a column, most frequent first
+func (ca *CSVAnalyzer) topValues(colIndex, n int) ([]string, []int) {
+	counts := make(map[string]int)
+	for _, row := range ca.dataset.Rows {
+		if colIndex < len(row) {
+			if value := strings.TrimSpace(row[colIndex]); value != "" {
+				counts[value]++
+			}
+		}
+	}
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	if len(values) > n {
+		values = values[:n]
+	}
+	top := make([]int, len(values))
+	for i, value := range values {
+		top[i] = counts[value]
+	}
+	return values, top
+}
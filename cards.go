@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxCardValues is how many distinct values a text card lists before truncating
+const maxCardValues = 10
+
+// cardTemplate renders one self-contained column card. Styles are inlined so the fragment
+// looks the same when pasted into a notebook cell or an internal portal page.
+var cardTemplate = template.Must(template.New("card").Funcs(template.FuncMap{
+	"num":  func(v float64) string { return fmt.Sprintf("%.3f", v) },
+	"date": formatDate,
+}).Parse(`<div class="csva-card" data-column="{{.Name}}" style="font-family:sans-serif;border:1px solid #d0d7de;border-radius:6px;padding:12px 16px;margin:8px;display:inline-block;vertical-align:top;min-width:220px">
+  <div style="font-weight:bold;font-size:15px">{{.Name}}{{with .Unit}} <span style="font-weight:normal;color:#57606a">({{.}})</span>{{end}}</div>
+  <div style="color:#57606a;font-size:12px;margin-bottom:8px">{{.Type}}</div>
+{{- with .Description}}
+  <div style="font-size:12px;margin-bottom:8px;max-width:320px">{{.}}</div>
+{{- end}}
+  <table style="border-collapse:collapse;font-size:13px">
+{{- with .Numeric}}
+    <tr><td>Count</td><td style="text-align:right;padding-left:16px">{{.Count}}</td></tr>
+    <tr><td>Sum</td><td style="text-align:right;padding-left:16px">{{num .Sum}}</td></tr>
+    <tr><td>Mean</td><td style="text-align:right;padding-left:16px">{{num .Mean}}</td></tr>
+{{- with .GeometricMean}}
+    <tr><td>Geometric Mean</td><td style="text-align:right;padding-left:16px">{{num .}}</td></tr>
+{{- end}}
+{{- with .HarmonicMean}}
+    <tr><td>Harmonic Mean</td><td style="text-align:right;padding-left:16px">{{num .}}</td></tr>
+{{- end}}
+    <tr><td>Trimmed Mean</td><td style="text-align:right;padding-left:16px">{{num .TrimmedMean}}</td></tr>
+    <tr><td>Median</td><td style="text-align:right;padding-left:16px">{{num .Median}}</td></tr>
+    <tr><td>Std Dev</td><td style="text-align:right;padding-left:16px">{{num .StdDev}}</td></tr>
+    <tr><td>Min</td><td style="text-align:right;padding-left:16px">{{num .Min}}</td></tr>
+    <tr><td>Max</td><td style="text-align:right;padding-left:16px">{{num .Max}}</td></tr>
+{{- end}}
+{{- with .Text}}
+    <tr><td>Total Count</td><td style="text-align:right;padding-left:16px">{{.TotalCount}}</td></tr>
+    <tr><td>Unique Count</td><td style="text-align:right;padding-left:16px">{{.UniqueCount}}</td></tr>
+{{- with .Strings}}
+    <tr><td>Length</td><td style="text-align:right;padding-left:16px">{{.MinLength}} to {{.MaxLength}}, mean {{num .MeanLength}}</td></tr>
+    <tr><td>Whitespace</td><td style="text-align:right;padding-left:16px">{{.LeadingWhitespace}} leading, {{.TrailingWhitespace}} trailing</td></tr>
+    <tr><td>Case</td><td style="text-align:right;padding-left:16px">{{.UpperCase}} upper, {{.LowerCase}} lower, {{.MixedCase}} mixed</td></tr>
+{{- end}}
+{{- end}}
+{{- with .Date}}
+    <tr><td>Count</td><td style="text-align:right;padding-left:16px">{{.Count}}</td></tr>
+    <tr><td>Earliest</td><td style="text-align:right;padding-left:16px">{{date .Earliest}}</td></tr>
+    <tr><td>Latest</td><td style="text-align:right;padding-left:16px">{{date .Latest}}</td></tr>
+    <tr><td>Invalid</td><td style="text-align:right;padding-left:16px">{{.InvalidCount}}</td></tr>
+{{- end}}
+{{- with .Boolean}}
+    <tr><td>True</td><td style="text-align:right;padding-left:16px">{{.TrueCount}}</td></tr>
+    <tr><td>False</td><td style="text-align:right;padding-left:16px">{{.FalseCount}}</td></tr>
+    <tr><td>Empty</td><td style="text-align:right;padding-left:16px">{{.EmptyCount}}</td></tr>
+    <tr><td>True Ratio</td><td style="text-align:right;padding-left:16px">{{num .TrueRatio}}</td></tr>
+{{- end}}
+  </table>
+{{- with .Text}}{{if .UniqueValues}}
+  <div style="color:#57606a;font-size:12px;margin-top:8px">{{range $i, $v := .UniqueValues}}{{if $i}}, {{end}}{{$v}}{{end}}</div>
+{{- end}}{{end}}
+</div>
+`))
+
+// cardColumn trims long value lists so a card stays small enough to embed
+func cardColumn(column ColumnReport) ColumnReport {
+	// Numeric cards and short value lists are left untouched.
+	if column.Text == nil || len(column.Text.UniqueValues) <= maxCardValues {
+		return column
+	}
+	// Copies the text stats so the caller's report is not modified.
+	text := *column.Text
+	text.UniqueValues = append(text.UniqueValues[:maxCardValues:maxCardValues], "…")
+	column.Text = &text
+	return column
+}
+
+// The WriteCards method is part of the CSVAnalyzer struct. Instead of one monolithic report it produces a small fragment
+// per column - an inline-styled HTML <div> or a single-line JSON object - that can be dropped into a notebook cell or a
+// portal page on its own. The fragments are written one after another to w.
+// WriteCards writes one HTML or JSON card per column
+func (ca *CSVAnalyzer) WriteCards(w io.Writer, format string) error {
+	// Renders every column of the report as a card.
+	for _, column := range ca.BuildReport().Columns {
+		if err := writeCard(w, column, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCardFiles writes every card to its own file in dir, named after the column
+func (ca *CSVAnalyzer) WriteCardFiles(dir, format string) error {
+	// Creates the output directory if it does not exist yet.
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating cards directory: %v", err)
+	}
+	// Picks the file extension that matches the card format.
+	ext := ".html"
+	if format == FormatJSONCards {
+		ext = ".json"
+	}
+	// Writes each card into a file prefixed with its column position, which keeps names unique.
+	for colIndex, column := range ca.BuildReport().Columns {
+		path := filepath.Join(dir, fmt.Sprintf("%02d_%s%s", colIndex+1, safeFileName(column.Name), ext))
+		file, err := createOutputFile(path, ca.options.Compress)
+		if err != nil {
+			return fmt.Errorf("error creating card file: %v", err)
+		}
+		err = writeCard(file, column, format)
+		// Closes the file before checking the write error so no handle is leaked.
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCard renders a single column card in the requested format
+func writeCard(w io.Writer, column ColumnReport, format string) error {
+	// JSON cards are full column reports, one compact object per line.
+	if format == FormatJSONCards {
+		data, err := json.Marshal(column)
+		if err != nil {
+			return fmt.Errorf("error encoding card: %v", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	// HTML cards are rendered through the template with shortened value lists.
+	if err := cardTemplate.Execute(w, cardColumn(column)); err != nil {
+		return fmt.Errorf("error rendering card: %v", err)
+	}
+	return nil
+}
+
+// safeFileName replaces characters that are awkward in file names with underscores
+func safeFileName(name string) string {
+	// Keeps letters, digits, dashes and dots; everything else becomes an underscore.
+	mapped := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, name)
+	// Guards against headers that are empty.
+	if mapped == "" {
+		return "column"
+	}
+	return mapped
+}
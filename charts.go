@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Layout of the terminal charts
+const (
+	sparklineBins = 20 // histogram bins drawn by a sparkline
+	boxPlotWidth  = 50 // characters between the ends of a terminal box plot
+)
+
+// Layout of the SVG charts
+const (
+	svgChartWidth  = 480
+	svgChartHeight = 240
+	svgChartMargin = 40
+)
+
+// sparkBlocks are the bar heights of a sparkline, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// BoxPlot summarizes a numeric column the way a Tukey box plot draws it: whiskers reach the furthest values within
+// 1.5 interquartile ranges of the box, and values beyond them are outliers
+type BoxPlot struct {
+	Min, Q1, Median, Q3, Max float64
+	LowerWhisker             float64
+	UpperWhisker             float64
+	Outliers                 int
+}
+
+// percentile returns the p-th quantile (0 to 1) of sorted values, interpolating between neighbouring values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// newBoxPlot computes the box plot of a set of finite values, at least one
+func newBoxPlot(values []float64) BoxPlot {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	box := BoxPlot{
+		Min: sorted[0], Max: sorted[len(sorted)-1],
+		Q1: percentile(sorted, 0.25), Median: percentile(sorted, 0.5), Q3: percentile(sorted, 0.75),
+	}
+	fence := 1.5 * (box.Q3 - box.Q1)
+	box.LowerWhisker, box.UpperWhisker = box.Max, box.Min
+	for _, v := range sorted {
+		if v < box.Q1-fence || v > box.Q3+fence {
+			box.Outliers++
+			continue
+		}
+		box.LowerWhisker = math.Min(box.LowerWhisker, v)
+		box.UpperWhisker = math.Max(box.UpperWhisker, v)
+	}
+	return box
+}
+
+// finiteValues drops NaN and infinite values, which "NaN" and "Inf" cells parse to and which no bin can hold
+func finiteValues(values []float64) []float64 {
+	finite := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			finite = append(finite, v)
+		}
+	}
+	return finite
+}
+
+// histogram counts the finite values falling into bins equal-width bins between the smallest and largest value
+func histogram(values []float64, bins int) []int {
+	counts := make([]int, bins)
+	values = finiteValues(values)
+	low, high := min(values...), max(values...)
+	for _, v := range values {
+		bin := 0
+		if high > low {
+			bin = int(float64(bins) * (v - low) / (high - low))
+		}
+		// The largest value belongs in the last bin rather than one past it.
+		if bin >= bins {
+			bin = bins - 1
+		}
+		counts[bin]++
+	}
+	return counts
+}
+
+// Histogram is the binned distribution of a numeric column, for callers that draw their own charts: Counts[i] values
+// lie between Edges[i] and Edges[i+1], and each bin includes its lower edge (the last bin includes both)
+type Histogram struct {
+	Edges  []float64 `json:"edges"`
+	Counts []int     `json:"counts"`
+}
+
+// The Histogram method is part of the CSVAnalyzer struct. It bins the values of a numeric column into bins
+// equal-width bins between its smallest and largest value, the same bins the sparklines and SVG histograms draw, and
+// returns the bin edges along with the counts. A constant column has all its values in the first bin. Bin counts of
+// sparse bins reveal individual values, so histograms are withheld under differential privacy.
+// Histogram returns the bin edges and counts of a numeric column, or nil when it has no numeric values
+func (ca *CSVAnalyzer) Histogram(colIndex, bins int) (*Histogram, error) {
+	if bins < 1 {
+		return nil, fmt.Errorf("histogram needs at least 1 bin, got %d", bins)
+	}
+	if ca.privacy != nil || ca.columnType(colIndex) != TypeNumeric {
+		return nil, nil
+	}
+	values := finiteValues(ca.extractNumericValues(colIndex))
+	if len(values) == 0 {
+		return nil, nil
+	}
+	low, high := min(values...), max(values...)
+	edges := make([]float64, bins+1)
+	for i := range edges {
+		edges[i] = low + float64(i)*(high-low)/float64(bins)
+	}
+	// Pins the last edge to the maximum so rounding never leaves it outside.
+	edges[bins] = high
+	return &Histogram{Edges: edges, Counts: histogram(values, bins)}, nil
+}
+
+// sparkline draws histogram counts as a row of block characters; empty bins stay blank
+func sparkline(counts []int) string {
+	peak := 0
+	for _, count := range counts {
+		if count > peak {
+			peak = count
+		}
+	}
+	var b strings.Builder
+	for _, count := range counts {
+		if count == 0 || peak == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparkBlocks[(count*len(sparkBlocks)-1)/peak])
+	}
+	return b.String()
+}
+
+// The render method is part of the BoxPlot struct. It draws the box plot on one line of width characters scaled from
+// the minimum to the maximum: whiskers as dashes ending in "|", the box between the quartiles as "=", the median as
+// "#" and outliers as "o". A column with a single distinct value is drawn as a lone median mark.
+// render draws the box plot as text
+func (b BoxPlot) render(width int) string {
+	line := []rune(strings.Repeat(" ", width))
+	position := func(v float64) int {
+		if b.Max == b.Min {
+			return width / 2
+		}
+		return int(math.Round(float64(width-1) * (v - b.Min) / (b.Max - b.Min)))
+	}
+	for i := position(b.LowerWhisker); i <= position(b.UpperWhisker); i++ {
+		line[i] = '-'
+	}
+	for i := position(b.Q1); i <= position(b.Q3); i++ {
+		line[i] = '='
+	}
+	line[position(b.LowerWhisker)], line[position(b.UpperWhisker)] = '|', '|'
+	if b.Outliers > 0 {
+		// Only the extremes are marked, which is enough to show on which side the outliers lie.
+		if b.Min < b.LowerWhisker {
+			line[position(b.Min)] = 'o'
+		}
+		if b.Max > b.UpperWhisker {
+			line[position(b.Max)] = 'o'
+		}
+	}
+	line[position(b.Median)] = '#'
+	return string(line)
+}
+
+// printDistributions shows a sparkline and a box plot of every numeric column in the text report
+func (ca *CSVAnalyzer) printDistributions() {
+	if !ca.options.Charts {
+		return
+	}
+	printed := false
+	for colIndex, header := range ca.dataset.Headers {
+		if _, ok := ca.calculateColumnStats(colIndex); !ok {
+			continue
+		}
+		// NaN and infinite cells would scale the plot to nothing, so only finite values are drawn.
+		values := finiteValues(ca.extractNumericValues(colIndex))
+		if len(values) == 0 {
+			continue
+		}
+		if !printed {
+			fmt.Println("\n\nDistributions (Numeric Columns):")
+			fmt.Println("--------------------------------")
+			printed = true
+		}
+		box := newBoxPlot(values)
+		fmt.Printf("\n%s:\n", header)
+		fmt.Printf("  Histogram: [%s] %s .. %s\n", sparkline(histogram(values, sparklineBins)), formatMetric(box.Min), formatMetric(box.Max))
+		fmt.Printf("  Box plot:  [%s]\n", box.render(boxPlotWidth))
+		fmt.Printf("             Q1 %s, median %s, Q3 %s, %d outliers\n", formatMetric(box.Q1), formatMetric(box.Median), formatMetric(box.Q3), box.Outliers)
+	}
+}
+
+// The WriteCharts method is part of the CSVAnalyzer struct. It writes an SVG histogram and an SVG box plot of every
+// numeric column into dir, named after the column's position and name like the card files, for inclusion in reports
+// and documentation. The charts are drawn directly as SVG so the build needs no plotting library.
+// WriteCharts writes a histogram and a box plot per numeric column as SVG files
+func (ca *CSVAnalyzer) WriteCharts(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating charts directory: %v", err)
+	}
+	for colIndex, header := range ca.dataset.Headers {
+		if _, ok := ca.calculateColumnStats(colIndex); !ok {
+			continue
+		}
+		values := finiteValues(ca.extractNumericValues(colIndex))
+		if len(values) == 0 {
+			continue
+		}
+		base := filepath.Join(dir, fmt.Sprintf("%02d_%s", colIndex+1, safeFileName(header)))
+		charts := map[string]string{
+			base + "_histogram.svg": histogramSVG(header, values),
+			base + "_boxplot.svg":   boxPlotSVG(header, newBoxPlot(values)),
+		}
+		for path, svg := range charts {
+			if err := writeOutputFile(path, ca.options.Compress, []byte(svg)); err != nil {
+				return fmt.Errorf("error writing chart: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// svgChart wraps chart elements in an SVG document with a title
+func svgChart(title, body string) string {
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">
+  <title>%s</title>
+  <rect width="100%%" height="100%%" fill="#fff"/>
+  <text x="%d" y="20" font-size="13" font-weight="bold">%s</text>
+%s</svg>
+`, svgChartWidth, svgChartHeight, html.EscapeString(title), svgChartMargin, html.EscapeString(title), body)
+}
+
+// histogramSVG draws the distribution of a column as bars over sparklineBins equal-width bins
+func histogramSVG(name string, values []float64) string {
+	counts := histogram(values, sparklineBins)
+	peak := 0
+	for _, count := range counts {
+		if count > peak {
+			peak = count
+		}
+	}
+	plotWidth := float64(svgChartWidth - 2*svgChartMargin)
+	plotHeight := float64(svgChartHeight - 2*svgChartMargin)
+	barWidth := plotWidth / float64(len(counts))
+	var body strings.Builder
+	for i, count := range counts {
+		height := plotHeight * float64(count) / float64(peak)
+		fmt.Fprintf(&body, "  <rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"#4c78a8\"><title>%d</title></rect>\n",
+			svgChartMargin+float64(i)*barWidth, svgChartMargin+plotHeight-height, barWidth-1, height, count)
+	}
+	// Labels the value range under the axis and the tallest bar's count beside it.
+	bottom := svgChartHeight - svgChartMargin
+	fmt.Fprintf(&body, "  <line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#333\"/>\n", svgChartMargin, bottom, svgChartWidth-svgChartMargin, bottom)
+	fmt.Fprintf(&body, "  <text x=\"%d\" y=\"%d\">%s</text>\n", svgChartMargin, bottom+15, formatMetric(min(values...)))
+	fmt.Fprintf(&body, "  <text x=\"%d\" y=\"%d\" text-anchor=\"end\">%s</text>\n", svgChartWidth-svgChartMargin, bottom+15, formatMetric(max(values...)))
+	fmt.Fprintf(&body, "  <text x=\"%d\" y=\"%d\" text-anchor=\"end\">%d</text>\n", svgChartMargin-4, svgChartMargin+4, peak)
+	return svgChart(name+" histogram", body.String())
+}
+
+// boxPlotSVG draws a horizontal Tukey box plot of a column
+func boxPlotSVG(name string, box BoxPlot) string {
+	plotWidth := float64(svgChartWidth - 2*svgChartMargin)
+	x := func(v float64) float64 {
+		if box.Max == box.Min {
+			return svgChartMargin + plotWidth/2
+		}
+		return svgChartMargin + plotWidth*(v-box.Min)/(box.Max-box.Min)
+	}
+	middle := float64(svgChartHeight) / 2
+	var body strings.Builder
+	// Whiskers, then the box with the median line across it.
+	fmt.Fprintf(&body, "  <line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"#333\"/>\n", x(box.LowerWhisker), middle, x(box.UpperWhisker), middle)
+	for _, end := range []float64{box.LowerWhisker, box.UpperWhisker} {
+		fmt.Fprintf(&body, "  <line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"#333\"/>\n", x(end), middle-15, x(end), middle+15)
+	}
+	fmt.Fprintf(&body, "  <rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"50\" fill=\"#9ecae9\" stroke=\"#333\"/>\n", x(box.Q1), middle-25, x(box.Q3)-x(box.Q1))
+	fmt.Fprintf(&body, "  <line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"#333\" stroke-width=\"2\"/>\n", x(box.Median), middle-25, x(box.Median), middle+25)
+	// Marks the extremes when they lie beyond the whiskers.
+	for _, extreme := range []float64{box.Min, box.Max} {
+		if extreme < box.LowerWhisker || extreme > box.UpperWhisker {
+			fmt.Fprintf(&body, "  <circle cx=\"%.1f\" cy=\"%.1f\" r=\"3\" fill=\"none\" stroke=\"#e05d44\"/>\n", x(extreme), middle)
+		}
+	}
+	fmt.Fprintf(&body, "  <text x=\"%d\" y=\"%d\">Q1 %s, median %s, Q3 %s, %d outliers</text>\n", svgChartMargin, svgChartHeight-svgChartMargin+15,
+		formatMetric(box.Q1), formatMetric(box.Median), formatMetric(box.Q3), box.Outliers)
+	return svgChart(name+" box plot", body.String())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Non-finite cells parse as numbers but have no place on a chart.
+var nonFiniteCSVs = map[string]string{
+	"Inf": "a,b\n1,x\n2,y\nInf,z\n3,w\n",
+	"NaN": "a,b\n1,x\nNaN,y\n-Inf,z\n3,w\n",
+}
+
+func TestPrintDistributionsNonFinite(t *testing.T) {
+	for name, content := range nonFiniteCSVs {
+		t.Run(name, func(t *testing.T) {
+			analyzer := loadTestCSV(t, content, "--charts")
+			text, err := renderTextReport(analyzer.printDistributions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(text), "1 .. 3") || strings.Contains(string(text), "NaN") {
+				t.Errorf("distributions not drawn from the finite values:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestPrintDistributionsSkipsColumnsWithoutFiniteValues(t *testing.T) {
+	analyzer := loadTestCSV(t, "a,b\nNaN,1\nInf,2\n", "--charts")
+	text, err := renderTextReport(analyzer.printDistributions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(text), "\na:") || !strings.Contains(string(text), "\nb:") {
+		t.Errorf("expected only column b to be drawn:\n%s", text)
+	}
+}
+
+func TestWriteChartsNonFinite(t *testing.T) {
+	for name, content := range nonFiniteCSVs {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := loadTestCSV(t, content).WriteCharts(dir); err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range []string{"01_a_histogram.svg", "01_a_boxplot.svg"} {
+				svg, err := os.ReadFile(filepath.Join(dir, file))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Contains(string(svg), "NaN") || strings.Contains(string(svg), "Inf") {
+					t.Errorf("%s has non-finite coordinates:\n%s", file, svg)
+				}
+			}
+		})
+	}
+}
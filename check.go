@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultCheckMaxMissingPct is the missing percentage of a column above which --check fails
+const defaultCheckMaxMissingPct = 5
+
+// CheckResult is the machine-readable outcome of a --check run
+type CheckResult struct {
+	Passed           bool          `json:"passed"`
+	Checks           []AlertResult `json:"checks"`
+	SchemaMismatches []string      `json:"schema_mismatches,omitempty"`
+	Keys             []KeyCheck    `json:"unique_keys,omitempty"`
+	Rules            []RuleResult  `json:"rules,omitempty"`
+}
+
+// LoadSchema reads an expected schema in the format of the schema.json file of a run bundle
+func LoadSchema(path string) ([]SchemaColumn, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema: %v", err)
+	}
+	var schema []SchemaColumn
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("error parsing schema %s: %v", path, err)
+	}
+	return schema, nil
+}
+
+// duplicateRows counts the rows identical to an earlier row in every cell
+func (ca *CSVAnalyzer) duplicateRows() int {
+	seen := make(map[string]bool, len(ca.dataset.Rows))
+	duplicates := 0
+	for _, row := range ca.dataset.Rows {
+		// The cell count is part of the key so ragged rows never collide with full ones.
+		key := fmt.Sprint(len(row)) + "\x00" + strings.Join(row, "\x00")
+		if seen[key] {
+			duplicates++
+		}
+		seen[key] = true
+	}
+	return duplicates
+}
+
+// The SchemaMismatches method is part of the CSVAnalyzer struct. It compares the loaded dataset with the expected
+// schema of --expect-schema, typically the schema.json of an earlier run's --bundle: columns that are missing or not
+// expected, columns whose detected type differs, and columns declared not nullable that have missing values. Column
+// order is not compared, since many exports shuffle it harmlessly.
+// SchemaMismatches describes every difference from the expected schema
+func (ca *CSVAnalyzer) SchemaMismatches() []string {
+	var mismatches []string
+	expected := make(map[string]bool)
+	for _, column := range ca.expectedSchema {
+		expected[column.Name] = true
+		colIndex := ca.columnIndex(column.Name)
+		if colIndex < 0 {
+			mismatches = append(mismatches, fmt.Sprintf("column %s is missing", column.Name))
+			continue
+		}
+		if actual := ca.columnTypeName(colIndex); !strings.EqualFold(actual, column.Type) {
+			mismatches = append(mismatches, fmt.Sprintf("column %s is %s, expected %s", column.Name, actual, column.Type))
+		}
+		if !column.Nullable && ca.countNonEmptyValues(colIndex) < len(ca.dataset.Rows) {
+			mismatches = append(mismatches, fmt.Sprintf("column %s has missing values but is not nullable", column.Name))
+		}
+	}
+	for _, header := range ca.dataset.Headers {
+		if !expected[header] {
+			mismatches = append(mismatches, fmt.Sprintf("column %s is not in the schema", header))
+		}
+	}
+	return mismatches
+}
+
+// The checkRules method is part of the CSVAnalyzer struct. It turns the --check thresholds into critical alert rules
+// once the columns are known: one missing_pct rule per column, a duplicate_rows rule for the dataset and, with
+// --expect-schema, a schema_mismatches rule. They run alongside the configured and quick-check rules, so suppressions
+// and the webhook apply to them as well.
+// checkRules returns the alert rules of --check
+func (ca *CSVAnalyzer) checkRules() []AlertRule {
+	opts := ca.options
+	var rules []AlertRule
+	for _, header := range ca.dataset.Headers {
+		rules = append(rules, AlertRule{Column: header, Metric: "missing_pct", Op: "<=", Value: opts.CheckMaxMissingPct, Severity: SeverityCritical})
+	}
+	rules = append(rules, AlertRule{Metric: "duplicate_rows", Op: "<=", Value: float64(opts.CheckMaxDuplicates), Severity: SeverityCritical})
+	if ca.expectedSchema != nil {
+		rules = append(rules, AlertRule{Metric: "schema_mismatches", Op: "==", Value: 0, Severity: SeverityCritical})
+	}
+	for i := range rules {
+		rules[i].normalize()
+	}
+	return rules
+}
+
+// Check evaluates every alert rule, the --check thresholds included; it passes when no critical rule failed
+func (ca *CSVAnalyzer) Check() CheckResult {
+	ca.config.Alerts = append(ca.config.Alerts, ca.checkRules()...)
+	results := ca.EvaluateAlerts()
+	result := CheckResult{Passed: !hasCriticalFailure(results), Checks: results}
+	if ca.expectedSchema != nil {
+		result.SchemaMismatches = ca.SchemaMismatches()
+	}
+	result.Keys = ca.KeyChecks()
+	result.Rules = ca.RuleResults()
+	return result
+}
+
+// The writeCheckResult function writes only the outcome of a check run: one line per failed rule and the schema
+// differences, then the verdict (or all of it as JSON for --format json). It is the quiet counterpart of the full
+// report for gating CI/CD pipelines, which only need the verdict and the exit status.
+// writeCheckResult writes the check outcome to w
+func writeCheckResult(w io.Writer, result CheckResult, format string) error {
+	if format == FormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("error encoding check results: %v", err)
+		}
+		return nil
+	}
+	results := result.Checks
+	failed := failedAlerts(results)
+	for _, check := range failed {
+		label := "FAIL"
+		if check.Suppressed {
+			label = "SUPPRESSED"
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", label, check.Rule.Name, check.Message)
+	}
+	for _, mismatch := range result.SchemaMismatches {
+		fmt.Fprintf(w, "  schema: %s\n", mismatch)
+	}
+	for _, key := range result.Keys {
+		if !key.Unique && len(key.Duplicates) > 0 {
+			fmt.Fprintf(w, "  key: (%s) %s\n", strings.Join(key.Columns, ", "), key.Duplicates[0].describe())
+		}
+	}
+	for _, rule := range result.Rules {
+		if rule.Violations > 0 {
+			fmt.Fprintf(w, "  rule: %s: %d of %d values (%s)\n", rule.Rule, rule.Violations, rule.Checked, rule.describeExamples())
+		}
+	}
+	verdict := "passed"
+	if !result.Passed {
+		verdict = "failed"
+	}
+	fmt.Fprintf(w, "Check %s: %d of %d rules passed\n", verdict, len(results)-len(failed), len(results))
+	return nil
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Options holds the command-line settings that control a run of the analyzer
+type Options struct {
+	// Format selects how the report is rendered (see outputFormats)
+	Format string
+	// CardsDir, when set with a card format, writes one file per column card instead of printing them
+	CardsDir string
+	// ConfigPath points at a JSON configuration file (alert rules and other settings)
+	ConfigPath string
+	// AlertWebhook receives a JSON notification listing every failed alert
+	AlertWebhook string
+	// DictionaryPath points at a data dictionary with column descriptions and units
+	DictionaryPath string
+	// Progress draws a progress bar with ETA on stderr while files load
+	Progress bool
+	// Locale enables parsing of currency symbols and locale-specific separators, e.g. "de-DE"
+	Locale string
+	// OutputLocale formats the numbers and dates of the text report with a locale's separators and date layout
+	OutputLocale string
+	// Encoding is the character encoding of the input files ("auto" detects it from the byte order mark and content)
+	Encoding string
+	// LazyQuotes, QuoteChar, CommentChar and TrimLeadingSpace configure how CSV files are parsed (see newCSVReader)
+	LazyQuotes       bool
+	QuoteChar        string
+	CommentChar      string
+	TrimLeadingSpace bool
+	// Table is the table read from a SQLite input, and the table convert --to sqlite writes
+	Table string
+	// Drop removes columns right after loading, and Renames renames them; both use the names in the file
+	Drop    columnList
+	Renames ColumnRenames
+	// Recode maps category values to new labels, after the drops and renames and before the derived columns
+	Recode CategoryRecodes
+	// Derive adds columns computed with date functions, after the drops and renames
+	Derive DerivedColumns
+	// Check only evaluates the alert rules and the --check thresholds, prints the outcome and exits 3 on a failure
+	Check              bool
+	CheckMaxMissingPct float64
+	CheckMaxDuplicates int
+	// ExpectSchema is a schema.json (as written by --bundle) the columns must match
+	ExpectSchema string
+	// RulesPath is a YAML file of value rules per column (min, max, between, in, ...) checked against every row
+	RulesPath string
+	// Preset selects the null values, date layouts, checks and report sections of a domain preset
+	Preset string
+	// NullValues are cell values treated as missing, e.g. "NA" or "(not set)"
+	NullValues columnList
+	// Strict turns every data warning (ragged rows, coercions, duplicate headers, mixed types) into an error
+	Strict bool
+	// Explain prints the operations of the run with their row counts and timings on stderr at the end of the run
+	Explain bool
+	// StatsInternal prints load time, throughput, peak memory and per-phase timings on stderr at the end of the run
+	StatsInternal bool
+	// NonNegative and Between are quick range checks on numeric columns, evaluated as critical alert rules
+	NonNegative columnList
+	Between     RangeChecks
+	// UniqueKeys are the --unique combinations of columns that must not repeat, each a critical alert rule
+	UniqueKeys CompositeKeys
+	// NAPolicy decides how the missing values of numeric columns enter their statistics, globally and per column
+	NAPolicy NAPolicies
+	// ConfidenceLevel is the coverage of the confidence interval reported for the mean of numeric columns
+	ConfidenceLevel float64
+	// TrimFraction is the share of values cut from each end of a numeric column for its trimmed mean
+	TrimFraction float64
+	// GroupBy summarizes numeric columns per value of this column, with each group's deviation from the overall
+	// mean when GroupDeviation is set
+	GroupBy        string
+	GroupDeviation bool
+	// TemplatePath is a Go template file the report is rendered through instead of a --format
+	TemplatePath string
+	// SplitBy writes a report of the rows of every value of this column into SplitDir, with the rows themselves as
+	// CSV when SplitCSV is set
+	SplitBy  string
+	SplitDir string
+	SplitCSV bool
+	// TopN lists the N rows with the highest and lowest values of every numeric column, or of the TopBy columns,
+	// with the cells of the TopWith columns for context
+	TopN    int
+	TopBy   columnList
+	TopWith columnList
+	// FillMissing fills the missing values of columns in the copy of the dataset written to FillOutput
+	FillMissing FillRules
+	FillOutput  string
+	// TreatOutliers removes or winsorizes the values outside OutlierBounds in the copy written to OutlierOutput
+	TreatOutliers OutlierRules
+	OutlierBounds OutlierBounds
+	OutlierOutput string
+	// SplitOn and SplitDate compare numeric columns before and after a date of the SplitOn time column
+	SplitOn   string
+	SplitDate string
+	// ScanPII flags columns likely to contain personal data (e-mails, phone numbers, card numbers, national IDs)
+	ScanPII bool
+	// MissingMatrix adds a matrix of where in the file each column's missing values are to the report
+	MissingMatrix bool
+	// CoMissing reports the column pairs whose missing values fall in the same rows
+	CoMissing bool
+	// CompletenessTiers segments the rows into full, partial and mostly empty ones with per-tier means
+	CompletenessTiers bool
+	// Bootstrap is the number of resamples for bootstrap confidence intervals (0 disables them), drawn from BootstrapSeed
+	Bootstrap     int
+	BootstrapSeed int64
+	// Correlations adds the correlation matrix of the numeric columns to the report, drawn as a heatmap in the text
+	// report, and CorrelationPNG is where a PNG of the heatmap is written (empty disables it)
+	Correlations   bool
+	CorrelationPNG string
+	// ClusterColumns groups columns whose content is at least ClusterSimilarity alike
+	ClusterColumns    bool
+	ClusterSimilarity float64
+	// Charts adds terminal histograms and box plots of numeric columns to the text report
+	Charts bool
+	// ChartsDir is a directory SVG histograms and box plots of numeric columns are written to (empty disables them)
+	ChartsDir string
+	// HistogramBins adds the bin edges and counts of every numeric column's histogram to the report (0 disables them)
+	HistogramBins int
+	// AuditParsing lists the values of numeric columns that failed to parse and were left out of the statistics
+	AuditParsing bool
+	// TypeDetection chooses the rows column types are detected from
+	TypeDetection TypeDetection
+	// TypeOverrides forces the type of named columns instead of detecting it
+	TypeOverrides TypeOverrides
+	// QuasiIdentifiers are the columns a k-anonymity assessment groups rows by (empty disables it)
+	QuasiIdentifiers columnList
+	// KThreshold is the smallest acceptable equivalence class size in the k-anonymity assessment
+	KThreshold int
+	// Rolling computes windowed statistics of numeric columns along the RollingOn time column
+	Rolling   RollingWindow
+	RollingOn string
+	// Sampling estimates statistics from a random sample of rows instead of every row
+	Sampling SamplingOptions
+	// NoCache recomputes the report instead of serving it from the report cache (the new report is still cached)
+	NoCache bool
+	// MaxMemory caps the memory the rows take; beyond it they are spilled to disk and profiled in passes (0: no cap)
+	MaxMemory ByteSize
+	// Rows loads only a range of the data rows, seeking with the row index of the file when there is one
+	Rows RowSelection
+	// PrivacyEpsilon enables Laplace noise on published aggregates with this privacy budget per column (0 disables it)
+	PrivacyEpsilon float64
+	// Compress compresses the written reports and datasets ("gz"; empty writes them uncompressed)
+	Compress string
+	// BundlePath is a .tar.gz archive the report, schema and quarantine file of the run are packed into
+	BundlePath string
+	// GESuitePath is the file a Great Expectations suite is written to (empty disables the export)
+	GESuitePath string
+	// GESuiteName overrides the name of the exported suite
+	GESuiteName string
+	// BadgePath is where an SVG summary badge is written (empty disables it)
+	BadgePath string
+	// BadgeLabel is the text on the left half of the badge (default: the input file name)
+	BadgeLabel string
+	// Lineage configures OpenLineage event emission (disabled while Lineage.URL is empty)
+	Lineage LineageOptions
+}
+
+// newOptionsFlagSet registers all analyzer flags on a new FlagSet that writes into opts
+func newOptionsFlagSet(opts *Options) *flag.FlagSet {
+	// ContinueOnError lets main decide the exit code instead of the flag package.
+	fs := flag.NewFlagSet("csv-analyzer", flag.ContinueOnError)
+	fs.StringVar(&opts.Format, "format", FormatText, "report `format`: "+strings.Join(outputFormats, ", "))
+	fs.StringVar(&opts.CardsDir, "cards-dir", "", "with a card format, write one file per column card into `dir`")
+	fs.StringVar(&opts.ConfigPath, "config", "", "JSON configuration `file` with alert rules and suppressions")
+	fs.StringVar(&opts.AlertWebhook, "alert-webhook", "", "post failed alerts of every severity as JSON to this `url`")
+	fs.StringVar(&opts.DictionaryPath, "dictionary", "", "data dictionary `file` (CSV with column,description,unit or JSON) used to annotate reports")
+	// The progress bar is on by default whenever stderr is an interactive terminal.
+	fs.BoolVar(&opts.Progress, "progress", isTerminal(os.Stderr), "show a progress bar with ETA while loading (default on when stderr is a terminal)")
+	fs.StringVar(&opts.OutputLocale, "output-locale", "", "write the numbers and dates of the text report the way this `locale` does, e.g. de-DE for 1.299,990 and 31.12.2024")
+	fs.StringVar(&opts.Locale, "locale", "", "parse numbers like \"$1,299.99\" or \"€45,00\" using this `locale`'s separators, e.g. en-US, de-DE, fr-FR")
+	fs.StringVar(&opts.Encoding, "encoding", EncodingAuto, "character `encoding` of the input: auto, utf-8, utf-16le, utf-16be, latin1 or windows-1252")
+	fs.BoolVar(&opts.LazyQuotes, "lazy-quotes", false, "accept stray quotes inside CSV fields instead of failing to parse")
+	fs.StringVar(&opts.QuoteChar, "quote-char", `"`, "`character` that quotes CSV fields, e.g. \"'\"")
+	fs.StringVar(&opts.CommentChar, "comment-char", "", "skip CSV lines starting with this `character`, e.g. \"#\"")
+	fs.BoolVar(&opts.TrimLeadingSpace, "trim-leading-space", false, "ignore spaces and tabs before each CSV field")
+	fs.StringVar(&opts.Table, "table", "", "`name` of the table to analyze in a SQLite input (default: its only table), and of the table convert --to sqlite or sql writes (default: the input file name)")
+	fs.Var(&opts.Drop, "drop", "remove these comma-separated `columns` before analysis or export")
+	fs.Var(&opts.Renames, "rename", "rename columns before analysis or export, given as old=new `pairs`, e.g. \"Qty=Quantity,Cat=Category\"")
+	fs.Var(&opts.Recode, "recode", "map category values to a new label, as \"`column: value,value=label`\", e.g. \"Category: Kitchen,Accessories=Home\"; separate more groups with ; or repeat the flag")
+	fs.Var(&opts.Derive, "derive", "add a column computed by a date function, as name=`expression`: year(col), month(col), weekday(col) or datediff(end, start) in days, e.g. \"Age=datediff(today(), OrderDate)\"; may be repeated")
+	fs.BoolVar(&opts.Check, "check", false, "only check the data against the thresholds and alert rules: print the failures, no report, and exit with status 3 when any critical check fails")
+	fs.Float64Var(&opts.CheckMaxMissingPct, "max-missing-pct", defaultCheckMaxMissingPct, "with --check, the highest acceptable `percentage` of missing values in any column")
+	fs.IntVar(&opts.CheckMaxDuplicates, "max-duplicates", 0, "with --check, the highest acceptable `number` of duplicate rows")
+	fs.StringVar(&opts.ExpectSchema, "expect-schema", "", "check the columns, types and nullability against a schema.json `file` (as written by --bundle)")
+	fs.StringVar(&opts.RulesPath, "rules", "", "check every row against the value rules of a YAML `file`, e.g. \"Price: {min: 0}\"; any violation fails the run (status 3)")
+	fs.StringVar(&opts.Preset, "preset", "", "apply the null values, date formats, checks and report sections of a domain `preset`: "+strings.Join(presetNames(), ", "))
+	fs.Var(&opts.NullValues, "null-values", "treat these comma-separated cell `values` as missing, e.g. \"NA,N/A,(not set)\" (case-insensitive)")
+	fs.BoolVar(&opts.Strict, "strict", false, "treat data warnings (ragged rows, coercions, duplicate headers, mixed types) as errors and exit with status 4")
+	fs.BoolVar(&opts.Explain, "explain", false, "print the operation plan of the run (loading steps, group-by, segmentation, windows, splits) with per-step row counts and timings on stderr")
+	fs.BoolVar(&opts.StatsInternal, "stats-internal", false, "print per-phase timings, rows/sec throughput and peak memory on stderr when the run finishes")
+	fs.Var(&opts.UniqueKeys, "unique", "fail the run (status 3) when a combination of comma-separated `columns` repeats, e.g. \"OrderID,LineNo\"; may be repeated")
+	fs.Var(&opts.NonNegative, "nonnegative", "fail the run (status 3) when any value of these comma-separated numeric `columns` is negative")
+	fs.Var(&opts.Between, "between", "fail the run (status 3) when numeric columns leave their `ranges`, e.g. \"Rating=1..5,Price=0..\"")
+	fs.Var(&opts.NAPolicy, "na-policy", "how missing values enter numeric statistics: skip, zero or propagate (NaN), as a default and/or `column=policy` pairs, e.g. \"skip,Sales=zero\"")
+	fs.Float64Var(&opts.ConfidenceLevel, "confidence", defaultConfidenceLevel, "coverage `level` of the confidence interval of the mean of numeric columns, e.g. 0.99")
+	fs.Float64Var(&opts.TrimFraction, "trim", defaultTrimFraction, "`fraction` of values cut from each end of numeric columns for the trimmed mean, e.g. 0.1")
+	fs.StringVar(&opts.TemplatePath, "template", "", "render the report through the Go template `file` (html/template for .html files) instead of --format")
+	fs.StringVar(&opts.SplitBy, "split-by", "", "write a separate report for the rows of every value of this `column` into --split-dir")
+	fs.StringVar(&opts.SplitDir, "split-dir", "", "`dir` the --split-by reports are written to, with an index.csv of the segments")
+	fs.BoolVar(&opts.SplitCSV, "split-csv", false, "with --split-by, also write the rows of every segment as CSV")
+	fs.IntVar(&opts.TopN, "top", 0, "list the `N` rows with the highest and lowest values of every numeric column")
+	fs.Var(&opts.TopBy, "top-by", "rank only by these comma-separated numeric `columns` for --top")
+	fs.Var(&opts.TopWith, "top-with", "comma-separated `columns` shown next to the ranked rows of --top (default: the first text column)")
+	fs.StringVar(&opts.GroupBy, "group-by", "", "summarize numeric columns per value of this `column` (count and mean per group)")
+	fs.BoolVar(&opts.GroupDeviation, "group-deviation", false, "with --group-by, show each group's deviation from the overall mean, absolute and in %")
+	fs.Var(&opts.FillMissing, "fill-missing", "fill missing values per column, as `column=method` pairs: mean, median, mode, constant:<value> or ffill; needs --fill-output")
+	fs.StringVar(&opts.FillOutput, "fill-output", "", "write the dataset with --fill-missing applied to `file` as CSV")
+	fs.Var(&opts.TreatOutliers, "treat-outliers", "remove or winsorize the outliers of numeric columns, as `column=method` pairs: remove (drop the row) or winsorize (clip the value); needs --outlier-output")
+	fs.Var(&opts.OutlierBounds, "outlier-bounds", "`bounds` outside which --treat-outliers treats values: iqr (1.5 IQR beyond the quartiles) or percentiles such as 5:95")
+	fs.StringVar(&opts.OutlierOutput, "outlier-output", "", "write the dataset with --treat-outliers applied to `file` as CSV")
+	fs.StringVar(&opts.SplitOn, "split-on", "", "time `column` whose --split-date divides the rows into before and after periods")
+	fs.StringVar(&opts.SplitDate, "split-date", "", "compare numeric columns before and after this `date` (mean change, % change, Cohen's d); needs --split-on")
+	fs.BoolVar(&opts.MissingMatrix, "missing-matrix", false, "show where missing values cluster: the missing share of every column in each twentieth of the rows")
+	fs.BoolVar(&opts.CoMissing, "co-missing", false, "report which columns tend to be missing together (correlation of their missing-value indicators)")
+	fs.BoolVar(&opts.CompletenessTiers, "completeness-tiers", false, "segment rows into full, partial and mostly empty (under half the cells filled) with the mean of numeric columns per tier")
+	fs.BoolVar(&opts.ScanPII, "scan-pii", false, "flag columns likely to contain personal data (e-mails, phone numbers, card numbers, national IDs)")
+	fs.IntVar(&opts.Bootstrap, "bootstrap", 0, "estimate 95% confidence intervals of mean, median, p10 and p90 from this many bootstrap `iterations`, e.g. 1000")
+	fs.Int64Var(&opts.BootstrapSeed, "bootstrap-seed", 0, "random `seed` of the bootstrap resamples (default: time-based)")
+	fs.BoolVar(&opts.Correlations, "correlations", false, "add the Pearson correlation matrix of numeric columns to the report, as a heatmap (coloured on a terminal unless NO_COLOR is set) in the text report")
+	fs.StringVar(&opts.CorrelationPNG, "correlation-png", "", "write the correlation heatmap of numeric columns as a PNG image to `file`")
+	fs.BoolVar(&opts.ClusterColumns, "cluster-columns", false, "group columns with similar content (correlation for numeric, value overlap for text)")
+	fs.Float64Var(&opts.ClusterSimilarity, "cluster-similarity", defaultClusterSimilarity, "`similarity` between 0 and 1 at which --cluster-columns groups two columns")
+	fs.BoolVar(&opts.Charts, "charts", false, "draw a sparkline histogram and a box plot of every numeric column in the text report")
+	fs.StringVar(&opts.ChartsDir, "charts-dir", "", "write an SVG histogram and box plot of every numeric column into `dir`")
+	fs.IntVar(&opts.HistogramBins, "histogram-bins", 0, "add the edges and counts of a histogram with this many equal-width `bins` to every numeric column of the JSON report")
+	fs.BoolVar(&opts.AuditParsing, "audit-parsing", false, "list the values of numeric columns that failed to parse, with their row numbers")
+	opts.TypeDetection.Strategy = DetectHead
+	fs.Var(&opts.TypeDetection, "type-sample", "rows to detect column types from: `strategy` head, random, spread (evenly across the file), every:N or all")
+	fs.IntVar(&opts.TypeDetection.Rows, "type-sample-rows", typeDetectionRows, "number of `rows` inspected by head, random and spread type detection")
+	fs.Float64Var(&opts.TypeDetection.Threshold, "type-threshold", 1, "`share` of the inspected non-empty values that must be numbers for a column to be numeric, e.g. 0.95; the others are reported as coercion warnings")
+	fs.Var(&opts.TypeOverrides, "types", "force column `types` instead of detecting them, e.g. \"ZipCode:string,OrderDate:date,Price:float\" (string, float, int, bool, date)")
+	fs.Var(&opts.QuasiIdentifiers, "quasi-identifiers", "assess k-anonymity over these comma-separated `columns`, e.g. \"Age,ZipCode,Gender\"")
+	fs.IntVar(&opts.KThreshold, "k-threshold", defaultKThreshold, "report rows in equivalence classes smaller than `k`")
+	fs.Var(&opts.Rolling, "rolling", "compute rolling mean/sum/min/max over a `window` of rows (7) or time (7d, 12h); needs --on")
+	fs.StringVar(&opts.RollingOn, "on", "", "time `column` that orders rows for --rolling")
+	fs.IntVar(&opts.Sampling.Size, "sample", 0, "estimate statistics from a uniform random sample of `N` rows (reservoir sampling)")
+	fs.Float64Var(&opts.Sampling.Fraction, "sample-frac", 0, "estimate statistics from a random `fraction` of rows, e.g. 0.01")
+	fs.Int64Var(&opts.Sampling.Seed, "sample-seed", 0, "random `seed` for --sample/--sample-frac (default: time-based)")
+	fs.BoolVar(&opts.NoCache, "no-cache", false, "bypass the report cache, recomputing the report without looking it up or storing it")
+	fs.Var(&opts.MaxMemory, "max-memory", "cap the memory the rows take at `size` (e.g. 512MB, 2GiB); beyond it they are spilled to temporary files and profiled a few columns at a time")
+	fs.Var(&opts.Rows, "rows", "load only these data `rows`: START:END (from 1, inclusive), :END (the first rows), START: or -N (the last N); seeks with the row index of the index subcommand when there is one")
+	fs.Float64Var(&opts.PrivacyEpsilon, "dp-epsilon", 0, "add differential-privacy (Laplace) noise to published statistics with privacy budget `epsilon` per column; smaller is more private")
+	fs.StringVar(&opts.Compress, "compress", "", "compress reports, cards, suites and converted data with `method` gz (files get a .gz suffix)")
+	fs.StringVar(&opts.BundlePath, "bundle", "", "pack the JSON report, schema and quarantined rows into a .tar.gz `file`")
+	fs.StringVar(&opts.GESuitePath, "ge-suite", "", "write a Great Expectations expectation suite (JSON) to `file`")
+	fs.StringVar(&opts.GESuiteName, "ge-suite-name", "", "`name` of the exported expectation suite (default: <file>.warning)")
+	fs.StringVar(&opts.BadgePath, "badge", "", "write an SVG badge with rows, quality score and pass/fail to `file`")
+	fs.StringVar(&opts.BadgeLabel, "badge-label", "", "`text` on the left of the badge (default: input file name)")
+	// OpenLineage settings default to the environment variables used by the official clients.
+	fs.StringVar(&opts.Lineage.URL, "openlineage-url", os.Getenv("OPENLINEAGE_URL"), "emit OpenLineage START, COMPLETE and FAIL run events to this `url` (env OPENLINEAGE_URL)")
+	fs.StringVar(&opts.Lineage.Namespace, "openlineage-namespace", envOrDefault("OPENLINEAGE_NAMESPACE", "csv-analyzer"), "OpenLineage job `namespace` (env OPENLINEAGE_NAMESPACE)")
+	fs.StringVar(&opts.Lineage.Job, "openlineage-job", "csv-analyzer.profile", "OpenLineage job `name`")
+	// The API key is only read from the environment so it never shows up in process listings.
+	opts.Lineage.APIKey = os.Getenv("OPENLINEAGE_API_KEY")
+
+	// Prints the usage lines followed by every registered flag.
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . [options] <csv-file>")
+		fmt.Fprintln(os.Stderr, "Or: go run . [options] sample  (to create and analyze sample data)")
+		fmt.Fprintln(os.Stderr, "Or: go run . sample --stratify <column> [--n rows] [options] <csv-file>  (to write a stratified sample)")
+		fmt.Fprintln(os.Stderr, "Or: go run . version [--json]  (to show the version, commit, build date and features)")
+		fmt.Fprintln(os.Stderr, "Or: go run . capabilities [--json]  (to list supported formats, statistics and limits)")
+		fmt.Fprintln(os.Stderr, "Or: go run . self-update [--check]  (to install the latest signed release)")
+		fmt.Fprintln(os.Stderr, "Or: go run . check-refs --key <column=referenced_column> <csv-file> <referenced-csv-file>  (to find orphan foreign keys)")
+		fmt.Fprintln(os.Stderr, "Or: go run . serve [--addr :8080]  (to analyze uploads over HTTP, with /healthz and /readyz)")
+		fmt.Fprintln(os.Stderr, "Or: go run . mask --columns <column=method,...> [options] <csv-file>  (to hash, redact, fake or shuffle sensitive columns)")
+		fmt.Fprintln(os.Stderr, "Or: go run . drift [options] <reference-csv-file> <current-csv-file>  (to detect distribution drift between two versions)")
+		fmt.Fprintln(os.Stderr, "Or: go run . dashboard [options] <csv-file>  (to explore the columns, histograms and rows in a full-screen terminal view)")
+		fmt.Fprintln(os.Stderr, "Or: go run . index [options] <csv-file>  (to build the row index that lets --rows seek instead of reading from the start)")
+		fmt.Fprintln(os.Stderr, "Or: go run . rank-features --target <column> [--json] [options] <csv-file>  (to rank the columns by their relationship with a target)")
+		fmt.Fprintln(os.Stderr, "Or: go run . concat [-o file] [options] <csv-file>...  (to union files whose columns differ in order or overlap only partly)")
+		fmt.Fprintln(os.Stderr, "Or: go run . chi-square --columns <column,column> [--bins 5] [--json] [options] <csv-file>  (to test two columns for independence)")
+		fmt.Fprintln(os.Stderr, "Or: go run . compare-groups --group <column> --value <column> [--groups a,b] [--json] [options] <csv-file>  (to compare a numeric column between two groups)")
+		fmt.Fprintln(os.Stderr, "Or: go run . watch [--every 5m] [--reports-dir dir] [--once] [options] <directory>  (to analyze new and changed CSV files as they arrive)")
+		fmt.Fprintln(os.Stderr, "Or: go run . generate [--rows n] [--columns n] [--types mix] [--null-rate p] [--seed seed]  (to write a synthetic CSV for benchmarks and tests)")
+		fmt.Fprintln(os.Stderr, "Or: go run . convert [--to json|jsonl|arrow|sqlite|sql] [options] <csv-file>  (to convert the data to JSON, Apache Arrow, SQLite or SQL statements)")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseInterspersed parses flags that may appear before, between or after positional arguments.
+// The standard flag package stops at the first positional argument, so we keep re-parsing the remainder.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	// Collects the positional arguments in the order they appear.
+	var positional []string
+	for {
+		// Parses flags up to the next positional argument.
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		// Stops once there is nothing left after the flags.
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		// Records the positional argument and continues parsing what follows it.
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// hasFlag reports whether args contain the named flag in any of the forms the flag package accepts
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		trimmed := strings.TrimLeft(arg, "-")
+		if strings.HasPrefix(arg, "-") && (trimmed == name || strings.HasPrefix(trimmed, name+"=")) {
+			return true
+		}
+	}
+	return false
+}
+
+// envOrDefault returns the value of an environment variable, or fallback when it is unset or empty
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// validate checks option combinations that the flag package cannot express on its own
+func (opts *Options) validate() error {
+	// The preset must be one we ship; its report sections are turned on here so every command sees them.
+	if err := opts.applyPreset(); err != nil {
+		return err
+	}
+	// The number locale must be one we know the separators of.
+	if _, err := lookupNumberLocale(opts.Locale); err != nil {
+		return err
+	}
+	// So must the locale the report is written in.
+	if _, err := lookupNumberLocale(opts.OutputLocale); err != nil {
+		return fmt.Errorf("--output-locale: %v", err)
+	}
+	if _, err := lookupEncoding(opts.Encoding); err != nil {
+		return err
+	}
+	if _, _, err := opts.csvDialect(); err != nil {
+		return err
+	}
+	// Rolling windows need a time column to order the rows by, and vice versa.
+	if opts.Rolling.enabled() != (opts.RollingOn != "") {
+		return fmt.Errorf("--rolling and --on must be used together")
+	}
+	if (len(opts.FillMissing) > 0) != (opts.FillOutput != "") {
+		return fmt.Errorf("--fill-missing and --fill-output must be used together")
+	}
+	if (len(opts.TreatOutliers) > 0) != (opts.OutlierOutput != "") {
+		return fmt.Errorf("--treat-outliers and --outlier-output must be used together")
+	}
+	// A before/after comparison needs both the time column and a date that parses.
+	if (opts.SplitOn != "") != (opts.SplitDate != "") {
+		return fmt.Errorf("--split-on and --split-date must be used together")
+	}
+	if _, ok := parseDate(opts.SplitDate); opts.SplitDate != "" && !ok {
+		return fmt.Errorf("invalid --split-date %q (expected a date such as 2024-06-01)", opts.SplitDate)
+	}
+	if opts.GroupDeviation && opts.GroupBy == "" {
+		return fmt.Errorf("--group-deviation needs --group-by")
+	}
+	if opts.Compress != "" && opts.Compress != CompressGzip {
+		return fmt.Errorf("unknown --compress method %q (expected gz)", opts.Compress)
+	}
+	if opts.TypeDetection.Threshold <= 0.5 || opts.TypeDetection.Threshold > 1 {
+		return fmt.Errorf("--type-threshold must be above 0.5 and at most 1")
+	}
+	if opts.TypeDetection.Rows < 1 {
+		return fmt.Errorf("--type-sample-rows must be at least 1")
+	}
+	if opts.TrimFraction < 0 || opts.TrimFraction >= 0.5 {
+		return fmt.Errorf("--trim must be at least 0 and below 0.5")
+	}
+	if opts.ConfidenceLevel <= 0 || opts.ConfidenceLevel >= 1 {
+		return fmt.Errorf("--confidence must be above 0 and below 1")
+	}
+	if opts.HistogramBins < 0 {
+		return fmt.Errorf("--histogram-bins must be positive")
+	}
+	if opts.Bootstrap < 0 {
+		return fmt.Errorf("--bootstrap must be positive")
+	}
+	if opts.ClusterSimilarity <= 0 || opts.ClusterSimilarity > 1 {
+		return fmt.Errorf("--cluster-similarity must be above 0 and at most 1")
+	}
+	if opts.KThreshold < 1 {
+		return fmt.Errorf("--k-threshold must be at least 1")
+	}
+	if opts.PrivacyEpsilon < 0 {
+		return fmt.Errorf("--dp-epsilon must be positive")
+	}
+	if opts.CheckMaxMissingPct < 0 || opts.CheckMaxMissingPct > 100 {
+		return fmt.Errorf("--max-missing-pct must be between 0 and 100")
+	}
+	if opts.CheckMaxDuplicates < 0 {
+		return fmt.Errorf("--max-duplicates must not be negative")
+	}
+	if opts.Check && opts.Format != FormatText && opts.Format != FormatJSON {
+		return fmt.Errorf("--check prints text or JSON (--format json), not %s", opts.Format)
+	}
+	// Only one sampling mode can be active at a time.
+	if opts.Sampling.Size > 0 && opts.Sampling.Fraction > 0 {
+		return fmt.Errorf("--sample and --sample-frac cannot be combined")
+	}
+	if opts.Sampling.Size < 0 {
+		return fmt.Errorf("--sample must be positive")
+	}
+	if opts.Sampling.Fraction < 0 || opts.Sampling.Fraction > 1 {
+		return fmt.Errorf("--sample-frac must be between 0 and 1")
+	}
+	if opts.TemplatePath != "" && opts.Format != FormatText {
+		return fmt.Errorf("--template replaces the report format and cannot be combined with --format %s", opts.Format)
+	}
+	if (opts.SplitBy != "") != (opts.SplitDir != "") {
+		return fmt.Errorf("--split-by and --split-dir must be given together")
+	}
+	if opts.SplitCSV && opts.SplitBy == "" {
+		return fmt.Errorf("--split-csv needs --split-by")
+	}
+	if opts.SplitBy != "" && opts.PrivacyEpsilon > 0 {
+		return fmt.Errorf("--split-by cannot be combined with --dp-epsilon: the segment files are named after the values and small segments reveal single rows")
+	}
+	if opts.TopN < 0 {
+		return fmt.Errorf("--top must not be negative")
+	}
+	if (len(opts.TopBy) > 0 || len(opts.TopWith) > 0) && opts.TopN == 0 {
+		return fmt.Errorf("--top-by and --top-with need --top")
+	}
+	if opts.MaxMemory != 0 && opts.MaxMemory < minMaxMemory {
+		return fmt.Errorf("--max-memory must be at least %s", ByteSize(minMaxMemory))
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// defaultClusterSimilarity is the similarity at which two columns are put in the same cluster
+const defaultClusterSimilarity = 0.9
+
+// minClusterPairs is how many rows with a value in both columns a correlation needs to count
+const minClusterPairs = 3
+
+// ColumnCluster is a group of columns with closely related content
+type ColumnCluster struct {
+	Kind    string   `json:"kind"` // "numeric" (correlation) or "text" (value overlap)
+	Columns []string `json:"columns"`
+	// Similarity is the weakest link that holds the cluster together
+	Similarity float64 `json:"similarity"`
+}
+
+// columnLink is the similarity of two columns
+type columnLink struct {
+	a, b       int
+	similarity float64
+}
+
+// pearson returns the absolute correlation of two columns over the rows where both have a value, and false when
+// there are too few such rows or either column is constant on them
+func pearson(x, y []float64) (float64, bool) {
+	r, _, ok := correlation(x, y)
+	return math.Abs(r), ok
+}
+
+// correlation returns the signed Pearson correlation of two aligned columns and the number of rows where both have
+// a value (NaN marks a missing one); ok is false when there are too few such rows or either column is constant
+func correlation(x, y []float64) (r float64, pairs int, ok bool) {
+	var n, sumX, sumY, sumXX, sumYY, sumXY float64
+	for i := range x {
+		if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+			continue
+		}
+		n++
+		sumX += x[i]
+		sumY += y[i]
+		sumXX += x[i] * x[i]
+		sumYY += y[i] * y[i]
+		sumXY += x[i] * y[i]
+	}
+	if n < minClusterPairs {
+		return 0, int(n), false
+	}
+	covariance := sumXY - sumX*sumY/n
+	varianceX, varianceY := sumXX-sumX*sumX/n, sumYY-sumY*sumY/n
+	if varianceX <= 0 || varianceY <= 0 {
+		return 0, int(n), false
+	}
+	return math.Max(-1, math.Min(1, covariance/math.Sqrt(varianceX*varianceY))), int(n), true
+}
+
+// jaccard returns the share of distinct values two sets have in common
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for value := range a {
+		if b[value] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// The ColumnClusters method is part of the CSVAnalyzer struct. Machine-generated exports often have hundreds of
+// columns of which many are copies, unit conversions or near-duplicates of each other. This method compares every
+// pair of numeric columns by the absolute Pearson correlation of their values and every pair of text columns by the
+// overlap (Jaccard index) of their distinct values, then joins columns whose similarity reaches the threshold into
+// clusters (single linkage: a column joins a cluster when it is similar enough to any member). Only clusters of two
+// or more columns are returned, largest first. Comparing every pair costs time quadratic in the number of columns.
+// ColumnClusters groups the columns with similar content, or returns nil when clustering was not requested
+func (ca *CSVAnalyzer) ColumnClusters() []ColumnCluster {
+	if !ca.options.ClusterColumns {
+		return nil
+	}
+	threshold := ca.options.ClusterSimilarity
+
+	// Extracts every column's content once: aligned values for numeric columns, value sets for text columns.
+	var numericCols, textCols []int
+	values := make(map[int][]float64)
+	valueSets := make(map[int]map[string]bool)
+	for colIndex := range ca.dataset.Headers {
+		switch ca.columnType(colIndex) {
+		case TypeNumeric:
+			numericCols = append(numericCols, colIndex)
+			values[colIndex] = ca.alignedNumericValues(colIndex)
+		case TypeText:
+			textCols = append(textCols, colIndex)
+			valueSets[colIndex] = make(map[string]bool)
+			for _, value := range ca.extractUniqueValues(colIndex) {
+				valueSets[colIndex][value] = true
+			}
+		}
+	}
+
+	var clusters []ColumnCluster
+	clusters = append(clusters, ca.linkColumns("numeric", numericCols, threshold, func(a, b int) (float64, bool) {
+		return pearson(values[a], values[b])
+	})...)
+	clusters = append(clusters, ca.linkColumns("text", textCols, threshold, func(a, b int) (float64, bool) {
+		return jaccard(valueSets[a], valueSets[b]), true
+	})...)
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Columns) > len(clusters[j].Columns)
+	})
+	return clusters
+}
+
+// alignedNumericValues returns one value per row of a numeric column, NaN where the cell is empty or not a number
+func (ca *CSVAnalyzer) alignedNumericValues(colIndex int) []float64 {
+	aligned := make([]float64, len(ca.dataset.Rows))
+	for rowIndex, row := range ca.dataset.Rows {
+		aligned[rowIndex] = math.NaN()
+		if colIndex < len(row) {
+			if num, err := ca.parseNumber(strings.TrimSpace(row[colIndex])); err == nil {
+				aligned[rowIndex] = num
+			}
+		}
+	}
+	return aligned
+}
+
+// linkColumns clusters the given columns by single linkage over the pairs at or above the threshold
+func (ca *CSVAnalyzer) linkColumns(kind string, columns []int, threshold float64, similarity func(a, b int) (float64, bool)) []ColumnCluster {
+	// Collects the strong pairs, strongest first, so each cluster's weakest link is the last one that joined it.
+	var links []columnLink
+	for i, a := range columns {
+		for _, b := range columns[i+1:] {
+			if s, ok := similarity(a, b); ok && s >= threshold {
+				links = append(links, columnLink{a: a, b: b, similarity: s})
+			}
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool { return links[i].similarity > links[j].similarity })
+
+	// Merges the linked columns with a union-find forest.
+	parent := make(map[int]int)
+	var find func(int) int
+	find = func(c int) int {
+		if p, ok := parent[c]; ok && p != c {
+			parent[c] = find(p)
+			return parent[c]
+		}
+		return c
+	}
+	weakest := make(map[int]float64)
+	for _, link := range links {
+		rootA, rootB := find(link.a), find(link.b)
+		if rootA == rootB {
+			continue
+		}
+		parent[rootB] = rootA
+		weakest[rootA] = link.similarity
+	}
+
+	// Gathers the members of every cluster in header order.
+	members := make(map[int][]int)
+	var roots []int
+	for _, c := range columns {
+		root := find(c)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], c)
+	}
+	var clusters []ColumnCluster
+	for _, root := range roots {
+		if len(members[root]) < 2 {
+			continue
+		}
+		cluster := ColumnCluster{Kind: kind, Similarity: weakest[root]}
+		for _, c := range members[root] {
+			cluster.Columns = append(cluster.Columns, ca.dataset.Headers[c])
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// printColumnClusters shows the groups of similar columns in the text report
+func printColumnClusters(clusters []ColumnCluster, enabled bool) {
+	if !enabled {
+		return
+	}
+	fmt.Println("\n\nSimilar Column Groups:")
+	fmt.Println("----------------------")
+	if len(clusters) == 0 {
+		fmt.Println("  No columns are similar enough to group.")
+		return
+	}
+	for i, cluster := range clusters {
+		measure := "correlation"
+		if cluster.Kind == "text" {
+			measure = "value overlap"
+		}
+		fmt.Printf("  Group %d (%s, %s >= %.2f): %s\n", i+1, cluster.Kind, measure, cluster.Similarity, strings.Join(cluster.Columns, ", "))
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Completeness tiers of rows
+const (
+	TierFull        = "full"
+	TierPartial     = "partial"
+	TierMostlyEmpty = "mostly_empty"
+)
+
+// mostlyEmptyFilledShare is the share of filled cells below which a row counts as mostly empty
+const mostlyEmptyFilledShare = 0.5
+
+// CompletenessTier counts the rows of one completeness tier
+type CompletenessTier struct {
+	Tier  string  `json:"tier"`
+	Rows  int     `json:"rows"`
+	Share float64 `json:"share"` // fraction of all rows
+}
+
+// CompletenessReport segments the rows by how many of their cells are filled
+type CompletenessReport struct {
+	Tiers []CompletenessTier `json:"tiers"`
+	// Columns holds the mean of every numeric column within each tier, with its deviation from the overall mean
+	Columns []GroupByColumn `json:"columns"`
+}
+
+// completenessTier names the tier of a row from its filled cells; cells missing from ragged rows count as empty
+func completenessTier(row []string, columns int) string {
+	filled := 0
+	for i := 0; i < columns && i < len(row); i++ {
+		if strings.TrimSpace(row[i]) != "" {
+			filled++
+		}
+	}
+	switch {
+	case filled == columns:
+		return TierFull
+	case float64(filled) < mostlyEmptyFilledShare*float64(columns):
+		return TierMostlyEmpty
+	default:
+		return TierPartial
+	}
+}
+
+// The CompletenessTiers method is part of the CSVAnalyzer struct. It segments the rows into completeness tiers: full
+// rows have every cell filled, mostly empty rows fewer than half of them and partial rows everything in between. Next
+// to the row counts it gives the mean of every numeric column per tier, with the deviation from the overall mean, so
+// placeholder rows that drag the averages are visible before they are filtered out. Tier figures of small tiers reveal
+// individual rows, so they are withheld under differential privacy.
+// CompletenessTiers returns the completeness segmentation, or nil when it was not requested
+func (ca *CSVAnalyzer) CompletenessTiers() *CompletenessReport {
+	if !ca.options.CompletenessTiers || ca.privacy != nil {
+		return nil
+	}
+	endStep := ca.explain.stepOnce("segment", "completeness tiers", len(ca.dataset.Rows))
+	columns := len(ca.dataset.Headers)
+	groupOf := func(row []string) string {
+		return completenessTier(row, columns)
+	}
+	counts := make(map[string]int)
+	for _, row := range ca.dataset.Rows {
+		counts[groupOf(row)]++
+	}
+	tiers := []string{TierFull, TierPartial, TierMostlyEmpty}
+	report := &CompletenessReport{}
+	for _, tier := range tiers {
+		share := 0.0
+		if len(ca.dataset.Rows) > 0 {
+			share = float64(counts[tier]) / float64(len(ca.dataset.Rows))
+		}
+		report.Tiers = append(report.Tiers, CompletenessTier{Tier: tier, Rows: counts[tier], Share: share})
+	}
+	report.Columns = ca.groupNumericColumns(groupOf, tiers, -1, true)
+	endStep(len(tiers))
+	return report
+}
+
+// printCompletenessTiers shows the completeness tiers and the per-tier means in the text report
+func printCompletenessTiers(report *CompletenessReport) {
+	if report == nil {
+		return
+	}
+	fmt.Println("\n\nRows by Completeness:")
+	fmt.Println("---------------------")
+	for _, tier := range report.Tiers {
+		fmt.Printf("  %-24s %8d rows  (%.1f%%)\n", tier.Tier, tier.Rows, 100*tier.Share)
+	}
+	printGroupColumns(report.Columns)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// CompressGzip is the only --compress method; it is the one every archive and data tool understands
+const CompressGzip = "gz"
+
+// compressedPath returns the name a file is written under with the given compression, adding ".gz" when needed
+func compressedPath(path, method string) string {
+	if method == CompressGzip && !strings.HasSuffix(path, ".gz") {
+		return path + ".gz"
+	}
+	return path
+}
+
+// compressedWriter closes the compressor before the file below it, so the compressed stream is complete on disk
+type compressedWriter struct {
+	io.WriteCloser
+	file io.Closer
+}
+
+// Close flushes the compressor and then closes the underlying file, reporting the first error
+func (w compressedWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// nopWriteCloser lets an uncompressed writer such as stdout be used where a WriteCloser is expected
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopWriteCloser) Close() error { return nil }
+
+// compressWriter wraps w so everything written is compressed with method ("" writes it unchanged); closing the
+// result finishes the compressed stream but leaves w open
+func compressWriter(w io.Writer, method string) io.WriteCloser {
+	if method == CompressGzip {
+		return gzip.NewWriter(w)
+	}
+	return nopWriteCloser{w}
+}
+
+// createOutputFile creates the file at compressedPath(path, method) and returns a writer that compresses into it
+func createOutputFile(path, method string) (io.WriteCloser, error) {
+	file, err := os.Create(compressedPath(path, method))
+	if err != nil {
+		return nil, err
+	}
+	if method == "" {
+		return file, nil
+	}
+	return compressedWriter{WriteCloser: compressWriter(file, method), file: file}, nil
+}
+
+// writeOutputFile writes data to compressedPath(path, method), compressing it with method
+func writeOutputFile(path, method string, data []byte) error {
+	w, err := createOutputFile(path, method)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ConcatFile describes how the columns of one input file line up with the combined columns
+type ConcatFile struct {
+	Path      string   `json:"path"`
+	Rows      int      `json:"rows"`
+	Missing   []string `json:"missing_columns,omitempty"` // combined columns the file lacks, filled in its rows
+	Added     []string `json:"added_columns,omitempty"`   // columns the earlier files did not have
+	Reordered bool     `json:"reordered,omitempty"`       // the shared columns come in another order than in the output
+}
+
+// ConcatTypeConflict is a column whose detected type differs between the files that have values in it
+type ConcatTypeConflict struct {
+	Column string            `json:"column"`
+	Types  map[string]string `json:"types"` // keyed by file
+}
+
+// ConcatReport is the outcome of a concat: the combined columns and every difference between the file schemas
+type ConcatReport struct {
+	Columns       []string             `json:"columns"`
+	Rows          int                  `json:"rows"`
+	Files         []ConcatFile         `json:"files"`
+	TypeConflicts []ConcatTypeConflict `json:"type_conflicts,omitempty"`
+	// SimilarColumns are names that differ only in case, spaces or underscores; they are probably one column, but
+	// are kept apart since that is a guess
+	SimilarColumns [][]string `json:"similar_columns,omitempty"`
+	Mismatched     bool       `json:"mismatched"`
+	// mapping[file][column] is the index of a combined column in that file's rows, or -1 when the file lacks it
+	mapping [][]int
+}
+
+// concatKey identifies a column across files by its trimmed name; a name repeated in a header is matched by its
+// occurrence, so the second "Note" of one file lines up with the second "Note" of another
+func concatKey(headers []string, colIndex int) string {
+	name := strings.TrimSpace(headers[colIndex])
+	occurrence := 0
+	for _, header := range headers[:colIndex] {
+		if strings.TrimSpace(header) == name {
+			occurrence++
+		}
+	}
+	return fmt.Sprintf("%s\x00%d", name, occurrence)
+}
+
+// similarityKey folds a column name to the form in which near-identical names compare equal
+func similarityKey(name string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// The Concat function lines up the columns of several loaded files by header name. The combined columns are those
+// of the first file followed by every column a later file adds, in the order they first appear. For each file it
+// records the columns it lacks, the columns it adds and whether its shared columns come in another order, and
+// across files the columns whose detected types disagree and the names that look like spellings of one column.
+// Concat reconciles the schemas of the files for writeConcat
+func Concat(parts []*CSVAnalyzer, paths []string) *ConcatReport {
+	report := &ConcatReport{}
+	position := make(map[string]int)
+	var keys []string
+	for _, part := range parts {
+		for colIndex, header := range part.dataset.Headers {
+			key := concatKey(part.dataset.Headers, colIndex)
+			if _, ok := position[key]; !ok {
+				position[key] = len(report.Columns)
+				keys = append(keys, key)
+				report.Columns = append(report.Columns, header)
+			}
+		}
+	}
+
+	for i, part := range parts {
+		file := ConcatFile{Path: paths[i], Rows: len(part.dataset.Rows)}
+		mapping := make([]int, len(report.Columns))
+		for j := range mapping {
+			mapping[j] = -1
+		}
+		last := -1
+		for colIndex := range part.dataset.Headers {
+			column := position[concatKey(part.dataset.Headers, colIndex)]
+			mapping[column] = colIndex
+			if column < last {
+				file.Reordered = true
+			}
+			last = column
+		}
+		for column, source := range mapping {
+			switch {
+			case source < 0:
+				file.Missing = append(file.Missing, report.Columns[column])
+			case i > 0 && !seenBefore(report.mapping, column):
+				file.Added = append(file.Added, report.Columns[column])
+			}
+		}
+		report.mapping = append(report.mapping, mapping)
+		report.Files = append(report.Files, file)
+		report.Rows += file.Rows
+		report.Mismatched = report.Mismatched || len(file.Missing) > 0 || len(file.Added) > 0 || file.Reordered
+	}
+
+	// Columns without values have no meaningful type, so they are left out of the comparison.
+	for column := range report.Columns {
+		types := make(map[string]string)
+		distinct := make(map[string]bool)
+		for i, part := range parts {
+			if source := report.mapping[i][column]; source >= 0 && part.countNonEmptyValues(source) > 0 {
+				types[paths[i]] = part.columnTypeName(source)
+				distinct[types[paths[i]]] = true
+			}
+		}
+		if len(distinct) > 1 {
+			report.TypeConflicts = append(report.TypeConflicts, ConcatTypeConflict{Column: report.Columns[column], Types: types})
+		}
+	}
+	similar := make(map[string][]string)
+	var order []string
+	for column, header := range report.Columns {
+		key := similarityKey(header)
+		if strings.HasSuffix(keys[column], "\x000") {
+			if similar[key] == nil {
+				order = append(order, key)
+			}
+			similar[key] = append(similar[key], header)
+		}
+	}
+	for _, key := range order {
+		if len(similar[key]) > 1 {
+			report.SimilarColumns = append(report.SimilarColumns, similar[key])
+		}
+	}
+	report.Mismatched = report.Mismatched || len(report.TypeConflicts) > 0 || len(report.SimilarColumns) > 0
+	return report
+}
+
+// seenBefore reports whether any earlier file has a combined column
+func seenBefore(mappings [][]int, column int) bool {
+	for _, mapping := range mappings {
+		if mapping[column] >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// The writeConcat method of ConcatReport writes the combined data as CSV: the combined header, then the rows of
+// every file in order with their cells moved to the combined columns and fill in the columns the file lacks or the
+// row is too short for. With a non-empty sourceColumn, a last column of that name holds the file of each row.
+// writeConcat writes the rows of all files under the combined header
+func (report *ConcatReport) writeConcat(w io.Writer, parts []*CSVAnalyzer, fill, sourceColumn string) error {
+	writer := csv.NewWriter(w)
+	header := append([]string(nil), report.Columns...)
+	if sourceColumn != "" {
+		header = append(header, sourceColumn)
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("error writing CSV: %v", err)
+	}
+	record := make([]string, len(header))
+	for i, part := range parts {
+		for _, row := range part.dataset.Rows {
+			for column, source := range report.mapping[i] {
+				record[column] = fill
+				if source >= 0 && source < len(row) {
+					record[column] = row[source]
+				}
+			}
+			if sourceColumn != "" {
+				record[len(record)-1] = report.Files[i].Path
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("error writing CSV: %v", err)
+			}
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing CSV: %v", err)
+	}
+	return nil
+}
+
+// printConcatReport shows the combined shape and the schema differences of a concat
+func printConcatReport(w io.Writer, report *ConcatReport) {
+	fmt.Fprintf(w, "Concatenated %d files into %d rows and %d columns:\n", len(report.Files), report.Rows, len(report.Columns))
+	for _, file := range report.Files {
+		var notes []string
+		if len(file.Missing) > 0 {
+			notes = append(notes, "missing "+strings.Join(file.Missing, ", ")+" (filled)")
+		}
+		if len(file.Added) > 0 {
+			notes = append(notes, "adds "+strings.Join(file.Added, ", "))
+		}
+		if file.Reordered {
+			notes = append(notes, "columns in another order")
+		}
+		line := fmt.Sprintf("  %s: %d rows", file.Path, file.Rows)
+		if len(notes) > 0 {
+			line += ", " + strings.Join(notes, "; ")
+		}
+		fmt.Fprintln(w, line)
+	}
+	for _, conflict := range report.TypeConflicts {
+		files := make([]string, 0, len(conflict.Types))
+		for file := range conflict.Types {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		var types []string
+		for _, file := range files {
+			types = append(types, conflict.Types[file]+" in "+file)
+		}
+		fmt.Fprintf(w, "  Type conflict in %s: %s\n", conflict.Column, strings.Join(types, ", "))
+	}
+	for _, names := range report.SimilarColumns {
+		fmt.Fprintf(w, "  Similar column names kept apart: %s\n", strings.Join(names, ", "))
+	}
+	if report.Mismatched {
+		fmt.Fprintln(w, "Schema: the files differ")
+	} else {
+		fmt.Fprintln(w, "Schema: identical in every file")
+	}
+}
+
+// runConcat implements the concat subcommand, which unions files whose columns differ into one CSV file
+func runConcat(args []string) {
+	// Reuses the analyzer flags so --types, --drop, --rename and the other loading options apply to every file.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	output := fs.String("output", "", "write the combined data to `file` instead of stdout")
+	fs.StringVar(output, "o", "", "shorthand for --output")
+	fill := fs.String("fill", "", "`value` written in the cells of columns a file does not have (default: empty)")
+	sourceColumn := fs.String("source-column", "", "add a last column of this `name` holding the file each row came from")
+	asJSON := fs.Bool("json", false, "write the schema report to stderr as JSON")
+	failOnMismatch := fs.Bool("fail-on-mismatch", false, "exit with status 3 when the file schemas differ")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . concat [-o file] [--fill value] [--source-column name] [--json] [--fail-on-mismatch] [options] <file>...")
+		fmt.Fprintln(os.Stderr, "\nColumns are matched by header name, so files may order them differently or have only some of them;")
+		fmt.Fprintln(os.Stderr, "the schema differences are reported on stderr.")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+
+	// Glob patterns are expanded so every matching file is reconciled on its own.
+	var paths []string
+	for _, pattern := range positional {
+		matches, err := expandInputPattern(pattern)
+		if err != nil {
+			log.Fatal("Error loading CSV:", err)
+		}
+		paths = append(paths, matches...)
+	}
+	parts := make([]*CSVAnalyzer, len(paths))
+	for i, path := range paths {
+		parts[i] = NewCSVAnalyzerWithOptions(*opts)
+		if err := parts[i].LoadCSV(path); err != nil {
+			log.Fatal("Error loading CSV:", err)
+		}
+		parts[i].enforceStrict()
+	}
+	report := Concat(parts, paths)
+
+	// Writes the combined rows, compressed when --compress is set.
+	w := compressWriter(os.Stdout, opts.Compress)
+	if *output != "" {
+		if w, err = createOutputFile(*output, opts.Compress); err != nil {
+			log.Fatal("Error creating output file:", err)
+		}
+	}
+	err = report.writeConcat(w, parts, *fill, *sourceColumn)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal("Error writing combined data:", err)
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stderr)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal("Error encoding concat report:", err)
+		}
+	} else {
+		printConcatReport(os.Stderr, report)
+	}
+	if *failOnMismatch && report.Mismatched {
+		os.Exit(exitCriticalAlert)
+	}
+}
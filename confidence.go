@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// defaultConfidenceLevel is the coverage of the confidence interval of the mean
+const defaultConfidenceLevel = 0.95
+
+// ConfidenceInterval is a confidence interval of a statistic at the given level
+type ConfidenceInterval struct {
+	Level float64 `json:"level"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// studentTQuantile returns the t value on df degrees of freedom that a two-sided interval of the given level reaches
+// out to, found by bisection on studentTPValue, which falls as t grows
+func studentTQuantile(level, df float64) float64 {
+	low, high := 0.0, 1.0
+	for studentTPValue(high, df) > 1-level {
+		high *= 2
+	}
+	for i := 0; i < 100 && high-low > 1e-12*high; i++ {
+		mid := (low + high) / 2
+		if studentTPValue(mid, df) > 1-level {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// The meanConfidenceInterval function gives the Student t interval of a mean: mean ± t * stdDev/sqrt(n), with t the
+// two-sided quantile of the t distribution on n-1 degrees of freedom. Unlike the normal ±1.96 standard errors the t
+// quantile widens as samples shrink (12.7 standard errors at 95% for two values, 2.26 for ten), so small samples show
+// how little their mean pins down. The interval assumes roughly normal values or enough of them for the mean to be;
+// --bootstrap gives intervals without that assumption.
+// meanConfidenceInterval returns the t interval of a mean, or nil for fewer than two values
+func meanConfidenceInterval(mean, stdDev float64, n int, level float64) *ConfidenceInterval {
+	if n < 2 || math.IsNaN(mean) || math.IsInf(mean, 0) {
+		return nil
+	}
+	margin := studentTQuantile(level, float64(n-1)) * stdDev / math.Sqrt(float64(n))
+	return &ConfidenceInterval{Level: level, Lower: mean - margin, Upper: mean + margin}
+}
+
+// describe renders the interval with its level, as in the text report
+func (ci *ConfidenceInterval) describe() string {
+	return fmt.Sprintf("%s to %s (%g%% confidence)", reportNumber(ci.Lower), reportNumber(ci.Upper), 100*ci.Level)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config is the optional JSON configuration file passed with --config
+type Config struct {
+	// Alerts are the data-quality rules evaluated after every analysis
+	Alerts []AlertRule `json:"alerts"`
+	// Suppressions silence known, accepted alerts until their expiry date
+	Suppressions []Suppression `json:"suppressions"`
+}
+
+// LoadConfig reads and validates a JSON configuration file
+func LoadConfig(path string) (Config, error) {
+	// Reads the whole file; configuration files are small.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("error opening config file: %v", err)
+	}
+	// Decodes strictly so misspelt keys are reported instead of silently ignored.
+	var config Config
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("error reading config file %s: %v", path, err)
+	}
+	// Checks every alert rule before any data is loaded.
+	for i := range config.Alerts {
+		if err := config.Alerts[i].normalize(); err != nil {
+			return Config{}, fmt.Errorf("invalid alert rule #%d in %s: %v", i+1, path, err)
+		}
+	}
+	// Parses the expiry dates of all suppressions.
+	for i := range config.Suppressions {
+		if err := config.Suppressions[i].normalize(); err != nil {
+			return Config{}, fmt.Errorf("invalid suppression #%d in %s: %v", i+1, path, err)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import "math"
+
+// ConstraintKind identifies the type of rule a mined constraint represents
+type ConstraintKind string
+
+const (
+	// ConstraintNotNull means every row has a non-empty value in the column
+	ConstraintNotNull ConstraintKind = "not_null"
+	// ConstraintUnique means no non-empty value appears more than once in the column
+	ConstraintUnique ConstraintKind = "unique"
+	// ConstraintType records the type of the column (Numeric, Boolean, Date or Text)
+	ConstraintType ConstraintKind = "type"
+	// ConstraintRange records the observed minimum and maximum of a numeric column, infinite when Inf cells are there
+	ConstraintRange ConstraintKind = "range"
+	// ConstraintValueSet records the closed set of values seen in a categorical text column
+	ConstraintValueSet ConstraintKind = "value_set"
+)
+
+// maxValueSetSize is the largest number of distinct values a text column may have
+// for it to be treated as categorical and mined into a value-set constraint
+const maxValueSetSize = 20
+
+// Constraint describes a single property that held for a column of the loaded data
+type Constraint struct {
+	Column string
+	Kind   ConstraintKind
+	Type   string   // set for ConstraintType
+	Min    float64  // set for ConstraintRange
+	Max    float64  // set for ConstraintRange
+	Values []string // set for ConstraintValueSet
+}
+
+// The MineConstraints method is part of the CSVAnalyzer struct. It inspects every column of the loaded dataset, in header
+// order, and records the properties that currently hold for it: whether the column is fully populated, whether its values
+// are unique, its detected type, the observed numeric range, and - for low-cardinality text columns - the full set of
+// values. These constraints are a starting point for validation suites exported to other tools.
+// MineConstraints infers column constraints from the loaded data
+func (ca *CSVAnalyzer) MineConstraints() []Constraint {
+	// Declares an empty slice to collect the constraints found for every column.
+	var constraints []Constraint
+	// Iterates through the headers in file order so the output is deterministic.
+	for colIndex, header := range ca.dataset.Headers {
+		// Counts how many rows have a value in this column.
+		nonEmpty := ca.countNonEmptyValues(colIndex)
+		// Collects the distinct values in the column (sorted).
+		uniqueValues := ca.extractUniqueValues(colIndex)
+
+		// A column is not-null when every data row has a value for it.
+		if len(ca.dataset.Rows) > 0 && nonEmpty == len(ca.dataset.Rows) {
+			constraints = append(constraints, Constraint{Column: header, Kind: ConstraintNotNull})
+		}
+		// A column is unique when no value is repeated among the populated cells.
+		if nonEmpty > 0 && len(uniqueValues) == nonEmpty {
+			constraints = append(constraints, Constraint{Column: header, Kind: ConstraintUnique})
+		}
+
+		// Numeric columns get a type constraint plus their observed range.
+		if ca.dataset.NumericCols[colIndex] {
+			constraints = append(constraints, Constraint{Column: header, Kind: ConstraintType, Type: "Numeric"})
+			// Extracts the parsed numbers to compute the range; NaN cells have no place in it.
+			var values []float64
+			for _, v := range ca.extractNumericValues(colIndex) {
+				if !math.IsNaN(v) {
+					values = append(values, v)
+				}
+			}
+			if len(values) > 0 {
+				constraints = append(constraints, Constraint{
+					Column: header,
+					Kind:   ConstraintRange,
+					Min:    min(values...),
+					Max:    max(values...),
+				})
+			}
+			continue
+		}
+
+		// Boolean and date columns only get a type constraint.
+		if colType := ca.columnType(colIndex); colType == TypeBoolean || colType == TypeDate {
+			constraints = append(constraints, Constraint{Column: header, Kind: ConstraintType, Type: string(colType)})
+			continue
+		}
+
+		// Text columns get a type constraint, and a value set when they look categorical.
+		constraints = append(constraints, Constraint{Column: header, Kind: ConstraintType, Type: "Text"})
+		// Only columns with repeated values and few distinct entries are treated as categorical.
+		if len(uniqueValues) > 0 && len(uniqueValues) <= maxValueSetSize && len(uniqueValues) < nonEmpty {
+			constraints = append(constraints, Constraint{Column: header, Kind: ConstraintValueSet, Values: uniqueValues})
+		}
+	}
+	// Returns all constraints mined from the dataset.
+	return constraints
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+)
+
+// Output formats of the convert subcommand
+const (
+	ConvertJSON   = "json"
+	ConvertJSONL  = "jsonl"
+	ConvertArrow  = "arrow" // Apache Arrow IPC stream
+	ConvertSQLite = "sqlite"
+	ConvertSQL    = "sql" // CREATE TABLE and INSERT statements
+)
+
+// typedValue converts a cell to the JSON value matching its column's type: numbers and booleans become native
+// values, empty cells become null and everything else stays a string. Values that do not parse as their column's
+// type are kept as the original text rather than lost.
+func (ca *CSVAnalyzer) typedValue(colIndex int, cell string) interface{} {
+	value := strings.TrimSpace(cell)
+	if value == "" {
+		return nil
+	}
+	switch ca.columnType(colIndex) {
+	case TypeNumeric:
+		// NaN and infinities have no JSON representation.
+		if num, err := ca.parseNumber(value); err == nil && !math.IsNaN(num) && !math.IsInf(num, 0) {
+			return num
+		}
+	case TypeBoolean:
+		if truth, ok := parseBoolean(value); ok {
+			return truth
+		}
+	}
+	return cell
+}
+
+// The WriteJSONRecords method is part of the CSVAnalyzer struct. It writes every row of the dataset as a JSON object
+// keyed by column name, with keys in header order and values typed as the analyzer detected (or was told via
+// --types). With lines set the objects are written one per line (JSON Lines); otherwise they form a single array.
+// Rows shorter than the header get null for the missing cells.
+// WriteJSONRecords writes the dataset as a JSON array or as newline-delimited JSON
+func (ca *CSVAnalyzer) WriteJSONRecords(w io.Writer, lines bool) error {
+	buffered := bufio.NewWriter(w)
+	// Encodes the header names once, since every object repeats them.
+	keys := make([][]byte, len(ca.dataset.Headers))
+	for colIndex, header := range ca.dataset.Headers {
+		keys[colIndex], _ = json.Marshal(header)
+	}
+
+	if !lines {
+		buffered.WriteString("[")
+	}
+	for rowIndex, row := range ca.dataset.Rows {
+		if !lines && rowIndex > 0 {
+			buffered.WriteString(",")
+		}
+		if !lines {
+			buffered.WriteString("\n  ")
+		}
+		// Builds the object by hand because encoding/json would sort the keys.
+		buffered.WriteString("{")
+		for colIndex := range ca.dataset.Headers {
+			if colIndex > 0 {
+				buffered.WriteString(",")
+			}
+			cell := ""
+			var value interface{}
+			if colIndex < len(row) {
+				cell = row[colIndex]
+				value = ca.typedValue(colIndex, cell)
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("error encoding row %d: %v", rowIndex+1, err)
+			}
+			buffered.Write(keys[colIndex])
+			buffered.WriteString(":")
+			buffered.Write(encoded)
+		}
+		buffered.WriteString("}")
+		if lines {
+			buffered.WriteString("\n")
+		}
+	}
+	if !lines {
+		if len(ca.dataset.Rows) > 0 {
+			buffered.WriteString("\n")
+		}
+		buffered.WriteString("]\n")
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("error writing records: %v", err)
+	}
+	return nil
+}
+
+// runConvert implements the convert subcommand: it loads a CSV file with the usual loading options and
+// writes its rows as JSON, JSON Lines, an Arrow stream, a SQLite database or SQL statements, optionally only the
+// --select columns and in their order
+func runConvert(args []string) {
+	// Reuses the analyzer flags so --types, --locale and sampling apply to conversions too.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	to := fs.String("to", ConvertJSONL, "output `format` of convert: json (array of objects), jsonl (one object per line), arrow (Arrow IPC stream), sqlite (SQLite database with one table) or sql (CREATE TABLE and INSERT statements)")
+	dialect := fs.String("dialect", SQLPostgres, "SQL `dialect` of --to sql: "+strings.Join(sqlDialects, ", "))
+	batchSize := fs.Int("batch-size", defaultSQLBatchSize, "`rows` per INSERT statement of --to sql")
+	output := fs.String("output", "", "write the converted data to `file` instead of stdout")
+	var selected columnList
+	fs.Var(&selected, "select", "write only these comma-separated `columns`, in this order, e.g. \"Category,Revenue,Price\"")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . convert [--to json|jsonl|arrow|sqlite|sql] [--output file] [--select columns] [--table name] [--dialect postgres|mysql|sqlite] [options] <csv-file>")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *to != ConvertJSON && *to != ConvertJSONL && *to != ConvertArrow && *to != ConvertSQLite && *to != ConvertSQL {
+		log.Fatalf("Unknown convert format %q (expected json, jsonl, arrow, sqlite or sql)", *to)
+	}
+	if *dialect != SQLPostgres && *dialect != SQLMySQL && *dialect != SQLSQLite {
+		log.Fatalf("Unknown SQL dialect %q (expected %s)", *dialect, strings.Join(sqlDialects, ", "))
+	}
+	if *batchSize < 1 {
+		log.Fatal("Invalid options: --batch-size must be at least 1")
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+
+	// Loads the data exactly as an analysis run would.
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	if err := analyzer.LoadCSV(positional[0]); err != nil {
+		log.Fatal("Error loading CSV:", err)
+	}
+	analyzer.enforceStrict()
+	// Projects the columns after loading, so types are detected and checks run as in an analysis of the whole file.
+	if len(selected) > 0 {
+		projected, err := analyzer.Dataset().Select(selected...)
+		if err != nil {
+			log.Fatal("Invalid --select: ", err)
+		}
+		analyzer = analyzer.WithDataset(projected)
+	}
+
+	// Writes to the requested destination, compressed when --compress is set.
+	w := compressWriter(os.Stdout, opts.Compress)
+	if *output != "" {
+		if w, err = createOutputFile(*output, opts.Compress); err != nil {
+			log.Fatal("Error creating output file:", err)
+		}
+	}
+	switch *to {
+	case ConvertArrow:
+		err = analyzer.WriteArrow(w)
+	case ConvertSQLite:
+		err = analyzer.WriteSQLite(w, sqliteTableName(*opts, positional[0]))
+	case ConvertSQL:
+		var unparsed int
+		unparsed, err = analyzer.WriteSQL(w, sqliteTableName(*opts, positional[0]), *dialect, *batchSize)
+		if unparsed > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d cells that do not parse as their column's type were written as NULL\n", unparsed)
+		}
+	default:
+		err = analyzer.WriteJSONRecords(w, *to == ConvertJSONL)
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal("Error converting:", err)
+	}
+}
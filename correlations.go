@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+)
+
+// Layout of the correlation heatmap: matrices of up to heatmapNumberColumns columns show each coefficient, wider
+// ones a compact three-character cell per pair; row labels are cut to heatmapLabelWidth characters
+const (
+	heatmapNumberColumns = 12
+	heatmapLabelWidth    = 18
+	heatmapPNGCell       = 24 // pixels per cell of the PNG export
+)
+
+// CorrelationMatrix holds the Pearson correlations between every pair of numeric columns
+type CorrelationMatrix struct {
+	Columns []string `json:"columns"`
+	// R[i][j] is the correlation of Columns[i] and Columns[j], nil when fewer than minClusterPairs rows have both
+	// values or either is constant on them
+	R [][]*float64 `json:"r"`
+}
+
+// The Correlations method is part of the CSVAnalyzer struct. It computes the signed Pearson correlation of every
+// pair of numeric columns over the rows where both have a value, the same correlation --cluster-columns groups
+// columns by, for the heatmap of the text report and --correlation-png. Comparing every pair costs time quadratic
+// in the number of columns. The matrix is withheld under --dp-epsilon, since a correlation over a few rows gives them
+// away.
+// Correlations returns the correlation matrix, or nil when it was not requested
+func (ca *CSVAnalyzer) Correlations() *CorrelationMatrix {
+	if (!ca.options.Correlations && ca.options.CorrelationPNG == "") || ca.privacy != nil {
+		return nil
+	}
+	matrix := &CorrelationMatrix{}
+	var values [][]float64
+	for colIndex, header := range ca.dataset.Headers {
+		if ca.columnType(colIndex) == TypeNumeric {
+			matrix.Columns = append(matrix.Columns, header)
+			values = append(values, ca.alignedNumericValues(colIndex))
+		}
+	}
+	matrix.R = make([][]*float64, len(values))
+	for i := range values {
+		matrix.R[i] = make([]*float64, len(values))
+	}
+	for i := range values {
+		for j := i; j < len(values); j++ {
+			if r, _, ok := correlation(values[i], values[j]); ok {
+				matrix.R[i][j], matrix.R[j][i] = &r, &r
+			}
+		}
+	}
+	return matrix
+}
+
+// heatmapColor is the xterm-256 colour of a correlation: red for positive, blue for negative, deeper the stronger
+func heatmapColor(r float64) int {
+	depth := int(math.Round(math.Abs(r) * 5))
+	if r < 0 {
+		return 16 + 36*(5-depth) + 6*(5-depth) + 5
+	}
+	return 16 + 36*5 + 6*(5-depth) + (5 - depth)
+}
+
+// heatmapShade is the block character standing for the strength of a correlation when colours are off
+func heatmapShade(r float64) string {
+	return []string{" ", "░", "░", "▒", "▓", "█"}[int(math.Round(math.Abs(r)*5))]
+}
+
+// heatmapCell renders one cell of the heatmap, six characters wide or three when compact, in colour or with shades
+func heatmapCell(r *float64, compact, colored bool) string {
+	var text string
+	switch {
+	case r == nil && compact:
+		text = "  ·"
+	case r == nil:
+		text = "     -"
+	case compact && colored:
+		text = "   "
+	case compact:
+		sign := "+"
+		if *r < 0 {
+			sign = "-"
+		}
+		text = " " + sign + heatmapShade(*r)
+	default:
+		text = fmt.Sprintf("%6.2f", *r)
+	}
+	if r == nil || !colored {
+		return text
+	}
+	foreground := 16
+	if math.Abs(*r) >= 0.7 {
+		foreground = 231
+	}
+	return fmt.Sprintf("\033[38;5;%dm\033[48;5;%dm%s%s", foreground, heatmapColor(*r), text, ansiReset)
+}
+
+// stdoutIsTerminal records at startup whether stdout is a terminal, before the report cache redirects it
+var stdoutIsTerminal = isTerminal(os.Stdout)
+
+// useColor reports whether the text report may colour its output: only on a terminal, and never with NO_COLOR set
+func useColor() bool {
+	return stdoutIsTerminal && os.Getenv("NO_COLOR") == ""
+}
+
+// The printCorrelationHeatmap function shows the correlation matrix in the text report as a heatmap: rows are
+// labelled with the column names and columns with their numbers, so wide datasets still fit. On a terminal the
+// cells are coloured red for positive and blue for negative correlations, deeper the stronger; elsewhere, and with
+// NO_COLOR, compact cells use a sign and a shade (░ ▒ ▓ █) instead. Matrices of more than heatmapNumberColumns
+// columns drop the coefficients for three-character cells.
+// printCorrelationHeatmap prints the correlation matrix of the numeric columns
+func printCorrelationHeatmap(matrix *CorrelationMatrix, colored bool) {
+	if matrix == nil {
+		return
+	}
+	fmt.Println("\n\nCorrelation Heatmap (Numeric Columns):")
+	fmt.Println("--------------------------------------")
+	if len(matrix.Columns) < 2 {
+		fmt.Println("  Fewer than two numeric columns to correlate.")
+		return
+	}
+	compact := len(matrix.Columns) > heatmapNumberColumns
+	cellWidth := 6
+	if compact {
+		cellWidth = 3
+	}
+	labelFormat := fmt.Sprintf("  %%3d %%-%ds ", heatmapLabelWidth)
+	fmt.Printf("  %*s", 4+heatmapLabelWidth+1, "")
+	for i := range matrix.Columns {
+		fmt.Printf("%*d", cellWidth, (i+1)%1000)
+	}
+	fmt.Println()
+	for i, name := range matrix.Columns {
+		if len([]rune(name)) > heatmapLabelWidth {
+			name = string([]rune(name)[:heatmapLabelWidth-1]) + "…"
+		}
+		fmt.Printf(labelFormat, i+1, name)
+		var row strings.Builder
+		for j := range matrix.Columns {
+			row.WriteString(heatmapCell(matrix.R[i][j], compact, colored))
+		}
+		fmt.Println(row.String())
+	}
+	if colored {
+		swatch := func(r float64) string { return heatmapCell(&r, true, true) }
+		fmt.Printf("\n  Scale: %s -1  %s 0  %s +1; · or - where there are too few shared values\n", swatch(-1), swatch(0), swatch(1))
+	} else if compact {
+		fmt.Println("\n  Cells show the sign and strength of r: ░ weak, ▒ moderate, ▓ strong, █ near ±1; · too few shared values")
+	}
+}
+
+// The WriteCorrelationPNG method is part of the CSVAnalyzer struct. It writes the correlation matrix as a PNG
+// heatmap with one heatmapPNGCell-pixel square per pair, in the colours of the terminal heatmap (red positive, blue
+// negative, white for none) and grey where the correlation is undefined. The image has no text, so its rows and
+// columns run in the order of the numeric columns in the file, as in the report's correlations.
+// WriteCorrelationPNG writes the correlation heatmap as a PNG image
+func (ca *CSVAnalyzer) WriteCorrelationPNG(path string) error {
+	matrix := ca.Correlations()
+	if matrix == nil || len(matrix.Columns) == 0 {
+		return fmt.Errorf("no correlations to draw (it needs numeric columns and is withheld under --dp-epsilon)")
+	}
+	n := len(matrix.Columns)
+	img := image.NewRGBA(image.Rect(0, 0, n*heatmapPNGCell, n*heatmapPNGCell))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			fill := color.RGBA{R: 200, G: 200, B: 200, A: 255}
+			if r := matrix.R[i][j]; r != nil {
+				fill = heatmapRGB(*r)
+			}
+			// Leaves a one-pixel white gap between the cells.
+			for y := i*heatmapPNGCell + 1; y < (i+1)*heatmapPNGCell; y++ {
+				for x := j*heatmapPNGCell + 1; x < (j+1)*heatmapPNGCell; x++ {
+					img.SetRGBA(x, y, fill)
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("error encoding correlation heatmap: %v", err)
+	}
+	return writeOutputFile(path, ca.options.Compress, buf.Bytes())
+}
+
+// heatmapRGB is the colour of a correlation in the PNG heatmap, blending from white to red or blue
+func heatmapRGB(r float64) color.RGBA {
+	fade := uint8(math.Round(255 * (1 - math.Min(1, math.Abs(r)))))
+	if r < 0 {
+		return color.RGBA{R: fade, G: fade, B: 255, A: 255}
+	}
+	return color.RGBA{R: 255, G: fade, B: fade, A: 255}
+}
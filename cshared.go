@@ -0,0 +1,97 @@
+//go:build cshared
+
+// C entry points for the shared-library build, so other languages can call the analyzer through their foreign
+// function interface:
+//
+//	go build -tags cshared -buildmode=c-shared -o libcsvanalyzer.so .
+//
+// This writes libcsvanalyzer.so (or .dylib/.dll) and the matching libcsvanalyzer.h. The tag keeps cgo, and with it
+// a C compiler, out of the normal build.
+
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"unsafe"
+)
+
+// csva_analyze analyzes the file at path with options given as a JSON object of flag names and values (NULL or ""
+// for none). It always returns a JSON document - the report, or {"error": "..."} - which the caller must release
+// with csva_free.
+//
+//export csva_analyze
+func csva_analyze(path *C.char, options *C.char) *C.char {
+	result, err := analyzeC(C.GoString(path), C.GoString(options))
+	if err != nil {
+		result, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return C.CString(string(result))
+}
+
+// analyzeC decodes the options of csva_analyze and runs the analysis
+func analyzeC(path, options string) ([]byte, error) {
+	values, err := decodeOptionsC(options)
+	if err != nil {
+		return nil, err
+	}
+	return AnalyzeFile(path, values)
+}
+
+// decodeOptionsC turns the JSON options object of the C entry points into options by flag name
+func decodeOptionsC(options string) (map[string]string, error) {
+	var parsed map[string]any
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &parsed); err != nil {
+			return nil, err
+		}
+	}
+	// Accepts JSON numbers and booleans as well as strings, e.g. {"sample": 1000, "strict": true}.
+	values := make(map[string]string, len(parsed))
+	for name, value := range parsed {
+		if text, ok := value.(string); ok {
+			values[name] = text
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		values[name] = string(encoded)
+	}
+	return values, nil
+}
+
+// csva_histogram returns the histogram of a numeric column as JSON ({"edges": [...], "counts": [...]}) with bins
+// equal-width bins, loading the file with options given as for csva_analyze. Errors are returned as {"error": "..."};
+// either way the caller must release the result with csva_free.
+//
+//export csva_histogram
+func csva_histogram(path, column *C.char, bins C.int, options *C.char) *C.char {
+	result, err := histogramC(C.GoString(path), C.GoString(column), int(bins), C.GoString(options))
+	if err != nil {
+		result, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return C.CString(string(result))
+}
+
+// histogramC decodes the options of csva_histogram and bins the column
+func histogramC(path, column string, bins int, options string) ([]byte, error) {
+	values, err := decodeOptionsC(options)
+	if err != nil {
+		return nil, err
+	}
+	histogram, err := ColumnHistogram(path, column, bins, values)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(histogram)
+}
+
+// csva_free releases a string returned by csva_analyze
+//
+//export csva_free
+func csva_free(result *C.char) {
+	C.free(unsafe.Pointer(result))
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Layout of the dashboard
+const (
+	dashboardListWidth   = 28 // widest the column list gets
+	dashboardCellWidth   = 14 // characters per column of the sample rows
+	dashboardMaxBins     = 12 // histogram bins drawn at most
+	dashboardMinHeight   = 16 // smallest terminal the panes fit in
+	dashboardMinWidth    = 60
+	dashboardDefaultSize = "24 80" // rows and columns assumed when stty cannot tell
+	dashboardTitle       = "CSV-Analyzer"
+)
+
+// ANSI escape sequences used by the dashboard
+const (
+	ansiReverse  = "\033[7m"
+	ansiBold     = "\033[1m"
+	ansiReset    = "\033[0m"
+	ansiClear    = "\033[H\033[2J"
+	ansiEnterAlt = "\033[?1049h\033[?25l" // switches to the alternate screen and hides the cursor
+	ansiLeaveAlt = "\033[?25h\033[?1049l"
+)
+
+// Keys the dashboard reacts to
+const (
+	keyUp = iota
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyQuit
+)
+
+// dashboard is the state of the interactive dashboard: the loaded data, its report and what is on screen
+type dashboard struct {
+	ca       *CSVAnalyzer
+	report   Report
+	name     string
+	selected int // column shown in the stats, histogram and first in the sample rows
+	listTop  int // first column visible in the column list
+	rowTop   int // first row visible in the sample rows
+	width    int
+	height   int
+}
+
+// terminalSize asks stty for the size of the terminal on stdin
+func terminalSize() (rows, cols int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil || len(strings.Fields(string(out))) != 2 {
+		out = []byte(dashboardDefaultSize)
+	}
+	fields := strings.Fields(string(out))
+	rows, _ = strconv.Atoi(fields[0])
+	cols, _ = strconv.Atoi(fields[1])
+	return rows, cols
+}
+
+// The rawTerminal function puts the terminal on stdin into raw mode, so keys arrive one at a time without being
+// echoed, and returns a function restoring the previous mode. It relies on stty, so the dashboard works in Unix
+// terminals (including WSL and Git Bash) but not in the Windows console.
+// rawTerminal switches stdin to raw mode and returns the function that undoes it
+func rawTerminal() (func(), error) {
+	save := exec.Command("stty", "-g")
+	save.Stdin = os.Stdin
+	state, err := save.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading terminal mode (the dashboard needs stty): %v", err)
+	}
+	raw := exec.Command("stty", "raw", "-echo")
+	raw.Stdin = os.Stdin
+	if err := raw.Run(); err != nil {
+		return nil, fmt.Errorf("error switching the terminal to raw mode: %v", err)
+	}
+	return func() {
+		restore := exec.Command("stty", strings.TrimSpace(string(state)))
+		restore.Stdin = os.Stdin
+		restore.Run()
+	}, nil
+}
+
+// readKeys reads the next key presses, decoding the escape sequences of arrow and paging keys; keys typed faster than
+// the screen redraws arrive together
+func readKeys(r io.Reader) ([]int, error) {
+	buf := make([]byte, 64)
+	n, err := r.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	var keys []int
+	for input := buf[:n]; len(input) > 0; {
+		// A lone escape is the Esc key; an escape followed by [ starts a cursor or paging key.
+		if len(input) >= 3 && input[0] == 0x1b && input[1] == '[' {
+			switch input[2] {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			case 'H':
+				keys = append(keys, keyHome)
+			case 'F':
+				keys = append(keys, keyEnd)
+			case '5':
+				keys = append(keys, keyPageUp)
+			case '6':
+				keys = append(keys, keyPageDown)
+			}
+			// Skips the rest of the sequence, e.g. the ~ of "\033[5~".
+			end := 3
+			if input[2] >= '0' && input[2] <= '9' && len(input) > 3 {
+				end = 4
+			}
+			input = input[end:]
+			continue
+		}
+		switch input[0] {
+		case 'k':
+			keys = append(keys, keyUp)
+		case 'j':
+			keys = append(keys, keyDown)
+		case 'b':
+			keys = append(keys, keyPageUp)
+		case ' ':
+			keys = append(keys, keyPageDown)
+		case 'g':
+			keys = append(keys, keyHome)
+		case 'G':
+			keys = append(keys, keyEnd)
+		case 'q', 0x1b, 0x03: // q, Esc and Ctrl-C
+			keys = append(keys, keyQuit)
+		}
+		input = input[1:]
+	}
+	return keys, nil
+}
+
+// fit pads or cuts text to exactly width characters
+func fit(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	count := utf8.RuneCountInString(text)
+	if count <= width {
+		return text + strings.Repeat(" ", width-count)
+	}
+	runes := []rune(text)
+	if width == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// fitRight is fit for right-aligned columns: the padding goes before the text
+func fitRight(text string, width int) string {
+	if count := utf8.RuneCountInString(text); count < width {
+		return strings.Repeat(" ", width-count) + text
+	}
+	return fit(text, width)
+}
+
+// sampleRowsHeight is the number of screen lines given to the sample rows pane, its header line included
+func (d *dashboard) sampleRowsHeight() int {
+	return (d.height - 2) / 3
+}
+
+// press applies one key press to the dashboard state
+func (d *dashboard) press(key int) {
+	columns := len(d.report.Columns)
+	page := d.sampleRowsHeight() - 2
+	if page < 1 {
+		page = 1
+	}
+	switch key {
+	case keyUp:
+		if d.selected > 0 {
+			d.selected--
+		}
+	case keyDown:
+		if d.selected < columns-1 {
+			d.selected++
+		}
+	case keyHome:
+		d.selected = 0
+	case keyEnd:
+		d.selected = columns - 1
+	case keyPageUp:
+		d.rowTop -= page
+	case keyPageDown:
+		d.rowTop += page
+	}
+	if last := len(d.ca.dataset.Rows) - page; d.rowTop > last {
+		d.rowTop = last
+	}
+	if d.rowTop < 0 {
+		d.rowTop = 0
+	}
+}
+
+// bold fits text to width and shows it in bold
+func bold(text string, width int) string {
+	return ansiBold + fit(text, width) + ansiReset
+}
+
+// statsLines describes the selected column, one line per figure; the name line is already fitted to width
+func (d *dashboard) statsLines(width int) []string {
+	column := d.report.Columns[d.selected]
+	lines := []string{bold(column.Name+" ("+column.Type+")", width)}
+	if column.Description != "" {
+		lines = append(lines, column.Description)
+	}
+	if card := column.Cardinality; card != nil {
+		distinct := strconv.Itoa(card.Distinct)
+		if card.Approximate {
+			distinct = "~" + distinct
+		}
+		lines = append(lines, fmt.Sprintf("Non-empty %d of %d, distinct %s", card.NonEmpty, d.report.Rows, distinct))
+	}
+	if stats := column.Numeric; stats != nil {
+		lines = append(lines,
+			fmt.Sprintf("Mean %s, median %s", formatMetric(stats.Mean), formatMetric(stats.Median)),
+			fmt.Sprintf("Std dev %s", formatMetric(stats.StdDev)),
+			fmt.Sprintf("Min %s, max %s", formatMetric(stats.Min), formatMetric(stats.Max)),
+		)
+	}
+	if stats := column.Text; stats != nil {
+		lines = append(lines, fmt.Sprintf("%d values, %d unique", stats.TotalCount, stats.UniqueCount))
+	}
+	if stats := column.Boolean; stats != nil {
+		lines = append(lines, fmt.Sprintf("True %d, false %d, empty %d", stats.TrueCount, stats.FalseCount, stats.EmptyCount))
+	}
+	if stats := column.Date; stats != nil && stats.Count > 0 {
+		lines = append(lines, fmt.Sprintf("%s .. %s (%.1f days)", formatDate(stats.Earliest), formatDate(stats.Latest), stats.SpanDays))
+	}
+	if test := column.Normality; test != nil {
+		lines = append(lines, "Normality: "+test.Verdict)
+	}
+	return lines
+}
+
+// The histogramLines method is part of the dashboard struct. It draws the distribution of the selected column as
+// horizontal bars in at most lines lines: the histogram bins of a numeric column, or the most frequent values of any
+// other column. Both show individual values' frequencies, so they are withheld under differential privacy.
+// histogramLines draws the distribution pane of the selected column
+func (d *dashboard) histogramLines(lines, width int) []string {
+	if d.ca.privacy != nil {
+		return []string{"Distribution withheld under --dp-epsilon"}
+	}
+	bins := lines - 1
+	if bins > dashboardMaxBins {
+		bins = dashboardMaxBins
+	}
+	if bins < 1 {
+		return nil
+	}
+	var labels []string
+	var counts []int
+	title := "Most frequent values"
+	if hist, _ := d.ca.Histogram(d.selected, bins); hist != nil {
+		title = "Histogram"
+		for i, count := range hist.Counts {
+			labels = append(labels, formatMetric(hist.Edges[i])+" .. "+formatMetric(hist.Edges[i+1]))
+			counts = append(counts, count)
+		}
+	} else {
+		labels, counts = d.ca.topValues(d.selected, bins)
+	}
+	if len(counts) == 0 {
+		return []string{title + ": no values"}
+	}
+	peak := 0
+	for _, count := range counts {
+		if count > peak {
+			peak = count
+		}
+	}
+	out := []string{bold(title, width)}
+	labelWidth := width / 3
+	barWidth := width - labelWidth - 10
+	for i, count := range counts {
+		bar := ""
+		if peak > 0 && barWidth > 0 {
+			bar = strings.Repeat("█", count*barWidth/peak)
+		}
+		out = append(out, fit(labels[i], labelWidth)+" "+fit(bar, barWidth)+" "+strconv.Itoa(count))
+	}
+	return out
+}
+
+// sampleLines shows the rows from rowTop on, starting at the selected column so it is always visible
+func (d *dashboard) sampleLines(lines int) []string {
+	if d.ca.privacy != nil {
+		return []string{"Sample rows withheld under --dp-epsilon"}
+	}
+	visible := d.width / (dashboardCellWidth + 1)
+	if visible < 1 {
+		visible = 1
+	}
+	columns := d.ca.dataset.Headers[d.selected:]
+	if len(columns) > visible {
+		columns = columns[:visible]
+	}
+	render := func(cells []string, first int) string {
+		var b strings.Builder
+		for i := range columns {
+			cell := ""
+			if first+i < len(cells) {
+				cell = cells[first+i]
+			}
+			b.WriteString(fit(cell, dashboardCellWidth) + " ")
+		}
+		return b.String()
+	}
+	out := []string{ansiBold + render(d.ca.dataset.Headers, d.selected) + ansiReset}
+	for i := 0; i < lines-1 && d.rowTop+i < len(d.ca.dataset.Rows); i++ {
+		out = append(out, render(d.ca.dataset.Rows[d.rowTop+i], d.selected))
+	}
+	return out
+}
+
+// The render method is part of the dashboard struct. It draws the whole screen: a title line, the column list on the
+// left, the stats and histogram of the selected column on the right, the sample rows across the bottom and the key
+// help. Lines are padded to the terminal width so every redraw fully replaces the previous screen.
+// render returns the screen contents for the current state
+func (d *dashboard) render() string {
+	if d.height < dashboardMinHeight || d.width < dashboardMinWidth {
+		return ansiClear + fmt.Sprintf("The dashboard needs a terminal of at least %dx%d; press q to quit", dashboardMinWidth, dashboardMinHeight)
+	}
+	listWidth := d.width / 3
+	if listWidth > dashboardListWidth {
+		listWidth = dashboardListWidth
+	}
+	rightWidth := d.width - listWidth - 3
+	sampleHeight := d.sampleRowsHeight()
+	paneHeight := d.height - 2 - sampleHeight - 1
+
+	// Keeps the selected column within the visible part of the list.
+	if d.selected < d.listTop {
+		d.listTop = d.selected
+	}
+	if d.selected >= d.listTop+paneHeight {
+		d.listTop = d.selected - paneHeight + 1
+	}
+	stats := d.statsLines(rightWidth)
+	right := append(stats, "")
+	right = append(right, d.histogramLines(paneHeight-len(right), rightWidth)...)
+
+	var b strings.Builder
+	b.WriteString(ansiClear)
+	title := fmt.Sprintf(" %s: %s, %d rows, %d columns", dashboardTitle, d.name, d.report.Rows, d.report.ColumnCount)
+	b.WriteString(ansiReverse + fit(title, d.width) + ansiReset + "\r\n")
+	for line := 0; line < paneHeight; line++ {
+		colIndex := d.listTop + line
+		entry := ""
+		if colIndex < len(d.report.Columns) {
+			entry = " " + d.report.Columns[colIndex].Name
+		}
+		if colIndex == d.selected {
+			b.WriteString(ansiReverse + fit(entry, listWidth) + ansiReset)
+		} else {
+			b.WriteString(fit(entry, listWidth))
+		}
+		b.WriteString(" │ ")
+		if line < len(right) {
+			// Styled lines were fitted before their escape sequences were added.
+			if strings.Contains(right[line], "\033[") {
+				b.WriteString(right[line])
+			} else {
+				b.WriteString(fit(right[line], rightWidth))
+			}
+		}
+		b.WriteString("\033[K\r\n")
+	}
+	b.WriteString(strings.Repeat("─", d.width) + "\r\n")
+	sample := d.sampleLines(sampleHeight)
+	for line := 0; line < sampleHeight; line++ {
+		if line < len(sample) {
+			b.WriteString(sample[line])
+		}
+		b.WriteString("\033[K\r\n")
+	}
+	help := fmt.Sprintf(" ↑↓/jk column  PgUp/PgDn rows %d-%d  Home/End first/last  q quit", d.rowTop+1, d.rowTop+len(sample)-1)
+	b.WriteString(ansiReverse + fit(help, d.width) + ansiReset)
+	return b.String()
+}
+
+// The run method is part of the dashboard struct. It takes over the terminal until q, Esc or Ctrl-C is pressed,
+// redrawing after every key. The size of the terminal is read again before each redraw, so a resized window is laid
+// out anew with the next key press.
+// run shows the dashboard until the user quits
+func (d *dashboard) run() error {
+	restore, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	fmt.Print(ansiEnterAlt)
+	defer fmt.Print(ansiLeaveAlt)
+	for {
+		d.height, d.width = terminalSize()
+		fmt.Print(d.render())
+		keys, err := readKeys(os.Stdin)
+		if err != nil {
+			return nil
+		}
+		for _, key := range keys {
+			if key == keyQuit {
+				return nil
+			}
+			d.press(key)
+		}
+	}
+}
+
+// runDashboard implements `dashboard [options] <file>`: it loads the file like an analysis run and explores it in a
+// full-screen terminal view with a column list, per-column stats and histogram, and the sample rows
+func runDashboard(args []string) {
+	// Reuses the analyzer flags so --types, --preset and the other loading options apply too.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . dashboard [options] <csv-file>")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		log.Fatal("The dashboard needs an interactive terminal; use the text or JSON report instead")
+	}
+
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	if err := analyzer.LoadCSV(positional[0]); err != nil {
+		log.Fatal("Error loading CSV:", err)
+	}
+	analyzer.enforceStrict()
+	d := &dashboard{ca: analyzer, report: analyzer.BuildReport(), name: positional[0]}
+	if len(d.report.Columns) == 0 {
+		log.Fatal("Error: the file has no columns to show")
+	}
+	if err := d.run(); err != nil {
+		log.Fatal("Error running the dashboard:", err)
+	}
+}
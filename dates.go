@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// dateLayouts are the formats tried, in order, when parsing values of a Date column
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"01/02/2006",
+	"01/02/2006 15:04:05",
+	"02.01.2006",
+	"02-Jan-2006",
+	"Jan 2, 2006",
+	"2 Jan 2006",
+	"20060102",
+}
+
+// DateColumnStats holds the range of a date column
+type DateColumnStats struct {
+	Name         string    `json:"name"`
+	Count        int       `json:"count"`
+	InvalidCount int       `json:"invalid_count"` // non-empty values no layout could parse
+	Earliest     time.Time `json:"earliest"`
+	Latest       time.Time `json:"latest"`
+	SpanDays     float64   `json:"span_days"`
+}
+
+// parseDate parses a cell value using the first matching layout
+func parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseDate parses a cell value with the built-in layouts, then with the date layouts of the --preset
+func (ca *CSVAnalyzer) parseDate(value string) (time.Time, bool) {
+	if t, ok := parseDate(value); ok {
+		return t, true
+	}
+	value = strings.TrimSpace(value)
+	for _, layout := range ca.dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// extractDateValues parses every non-empty value of a column, also counting the values that are not dates
+func (ca *CSVAnalyzer) extractDateValues(colIndex int) (dates []time.Time, invalid int) {
+	for _, row := range ca.dataset.Rows {
+		if colIndex >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[colIndex])
+		if value == "" {
+			continue
+		}
+		if t, ok := ca.parseDate(value); ok {
+			dates = append(dates, t)
+		} else {
+			invalid++
+		}
+	}
+	return dates, invalid
+}
+
+// CalculateDateStats computes the range of every date column, in header order
+func (ca *CSVAnalyzer) CalculateDateStats() []DateColumnStats {
+	var stats []DateColumnStats
+	for colIndex := range ca.dataset.Headers {
+		if colStats, ok := ca.calculateDateColumnStats(colIndex); ok {
+			stats = append(stats, colStats)
+		}
+	}
+	return stats
+}
+
+// calculateDateColumnStats finds the earliest and latest value of a single date column.
+// The boolean result is false when the column is not a date column.
+func (ca *CSVAnalyzer) calculateDateColumnStats(colIndex int) (DateColumnStats, bool) {
+	// Only columns typed as dates (via --types) have a date range.
+	if ca.columnType(colIndex) != TypeDate {
+		return DateColumnStats{}, false
+	}
+	dates, invalid := ca.extractDateValues(colIndex)
+	colStats := DateColumnStats{Name: ca.dataset.Headers[colIndex], Count: len(dates), InvalidCount: invalid}
+	// Scans once for the extremes.
+	for i, t := range dates {
+		if i == 0 || t.Before(colStats.Earliest) {
+			colStats.Earliest = t
+		}
+		if i == 0 || t.After(colStats.Latest) {
+			colStats.Latest = t
+		}
+	}
+	if len(dates) > 0 {
+		colStats.SpanDays = colStats.Latest.Sub(colStats.Earliest).Hours() / 24
+	}
+	return colStats, true
+}
+
+// formatDate prints a date without a time part when it falls exactly on midnight UTC
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deriveFunctions are the functions --derive expressions may call, with the number of arguments each takes
+var deriveFunctions = map[string]int{
+	"year":     1,
+	"month":    1,
+	"weekday":  1,
+	"datediff": 2,
+}
+
+// deriveArg is an argument of a derived-column function: a column, a quoted date or today()
+type deriveArg struct {
+	column string
+	date   time.Time
+	today  bool
+}
+
+// DerivedColumn is a column computed from others by a --derive expression such as "OrderMonth=month(OrderDate)"
+type DerivedColumn struct {
+	Name       string
+	Expression string
+	function   string
+	args       []deriveArg
+}
+
+// DerivedColumns is the list of --derive columns. It implements flag.Value; the flag is repeated for several
+// columns, since the arguments of datediff are separated by commas themselves.
+type DerivedColumns []DerivedColumn
+
+// String renders the columns in --derive syntax
+func (d DerivedColumns) String() string {
+	parts := make([]string, len(d))
+	for i, column := range d {
+		parts[i] = column.Name + "=" + column.Expression
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set parses one --derive value such as "Age=datediff(today(), OrderDate)"
+func (d *DerivedColumns) Set(value string) error {
+	name, expression, ok := strings.Cut(value, "=")
+	name, expression = strings.TrimSpace(name), strings.TrimSpace(expression)
+	if !ok || name == "" || expression == "" {
+		return fmt.Errorf("invalid derived column %q (expected name=function(args), e.g. OrderMonth=month(OrderDate))", value)
+	}
+	open := strings.Index(expression, "(")
+	if open < 0 || !strings.HasSuffix(expression, ")") {
+		return fmt.Errorf("invalid expression %q for %s (expected function(args))", expression, name)
+	}
+	function := strings.ToLower(strings.TrimSpace(expression[:open]))
+	arity, known := deriveFunctions[function]
+	if !known {
+		return fmt.Errorf("unknown function %q for %s (expected year, month, weekday or datediff)", function, name)
+	}
+	column := DerivedColumn{Name: name, Expression: expression, function: function}
+	for _, text := range splitDeriveArgs(expression[open+1 : len(expression)-1]) {
+		arg, err := parseDeriveArg(text)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		column.args = append(column.args, arg)
+	}
+	if len(column.args) != arity {
+		return fmt.Errorf("%s() takes %d argument(s), got %d in %s", function, arity, len(column.args), name)
+	}
+	*d = append(*d, column)
+	return nil
+}
+
+// splitDeriveArgs splits function arguments at the commas outside quotes and parentheses
+func splitDeriveArgs(text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	var args []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(text[start:]))
+}
+
+// parseDeriveArg reads one argument: today(), a date in quotes such as '2024-01-31', or else a column name
+func parseDeriveArg(text string) (deriveArg, error) {
+	if strings.EqualFold(strings.ReplaceAll(text, " ", ""), "today()") {
+		return deriveArg{today: true}, nil
+	}
+	if len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0] {
+		date, ok := parseDate(text[1 : len(text)-1])
+		if !ok {
+			return deriveArg{}, fmt.Errorf("%s is not a date", text)
+		}
+		return deriveArg{date: date}, nil
+	}
+	if text == "" {
+		return deriveArg{}, fmt.Errorf("empty argument")
+	}
+	return deriveArg{column: text}, nil
+}
+
+// civilDays counts the calendar days from 1970-01-01 to a date, ignoring its time of day and zone
+func civilDays(t time.Time) int {
+	year, month, day := t.Date()
+	return int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// The deriveColumns method is part of the CSVAnalyzer struct. It appends the --derive columns to the dataset, in the
+// order they were given, after drops and renames and before types are detected, so a derived column is profiled,
+// grouped on (--group-by OrderMonth) and exported like any other and a later expression may use an earlier one.
+// year() and month() give the year and month number (1-12) of a date, weekday() its English day name and
+// datediff(end, start) the calendar days from start to end, negative when end comes first; dates are read like the
+// values of date columns, preset formats included. A cell whose date is empty or unreadable gives an empty cell.
+// deriveColumns adds the columns computed by --derive expressions
+func (ca *CSVAnalyzer) deriveColumns() error {
+	today := time.Now()
+	for _, derived := range ca.options.Derive {
+		if ca.dataset.headerIndex(derived.Name) >= 0 {
+			return fmt.Errorf("cannot derive %q: a column with that name already exists", derived.Name)
+		}
+		indexes := make([]int, len(derived.args))
+		for i, arg := range derived.args {
+			indexes[i] = -1
+			if arg.column == "" {
+				continue
+			}
+			if indexes[i] = ca.dataset.headerIndex(arg.column); indexes[i] < 0 {
+				return fmt.Errorf("--derive %s: unknown column %q", derived.Name, arg.column)
+			}
+		}
+
+		width := len(ca.dataset.Headers)
+		ca.dataset.Headers = append(append([]string(nil), ca.dataset.Headers...), derived.Name)
+		for rowIndex, row := range ca.dataset.Rows {
+			// Resolves every argument to a date; a missing one leaves the cell empty.
+			dates := make([]time.Time, len(derived.args))
+			valid := true
+			for i, arg := range derived.args {
+				switch {
+				case arg.today:
+					dates[i] = today
+				case arg.column == "":
+					dates[i] = arg.date
+				case indexes[i] < len(row):
+					dates[i], valid = ca.parseDate(row[indexes[i]])
+				default:
+					valid = false
+				}
+				if !valid {
+					break
+				}
+			}
+			value := ""
+			if valid {
+				switch derived.function {
+				case "year":
+					value = strconv.Itoa(dates[0].Year())
+				case "month":
+					value = strconv.Itoa(int(dates[0].Month()))
+				case "weekday":
+					value = dates[0].Weekday().String()
+				case "datediff":
+					value = strconv.Itoa(civilDays(dates[0]) - civilDays(dates[1]))
+				}
+			}
+			// Ragged rows are evened out to the header, so the new cell lines up with its column.
+			cells := make([]string, width+1)
+			copy(cells, row)
+			cells[width] = value
+			ca.dataset.Rows[rowIndex] = cells
+		}
+	}
+	return nil
+}
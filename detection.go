@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Row sampling strategies for type detection
+const (
+	DetectHead   = "head"   // the first rows (the historical behaviour)
+	DetectRandom = "random" // rows chosen at random, with a fixed seed so detection is repeatable
+	DetectSpread = "spread" // rows spread evenly from the start to the end of the data
+	DetectEvery  = "every"  // every Nth row of the whole dataset
+	DetectAll    = "all"    // every row
+)
+
+// detectionSeed seeds random type detection; it is fixed so the same file always gets the same types
+const detectionSeed = 1
+
+// TypeDetection configures which rows detectNumericColumns inspects. It implements flag.Value for --type-sample,
+// which accepts "head", "random", "spread", "every:N" or "all".
+type TypeDetection struct {
+	Strategy string
+	Every    int // the N of every:N
+	Rows     int // rows to inspect for head, random and spread (0 means typeDetectionRows)
+	// Threshold is the share of the inspected non-empty values that must be numbers for a column to be numeric
+	// (0 means all of them)
+	Threshold float64
+}
+
+// String renders the strategy in --type-sample syntax
+func (d TypeDetection) String() string {
+	if d.Strategy == DetectEvery {
+		return DetectEvery + ":" + strconv.Itoa(d.Every)
+	}
+	return d.Strategy
+}
+
+// Set parses a --type-sample value
+func (d *TypeDetection) Set(value string) error {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case DetectHead, DetectRandom, DetectSpread, DetectAll:
+		d.Strategy = value
+		return nil
+	}
+	if n, ok := strings.CutPrefix(value, DetectEvery+":"); ok {
+		every, err := strconv.Atoi(n)
+		if err != nil || every < 1 {
+			return fmt.Errorf("invalid row interval in %q", value)
+		}
+		d.Strategy, d.Every = DetectEvery, every
+		return nil
+	}
+	return fmt.Errorf("unknown type detection strategy %q (expected head, random, spread, every:N or all)", value)
+}
+
+// The detectionRows method is part of the CSVAnalyzer struct. It returns, in ascending order, the positions of the
+// rows type detection looks at. Looking only at the head of a file misclassifies columns whose first block is empty
+// or unrepresentative (an export sorted by a column that is empty for old records, say), so the rows can instead be
+// drawn at random, spread evenly over the whole file, or taken at a fixed interval. Every strategy inspects at most
+// Rows rows except every:N, which visits the whole dataset at that interval, and all, which looks at every row.
+// detectionRows picks the rows inspected by type detection
+func (ca *CSVAnalyzer) detectionRows() []int {
+	detection := ca.options.TypeDetection
+	total := len(ca.dataset.Rows)
+	limit := detection.Rows
+	if limit <= 0 {
+		limit = typeDetectionRows
+	}
+	if limit > total {
+		limit = total
+	}
+
+	var rows []int
+	switch detection.Strategy {
+	case DetectRandom:
+		// A seeded permutation gives a sample without repeats.
+		rows = rand.New(rand.NewSource(detectionSeed)).Perm(total)[:limit]
+		sort.Ints(rows)
+	case DetectSpread:
+		// Takes the first row of each of limit equal slices of the data.
+		for i := 0; i < limit; i++ {
+			rows = append(rows, i*total/limit)
+		}
+	case DetectEvery:
+		for i := 0; i < total; i += detection.Every {
+			rows = append(rows, i)
+		}
+	case DetectAll:
+		for i := 0; i < total; i++ {
+			rows = append(rows, i)
+		}
+	default:
+		for i := 0; i < limit; i++ {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+// numericThreshold is the share of inspected values that must be numbers for a numeric column, 1 unless
+// --type-threshold lowered it
+func (d TypeDetection) numericThreshold() float64 {
+	if d.Threshold <= 0 {
+		return 1
+	}
+	return d.Threshold
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// quoteSwapReader exchanges two ASCII bytes in everything read through it. The csv package only knows the double
+// quote, so files quoted with another character are read by swapping that character with the double quote before
+// parsing and swapping them back in the parsed fields (see swapQuotes).
+type quoteSwapReader struct {
+	reader io.Reader
+	quote  byte
+}
+
+// Read passes the read through with the quote character and the double quote exchanged
+func (r *quoteSwapReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	for i := 0; i < n; i++ {
+		switch b[i] {
+		case r.quote:
+			b[i] = '"'
+		case '"':
+			b[i] = r.quote
+		}
+	}
+	return n, err
+}
+
+// swapQuotes undoes the exchange of quoteSwapReader in the fields of a parsed record
+func swapQuotes(record []string, quote byte) {
+	for i, field := range record {
+		if strings.IndexByte(field, '"') < 0 && strings.IndexByte(field, quote) < 0 {
+			continue
+		}
+		swapped := []byte(field)
+		for j, c := range swapped {
+			switch c {
+			case quote:
+				swapped[j] = '"'
+			case '"':
+				swapped[j] = quote
+			}
+		}
+		record[i] = string(swapped)
+	}
+}
+
+// singleRune returns the only character of a flag value, or an error naming the flag
+func singleRune(flagName, value string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || r == utf8.RuneError || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("--%s must be a single character other than a line break, got %q", flagName, value)
+	}
+	return r, nil
+}
+
+// The csvDialect method is part of the Options struct. It checks the CSV parsing options and returns the quote byte
+// to swap with the double quote (0 when fields are quoted with the double quote) and the comment character as the
+// csv package will see it after the swap (0 when there is none). The quote must be ASCII since it is swapped byte by
+// byte, and the comment character cannot be the quote.
+// csvDialect returns the validated quote and comment characters
+func (opts *Options) csvDialect() (byte, rune, error) {
+	var quote byte
+	if opts.QuoteChar != "" {
+		r, err := singleRune("quote-char", opts.QuoteChar)
+		if err != nil {
+			return 0, 0, err
+		}
+		if r >= utf8.RuneSelf || r == ',' {
+			return 0, 0, fmt.Errorf("--quote-char must be an ASCII character other than the comma, got %q", opts.QuoteChar)
+		}
+		if r != '"' {
+			quote = byte(r)
+		}
+	}
+	var comment rune
+	if opts.CommentChar != "" {
+		r, err := singleRune("comment-char", opts.CommentChar)
+		if err != nil {
+			return 0, 0, err
+		}
+		if r == ',' || (quote == 0 && r == '"') || (quote != 0 && r == rune(quote)) {
+			return 0, 0, fmt.Errorf("--comment-char cannot be the comma or the quote character")
+		}
+		comment = r
+		// The swap turns double quotes in the file into the quote byte, comment markers included.
+		if quote != 0 && r == '"' {
+			comment = rune(quote)
+		}
+	}
+	return quote, comment, nil
+}
+
+// The newCSVReader method is part of the CSVAnalyzer struct. It creates the CSV reader of an input with the parsing
+// options of the command line, so messy exports parse: --lazy-quotes accepts stray quotes in fields, --quote-char
+// quotes fields with another character than the double quote, --comment-char skips lines starting with a character
+// such as #, and --trim-leading-space ignores blanks before a field.
+// newCSVReader returns the record reader of a CSV input
+func (ca *CSVAnalyzer) newCSVReader(input io.Reader) (*csvRecordReader, error) {
+	quote, comment, err := ca.options.csvDialect()
+	if err != nil {
+		return nil, err
+	}
+	if quote != 0 {
+		input = &quoteSwapReader{reader: input, quote: quote}
+	}
+	reader := csv.NewReader(input)
+	// Ragged rows are accepted here and reported as data warnings by LoadCSV.
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = ca.options.LazyQuotes
+	reader.TrimLeadingSpace = ca.options.TrimLeadingSpace
+	reader.Comment = comment
+	return &csvRecordReader{reader: reader, quote: quote}, nil
+}
+
+// csvRecordReader reads the records of a CSV input created by newCSVReader
+type csvRecordReader struct {
+	reader *csv.Reader
+	quote  byte // the --quote-char swapped with the double quote, 0 for none
+}
+
+// Read returns the next record, with the quotes of a --quote-char restored
+func (r *csvRecordReader) Read() ([]string, error) {
+	record, err := r.reader.Read()
+	if r.quote != 0 && record != nil {
+		swapQuotes(record, r.quote)
+	}
+	return record, err
+}
+
+// InputOffset is the byte offset in the input just after the last record read
+func (r *csvRecordReader) InputOffset() int64 {
+	return r.reader.InputOffset()
+}
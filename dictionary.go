@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ColumnAnnotation is the human description of a column taken from a data dictionary
+type ColumnAnnotation struct {
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+}
+
+// DataDictionary maps column names to their annotations
+type DataDictionary map[string]ColumnAnnotation
+
+// The LoadDataDictionary method is part of the CSVAnalyzer struct. It reads a data-dictionary file and attaches the
+// descriptions and units it contains to the analyzer, so every report can show reviewers what a column means. Two formats
+// are accepted: a CSV file with "column", "description" and (optionally) "unit" headers, or a JSON object keyed by column
+// name whose values hold "description" and "unit" fields.
+// LoadDataDictionary reads column descriptions and units from a dictionary file
+func (ca *CSVAnalyzer) LoadDataDictionary(path string) error {
+	// Picks the parser based on the file extension.
+	var dictionary DataDictionary
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dictionary, err = readJSONDictionary(path)
+	} else {
+		dictionary, err = readCSVDictionary(path)
+	}
+	if err != nil {
+		return err
+	}
+	// Stores the dictionary for use by the report renderers.
+	ca.dictionary = dictionary
+	return nil
+}
+
+// readCSVDictionary parses a CSV data dictionary with column/description/unit headers
+func readCSVDictionary(path string) (DataDictionary, error) {
+	// Opens and reads the whole dictionary file; these files are always small.
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening data dictionary: %v", err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	// Dictionary rows may legitimately omit the trailing unit field.
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading data dictionary: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty data dictionary: %s", path)
+	}
+
+	// Locates the columns of interest by header name, case-insensitively.
+	columnIdx, descriptionIdx, unitIdx := -1, -1, -1
+	for i, header := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(header)) {
+		case "column", "name", "column_name":
+			columnIdx = i
+		case "description":
+			descriptionIdx = i
+		case "unit", "units":
+			unitIdx = i
+		}
+	}
+	// A dictionary without a column name field cannot be matched to anything.
+	if columnIdx < 0 {
+		return nil, fmt.Errorf("data dictionary %s has no \"column\" header", path)
+	}
+
+	// Builds the dictionary from each data row.
+	dictionary := make(DataDictionary)
+	for _, row := range records[1:] {
+		name := cellAt(row, columnIdx)
+		if name == "" {
+			continue
+		}
+		dictionary[name] = ColumnAnnotation{
+			Description: cellAt(row, descriptionIdx),
+			Unit:        cellAt(row, unitIdx),
+		}
+	}
+	return dictionary, nil
+}
+
+// readJSONDictionary parses a JSON data dictionary of the form {"Price": {"description": "...", "unit": "USD"}}
+func readJSONDictionary(path string) (DataDictionary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening data dictionary: %v", err)
+	}
+	var dictionary DataDictionary
+	if err := json.Unmarshal(data, &dictionary); err != nil {
+		return nil, fmt.Errorf("error reading data dictionary: %v", err)
+	}
+	return dictionary, nil
+}
+
+// cellAt returns the trimmed cell at index i, or "" when the row is too short or i is negative
+func cellAt(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// annotation returns the dictionary entry for a column, if there is one
+func (ca *CSVAnalyzer) annotation(name string) ColumnAnnotation {
+	return ca.dictionary[strings.TrimSpace(name)]
+}
+
+// unmatchedDictionaryColumns lists dictionary entries that do not correspond to any loaded column
+func (ca *CSVAnalyzer) unmatchedDictionaryColumns() []string {
+	// Indexes the loaded headers for quick lookup.
+	headers := make(map[string]bool)
+	for _, header := range ca.dataset.Headers {
+		headers[strings.TrimSpace(header)] = true
+	}
+	// Collects every dictionary name that did not match.
+	var unmatched []string
+	for name := range ca.dictionary {
+		if !headers[name] {
+			unmatched = append(unmatched, name)
+		}
+	}
+	sort.Strings(unmatched)
+	return unmatched
+}
+
+// labelWithUnit appends the unit in parentheses, e.g. "Price (USD)"
+func labelWithUnit(name, unit string) string {
+	if unit == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, unit)
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// driftBins is the number of reference quantile bins the PSI of a numeric column is computed over
+const driftBins = 10
+
+// driftMaxCategories is the number of distinct reference values above which a text column is treated as free text
+// (IDs, names, comments) and its categories are not compared
+const driftMaxCategories = 50
+
+// driftCategoryExamples is how many new categories a column drift lists
+const driftCategoryExamples = 10
+
+// psiFloor stands in for empty bins so the PSI stays finite
+const psiFloor = 1e-4
+
+// Default thresholds of the drift subcommand
+const (
+	defaultPSIThreshold  = 0.2  // the usual cut-off for a significant population shift
+	defaultNullThreshold = 0.05 // five percentage points of missing values
+)
+
+// KSTest is a two-sample Kolmogorov–Smirnov test
+type KSTest struct {
+	Statistic float64 `json:"statistic"` // the largest distance between the two empirical distributions
+	PValue    float64 `json:"p_value"`
+}
+
+// ColumnDrift compares one column of the reference file with the same column of the current file
+type ColumnDrift struct {
+	Column           string   `json:"column"`
+	Type             string   `json:"type"`
+	ReferenceType    string   `json:"reference_type,omitempty"` // set when the detected type changed
+	NullRateBefore   float64  `json:"null_rate_before"`
+	NullRateAfter    float64  `json:"null_rate_after"`
+	MeanBefore       *float64 `json:"mean_before,omitempty"`
+	MeanAfter        *float64 `json:"mean_after,omitempty"`
+	MeanShiftSD      *float64 `json:"mean_shift_sd,omitempty"` // the mean shift in reference standard deviations
+	StdDevBefore     *float64 `json:"std_dev_before,omitempty"`
+	StdDevAfter      *float64 `json:"std_dev_after,omitempty"`
+	KS               *KSTest  `json:"ks,omitempty"`
+	PSI              *float64 `json:"psi,omitempty"`
+	NewCategories    []string `json:"new_categories,omitempty"`
+	NewCategoryCount int      `json:"new_category_count,omitempty"`
+	Drifted          bool     `json:"drifted"`
+	Reasons          []string `json:"reasons,omitempty"`
+}
+
+// DriftReport holds the comparison of two versions of a dataset
+type DriftReport struct {
+	Reference      string        `json:"reference"`
+	Current        string        `json:"current"`
+	ReferenceRows  int           `json:"reference_rows"`
+	CurrentRows    int           `json:"current_rows"`
+	PSIThreshold   float64       `json:"psi_threshold"`
+	NullThreshold  float64       `json:"null_threshold"`
+	AddedColumns   []string      `json:"added_columns,omitempty"`
+	RemovedColumns []string      `json:"removed_columns,omitempty"`
+	Columns        []ColumnDrift `json:"columns"`
+	Drifted        bool          `json:"drifted"`
+}
+
+// nullRate returns the share of rows whose cell in a column is empty or missing
+func (ca *CSVAnalyzer) nullRate(colIndex int) float64 {
+	if len(ca.dataset.Rows) == 0 {
+		return 0
+	}
+	empty := 0
+	for _, row := range ca.dataset.Rows {
+		if colIndex >= len(row) || strings.TrimSpace(row[colIndex]) == "" {
+			empty++
+		}
+	}
+	return float64(empty) / float64(len(ca.dataset.Rows))
+}
+
+// categoryShares returns the share of every distinct non-empty value of a column
+func (ca *CSVAnalyzer) categoryShares(colIndex int) map[string]float64 {
+	counts := make(map[string]float64)
+	total := 0.0
+	for _, row := range ca.dataset.Rows {
+		if colIndex < len(row) {
+			if value := strings.TrimSpace(row[colIndex]); value != "" {
+				counts[value]++
+				total++
+			}
+		}
+	}
+	for value := range counts {
+		counts[value] /= total
+	}
+	return counts
+}
+
+// psiTerm is one bin's contribution to the population stability index
+func psiTerm(before, after float64) float64 {
+	before, after = math.Max(before, psiFloor), math.Max(after, psiFloor)
+	return (after - before) * math.Log(after/before)
+}
+
+// The numericPSI function computes the population stability index of a numeric column: the reference values are cut
+// into driftBins bins at their quantiles, so each holds about a tenth of them, and the shares of both files in every
+// bin are compared by Σ (after - before) · ln(after / before). Below 0.1 a population is usually considered stable,
+// 0.1 to 0.2 a moderate shift, and above 0.2 a significant one.
+// numericPSI returns the PSI of the current values against sorted reference values
+func numericPSI(before, after []float64) float64 {
+	// The bin edges are the inner quantiles of the reference; repeated edges merge bins of a lumpy column.
+	var edges []float64
+	for i := 1; i < driftBins; i++ {
+		edge := percentile(before, float64(i)/driftBins)
+		if len(edges) == 0 || edge > edges[len(edges)-1] {
+			edges = append(edges, edge)
+		}
+	}
+	share := func(values []float64) []float64 {
+		counts := make([]float64, len(edges)+1)
+		for _, v := range values {
+			counts[sort.SearchFloat64s(edges, v)]++
+		}
+		for i := range counts {
+			counts[i] /= float64(len(values))
+		}
+		return counts
+	}
+	beforeShares, afterShares := share(before), share(after)
+	psi := 0.0
+	for i := range beforeShares {
+		psi += psiTerm(beforeShares[i], afterShares[i])
+	}
+	return psi
+}
+
+// The ksTest function runs the two-sample Kolmogorov–Smirnov test on sorted samples: the statistic is the largest
+// vertical distance between their empirical distribution functions, and the p-value comes from the asymptotic
+// Kolmogorov distribution with the small-sample correction of Stephens. With the thousands of rows of a daily feed
+// even harmless shifts come out significant, so the p-value is reported but drift is decided on the PSI.
+// ksTest compares two sorted samples
+func ksTest(before, after []float64) KSTest {
+	n1, n2 := float64(len(before)), float64(len(after))
+	d := 0.0
+	i, j := 0, 0
+	for i < len(before) && j < len(after) {
+		// Steps past every copy of the smaller value in both samples before measuring the gap.
+		x := math.Min(before[i], after[j])
+		for i < len(before) && before[i] == x {
+			i++
+		}
+		for j < len(after) && after[j] == x {
+			j++
+		}
+		d = math.Max(d, math.Abs(float64(i)/n1-float64(j)/n2))
+	}
+	effective := math.Sqrt(n1 * n2 / (n1 + n2))
+	lambda := (effective + 0.12 + 0.11/effective) * d
+	// The series does not converge near zero, where the distributions are indistinguishable anyway.
+	if lambda < 0.2 {
+		return KSTest{Statistic: d, PValue: 1}
+	}
+	p := 0.0
+	for k := 1; k <= 100; k++ {
+		term := 2 * math.Pow(-1, float64(k-1)) * math.Exp(-2*float64(k*k)*lambda*lambda)
+		p += term
+		if math.Abs(term) < 1e-10 {
+			break
+		}
+	}
+	return KSTest{Statistic: d, PValue: math.Max(0, math.Min(1, p))}
+}
+
+// The Drift method is part of the CSVAnalyzer struct. It compares the loaded (reference) dataset with a newer version
+// of it, column by column. Columns present in only one file, a changed detected type and a missing-value rate that
+// moved by at least nullThreshold count as drift in every column. Numeric columns also get their mean shift (in
+// reference standard deviations), their standard deviations, a Kolmogorov–Smirnov test and the PSI over reference
+// deciles; text and boolean columns with at most driftMaxCategories reference values get the PSI over their values and
+// the values that were never seen before. A PSI at or above psiThreshold, or any new category, counts as drift.
+// Drift compares the dataset with its current version
+func (ca *CSVAnalyzer) Drift(current *CSVAnalyzer, psiThreshold, nullThreshold float64) DriftReport {
+	report := DriftReport{
+		ReferenceRows: len(ca.dataset.Rows),
+		CurrentRows:   len(current.dataset.Rows),
+		PSIThreshold:  psiThreshold,
+		NullThreshold: nullThreshold,
+	}
+	for _, header := range current.dataset.Headers {
+		if ca.columnIndex(header) < 0 {
+			report.AddedColumns = append(report.AddedColumns, header)
+		}
+	}
+	for colIndex, header := range ca.dataset.Headers {
+		currentIndex := current.columnIndex(header)
+		if currentIndex < 0 {
+			report.RemovedColumns = append(report.RemovedColumns, header)
+			continue
+		}
+		drift := ColumnDrift{
+			Column:         header,
+			Type:           current.columnTypeName(currentIndex),
+			NullRateBefore: ca.nullRate(colIndex),
+			NullRateAfter:  current.nullRate(currentIndex),
+		}
+		if referenceType := ca.columnTypeName(colIndex); referenceType != drift.Type {
+			drift.ReferenceType = referenceType
+			drift.Reasons = append(drift.Reasons, fmt.Sprintf("type changed from %s to %s", referenceType, drift.Type))
+		}
+		if change := drift.NullRateAfter - drift.NullRateBefore; math.Abs(change) >= nullThreshold {
+			drift.Reasons = append(drift.Reasons, fmt.Sprintf("missing values %+.1f points", 100*change))
+		}
+
+		switch {
+		case drift.ReferenceType != "":
+			// Distributions of different types are not comparable.
+		case ca.columnType(colIndex) == TypeNumeric:
+			before := append([]float64(nil), ca.extractNumericValues(colIndex)...)
+			after := append([]float64(nil), current.extractNumericValues(currentIndex)...)
+			if len(before) == 0 || len(after) == 0 {
+				break
+			}
+			sort.Float64s(before)
+			sort.Float64s(after)
+			meanBefore, meanAfter := sum(before)/float64(len(before)), sum(after)/float64(len(after))
+			stdBefore, stdAfter := standardDeviation(before), standardDeviation(after)
+			drift.MeanBefore, drift.MeanAfter = &meanBefore, &meanAfter
+			drift.StdDevBefore, drift.StdDevAfter = &stdBefore, &stdAfter
+			if stdBefore > 0 {
+				shift := (meanAfter - meanBefore) / stdBefore
+				drift.MeanShiftSD = &shift
+			}
+			ks := ksTest(before, after)
+			drift.KS = &ks
+			psi := numericPSI(before, after)
+			drift.PSI = &psi
+		case ca.columnType(colIndex) == TypeText || ca.columnType(colIndex) == TypeBoolean:
+			before, after := ca.categoryShares(colIndex), current.categoryShares(currentIndex)
+			if len(before) == 0 || len(before) > driftMaxCategories {
+				break
+			}
+			psi := 0.0
+			for value, share := range before {
+				psi += psiTerm(share, after[value])
+			}
+			for value, share := range after {
+				if _, ok := before[value]; !ok {
+					psi += psiTerm(0, share)
+					drift.NewCategories = append(drift.NewCategories, value)
+				}
+			}
+			drift.PSI = &psi
+			sort.Strings(drift.NewCategories)
+			drift.NewCategoryCount = len(drift.NewCategories)
+			if drift.NewCategoryCount > driftCategoryExamples {
+				drift.NewCategories = drift.NewCategories[:driftCategoryExamples]
+			}
+			if drift.NewCategoryCount > 0 {
+				drift.Reasons = append(drift.Reasons, fmt.Sprintf("new categories: %d", drift.NewCategoryCount))
+			}
+		}
+		if drift.PSI != nil && *drift.PSI >= psiThreshold {
+			drift.Reasons = append(drift.Reasons, fmt.Sprintf("PSI %.3f", *drift.PSI))
+		}
+		drift.Drifted = len(drift.Reasons) > 0
+		report.Drifted = report.Drifted || drift.Drifted
+		report.Columns = append(report.Columns, drift)
+	}
+	report.Drifted = report.Drifted || len(report.AddedColumns) > 0 || len(report.RemovedColumns) > 0
+	return report
+}
+
+// runDrift implements `drift <reference-file> <current-file>`: it compares the column distributions of two versions
+// of a dataset and exits with status 3 when any column drifted
+func runDrift(args []string) {
+	// Reuses the analyzer flags so --types, --locale and the other loading options apply to both files.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	asJSON := fs.Bool("json", false, "print the comparison as JSON")
+	psiThreshold := fs.Float64("psi-threshold", defaultPSIThreshold, "population stability index at or above which a column has drifted")
+	nullThreshold := fs.Float64("null-threshold", defaultNullThreshold, "change in the share of missing values (0 to 1) at or above which a column has drifted")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . drift [--json] [--psi-threshold 0.2] [--null-threshold 0.05] [options] <reference-csv-file> <current-csv-file>")
+		fmt.Fprintln(os.Stderr, "\nExits with status 3 when any column drifted, so scheduled jobs can alert on it.")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *psiThreshold <= 0 || *nullThreshold <= 0 || *nullThreshold > 1 {
+		log.Fatal("Invalid options: --psi-threshold must be positive and --null-threshold between 0 and 1")
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+
+	var analyzers [2]*CSVAnalyzer
+	for i, path := range positional {
+		analyzers[i] = NewCSVAnalyzerWithOptions(*opts)
+		if err := analyzers[i].LoadCSV(path); err != nil {
+			log.Fatal("Error loading CSV:", err)
+		}
+		analyzers[i].enforceStrict()
+	}
+	report := analyzers[0].Drift(analyzers[1], *psiThreshold, *nullThreshold)
+	report.Reference, report.Current = positional[0], positional[1]
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal("Error encoding drift report:", err)
+		}
+	} else {
+		printDrift(report)
+	}
+	// Drift fails the run like a critical alert does.
+	if report.Drifted {
+		os.Exit(exitCriticalAlert)
+	}
+}
+
+// printDrift shows the comparison of two dataset versions
+func printDrift(report DriftReport) {
+	fmt.Printf("Drift from %s (%d rows) to %s (%d rows):\n", report.Reference, report.ReferenceRows, report.Current, report.CurrentRows)
+	for _, header := range report.AddedColumns {
+		fmt.Printf("\n%s: DRIFT (new column)\n", header)
+	}
+	for _, header := range report.RemovedColumns {
+		fmt.Printf("\n%s: DRIFT (column removed)\n", header)
+	}
+	for _, drift := range report.Columns {
+		status := "OK"
+		if drift.Drifted {
+			status = "DRIFT (" + strings.Join(drift.Reasons, ", ") + ")"
+		}
+		fmt.Printf("\n%s: %s\n", drift.Column, status)
+		fmt.Printf("  Missing:   %.1f%% -> %.1f%%\n", 100*drift.NullRateBefore, 100*drift.NullRateAfter)
+		if drift.MeanBefore != nil {
+			fmt.Printf("  Mean:      %s -> %s", formatMetric(*drift.MeanBefore), formatMetric(*drift.MeanAfter))
+			if drift.MeanShiftSD != nil {
+				fmt.Printf(" (%+.2f sd)", *drift.MeanShiftSD)
+			}
+			fmt.Println()
+			fmt.Printf("  Std Dev:   %s -> %s\n", formatMetric(*drift.StdDevBefore), formatMetric(*drift.StdDevAfter))
+		}
+		if drift.KS != nil {
+			fmt.Printf("  KS:        D = %.3f, p = %.4f\n", drift.KS.Statistic, drift.KS.PValue)
+		}
+		if drift.PSI != nil {
+			fmt.Printf("  PSI:       %.3f\n", *drift.PSI)
+		}
+		if drift.NewCategoryCount > 0 {
+			fmt.Printf("  New:       %s", strings.Join(drift.NewCategories, ", "))
+			if drift.NewCategoryCount > len(drift.NewCategories) {
+				fmt.Printf(" and %d more", drift.NewCategoryCount-len(drift.NewCategories))
+			}
+			fmt.Println()
+		}
+	}
+	if report.Drifted {
+		fmt.Println("\nResult: drift detected")
+	} else {
+		fmt.Println("\nResult: no significant drift")
+	}
+}
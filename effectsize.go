@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Thresholds of Cohen's conventional effect size labels
+const (
+	effectSmall  = 0.2
+	effectMedium = 0.5
+	effectLarge  = 0.8
+)
+
+// EffectSize compares one numeric column before and after the split date
+type EffectSize struct {
+	Column      string   `json:"column"`
+	BeforeCount int      `json:"before_count"`
+	AfterCount  int      `json:"after_count"`
+	BeforeMean  float64  `json:"before_mean"`
+	AfterMean   float64  `json:"after_mean"`
+	Difference  float64  `json:"mean_difference"` // after minus before
+	PctChange   *float64 `json:"pct_change,omitempty"`
+	CohensD     *float64 `json:"cohens_d,omitempty"`
+	Magnitude   string   `json:"magnitude,omitempty"` // negligible, small, medium or large
+}
+
+// PeriodComparison holds the before/after comparison of every numeric column
+type PeriodComparison struct {
+	On          string       `json:"on"`
+	Split       time.Time    `json:"split"`
+	BeforeRows  int          `json:"before_rows"`
+	AfterRows   int          `json:"after_rows"`
+	SkippedRows int          `json:"skipped_rows"` // rows whose time could not be parsed
+	Metrics     []EffectSize `json:"metrics"`
+}
+
+// effectMagnitude labels an absolute Cohen's d by the usual conventions
+func effectMagnitude(d float64) string {
+	switch d = math.Abs(d); {
+	case d < effectSmall:
+		return "negligible"
+	case d < effectMedium:
+		return "small"
+	case d < effectLarge:
+		return "medium"
+	default:
+		return "large"
+	}
+}
+
+// The cohensD function returns the standardized mean difference of two samples: the difference of their means
+// divided by the pooled standard deviation, sqrt(((n1-1)s1² + (n2-1)s2²) / (n1+n2-2)) with sample variances. It is
+// undefined, and ok is false, when either sample has fewer than two values or both are constant.
+// cohensD returns Cohen's d of after relative to before
+func cohensD(before, after []float64, beforeMean, afterMean float64) (float64, bool) {
+	n1, n2 := float64(len(before)), float64(len(after))
+	if n1 < 2 || n2 < 2 {
+		return 0, false
+	}
+	// standardDeviation is the sample standard deviation, so its square is the sample variance.
+	s1 := standardDeviation(before)
+	s2 := standardDeviation(after)
+	pooled := math.Sqrt(((n1-1)*s1*s1 + (n2-1)*s2*s2) / (n1 + n2 - 2))
+	if pooled == 0 {
+		return 0, false
+	}
+	return (afterMean - beforeMean) / pooled, true
+}
+
+// The ComparePeriods method is part of the CSVAnalyzer struct. It splits the rows at --split-date by the time in the
+// --split-on column (rows at or after the split count as after) and compares every numeric column across the two
+// periods: the mean difference, the percentage change of the mean and Cohen's d, the difference in units of the
+// pooled standard deviation, with its conventional label. That answers "did the release change anything?" straight
+// from a raw export, while d keeps a shift that is large in absolute terms but small next to the usual spread from
+// looking important. The period means describe small sets of rows, so the comparison is withheld under --dp-epsilon.
+// ComparePeriods returns the before/after comparison, or nil when no split was configured
+func (ca *CSVAnalyzer) ComparePeriods() *PeriodComparison {
+	if ca.options.SplitOn == "" || ca.privacy != nil {
+		return nil
+	}
+	// The split date was validated with the options.
+	split, _ := parseDate(ca.options.SplitDate)
+	onIndex := ca.columnIndex(ca.options.SplitOn)
+	comparison := &PeriodComparison{On: ca.options.SplitOn, Split: split}
+	endStep := ca.explain.stepOnce("split periods", ca.options.SplitOn+" at "+ca.options.SplitDate, len(ca.dataset.Rows))
+	defer func() { endStep(comparison.BeforeRows + comparison.AfterRows) }()
+
+	// Assigns every row to a period, or skips it when its time is missing.
+	after := make([]bool, len(ca.dataset.Rows))
+	dated := make([]bool, len(ca.dataset.Rows))
+	for rowIndex, row := range ca.dataset.Rows {
+		if onIndex >= len(row) {
+			comparison.SkippedRows++
+			continue
+		}
+		t, ok := ca.parseDate(row[onIndex])
+		if !ok {
+			comparison.SkippedRows++
+			continue
+		}
+		dated[rowIndex], after[rowIndex] = true, !t.Before(split)
+		if after[rowIndex] {
+			comparison.AfterRows++
+		} else {
+			comparison.BeforeRows++
+		}
+	}
+
+	for colIndex, header := range ca.dataset.Headers {
+		if colIndex == onIndex || ca.columnType(colIndex) != TypeNumeric {
+			continue
+		}
+		var beforeValues, afterValues []float64
+		for rowIndex, row := range ca.dataset.Rows {
+			if !dated[rowIndex] || colIndex >= len(row) {
+				continue
+			}
+			if v, err := ca.parseNumber(strings.TrimSpace(row[colIndex])); err == nil {
+				if after[rowIndex] {
+					afterValues = append(afterValues, v)
+				} else {
+					beforeValues = append(beforeValues, v)
+				}
+			}
+		}
+		// A column missing from either period has nothing to compare.
+		if len(beforeValues) == 0 || len(afterValues) == 0 {
+			continue
+		}
+		effect := EffectSize{
+			Column:      header,
+			BeforeCount: len(beforeValues),
+			AfterCount:  len(afterValues),
+			BeforeMean:  sum(beforeValues) / float64(len(beforeValues)),
+			AfterMean:   sum(afterValues) / float64(len(afterValues)),
+		}
+		effect.Difference = effect.AfterMean - effect.BeforeMean
+		if effect.BeforeMean != 0 {
+			pct := 100 * effect.Difference / math.Abs(effect.BeforeMean)
+			effect.PctChange = &pct
+		}
+		if d, ok := cohensD(beforeValues, afterValues, effect.BeforeMean, effect.AfterMean); ok {
+			effect.CohensD = &d
+			effect.Magnitude = effectMagnitude(d)
+		}
+		comparison.Metrics = append(comparison.Metrics, effect)
+	}
+	return comparison
+}
+
+// printPeriodComparison shows the before/after comparison in the text report
+func printPeriodComparison(comparison *PeriodComparison) {
+	if comparison == nil {
+		return
+	}
+	fmt.Printf("\n\nBefore/After %s (split on %s):\n", formatDate(comparison.Split), comparison.On)
+	fmt.Println("---------------------------------------")
+	fmt.Printf("  Rows: %d before, %d after", comparison.BeforeRows, comparison.AfterRows)
+	if comparison.SkippedRows > 0 {
+		fmt.Printf(", %d without a valid %s left out", comparison.SkippedRows, comparison.On)
+	}
+	fmt.Println()
+	for _, effect := range comparison.Metrics {
+		fmt.Printf("\n%s:\n", effect.Column)
+		fmt.Printf("  Mean:       %s -> %s (%+.3f", formatMetric(effect.BeforeMean), formatMetric(effect.AfterMean), effect.Difference)
+		if effect.PctChange != nil {
+			fmt.Printf(", %+.1f%%", *effect.PctChange)
+		}
+		fmt.Println(")")
+		if effect.CohensD != nil {
+			fmt.Printf("  Cohen's d:  %+.3f (%s)\n", *effect.CohensD, effect.Magnitude)
+		} else {
+			fmt.Println("  Cohen's d:  n/a (needs two varying values in each period)")
+		}
+	}
+}
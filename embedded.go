@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// embeddedPreviewLength is how many characters of a cell the preview of embedded characters shows
+const embeddedPreviewLength = 60
+
+// EmbeddedCharacters counts the cells of a column that hold line breaks, quote characters or the delimiter, which
+// the CSV format allows inside quoted fields but naive parsers (split on newlines, then on commas) break on
+type EmbeddedCharacters struct {
+	Cells      int    `json:"cells"` // cells with any of the three
+	Newlines   int    `json:"newlines"`
+	Quotes     int    `json:"quotes"`
+	Delimiters int    `json:"delimiters"`
+	ExampleRow int    `json:"example_row,omitempty"` // 1-based data row of the first such cell
+	Example    string `json:"example,omitempty"`
+}
+
+// The EmbeddedCharacters method is part of the CSVAnalyzer struct. It counts the cells of a column, of any type,
+// that carry a line break (\n or \r), the quote character (after unescaping, so a literal "" in the file counts) or
+// the comma delimiter, and keeps the first such cell as a preview. They are valid CSV but the usual reason a file
+// that loads here falls apart in `cut`, `awk` or a hand-rolled split downstream; a comma in a number formatted for
+// a locale such as de-DE counts too. The preview is withheld under differential privacy.
+// EmbeddedCharacters returns the embedded-character counts of a column, or nil when no cell has any
+func (ca *CSVAnalyzer) EmbeddedCharacters(colIndex int) *EmbeddedCharacters {
+	quote := "\""
+	if ca.options.QuoteChar != "" {
+		quote = ca.options.QuoteChar
+	}
+	found := &EmbeddedCharacters{}
+	for rowIndex, row := range ca.dataset.Rows {
+		if colIndex >= len(row) {
+			continue
+		}
+		value := row[colIndex]
+		newline := strings.ContainsAny(value, "\n\r")
+		quoted := strings.Contains(value, quote)
+		delimited := strings.Contains(value, ",")
+		if !newline && !quoted && !delimited {
+			continue
+		}
+		if found.Cells == 0 && ca.privacy == nil {
+			found.ExampleRow, found.Example = rowIndex+1, embeddedPreview(value)
+		}
+		found.Cells++
+		if newline {
+			found.Newlines++
+		}
+		if quoted {
+			found.Quotes++
+		}
+		if delimited {
+			found.Delimiters++
+		}
+	}
+	if found.Cells == 0 {
+		return nil
+	}
+	return found
+}
+
+// embeddedPreview shortens a cell to embeddedPreviewLength characters for the preview
+func embeddedPreview(value string) string {
+	if runes := []rune(value); len(runes) > embeddedPreviewLength {
+		return string(runes[:embeddedPreviewLength-1]) + "…"
+	}
+	return value
+}
+
+// printEmbeddedCharacters lists the columns with embedded line breaks, quotes or delimiters in the text report
+func (ca *CSVAnalyzer) printEmbeddedCharacters() {
+	printed := false
+	for colIndex, header := range ca.dataset.Headers {
+		found := ca.EmbeddedCharacters(colIndex)
+		if found == nil {
+			continue
+		}
+		if !printed {
+			fmt.Println("\n\nEmbedded Line Breaks, Quotes and Delimiters (break naive parsers):")
+			fmt.Println("------------------------------------------------------------------")
+			fmt.Printf("  %-24s %8s %8s %8s %10s  %s\n", "Column", "Cells", "Newlines", "Quotes", "Delimiters", "First")
+			printed = true
+		}
+		first := "withheld"
+		if found.ExampleRow > 0 {
+			// Quoting shows the line breaks and quotes as \n and \" rather than breaking the table.
+			first = fmt.Sprintf("row %d: %q", found.ExampleRow, found.Example)
+		}
+		fmt.Printf("  %s %8d %8d %8d %10d  %s\n", fit(header, 24), found.Cells, found.Newlines, found.Quotes, found.Delimiters, first)
+	}
+}
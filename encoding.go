@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Input encodings accepted by --encoding
+const (
+	EncodingAuto        = "auto"
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingLatin1      = "latin1"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// encodingNames maps the spellings accepted by --encoding to the canonical encoding names
+var encodingNames = map[string]string{
+	"auto":  EncodingAuto,
+	"utf-8": EncodingUTF8, "utf8": EncodingUTF8,
+	"utf-16le": EncodingUTF16LE, "utf16le": EncodingUTF16LE, "utf-16": EncodingUTF16LE, "ucs-2": EncodingUTF16LE,
+	"utf-16be": EncodingUTF16BE, "utf16be": EncodingUTF16BE,
+	"latin1": EncodingLatin1, "latin-1": EncodingLatin1, "iso-8859-1": EncodingLatin1,
+	"windows-1252": EncodingWindows1252, "cp1252": EncodingWindows1252,
+}
+
+// encodingSniffSize is how many leading bytes automatic detection looks at
+const encodingSniffSize = 4096
+
+// lookupEncoding returns the canonical name of an --encoding value, or an error when it is not supported
+func lookupEncoding(name string) (string, error) {
+	if name == "" {
+		return EncodingAuto, nil
+	}
+	encoding, ok := encodingNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return "", fmt.Errorf("unknown --encoding %q (expected auto, utf-8, utf-16le, utf-16be, latin1 or windows-1252)", name)
+	}
+	return encoding, nil
+}
+
+// windows1252High maps the bytes 0x80-0x9F of Windows-1252 to Unicode; the rest of the code page matches Latin-1.
+// The five unassigned bytes decode to the replacement character.
+var windows1252High = [32]rune{
+	'€', '\uFFFD', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\uFFFD', 'Ž', '\uFFFD',
+	'\uFFFD', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\uFFFD', 'ž', 'Ÿ',
+}
+
+// The decodeInput function wraps a file's contents in a reader that produces UTF-8, stripping any byte order mark.
+// With the auto encoding it first looks at the BOM (UTF-8, UTF-16LE or UTF-16BE). Without one, text where most
+// odd or even bytes are zero is taken to be UTF-16 without a BOM, valid UTF-8 stays as it is, and anything else is
+// read as Windows-1252, the usual encoding of spreadsheet exports on Windows (it is a superset of Latin-1's
+// printable characters). Only the first encodingSniffSize bytes are inspected. It returns the encoding used.
+// decodeInput transcodes input in the given encoding to UTF-8
+func decodeInput(input io.Reader, encoding string) (io.Reader, string) {
+	buffered := bufio.NewReaderSize(input, encodingSniffSize)
+	head, _ := buffered.Peek(encodingSniffSize)
+
+	// A byte order mark is authoritative in auto mode and is dropped whatever the encoding.
+	bom := ""
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		bom = EncodingUTF8
+		buffered.Discard(3)
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		bom = EncodingUTF16LE
+		buffered.Discard(2)
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		bom = EncodingUTF16BE
+		buffered.Discard(2)
+	}
+	if encoding == EncodingAuto {
+		encoding = bom
+		if encoding == "" {
+			encoding = sniffEncoding(head)
+		}
+	}
+
+	switch encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		return &utf16Reader{input: buffered, bigEndian: encoding == EncodingUTF16BE}, encoding
+	case EncodingLatin1, EncodingWindows1252:
+		return &singleByteReader{input: buffered, windows1252: encoding == EncodingWindows1252}, encoding
+	}
+	return buffered, EncodingUTF8
+}
+
+// sniffEncoding guesses the encoding of text that has no byte order mark
+func sniffEncoding(head []byte) string {
+	if len(head) >= 2 {
+		// ASCII text in UTF-16 has a zero in every other byte.
+		zeroEven, zeroOdd := 0, 0
+		for i, b := range head {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				zeroEven++
+			} else {
+				zeroOdd++
+			}
+		}
+		pairs := len(head) / 2
+		switch {
+		case zeroOdd > pairs/2 && zeroEven == 0:
+			return EncodingUTF16LE
+		case zeroEven > pairs/2 && zeroOdd == 0:
+			return EncodingUTF16BE
+		}
+	}
+	// A multi-byte character may be cut off at the end of the sample, so only the complete part is validated.
+	for trim := 0; trim < utf8.UTFMax && trim < len(head); trim++ {
+		if utf8.Valid(head[:len(head)-trim]) {
+			return EncodingUTF8
+		}
+	}
+	if len(head) == 0 {
+		return EncodingUTF8
+	}
+	return EncodingWindows1252
+}
+
+// utf16Reader decodes UTF-16 text to UTF-8
+type utf16Reader struct {
+	input     *bufio.Reader
+	bigEndian bool
+	pending   []byte // decoded UTF-8 not yet returned
+	err       error
+}
+
+// Read returns decoded UTF-8, decoding the input one code unit (or surrogate pair) at a time
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	for len(r.pending) < len(p) && r.err == nil {
+		unit, err := r.readUnit()
+		if err != nil {
+			r.err = err
+			break
+		}
+		char := rune(unit)
+		// Joins a surrogate pair into one character; a broken pair becomes the replacement character.
+		if char >= 0xD800 && char < 0xDC00 {
+			low, err := r.readUnit()
+			if err != nil {
+				r.err = err
+			}
+			char = utf16.DecodeRune(char, rune(low))
+		}
+		r.pending = utf8.AppendRune(r.pending, char)
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	if n == 0 && r.err != nil {
+		return 0, r.err
+	}
+	return n, nil
+}
+
+// readUnit reads one 16-bit code unit; a dangling final byte is dropped
+func (r *utf16Reader) readUnit() (uint16, error) {
+	var unit [2]byte
+	if _, err := io.ReadFull(r.input, unit[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	if r.bigEndian {
+		return uint16(unit[0])<<8 | uint16(unit[1]), nil
+	}
+	return uint16(unit[1])<<8 | uint16(unit[0]), nil
+}
+
+// singleByteReader decodes Latin-1 or Windows-1252 text, where every byte is one character, to UTF-8
+type singleByteReader struct {
+	input       *bufio.Reader
+	windows1252 bool
+	pending     []byte
+}
+
+// Read returns decoded UTF-8
+func (r *singleByteReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		// Reads at most a quarter of p, since one byte can grow into up to three.
+		size := len(p) / 4
+		if size < 1 {
+			size = 1
+		}
+		raw := make([]byte, size)
+		n, err := r.input.Read(raw)
+		for _, b := range raw[:n] {
+			char := rune(b)
+			if r.windows1252 && b >= 0x80 && b <= 0x9F {
+				char = windows1252High[b-0x80]
+			}
+			r.pending = utf8.AppendRune(r.pending, char)
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
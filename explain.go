@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// explainStep is one operation of a run's plan with the rows it consumed and produced
+type explainStep struct {
+	operation string
+	detail    string
+	rowsIn    int // -1 when the operation reads no rows, e.g. a file scan
+	rowsOut   int
+	duration  time.Duration
+}
+
+// The explainPlan struct records the operations of a run for --explain: every loading step and every multi-step
+// analysis the options turn on (grouping, segmentation, windows, period splits, k-anonymity, reference joins), in the
+// order they ran, with their row counts and wall-clock time. The report formats ask for some analyses more than once,
+// so analyses are recorded with stepOnce, which ignores all but their first run. A nil *explainPlan is valid and records nothing, so callers
+// need no checks when the flag is off.
+type explainPlan struct {
+	mu    sync.Mutex
+	steps []explainStep
+	seen  map[string]bool
+}
+
+// newExplainPlan starts a plan, or returns nil when enabled is false
+func newExplainPlan(enabled bool) *explainPlan {
+	if !enabled {
+		return nil
+	}
+	return &explainPlan{seen: make(map[string]bool)}
+}
+
+// stepOnce is step for operations that may run several times per run; only the first run is recorded
+func (p *explainPlan) stepOnce(operation, detail string, rowsIn int) func(rowsOut int) {
+	if p == nil {
+		return func(int) {}
+	}
+	key := operation + "\x00" + detail
+	p.mu.Lock()
+	repeated := p.seen[key]
+	p.seen[key] = true
+	p.mu.Unlock()
+	if repeated {
+		return func(int) {}
+	}
+	return p.step(operation, detail, rowsIn)
+}
+
+// step starts timing an operation over rowsIn rows and returns the function that ends it with the rows it produced
+func (p *explainPlan) step(operation, detail string, rowsIn int) func(rowsOut int) {
+	if p == nil {
+		return func(int) {}
+	}
+	begin := time.Now()
+	return func(rowsOut int) {
+		p.mu.Lock()
+		p.steps = append(p.steps, explainStep{operation: operation, detail: detail, rowsIn: rowsIn, rowsOut: rowsOut, duration: time.Since(begin)})
+		p.mu.Unlock()
+	}
+}
+
+// formatRows renders a row count of the plan, "-" when it does not apply
+func formatRows(rows int) string {
+	if rows < 0 {
+		return "-"
+	}
+	return strconv.Itoa(rows)
+}
+
+// report writes the plan as a table of steps in execution order
+func (p *explainPlan) report(w io.Writer) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(w, "\nExplain:")
+	fmt.Fprintln(w, "--------")
+	fmt.Fprintf(w, "  %-3s %-22s %-34s %10s %10s %12s\n", "#", "Operation", "Detail", "Rows in", "Rows out", "Time")
+	var total time.Duration
+	for i, step := range p.steps {
+		fmt.Fprintf(w, "  %-3d %-22s %s %10s %10s %12s\n", i+1, step.operation, fit(step.detail, 34), formatRows(step.rowsIn), formatRows(step.rowsOut), step.duration.Round(time.Microsecond))
+		total += step.duration
+	}
+	fmt.Fprintf(w, "  %-3s %-22s %-34s %10s %10s %12s\n", "", "total", "", "", "", total.Round(time.Microsecond))
+}
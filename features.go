@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// rankMaxCategories is the number of distinct values above which a categorical column is treated as an identifier
+// or free text, whose groups would explain the target by memorizing it, and is not ranked
+const rankMaxCategories = 50
+
+// FeatureRank is the relationship of one column with the target column
+type FeatureRank struct {
+	Column string `json:"column"`
+	Type   string `json:"type"`
+	Method string `json:"method"` // "pearson" for two numeric columns, "anova" when one of them is categorical
+	Pairs  int    `json:"pairs"`  // rows with a value in both columns
+	Levels int    `json:"levels,omitempty"`
+	// R is the signed Pearson correlation, F and EtaSquared the one-way ANOVA of the numeric column by the categories
+	R          *float64 `json:"r,omitempty"`
+	F          *float64 `json:"f,omitempty"` // omitted when the groups have no variance within them
+	EtaSquared *float64 `json:"eta_squared,omitempty"`
+	// Strength is the share of variance explained, r² or η², so both methods rank on the same scale
+	Strength float64 `json:"strength"`
+}
+
+// SkippedFeature is a column that could not be ranked against the target, with the reason
+type SkippedFeature struct {
+	Column string `json:"column"`
+	Reason string `json:"reason"`
+}
+
+// FeatureRanking ranks the columns of a dataset by their relationship with a target column, strongest first
+type FeatureRanking struct {
+	Target     string           `json:"target"`
+	TargetType string           `json:"target_type"`
+	Rows       int              `json:"rows"`
+	Features   []FeatureRank    `json:"features"`
+	Skipped    []SkippedFeature `json:"skipped,omitempty"`
+}
+
+// isCategorical reports whether a column type is compared by groups rather than by correlation
+func isCategorical(colType ColumnType) bool {
+	return colType == TypeText || colType == TypeBoolean
+}
+
+// alignedCategories returns one trimmed value per row of a column, empty where the cell is missing
+func (ca *CSVAnalyzer) alignedCategories(colIndex int) []string {
+	aligned := make([]string, len(ca.dataset.Rows))
+	for rowIndex, row := range ca.dataset.Rows {
+		if colIndex < len(row) {
+			aligned[rowIndex] = strings.TrimSpace(row[colIndex])
+		}
+	}
+	return aligned
+}
+
+// The anova function runs a one-way analysis of variance of numeric values grouped by categories, over the rows
+// where both are present (NaN and "" mark missing ones). The between-group sum of squares SSB = Σ nᵍ(x̄ᵍ - x̄)² and
+// the within-group SSW = Σ (x - x̄ᵍ)² give F = (SSB/(k-1)) / (SSW/(n-k)) for k groups of n rows and η² = SSB/(SSB+SSW),
+// the share of the variance of the values that the categories explain. F is infinite when the values never vary
+// within a group. It is undefined, and ok is false, with fewer than two groups, no more rows than groups, or
+// constant values.
+// anova returns F, η², the number of rows and the number of groups
+func anova(values []float64, categories []string) (f, etaSquared float64, pairs, levels int, ok bool) {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	var total float64
+	for i, value := range values {
+		if math.IsNaN(value) || categories[i] == "" {
+			continue
+		}
+		sums[categories[i]] += value
+		counts[categories[i]]++
+		total += value
+		pairs++
+	}
+	levels = len(counts)
+	if levels < 2 || pairs <= levels {
+		return 0, 0, pairs, levels, false
+	}
+	grandMean := total / float64(pairs)
+	var between, within float64
+	for category, sum := range sums {
+		mean := sum / float64(counts[category])
+		between += float64(counts[category]) * (mean - grandMean) * (mean - grandMean)
+	}
+	for i, value := range values {
+		if math.IsNaN(value) || categories[i] == "" {
+			continue
+		}
+		deviation := value - sums[categories[i]]/float64(counts[categories[i]])
+		within += deviation * deviation
+	}
+	if between+within <= 0 {
+		return 0, 0, pairs, levels, false
+	}
+	etaSquared = between / (between + within)
+	f = math.Inf(1)
+	if within > 0 {
+		f = (between / float64(levels-1)) / (within / float64(pairs-levels))
+	}
+	return f, etaSquared, pairs, levels, true
+}
+
+// The RankFeatures method is part of the CSVAnalyzer struct. It measures how strongly every other column is related
+// to the target column, for a quick triage of candidate features before modeling. A numeric column is compared with
+// a numeric target by the Pearson correlation; when either the column or the target is categorical (text or
+// boolean), the numeric one is analyzed by a one-way ANOVA over the categories of the other. Both give a share of
+// explained variance (r² or η²), by which the columns are ranked. Columns that cannot be compared this way are
+// listed as skipped: dates, pairs of categorical columns, categoricals with more than rankMaxCategories values and
+// columns with too few rows in common or no variance. The measures are linear and unadjusted, so they point at
+// candidates rather than prove anything.
+// RankFeatures ranks the columns by their relationship with the target
+func (ca *CSVAnalyzer) RankFeatures(target string) (*FeatureRanking, error) {
+	targetIndex := ca.columnIndex(target)
+	if targetIndex < 0 {
+		return nil, fmt.Errorf("target column %q not found", target)
+	}
+	targetType := ca.columnType(targetIndex)
+	if targetType != TypeNumeric && !isCategorical(targetType) {
+		return nil, fmt.Errorf("target column %q is %s; it must be numeric, text or boolean", target, targetType)
+	}
+	ranking := &FeatureRanking{Target: ca.dataset.Headers[targetIndex], TargetType: string(targetType), Rows: len(ca.dataset.Rows), Features: []FeatureRank{}}
+	if ranking.Rows > 0 {
+		defer ca.explain.stepOnce("rank features", "target "+ranking.Target, ranking.Rows)(ranking.Rows)
+	}
+
+	// The target is extracted once in the form its type is compared in.
+	var targetValues []float64
+	var targetCategories []string
+	if targetType == TypeNumeric {
+		targetValues = ca.alignedNumericValues(targetIndex)
+	} else {
+		targetCategories = ca.alignedCategories(targetIndex)
+		if levels := len(ca.extractUniqueValues(targetIndex)); levels > rankMaxCategories {
+			return nil, fmt.Errorf("target column %q has %d distinct values; a categorical target can have at most %d", target, levels, rankMaxCategories)
+		}
+	}
+
+	for colIndex, header := range ca.dataset.Headers {
+		if colIndex == targetIndex {
+			continue
+		}
+		colType := ca.columnType(colIndex)
+		rank := FeatureRank{Column: header, Type: string(colType)}
+		var reason string
+		switch {
+		case colType == TypeNumeric && targetType == TypeNumeric:
+			rank.Method = "pearson"
+			r, pairs, ok := correlation(ca.alignedNumericValues(colIndex), targetValues)
+			rank.Pairs = pairs
+			if !ok {
+				reason = "too few rows in common or no variance"
+				break
+			}
+			rank.R, rank.Strength = &r, r*r
+		case colType == TypeNumeric || (isCategorical(colType) && targetType == TypeNumeric):
+			rank.Method = "anova"
+			values, categories := targetValues, targetCategories
+			if colType == TypeNumeric {
+				values = ca.alignedNumericValues(colIndex)
+			} else if levels := len(ca.extractUniqueValues(colIndex)); levels > rankMaxCategories {
+				reason = fmt.Sprintf("%d distinct values (more than %d)", levels, rankMaxCategories)
+				break
+			} else {
+				categories = ca.alignedCategories(colIndex)
+			}
+			f, etaSquared, pairs, levels, ok := anova(values, categories)
+			rank.Pairs, rank.Levels = pairs, levels
+			if !ok {
+				reason = "too few rows or groups in common, or no variance"
+				break
+			}
+			if !math.IsInf(f, 1) {
+				rank.F = &f
+			}
+			rank.EtaSquared, rank.Strength = &etaSquared, etaSquared
+		case isCategorical(colType):
+			reason = "categorical column against a categorical target"
+		default:
+			reason = strings.ToLower(string(colType)) + " column"
+		}
+		if reason != "" {
+			ranking.Skipped = append(ranking.Skipped, SkippedFeature{Column: header, Reason: reason})
+			continue
+		}
+		ranking.Features = append(ranking.Features, rank)
+	}
+	sort.SliceStable(ranking.Features, func(i, j int) bool {
+		return ranking.Features[i].Strength > ranking.Features[j].Strength
+	})
+	return ranking, nil
+}
+
+// printFeatureRanking shows the ranked columns as a table, strongest relationship first
+func printFeatureRanking(ranking *FeatureRanking) {
+	fmt.Printf("Features ranked by their relationship with %s (%s, %d rows):\n\n", ranking.Target, ranking.TargetType, ranking.Rows)
+	if len(ranking.Features) == 0 {
+		fmt.Println("  No column could be compared with the target")
+	} else {
+		fmt.Printf("  %-3s %-24s %-8s %-7s %8s %8s %8s %10s\n", "#", "Column", "Type", "Method", "Strength", "r", "F", "Rows")
+		for i, rank := range ranking.Features {
+			r, f := "", ""
+			if rank.R != nil {
+				r = fmt.Sprintf("%+.3f", *rank.R)
+			}
+			if rank.F != nil {
+				f = formatMetric(*rank.F)
+			} else if rank.EtaSquared != nil {
+				f = "inf"
+			}
+			fmt.Printf("  %-3d %s %-8s %-7s %8.3f %8s %8s %10d\n", i+1, fit(rank.Column, 24), rank.Type, rank.Method, rank.Strength, r, f, rank.Pairs)
+		}
+		fmt.Println("\n  Strength is the share of the variance explained: r² for a correlation, η² for an ANOVA.")
+	}
+	for _, skipped := range ranking.Skipped {
+		fmt.Printf("  Skipped %s: %s\n", skipped.Column, skipped.Reason)
+	}
+}
+
+// runRankFeatures implements the rank-features subcommand, which ranks every column of a file by its relationship
+// with a target column
+func runRankFeatures(args []string) {
+	// Reuses the analyzer flags so --types, --preset and the other loading options apply too.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	target := fs.String("target", "", "`column` to rank the other columns against")
+	asJSON := fs.Bool("json", false, "print the ranking as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . rank-features --target <column> [--json] [options] <csv-file>")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 1 || *target == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+	// The measures are exact figures over all rows, which differential privacy does not allow.
+	if opts.PrivacyEpsilon > 0 {
+		log.Fatal("Invalid options: rank-features cannot be combined with --dp-epsilon")
+	}
+
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	if err := analyzer.LoadCSV(positional[0]); err != nil {
+		log.Fatal("Error loading CSV:", err)
+	}
+	analyzer.enforceStrict()
+	ranking, err := analyzer.RankFeatures(*target)
+	if err != nil {
+		log.Fatal("Error ranking features:", err)
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(ranking); err != nil {
+			log.Fatal("Error encoding feature ranking:", err)
+		}
+	} else {
+		printFeatureRanking(ranking)
+	}
+	analyzer.explain.report(os.Stderr)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// geVersion is the Great Expectations release whose suite format we emit
+const geVersion = "0.18.0"
+
+// GEExpectation is a single expectation in a Great Expectations suite
+type GEExpectation struct {
+	ExpectationType string                 `json:"expectation_type"`
+	Kwargs          map[string]interface{} `json:"kwargs"`
+	Meta            map[string]interface{} `json:"meta"`
+}
+
+// GEExpectationSuite mirrors the JSON layout of a Great Expectations expectation suite
+type GEExpectationSuite struct {
+	ExpectationSuiteName string                 `json:"expectation_suite_name"`
+	DataAssetType        interface{}            `json:"data_asset_type"`
+	Expectations         []GEExpectation        `json:"expectations"`
+	Meta                 map[string]interface{} `json:"meta"`
+}
+
+// The BuildExpectationSuite method is part of the CSVAnalyzer struct. It translates the constraints mined from the
+// loaded data into Great Expectations expectations: the table's column list, per-column not-null, uniqueness and type
+// expectations, numeric ranges and categorical value sets. The rules the run was given follow them: the value rules
+// of --rules and the --nonnegative, --between and --unique checks. Each expectation carries a meta note saying where
+// it came from, "inferred" or "configured", so anyone reviewing the suite knows which parts were inferred and should
+// be tightened by hand.
+// BuildExpectationSuite converts mined constraints into a Great Expectations suite
+func (ca *CSVAnalyzer) BuildExpectationSuite(name string) GEExpectationSuite {
+	// Starts the suite with the metadata Great Expectations expects to find.
+	suite := GEExpectationSuite{
+		ExpectationSuiteName: name,
+		Meta: map[string]interface{}{
+			"great_expectations_version": geVersion,
+			"generated_by":               "csv-analyzer",
+		},
+	}
+
+	// Copies the headers so the suite does not alias the dataset's slice.
+	columns := make([]string, len(ca.dataset.Headers))
+	copy(columns, ca.dataset.Headers)
+	// The first expectation pins the exact column list and order.
+	suite.Expectations = append(suite.Expectations, newGEExpectation(
+		"expect_table_columns_to_match_ordered_list",
+		map[string]interface{}{"column_list": columns},
+		"inferred",
+	))
+
+	// Translates every mined constraint into its Great Expectations equivalent.
+	for _, c := range ca.MineConstraints() {
+		if exp, ok := constraintToExpectation(c); ok {
+			suite.Expectations = append(suite.Expectations, exp)
+		}
+	}
+	// Adds the rules the run was configured with.
+	for _, rule := range ca.valueRules {
+		suite.Expectations = append(suite.Expectations, ruleToExpectation(rule))
+	}
+	suite.Expectations = append(suite.Expectations, ca.options.quickCheckExpectations()...)
+	// Returns the completed suite.
+	return suite
+}
+
+// constraintToExpectation maps a mined constraint onto a Great Expectations expectation.
+// The boolean result is false when the constraint has no equivalent expectation.
+func constraintToExpectation(c Constraint) (GEExpectation, bool) {
+	// Every expectation targets a single column.
+	kwargs := map[string]interface{}{"column": c.Column}
+	// Picks the expectation type and extra arguments based on the constraint kind.
+	switch c.Kind {
+	case ConstraintNotNull:
+		return newGEExpectation("expect_column_values_to_not_be_null", kwargs, "inferred"), true
+	case ConstraintUnique:
+		return newGEExpectation("expect_column_values_to_be_unique", kwargs, "inferred"), true
+	case ConstraintType:
+		// Great Expectations compares against backend type names, so list the common ones.
+		switch ColumnType(c.Type) {
+		case TypeNumeric:
+			kwargs["type_list"] = []string{"int", "int64", "float", "float64", "INTEGER", "FLOAT", "DOUBLE", "NUMERIC"}
+		case TypeBoolean:
+			// Boolean-ish files are often loaded as strings or integers, so accept those too.
+			kwargs["type_list"] = []string{"bool", "boolean", "BOOLEAN", "str", "object", "int", "int64"}
+		case TypeDate:
+			// Dates stay strings unless the loader parses them, so accept both.
+			kwargs["type_list"] = []string{"datetime64", "datetime64[ns]", "Timestamp", "DATE", "TIMESTAMP", "str", "object"}
+		default:
+			kwargs["type_list"] = []string{"str", "object", "string", "VARCHAR", "TEXT"}
+		}
+		return newGEExpectation("expect_column_values_to_be_in_type_list", kwargs, "inferred"), true
+	case ConstraintRange:
+		setGEBound(kwargs, "min_value", c.Min)
+		setGEBound(kwargs, "max_value", c.Max)
+		if len(kwargs) == 1 {
+			return GEExpectation{}, false
+		}
+		return newGEExpectation("expect_column_values_to_be_between", kwargs, "inferred"), true
+	case ConstraintValueSet:
+		kwargs["value_set"] = c.Values
+		return newGEExpectation("expect_column_values_to_be_in_set", kwargs, "inferred"), true
+	}
+	// Unknown constraint kinds are skipped.
+	return GEExpectation{}, false
+}
+
+// ruleToExpectation maps a value rule of --rules onto its Great Expectations expectation
+func ruleToExpectation(rule ValueRule) GEExpectation {
+	kwargs := map[string]interface{}{"column": rule.Column}
+	expectationType := "expect_column_values_to_be_between"
+	switch rule.Kind {
+	case RuleMin:
+		setGEBound(kwargs, "min_value", rule.Min)
+	case RuleMax:
+		setGEBound(kwargs, "max_value", rule.Max)
+	case RuleBetween:
+		setGEBound(kwargs, "min_value", rule.Min)
+		setGEBound(kwargs, "max_value", rule.Max)
+	case RuleIn:
+		expectationType = "expect_column_values_to_be_in_set"
+		kwargs["value_set"] = rule.Values
+	case RuleNotNull:
+		expectationType = "expect_column_values_to_not_be_null"
+	case RuleUnique:
+		expectationType = "expect_column_values_to_be_unique"
+	case RulePattern:
+		// The pattern is already anchored to the whole value, as the rule matches it.
+		expectationType = "expect_column_values_to_match_regex"
+		kwargs["regex"] = rule.pattern.String()
+	case RuleMinLength:
+		expectationType = "expect_column_value_lengths_to_be_between"
+		kwargs["min_value"] = int(rule.Min)
+	case RuleMaxLength:
+		expectationType = "expect_column_value_lengths_to_be_between"
+		kwargs["max_value"] = int(rule.Max)
+	}
+	return newGEExpectation(expectationType, kwargs, "configured")
+}
+
+// The quickCheckExpectations method is part of the Options struct. It maps the --nonnegative and --between checks
+// onto range expectations, open on the side a bound was left out, and the --unique keys onto uniqueness
+// expectations, of the column or, for a key of several columns, of their combination.
+// quickCheckExpectations returns the expectations of the built-in checks
+func (opts *Options) quickCheckExpectations() []GEExpectation {
+	var expectations []GEExpectation
+	for _, column := range opts.NonNegative {
+		kwargs := map[string]interface{}{"column": column, "min_value": 0}
+		expectations = append(expectations, newGEExpectation("expect_column_values_to_be_between", kwargs, "configured"))
+	}
+	for _, check := range opts.Between {
+		kwargs := map[string]interface{}{"column": check.Column}
+		if check.Min != nil {
+			setGEBound(kwargs, "min_value", *check.Min)
+		}
+		if check.Max != nil {
+			setGEBound(kwargs, "max_value", *check.Max)
+		}
+		expectations = append(expectations, newGEExpectation("expect_column_values_to_be_between", kwargs, "configured"))
+	}
+	for _, key := range opts.UniqueKeys {
+		if len(key) == 1 {
+			kwargs := map[string]interface{}{"column": key[0]}
+			expectations = append(expectations, newGEExpectation("expect_column_values_to_be_unique", kwargs, "configured"))
+			continue
+		}
+		kwargs := map[string]interface{}{"column_list": append([]string(nil), key...)}
+		expectations = append(expectations, newGEExpectation("expect_compound_columns_to_be_unique", kwargs, "configured"))
+	}
+	return expectations
+}
+
+// setGEBound sets a bound of a range expectation unless it is infinite or NaN, which JSON has no number for and which
+// leaves that side open
+func setGEBound(kwargs map[string]interface{}, key string, bound float64) {
+	if !math.IsInf(bound, 0) && !math.IsNaN(bound) {
+		kwargs[key] = bound
+	}
+}
+
+// newGEExpectation builds an expectation and records its origin in the meta block
+func newGEExpectation(expectationType string, kwargs map[string]interface{}, source string) GEExpectation {
+	return GEExpectation{
+		ExpectationType: expectationType,
+		Kwargs:          kwargs,
+		Meta:            map[string]interface{}{"source": source},
+	}
+}
+
+// WriteExpectationSuite builds the suite and writes it as indented JSON to the given path
+func (ca *CSVAnalyzer) WriteExpectationSuite(path, name string) error {
+	// Builds the suite from the currently loaded data.
+	suite := ca.BuildExpectationSuite(name)
+	// Encodes the suite as human-readable JSON.
+	data, err := json.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding expectation suite: %v", err)
+	}
+	// Writes the encoded suite to disk, compressed when --compress is set.
+	if err := writeOutputFile(path, ca.options.Compress, append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing expectation suite: %v", err)
+	}
+	return nil
+}
+
+// defaultSuiteName derives a suite name from the input file name, e.g. "sales.csv" -> "sales.warning"
+func defaultSuiteName(filename string) string {
+	// Strips the directory and extension from the file name.
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	// Great Expectations conventionally suffixes generated suites with ".warning".
+	return base + ".warning"
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpectationSuiteWithNonFiniteCells(t *testing.T) {
+	analyzer := loadTestCSV(t, "a,b,c\n1,x,2\nNaN,y,3\nInf,x,4\n5,y,-Inf\n")
+	suite := analyzer.BuildExpectationSuite("data.warning")
+	if _, err := json.Marshal(suite); err != nil {
+		t.Fatalf("suite does not encode: %v", err)
+	}
+	ranges := map[string]map[string]interface{}{}
+	for _, exp := range suite.Expectations {
+		if exp.ExpectationType == "expect_column_values_to_be_between" {
+			ranges[exp.Kwargs["column"].(string)] = exp.Kwargs
+		}
+	}
+	// a is open above because of its Inf cell, and its NaN cell is left out; c is open below.
+	if want := map[string]interface{}{"column": "a", "min_value": 1.0}; !reflect.DeepEqual(ranges["a"], want) {
+		t.Errorf("range of a = %v, want %v", ranges["a"], want)
+	}
+	if want := map[string]interface{}{"column": "c", "max_value": 4.0}; !reflect.DeepEqual(ranges["c"], want) {
+		t.Errorf("range of c = %v, want %v", ranges["c"], want)
+	}
+}
+
+func TestExpectationSuiteConfiguredRules(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	rules := "a: {between: [0, 10]}\nb: {in: [x, y], pattern: \"[a-z]+\"}\n"
+	if err := os.WriteFile(rulesPath, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	analyzer := loadTestCSV(t, "a,b,c\n1,x,2\n2,y,3\n", "--nonnegative", "c", "--between", "a=..5",
+		"--unique", "a", "--unique", "a,b")
+	var err error
+	if analyzer.valueRules, err = LoadRules(rulesPath); err != nil {
+		t.Fatal(err)
+	}
+	var configured []GEExpectation
+	for _, exp := range analyzer.BuildExpectationSuite("data.warning").Expectations {
+		if exp.Meta["source"] == "configured" {
+			configured = append(configured, exp)
+		}
+	}
+	want := []GEExpectation{
+		newGEExpectation("expect_column_values_to_be_between", map[string]interface{}{"column": "a", "min_value": 0.0, "max_value": 10.0}, "configured"),
+		newGEExpectation("expect_column_values_to_be_in_set", map[string]interface{}{"column": "b", "value_set": []string{"x", "y"}}, "configured"),
+		newGEExpectation("expect_column_values_to_match_regex", map[string]interface{}{"column": "b", "regex": "^(?:[a-z]+)$"}, "configured"),
+		newGEExpectation("expect_column_values_to_be_between", map[string]interface{}{"column": "c", "min_value": 0}, "configured"),
+		newGEExpectation("expect_column_values_to_be_between", map[string]interface{}{"column": "a", "max_value": 5.0}, "configured"),
+		newGEExpectation("expect_column_values_to_be_unique", map[string]interface{}{"column": "a"}, "configured"),
+		newGEExpectation("expect_compound_columns_to_be_unique", map[string]interface{}{"column_list": []string{"a", "b"}}, "configured"),
+	}
+	if !reflect.DeepEqual(configured, want) {
+		t.Errorf("configured expectations:\n got %v\nwant %v", configured, want)
+	}
+}
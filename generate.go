@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// generatorKinds are the column types `generate` produces, in the order their columns appear
+var generatorKinds = []string{"numeric", "integer", "category", "text", "bool", "date"}
+
+// defaultGeneratorTypes is the default --types mix: two numeric columns for every one of each other type
+const defaultGeneratorTypes = "numeric=2,integer=1,category=1,text=1,bool=1,date=1"
+
+// generatorWords are the words the free-text columns of generated data are made of
+var generatorWords = []string{
+	"fast", "quiet", "blue", "compact", "premium", "steel", "wireless", "classic", "travel", "organic",
+	"desk", "lamp", "chair", "bottle", "cable", "mug", "notebook", "speaker", "backpack", "monitor",
+}
+
+// GeneratorSpec describes a synthetic dataset: its size, the mix of column types, how often cells are empty and how
+// many distinct values the category columns take
+type GeneratorSpec struct {
+	Rows        int
+	Columns     int
+	Types       map[string]int
+	NullRate    float64
+	Cardinality int
+	Seed        int64
+}
+
+// parseGeneratorTypes reads a --types mix such as "numeric=3,category=1" into weights per column type
+func parseGeneratorTypes(value string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		kind, weight, ok := strings.Cut(entry, "=")
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		n := 1
+		if ok {
+			var err error
+			if n, err = strconv.Atoi(strings.TrimSpace(weight)); err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid weight %q for %s (expected a whole number of at least 0)", weight, kind)
+			}
+		}
+		known := false
+		for _, k := range generatorKinds {
+			known = known || k == kind
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column type %q (expected %s)", kind, strings.Join(generatorKinds, ", "))
+		}
+		weights[kind] = n
+	}
+	total := 0
+	for _, n := range weights {
+		total += n
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("the type mix %q gives no column a weight", value)
+	}
+	return weights, nil
+}
+
+// The columnKinds method is part of the GeneratorSpec struct. It shares the columns out between the types in
+// proportion to their weights, by largest remainder so the counts add up to Columns exactly ("numeric=1,bool=1" over
+// five columns gives three numeric and two bool; ties go to the type listed first in generatorKinds). The columns of a
+// type are kept together, in the order of generatorKinds.
+// columnKinds returns the type of every generated column
+func (spec GeneratorSpec) columnKinds() []string {
+	total := 0
+	for _, kind := range generatorKinds {
+		total += spec.Types[kind]
+	}
+	counts := make(map[string]int)
+	remainders := make(map[string]float64)
+	assigned := 0
+	for _, kind := range generatorKinds {
+		share := float64(spec.Columns*spec.Types[kind]) / float64(total)
+		counts[kind] = int(share)
+		remainders[kind] = share - math.Floor(share)
+		assigned += counts[kind]
+	}
+	byRemainder := append([]string(nil), generatorKinds...)
+	sort.SliceStable(byRemainder, func(i, j int) bool { return remainders[byRemainder[i]] > remainders[byRemainder[j]] })
+	for i := 0; assigned < spec.Columns; i++ {
+		counts[byRemainder[i%len(byRemainder)]]++
+		assigned++
+	}
+	var kinds []string
+	for _, kind := range generatorKinds {
+		for i := 0; i < counts[kind]; i++ {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// The Generate method is part of the GeneratorSpec struct. It writes a synthetic CSV of the spec's size: a header of
+// columns named after their type (numeric_1, numeric_2, category_1, ...) and Rows rows, written one at a time so
+// millions of rows need no more memory than one. Numeric columns are normally distributed around 100 with two
+// decimals, integer columns uniform from 0 to 999, category columns uniform over Cardinality labels, text columns two
+// to four random words, bool columns true or false and date columns days from 2022 to 2024; every cell is left empty
+// with probability NullRate. The same seed writes the same file, so benchmarks and pipeline tests can be repeated.
+// Generate writes the synthetic dataset to w
+func (spec GeneratorSpec) Generate(w io.Writer) error {
+	rng := rand.New(rand.NewSource(spec.Seed))
+	kinds := spec.columnKinds()
+	header := make([]string, len(kinds))
+	numbers := make(map[string]int)
+	for i, kind := range kinds {
+		numbers[kind]++
+		header[i] = fmt.Sprintf("%s_%d", kind, numbers[kind])
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("error writing CSV: %v", err)
+	}
+	start := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	row := make([]string, len(kinds))
+	for r := 0; r < spec.Rows; r++ {
+		for i, kind := range kinds {
+			if spec.NullRate > 0 && rng.Float64() < spec.NullRate {
+				row[i] = ""
+				continue
+			}
+			switch kind {
+			case "numeric":
+				row[i] = strconv.FormatFloat(100+25*rng.NormFloat64(), 'f', 2, 64)
+			case "integer":
+				row[i] = strconv.Itoa(rng.Intn(1000))
+			case "category":
+				row[i] = fmt.Sprintf("%s_v%d", header[i], rng.Intn(spec.Cardinality)+1)
+			case "text":
+				words := make([]string, 2+rng.Intn(3))
+				for j := range words {
+					words[j] = generatorWords[rng.Intn(len(generatorWords))]
+				}
+				row[i] = strings.Join(words, " ")
+			case "bool":
+				row[i] = strconv.FormatBool(rng.Intn(2) == 1)
+			case "date":
+				row[i] = start.AddDate(0, 0, rng.Intn(3*365)).Format("2006-01-02")
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing CSV: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing CSV: %v", err)
+	}
+	return nil
+}
+
+// runGenerate implements `generate [--rows n] [--columns n] [--types mix] [--null-rate p] [--cardinality n]`: it
+// writes a synthetic CSV for benchmarking the analyzer and testing downstream pipelines
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	rows := fs.Int("rows", 1000, "number of data `rows`")
+	columns := fs.Int("columns", 7, "number of `columns`")
+	types := fs.String("types", defaultGeneratorTypes, "`mix` of column types by weight: numeric, integer, category, text, bool and date")
+	nullRate := fs.Float64("null-rate", 0, "`fraction` of cells left empty, from 0 to 1")
+	cardinality := fs.Int("cardinality", 10, "number of distinct `values` in each category column")
+	seed := fs.Int64("seed", 0, "random `seed`; the same seed writes the same file (default: the current time)")
+	output := fs.String("output", "", "write the data to `file` instead of stdout")
+	compress := fs.String("compress", "", "compress the data with `method` gz (the file gets a .gz suffix)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . generate [--rows n] [--columns n] [--types mix] [--null-rate p] [--cardinality n] [--seed seed] [--output file]")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	spec := GeneratorSpec{Rows: *rows, Columns: *columns, NullRate: *nullRate, Cardinality: *cardinality, Seed: *seed}
+	switch {
+	case *rows < 0:
+		err = fmt.Errorf("--rows must not be negative")
+	case *columns < 1:
+		err = fmt.Errorf("--columns must be at least 1")
+	case *nullRate < 0 || *nullRate > 1:
+		err = fmt.Errorf("--null-rate must be between 0 and 1")
+	case *cardinality < 1:
+		err = fmt.Errorf("--cardinality must be at least 1")
+	case *compress != "" && *compress != CompressGzip:
+		err = fmt.Errorf("unknown --compress method %q (expected gz)", *compress)
+	default:
+		spec.Types, err = parseGeneratorTypes(*types)
+	}
+	if err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+	if spec.Seed == 0 {
+		spec.Seed = time.Now().UnixNano()
+	}
+
+	w := compressWriter(os.Stdout, *compress)
+	if *output != "" {
+		if w, err = createOutputFile(*output, *compress); err != nil {
+			log.Fatal("Error creating output file:", err)
+		}
+	}
+	began := time.Now()
+	err = spec.Generate(w)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal("Error generating data:", err)
+	}
+	fmt.Fprintf(os.Stderr, "Generated %d rows of %d columns (seed %d) in %s\n", spec.Rows, spec.Columns, spec.Seed, time.Since(began).Round(time.Millisecond))
+}
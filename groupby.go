@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// GroupStats summarizes one numeric column within one group of rows
+type GroupStats struct {
+	Group string  `json:"group"`
+	Count int     `json:"count"` // rows of the group with a numeric value in the column
+	Mean  float64 `json:"mean"`
+	// Deviation and DeviationPct compare the group mean with the overall mean (only with --group-deviation);
+	// DeviationPct is nil when the overall mean is zero
+	Deviation    *float64 `json:"deviation,omitempty"`
+	DeviationPct *float64 `json:"deviation_pct,omitempty"`
+}
+
+// GroupByColumn holds the per-group summary of one numeric column
+type GroupByColumn struct {
+	Column      string       `json:"column"`
+	OverallMean float64      `json:"overall_mean"`
+	Groups      []GroupStats `json:"groups"`
+}
+
+// GroupByReport holds the per-group summaries of every numeric column
+type GroupByReport struct {
+	By      string          `json:"by"`
+	Columns []GroupByColumn `json:"columns"`
+}
+
+// The GroupBy method is part of the CSVAnalyzer struct. It splits the rows by the value of the --group-by column and
+// summarizes every numeric column within each group, largest group first (empty values form a group of their own).
+// With --group-deviation each group also gets the difference between its mean and the overall mean of the column,
+// absolute and as a percentage of the overall mean, so the anomalous groups stand out without manual arithmetic.
+// Group figures of small groups reveal individual rows, so they are withheld under differential privacy.
+// GroupBy returns the per-group summary, or nil when no --group-by column was given
+func (ca *CSVAnalyzer) GroupBy() *GroupByReport {
+	if ca.options.GroupBy == "" || ca.privacy != nil {
+		return nil
+	}
+	endStep := ca.explain.stepOnce("group-by", ca.options.GroupBy, len(ca.dataset.Rows))
+	byIndex := ca.columnIndex(ca.options.GroupBy)
+	groupOf := func(row []string) string {
+		if byIndex < len(row) {
+			return strings.TrimSpace(row[byIndex])
+		}
+		return ""
+	}
+	// Orders the groups by size, then by value so the table is stable.
+	sizes := make(map[string]int)
+	for _, row := range ca.dataset.Rows {
+		sizes[groupOf(row)]++
+	}
+	groups := make([]string, 0, len(sizes))
+	for group := range sizes {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if sizes[groups[i]] != sizes[groups[j]] {
+			return sizes[groups[i]] > sizes[groups[j]]
+		}
+		return groups[i] < groups[j]
+	})
+
+	report := &GroupByReport{By: ca.options.GroupBy, Columns: ca.groupNumericColumns(groupOf, groups, byIndex, ca.options.GroupDeviation)}
+	endStep(len(groups))
+	return report
+}
+
+// The groupNumericColumns method is part of the CSVAnalyzer struct. It summarizes every numeric column except skip
+// within each of the groups that groupOf assigns rows to, in the order given, optionally with each group's deviation
+// from the overall mean. Groups without numeric values in a column are left out of that column.
+// groupNumericColumns returns the per-group summary of the numeric columns
+func (ca *CSVAnalyzer) groupNumericColumns(groupOf func(row []string) string, groups []string, skip int, withDeviation bool) []GroupByColumn {
+	var columns []GroupByColumn
+	for colIndex, header := range ca.dataset.Headers {
+		if colIndex == skip || ca.columnType(colIndex) != TypeNumeric {
+			continue
+		}
+		// Collects the column's values per group.
+		values := make(map[string][]float64)
+		var all []float64
+		for _, row := range ca.dataset.Rows {
+			if colIndex >= len(row) {
+				continue
+			}
+			if num, err := ca.parseNumber(strings.TrimSpace(row[colIndex])); err == nil {
+				values[groupOf(row)] = append(values[groupOf(row)], num)
+				all = append(all, num)
+			}
+		}
+		if len(all) == 0 {
+			continue
+		}
+		column := GroupByColumn{Column: header, OverallMean: sum(all) / float64(len(all))}
+		for _, group := range groups {
+			if len(values[group]) == 0 {
+				continue
+			}
+			stats := GroupStats{Group: group, Count: len(values[group]), Mean: sum(values[group]) / float64(len(values[group]))}
+			if withDeviation {
+				deviation := stats.Mean - column.OverallMean
+				stats.Deviation = &deviation
+				if column.OverallMean != 0 {
+					pct := 100 * deviation / math.Abs(column.OverallMean)
+					stats.DeviationPct = &pct
+				}
+			}
+			column.Groups = append(column.Groups, stats)
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// printGroupBy shows the per-group summary of every numeric column in the text report
+func printGroupBy(report *GroupByReport) {
+	if report == nil {
+		return
+	}
+	fmt.Printf("\n\nGroup Summary by %s:\n", report.By)
+	fmt.Println(strings.Repeat("-", len("Group Summary by :")+len(report.By)))
+	printGroupColumns(report.Columns)
+}
+
+// printGroupColumns shows the per-group lines of every summarized column
+func printGroupColumns(columns []GroupByColumn) {
+	for _, column := range columns {
+		fmt.Printf("\n%s (overall mean %s):\n", column.Column, reportMetric(column.OverallMean))
+		for _, stats := range column.Groups {
+			group := stats.Group
+			if group == "" {
+				group = "(empty)"
+			}
+			line := fmt.Sprintf("  %-24s %8d rows  mean %12s", group, stats.Count, reportMetric(stats.Mean))
+			if stats.Deviation != nil {
+				deviation := reportNumber(*stats.Deviation)
+				if *stats.Deviation >= 0 {
+					deviation = "+" + deviation
+				}
+				line += fmt.Sprintf("  %12s", deviation)
+				if stats.DeviationPct != nil {
+					sign := ""
+					if *stats.DeviationPct >= 0 {
+						sign = "+"
+					}
+					line += fmt.Sprintf(" (%s%s%%)", sign, localizedNumber(*stats.DeviationPct, 1))
+				}
+			}
+			fmt.Println(line)
+		}
+	}
+}
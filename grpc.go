@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// grpcServicePrefix is the path prefix of the methods of the Analyzer service in analyzer.proto
+const grpcServicePrefix = "/csvanalyzer.v1.Analyzer/"
+
+// gRPC status codes the service answers with
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcNotFound          = 5
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+// grpcError is a failed call with the status code it is answered with
+type grpcError struct {
+	code    int
+	message string
+}
+
+// Error returns the status message
+func (e *grpcError) Error() string {
+	return e.message
+}
+
+// grpcErrorf returns a grpcError with a formatted message
+func grpcErrorf(code int, format string, args ...interface{}) error {
+	return &grpcError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// Protocol buffer wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoField is one field of an encoded protocol buffer message; varint holds numeric values and bytes the
+// length-delimited ones
+type protoField struct {
+	number   int
+	wireType int
+	varint   uint64
+	bytes    []byte
+}
+
+// parseProto splits an encoded protocol buffer message into its fields, in the order they were written
+func parseProto(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("malformed field tag")
+		}
+		data = data[n:]
+		field := protoField{number: int(tag >> 3), wireType: int(tag & 7)}
+		switch field.wireType {
+		case protoVarint:
+			if field.varint, n = binary.Uvarint(data); n <= 0 {
+				return nil, fmt.Errorf("malformed varint in field %d", field.number)
+			}
+			data = data[n:]
+		case protoFixed64, protoFixed32:
+			size := 8
+			if field.wireType == protoFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return nil, fmt.Errorf("truncated field %d", field.number)
+			}
+			data = data[size:]
+		case protoBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return nil, fmt.Errorf("truncated field %d", field.number)
+			}
+			field.bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d in field %d", field.wireType, field.number)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// appendProtoTag appends the tag of a field
+func appendProtoTag(b []byte, number, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+// appendProtoBytes appends a length-delimited field: a string, bytes or an embedded message
+func appendProtoBytes(b []byte, number int, value []byte) []byte {
+	b = appendProtoTag(b, number, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// appendProtoInt appends an integer or bool field
+func appendProtoInt(b []byte, number int, value int64) []byte {
+	b = appendProtoTag(b, number, protoVarint)
+	return binary.AppendUvarint(b, uint64(value))
+}
+
+// appendProtoDouble appends a double field
+func appendProtoDouble(b []byte, number int, value float64) []byte {
+	b = appendProtoTag(b, number, protoFixed64)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(value))
+}
+
+// grpcRequest is the decoded AnalyzeFileRequest, RowChunk or GetStatsRequest, which share their field numbers
+type grpcRequest struct {
+	name    string
+	data    []byte
+	options map[string]string
+	columns []string
+}
+
+// decodeGRPCRequest decodes a request message; fields it does not know are skipped as protobuf requires
+func decodeGRPCRequest(message []byte) (grpcRequest, error) {
+	request := grpcRequest{options: make(map[string]string)}
+	fields, err := parseProto(message)
+	if err != nil {
+		return request, err
+	}
+	for _, field := range fields {
+		if field.wireType != protoBytes {
+			continue
+		}
+		switch field.number {
+		case 1:
+			request.name = string(field.bytes)
+		case 2:
+			request.data = field.bytes
+		case 3:
+			// Map entries are messages with the key in field 1 and the value in field 2.
+			entry, err := parseProto(field.bytes)
+			if err != nil {
+				return request, fmt.Errorf("malformed option: %v", err)
+			}
+			var key, value string
+			for _, part := range entry {
+				switch {
+				case part.number == 1 && part.wireType == protoBytes:
+					key = string(part.bytes)
+				case part.number == 2 && part.wireType == protoBytes:
+					value = string(part.bytes)
+				}
+			}
+			request.options[key] = value
+		case 4:
+			request.columns = append(request.columns, string(field.bytes))
+		}
+	}
+	return request, nil
+}
+
+// encodeAnalyzeResponse encodes the AnalyzeResponse of a report
+func encodeAnalyzeResponse(report Report, reportJSON []byte) []byte {
+	b := appendProtoBytes(nil, 1, reportJSON)
+	b = appendProtoInt(b, 2, int64(report.Rows))
+	b = appendProtoInt(b, 3, int64(report.ColumnCount))
+	b = appendProtoInt(b, 4, int64(len(report.Warnings)))
+	b = appendProtoInt(b, 5, int64(len(activeFailures(report.Alerts))))
+	if hasCriticalFailure(report.Alerts) {
+		b = appendProtoInt(b, 6, 1)
+	}
+	return b
+}
+
+// The encodeStatsResponse function encodes the GetStatsResponse of a report, with a ColumnSummary per requested
+// column in header order. The value counts come from the cardinality profile and the statistics from the numeric
+// ones, so fields of a column that has neither are left unset rather than sent as zero.
+// encodeStatsResponse encodes the headline statistics of the report's columns
+func encodeStatsResponse(report Report, columns []string) ([]byte, error) {
+	wanted := make(map[string]bool)
+	for _, column := range columns {
+		wanted[column] = true
+	}
+	for _, column := range report.Columns {
+		delete(wanted, column.Name)
+	}
+	if len(wanted) > 0 {
+		return nil, grpcErrorf(grpcNotFound, "columns not found: %s", strings.Join(sortedKeys(wanted), ", "))
+	}
+
+	b := appendProtoInt(nil, 1, int64(report.Rows))
+	for _, column := range report.Columns {
+		if len(columns) > 0 && !containsString(columns, column.Name) {
+			continue
+		}
+		summary := appendProtoBytes(nil, 1, []byte(column.Name))
+		summary = appendProtoBytes(summary, 2, []byte(column.Type))
+		if c := column.Cardinality; c != nil {
+			summary = appendProtoInt(summary, 3, int64(c.NonEmpty))
+			summary = appendProtoInt(summary, 4, int64(report.Rows-c.NonEmpty))
+			summary = appendProtoInt(summary, 5, int64(c.Distinct))
+		}
+		if n := column.Numeric; n != nil {
+			summary = appendProtoDouble(summary, 6, n.Mean)
+			summary = appendProtoDouble(summary, 7, n.StdDev)
+			summary = appendProtoDouble(summary, 8, n.Min)
+			summary = appendProtoDouble(summary, 9, n.Median)
+			summary = appendProtoDouble(summary, 10, n.Max)
+		}
+		b = appendProtoBytes(b, 2, summary)
+	}
+	return b, nil
+}
+
+// containsString reports whether values holds value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// The readGRPCMessage function reads one length-prefixed message of a gRPC stream: a compression flag byte, the
+// length as four big-endian bytes and the message. Messages compressed with gzip are decompressed; other encodings
+// are refused. io.EOF marks the clean end of the stream.
+// readGRPCMessage reads the next message of a request stream, of at most limit bytes
+func readGRPCMessage(r io.Reader, encoding string, limit int64) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, grpcErrorf(grpcInvalidArgument, "error reading message: %v", err)
+	}
+	length := int64(binary.BigEndian.Uint32(prefix[1:]))
+	if length > limit {
+		return nil, grpcErrorf(grpcResourceExhausted, "message is larger than %d bytes", limit)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "error reading message: %v", err)
+	}
+	if prefix[0] == 0 {
+		return message, nil
+	}
+	if encoding != "gzip" {
+		return nil, grpcErrorf(grpcUnimplemented, "message compression %q is not supported (use gzip or none)", encoding)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(message))
+	if err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "error decompressing message: %v", err)
+	}
+	message, err = io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "error decompressing message: %v", err)
+	}
+	if int64(len(message)) > limit {
+		return nil, grpcErrorf(grpcResourceExhausted, "message is larger than %d bytes", limit)
+	}
+	return message, nil
+}
+
+// writeGRPCMessage writes one uncompressed length-prefixed message
+func writeGRPCMessage(w io.Writer, message []byte) error {
+	prefix := [5]byte{}
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(message)
+	return err
+}
+
+// grpcPercentEncode encodes a status message for the grpc-message trailer, which only carries printable ASCII
+func grpcPercentEncode(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// The handleGRPC method is part of the analysisServer struct. It answers the calls of the Analyzer service of
+// analyzer.proto, which gRPC clients send as HTTP/2 POST requests to /csvanalyzer.v1.Analyzer/<method>. The call's
+// status goes into the grpc-status and grpc-message trailers, after the single response message when the call
+// succeeded. Calls are authenticated, limited and stored the same way as POST /analyze, with the API key sent as
+// authorization or x-api-key metadata.
+// handleGRPC serves one gRPC call
+func (s *analysisServer) handleGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	response, err := s.callGRPC(r)
+	w.WriteHeader(http.StatusOK)
+	if err == nil {
+		err = writeGRPCMessage(w, response)
+	}
+	var status *grpcError
+	switch {
+	case err == nil:
+		w.Header().Set("Grpc-Status", fmt.Sprint(grpcOK))
+		return
+	case !errors.As(err, &status):
+		status = &grpcError{code: grpcInternal, message: err.Error()}
+	}
+	w.Header().Set("Grpc-Status", fmt.Sprint(status.code))
+	w.Header().Set("Grpc-Message", grpcPercentEncode(status.message))
+}
+
+// callGRPC runs the method a gRPC request names and returns its encoded response message
+func (s *analysisServer) callGRPC(r *http.Request) ([]byte, error) {
+	tenant, ok := s.authenticate(r)
+	if !ok {
+		return nil, grpcErrorf(grpcUnauthenticated, "missing or unknown API key")
+	}
+	if !s.ready.Load() {
+		return nil, grpcErrorf(grpcUnavailable, "shutting down")
+	}
+	encoding := r.Header.Get("Grpc-Encoding")
+	method, ok := strings.CutPrefix(r.URL.Path, grpcServicePrefix)
+	if !ok {
+		return nil, grpcErrorf(grpcUnimplemented, "unknown service of %s", r.URL.Path)
+	}
+	switch method {
+	case "AnalyzeFile", "GetStats":
+		message, err := readGRPCMessage(r.Body, encoding, s.maxUpload)
+		if err == io.EOF {
+			return nil, grpcErrorf(grpcInvalidArgument, "missing request message")
+		}
+		if err != nil {
+			return nil, err
+		}
+		request, err := decodeGRPCRequest(message)
+		if err != nil {
+			return nil, grpcErrorf(grpcInvalidArgument, "malformed request: %v", err)
+		}
+		if method == "GetStats" {
+			return s.grpcStats(tenant, request)
+		}
+		return s.grpcAnalyze(tenant, request, func(w io.Writer) error {
+			_, err := w.Write(request.data)
+			return err
+		})
+	case "AnalyzeStream":
+		return s.grpcAnalyzeStream(tenant, r.Body, encoding)
+	}
+	return nil, grpcErrorf(grpcUnimplemented, "unknown method %s", method)
+}
+
+// grpcAnalyzeStream reads the row chunks of an AnalyzeStream call straight into the upload and analyzes it
+func (s *analysisServer) grpcAnalyzeStream(tenant string, body io.Reader, encoding string) ([]byte, error) {
+	message, err := readGRPCMessage(body, encoding, s.maxUpload)
+	if err == io.EOF {
+		return nil, grpcErrorf(grpcInvalidArgument, "the stream has no chunks")
+	}
+	if err != nil {
+		return nil, err
+	}
+	first, err := decodeGRPCRequest(message)
+	if err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "malformed chunk: %v", err)
+	}
+	return s.grpcAnalyze(tenant, first, func(w io.Writer) error {
+		written := int64(len(first.data))
+		if _, err := w.Write(first.data); err != nil {
+			return err
+		}
+		for {
+			message, err := readGRPCMessage(body, encoding, s.maxUpload)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			chunk, err := decodeGRPCRequest(message)
+			if err != nil {
+				return grpcErrorf(grpcInvalidArgument, "malformed chunk: %v", err)
+			}
+			if written += int64(len(chunk.data)); written > s.maxUpload {
+				return grpcErrorf(grpcResourceExhausted, "upload is larger than %d bytes", s.maxUpload)
+			}
+			if _, err := w.Write(chunk.data); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// grpcAnalyze spools an upload written by write, analyzes it with the request's options and keeps it in the tenant
+// store like POST /analyze does
+func (s *analysisServer) grpcAnalyze(tenant string, request grpcRequest, write func(io.Writer) error) ([]byte, error) {
+	name := datasetName(request.name)
+	path, err := spoolUpload(name, write)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	report, reportJSON, err := s.analyzeUpload(path, request.options)
+	if err != nil {
+		return nil, err
+	}
+	if s.store != nil {
+		if err := s.store.save(tenant, name, path, reportJSON, time.Now()); err != nil {
+			return nil, grpcErrorf(grpcInternal, "%v", err)
+		}
+	}
+	return encodeAnalyzeResponse(report, reportJSON), nil
+}
+
+// grpcStats answers GetStats from the file sent along, or from the tenant's latest stored upload of the dataset
+func (s *analysisServer) grpcStats(tenant string, request grpcRequest) ([]byte, error) {
+	name := datasetName(request.name)
+	path := ""
+	switch {
+	case len(request.data) > 0:
+		spooled, err := spoolUpload(name, func(w io.Writer) error {
+			_, err := w.Write(request.data)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(spooled)
+		path = spooled
+	case s.store == nil:
+		return nil, grpcErrorf(grpcInvalidArgument, "send the file as content; stored datasets need --data-dir")
+	default:
+		path = filepath.Join(s.store.dir, tenant, "datasets", name)
+		if _, err := os.Stat(path); err != nil {
+			return nil, grpcErrorf(grpcNotFound, "no stored dataset %s", name)
+		}
+	}
+	report, _, err := s.analyzeUpload(path, request.options)
+	if err != nil {
+		return nil, err
+	}
+	return encodeStatsResponse(report, request.columns)
+}
+
+// spoolUpload writes an upload into a temporary file that keeps its extension and returns the file's path
+func spoolUpload(name string, write func(io.Writer) error) (string, error) {
+	upload, err := os.CreateTemp("", "csv-analyzer-*-"+name)
+	if err != nil {
+		return "", grpcErrorf(grpcInternal, "error storing upload: %v", err)
+	}
+	err = write(upload)
+	if closeErr := upload.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(upload.Name())
+		return "", err
+	}
+	return upload.Name(), nil
+}
+
+// analyzeUpload analyzes a spooled upload with the request's options on top of the server defaults
+func (s *analysisServer) analyzeUpload(path string, requested map[string]string) (Report, []byte, error) {
+	options, err := s.requestOptions(requested)
+	if err != nil {
+		return Report{}, nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	opts, err := AnalyzeOptions(options)
+	if err != nil {
+		return Report{}, nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	report, err := analyzeToReport(path, opts)
+	if err != nil {
+		return Report{}, nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return Report{}, nil, grpcErrorf(grpcInternal, "error encoding report: %v", err)
+	}
+	return report, reportJSON, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// grpcStatus returns the status code of a gRPC error, or -1 for any other error
+func grpcStatus(err error) int {
+	var status *grpcError
+	if errors.As(err, &status) {
+		return status.code
+	}
+	return -1
+}
+
+// gzipTest compresses data with gzip
+func gzipTest(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestParseProtoRoundTrip(t *testing.T) {
+	b := appendProtoBytes(nil, 1, []byte("name"))
+	b = appendProtoInt(b, 2, 300)
+	b = appendProtoInt(b, 3, -1)
+	b = appendProtoDouble(b, 4, 1.5)
+	b = appendProtoBytes(b, 2000, nil)
+	fields, err := parseProto(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []protoField{
+		{number: 1, wireType: protoBytes, bytes: []byte("name")},
+		{number: 2, wireType: protoVarint, varint: 300},
+		// Negative int64 values take ten bytes as their two's complement.
+		{number: 3, wireType: protoVarint, varint: math.MaxUint64},
+		{number: 4, wireType: protoFixed64},
+		{number: 2000, wireType: protoBytes, bytes: []byte{}},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("parsed %+v, want %+v", fields, want)
+	}
+	if got := appendProtoDouble(nil, 4, 1.5); !bytes.Equal(got, []byte{0x21, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f}) {
+		t.Errorf("1.5 encoded as % x", got)
+	}
+}
+
+func TestParseProtoRejectsMalformedMessages(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"truncated tag":         {0x80},
+		"truncated varint":      {0x08, 0x80},
+		"truncated fixed64":     {0x09, 1, 2, 3},
+		"truncated fixed32":     {0x0d, 1, 2},
+		"length past the end":   {0x0a, 5, 'a', 'b'},
+		"unsupported wire type": {0x0b},
+	} {
+		if _, err := parseProto(data); err == nil {
+			t.Errorf("%s: parsed without an error", name)
+		}
+	}
+}
+
+func TestDecodeGRPCRequest(t *testing.T) {
+	option := func(key, value string) []byte {
+		return appendProtoBytes(appendProtoBytes(nil, 1, []byte(key)), 2, []byte(value))
+	}
+	b := appendProtoBytes(nil, 1, []byte("sales.csv"))
+	b = appendProtoBytes(b, 2, []byte("a,b\n1,2\n"))
+	b = appendProtoBytes(b, 3, option("type-threshold", "0.8"))
+	b = appendProtoBytes(b, 3, option("schema", ""))
+	b = appendProtoBytes(b, 4, []byte("a"))
+	b = appendProtoBytes(b, 4, []byte("b"))
+	// Fields of later versions of the messages are skipped.
+	b = appendProtoInt(b, 9, 7)
+	b = appendProtoDouble(b, 10, 2)
+	b = appendProtoInt(b, 1, 1)
+	request, err := decodeGRPCRequest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := grpcRequest{
+		name:    "sales.csv",
+		data:    []byte("a,b\n1,2\n"),
+		options: map[string]string{"type-threshold": "0.8", "schema": ""},
+		columns: []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(request, want) {
+		t.Errorf("decoded %+v, want %+v", request, want)
+	}
+	if _, err := decodeGRPCRequest(appendProtoBytes(nil, 3, []byte{0x0a, 9})); err == nil {
+		t.Error("malformed option decoded without an error")
+	}
+}
+
+func TestGRPCMessageFraming(t *testing.T) {
+	var stream bytes.Buffer
+	for _, message := range []string{"first", "", "third"} {
+		if err := writeGRPCMessage(&stream, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := stream.Bytes()[:5]; !bytes.Equal(got, []byte{0, 0, 0, 0, 5}) {
+		t.Errorf("prefix % x, want an uncompressed flag and length 5", got)
+	}
+	for _, want := range []string{"first", "", "third"} {
+		message, err := readGRPCMessage(&stream, "", 100)
+		if err != nil || string(message) != want {
+			t.Fatalf("read %q, %v; want %q", message, err, want)
+		}
+	}
+	if _, err := readGRPCMessage(&stream, "", 100); err != io.EOF {
+		t.Errorf("read past the last message: %v, want io.EOF", err)
+	}
+}
+
+func TestReadGRPCMessageErrors(t *testing.T) {
+	framed := func(flag byte, message []byte) *bytes.Reader {
+		var b bytes.Buffer
+		writeGRPCMessage(&b, message)
+		data := b.Bytes()
+		data[0] = flag
+		return bytes.NewReader(data)
+	}
+	compressed := gzipTest(t, []byte(strings.Repeat("a", 1000)))
+	tests := []struct {
+		name     string
+		input    io.Reader
+		encoding string
+		code     int
+	}{
+		{"truncated prefix", bytes.NewReader([]byte{0, 0, 0}), "", grpcInvalidArgument},
+		{"truncated message", bytes.NewReader([]byte{0, 0, 0, 0, 9, 'a'}), "", grpcInvalidArgument},
+		{"message over the limit", framed(0, make([]byte, 101)), "", grpcResourceExhausted},
+		{"compressed without gzip", framed(1, compressed), "", grpcUnimplemented},
+		{"compressed with another encoding", framed(1, compressed), "snappy", grpcUnimplemented},
+		{"not gzip data", framed(1, []byte("plain")), "gzip", grpcInvalidArgument},
+		// The compressed message is small but decompresses past the limit.
+		{"decompressed over the limit", framed(1, compressed), "gzip", grpcResourceExhausted},
+	}
+	for _, test := range tests {
+		if _, err := readGRPCMessage(test.input, test.encoding, 100); grpcStatus(err) != test.code {
+			t.Errorf("%s: %v, want status %d", test.name, err, test.code)
+		}
+	}
+	message, err := readGRPCMessage(framed(1, gzipTest(t, []byte("hello"))), "gzip", 100)
+	if err != nil || string(message) != "hello" {
+		t.Errorf("gzip message read as %q, %v", message, err)
+	}
+}
+
+func TestGRPCPercentEncode(t *testing.T) {
+	for message, want := range map[string]string{
+		"plain text":      "plain text",
+		"100% done":       "100%25 done",
+		"line\nbreak":     "line%0Abreak",
+		"café":            "caf%C3%A9",
+		"tab\tand ~tilde": "tab%09and ~tilde",
+	} {
+		if got := grpcPercentEncode(message); got != want {
+			t.Errorf("grpcPercentEncode(%q) = %q, want %q", message, got, want)
+		}
+	}
+}
+
+// callTestGRPC sends one gRPC call to s and returns the response message and the grpc-status and grpc-message
+// trailers
+func callTestGRPC(t *testing.T, s *analysisServer, method string, request []byte) (response []byte, status, message string) {
+	t.Helper()
+	var body bytes.Buffer
+	writeGRPCMessage(&body, request)
+	r := httptest.NewRequest(http.MethodPost, grpcServicePrefix+method, &body)
+	r.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	s.handleGRPC(w, r)
+	result := w.Result()
+	if status = result.Trailer.Get("Grpc-Status"); status == "0" {
+		var err error
+		if response, err = readGRPCMessage(result.Body, "", 1<<20); err != nil {
+			t.Fatalf("reading the response: %v", err)
+		}
+	}
+	return response, status, result.Trailer.Get("Grpc-Message")
+}
+
+func TestGRPCAnalyzeFile(t *testing.T) {
+	request := appendProtoBytes(nil, 1, []byte("data.csv"))
+	request = appendProtoBytes(request, 2, []byte("id,price\n1,2.5\n2,3.5\n3,\n"))
+	response, status, message := callTestGRPC(t, newTestServer(nil), "AnalyzeFile", request)
+	if status != "0" {
+		t.Fatalf("status %s: %s", status, message)
+	}
+	fields, err := parseProto(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[int]uint64)
+	for _, field := range fields {
+		if field.wireType == protoVarint {
+			counts[field.number] = field.varint
+		}
+	}
+	if fields[0].number != 1 || !bytes.Contains(fields[0].bytes, []byte(`"rows":3`)) {
+		t.Errorf("report JSON field %+v", fields[0])
+	}
+	if counts[2] != 3 || counts[3] != 2 {
+		t.Errorf("rows %d and columns %d, want 3 and 2", counts[2], counts[3])
+	}
+}
+
+func TestGRPCGetStats(t *testing.T) {
+	request := appendProtoBytes(nil, 1, []byte("data.csv"))
+	request = appendProtoBytes(request, 2, []byte("id,price\n1,2.5\n2,3.5\n3,\n"))
+	request = appendProtoBytes(request, 4, []byte("price"))
+	response, status, message := callTestGRPC(t, newTestServer(nil), "GetStats", request)
+	if status != "0" {
+		t.Fatalf("status %s: %s", status, message)
+	}
+	fields, err := parseProto(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || fields[0].varint != 3 || fields[1].number != 2 {
+		t.Fatalf("response fields %+v, want the row count and one summary", fields)
+	}
+	summary := fields[1].bytes
+	for _, want := range [][]byte{
+		appendProtoBytes(nil, 1, []byte("price")),
+		appendProtoInt(nil, 3, 2),
+		appendProtoInt(nil, 4, 1),
+		appendProtoDouble(nil, 6, 3),
+		appendProtoDouble(nil, 8, 2.5),
+		appendProtoDouble(nil, 10, 3.5),
+	} {
+		if !bytes.Contains(summary, want) {
+			t.Errorf("summary % x lacks % x", summary, want)
+		}
+	}
+
+	missing := appendProtoBytes(appendProtoBytes(nil, 2, []byte("a\n1\n")), 4, []byte("nope"))
+	if _, status, message := callTestGRPC(t, newTestServer(nil), "GetStats", missing); status != "5" || message != "columns not found: nope" {
+		t.Errorf("unknown column: status %s %q, want 5 (not found)", status, message)
+	}
+}
+
+func TestGRPCUnknownMethod(t *testing.T) {
+	if _, status, message := callTestGRPC(t, newTestServer(nil), "Nope", nil); status != "12" || message != "unknown method Nope" {
+		t.Errorf("status %s %q, want 12 (unimplemented)", status, message)
+	}
+}
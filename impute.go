@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Imputation methods of --fill-missing
+const (
+	FillMean     = "mean"
+	FillMedian   = "median"
+	FillMode     = "mode"
+	FillConstant = "constant" // written constant:<value>
+	FillForward  = "ffill"    // the last non-empty value above
+)
+
+// FillRule names a column and how its missing values are filled
+type FillRule struct {
+	Column string
+	Method string
+	Value  string // the fill value of the constant method
+}
+
+// FillRules is a flag.Value collecting imputation rules such as "Price=median,Region=constant:Unknown"
+type FillRules []FillRule
+
+// String renders the rules in --fill-missing syntax
+func (f FillRules) String() string {
+	parts := make([]string, len(f))
+	for i, rule := range f {
+		parts[i] = rule.Column + "=" + rule.Method
+		if rule.Method == FillConstant {
+			parts[i] += ":" + rule.Value
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a --fill-missing value such as "Price=mean,Qty=median,Region=constant:Unknown,Temp=ffill"
+func (f *FillRules) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		column, method, ok := strings.Cut(entry, "=")
+		column, method = strings.TrimSpace(column), strings.TrimSpace(method)
+		if !ok || column == "" {
+			return fmt.Errorf("invalid fill rule %q (expected column=method)", entry)
+		}
+		rule := FillRule{Column: column, Method: strings.ToLower(method)}
+		if constant, ok := strings.CutPrefix(method, FillConstant+":"); ok {
+			rule.Method, rule.Value = FillConstant, constant
+		}
+		switch rule.Method {
+		case FillMean, FillMedian, FillMode, FillForward:
+		case FillConstant:
+			if rule.Value == "" {
+				return fmt.Errorf("fill rule for %s needs a value, e.g. constant:0", column)
+			}
+		default:
+			return fmt.Errorf("unknown fill method %q for %s (expected mean, median, mode, constant:<value> or ffill)", method, column)
+		}
+		*f = append(*f, rule)
+	}
+	return nil
+}
+
+// FillSummary reports how the missing values of one column were filled
+type FillSummary struct {
+	Column string `json:"column"`
+	Method string `json:"method"`
+	Value  string `json:"value,omitempty"` // the single fill value; empty for ffill
+	Filled int    `json:"filled"`
+	Left   int    `json:"left"` // cells still empty, e.g. before the first value under ffill
+}
+
+// fillValue works out the single value a rule fills a column with; ok is false when the column has nothing to base it on
+func (ca *CSVAnalyzer) fillValue(rule FillRule, colIndex int) (string, bool) {
+	switch rule.Method {
+	case FillConstant:
+		return rule.Value, true
+	case FillMean, FillMedian:
+		values := ca.extractNumericValues(colIndex)
+		if len(values) == 0 {
+			return "", false
+		}
+		if rule.Method == FillMean {
+			return formatMetric(sum(values) / float64(len(values))), true
+		}
+		return formatMetric(median(values)), true
+	case FillMode:
+		// Counts the non-empty values; ties go to the value that sorts first, so the fill is always the same.
+		counts := make(map[string]int)
+		for _, row := range ca.dataset.Rows {
+			if colIndex < len(row) {
+				if value := strings.TrimSpace(row[colIndex]); value != "" {
+					counts[value]++
+				}
+			}
+		}
+		values := make([]string, 0, len(counts))
+		for value := range counts {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		mode := ""
+		for _, value := range values {
+			if counts[value] > counts[mode] {
+				mode = value
+			}
+		}
+		return mode, mode != ""
+	}
+	return "", false
+}
+
+// The ImputedRows method is part of the CSVAnalyzer struct. It returns a copy of the rows with the missing (empty or
+// blank) cells of the --fill-missing columns filled: with the column's mean or median for numeric columns, its most
+// common value, a constant, or the last non-empty value above the cell (ffill, in file order). The loaded data is
+// left untouched, so the report still describes the missingness that was found. mean and median need numeric
+// values and fail on columns without any; ffill leaves cells before the first value empty and says so.
+// ImputedRows returns the rows with missing values filled and what was filled per column
+func (ca *CSVAnalyzer) ImputedRows() ([][]string, []FillSummary, error) {
+	rows := make([][]string, len(ca.dataset.Rows))
+	for i, row := range ca.dataset.Rows {
+		// Pads short rows so their missing trailing cells can be filled too.
+		rows[i] = append([]string(nil), row...)
+		for len(rows[i]) < len(ca.dataset.Headers) {
+			rows[i] = append(rows[i], "")
+		}
+	}
+	var summaries []FillSummary
+	for _, rule := range ca.options.FillMissing {
+		colIndex := ca.columnIndex(rule.Column)
+		if colIndex < 0 {
+			return nil, nil, fmt.Errorf("fill column %q not found", rule.Column)
+		}
+		summary := FillSummary{Column: rule.Column, Method: rule.Method}
+		if rule.Method != FillForward {
+			value, ok := ca.fillValue(rule, colIndex)
+			if !ok {
+				return nil, nil, fmt.Errorf("cannot fill %s with its %s: the column has no numeric values", rule.Column, rule.Method)
+			}
+			summary.Value = value
+		}
+		last := ""
+		for _, row := range rows {
+			if value := strings.TrimSpace(row[colIndex]); value != "" {
+				last = row[colIndex]
+				continue
+			}
+			fill := summary.Value
+			if rule.Method == FillForward {
+				fill = last
+			}
+			if fill == "" {
+				summary.Left++
+				continue
+			}
+			row[colIndex] = fill
+			summary.Filled++
+		}
+		summaries = append(summaries, summary)
+	}
+	return rows, summaries, nil
+}
+
+// The WriteImputed method is part of the CSVAnalyzer struct. It writes the dataset with its missing values filled
+// as CSV to path (compressed with --compress) and prints per column how many cells were filled, and with what, on w.
+// WriteImputed writes the imputed dataset to path
+func (ca *CSVAnalyzer) WriteImputed(path string, w io.Writer) error {
+	rows, summaries, err := ca.ImputedRows()
+	if err != nil {
+		return err
+	}
+	out, err := createOutputFile(path, ca.options.Compress)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	err = writeCSVRecords(out, append([][]string{ca.dataset.Headers}, rows...))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		fill := summary.Value
+		if summary.Method == FillForward {
+			fill = "the value above"
+		}
+		fmt.Fprintf(w, "Filled %d missing %s values with %s (%s)", summary.Filled, summary.Column, fill, summary.Method)
+		if summary.Left > 0 {
+			fmt.Fprintf(w, ", %d left empty", summary.Left)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
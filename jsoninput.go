@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// isJSONInput reports whether a file should be read as JSON or JSON Lines instead of CSV, judging by its extension
+func isJSONInput(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json", ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// The readJSONRecords function turns a JSON document into CSV-style records so the rest of the analyzer can treat it
+// like any other dataset. The input is either an array of objects (.json) or a stream of objects, one per line
+// (.jsonl). Top-level fields become columns, in the order they are first seen across all objects; values are kept
+// as their JSON text, so numbers and booleans are detected as usual, nulls and missing fields become empty cells, and
+// nested objects and arrays are stored as compact JSON. The column set is only known after the last object, so the
+// whole file is read before any record is passed on.
+// readJSONRecords passes the header and then every object of a JSON or JSON Lines document to visit
+func readJSONRecords(input io.Reader, filename string, visit func(record []string) error) error {
+	decoder := json.NewDecoder(input)
+	decoder.UseNumber()
+
+	// An opening bracket means a single array of objects; anything else is a stream of objects.
+	var objects []map[string]string
+	var headers []string
+	seen := make(map[string]bool)
+	inArray := false
+	if token, err := decoder.Token(); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error reading JSON file %s: %v", filename, err)
+	} else if token == json.Delim('[') {
+		inArray = true
+	} else if token != json.Delim('{') {
+		return fmt.Errorf("error reading JSON file %s: expected an array or objects", filename)
+	}
+
+	// Reads the objects one by one, the first one having had its opening brace consumed already.
+	for first := !inArray; ; first = false {
+		if !first {
+			if inArray && !decoder.More() {
+				break
+			}
+			token, err := decoder.Token()
+			if err == io.EOF && !inArray {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("error reading JSON file %s: %v", filename, err)
+			}
+			if token != json.Delim('{') {
+				return fmt.Errorf("error reading JSON file %s: record %d is not an object", filename, len(objects)+1)
+			}
+		}
+		object, keys, err := decodeJSONObject(decoder)
+		if err != nil {
+			return fmt.Errorf("error reading JSON file %s: record %d: %v", filename, len(objects)+1, err)
+		}
+		// Adds any field not seen before as a new column.
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				headers = append(headers, key)
+			}
+		}
+		objects = append(objects, object)
+	}
+
+	// Emits the header followed by one record per object, aligned to the full column set.
+	if err := visit(headers); err != nil {
+		return err
+	}
+	for _, object := range objects {
+		record := make([]string, len(headers))
+		for i, header := range headers {
+			record[i] = object[header]
+		}
+		if err := visit(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeJSONObject reads the fields of an object whose opening brace was already consumed, returning the cell text
+// of every field and the field names in document order
+func decodeJSONObject(decoder *json.Decoder) (map[string]string, []string, error) {
+	object := make(map[string]string)
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := token.(string)
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		if _, duplicate := object[key]; !duplicate {
+			keys = append(keys, key)
+		}
+		object[key] = jsonCellText(raw)
+	}
+	// Consumes the closing brace.
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, err
+	}
+	return object, keys, nil
+}
+
+// jsonCellText converts a JSON value to the text of a CSV cell
+func jsonCellText(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return ""
+	case len(raw) > 0 && raw[0] == '"':
+		var text string
+		json.Unmarshal(raw, &text)
+		return text
+	case len(raw) > 0 && (raw[0] == '{' || raw[0] == '['):
+		// Nested values are compacted so equal structures compare equal.
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err == nil {
+			return compact.String()
+		}
+	}
+	// Numbers and booleans keep their literal text.
+	return string(raw)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDuplicateKeys is how many duplicated key tuples a key check lists, and maxDuplicateKeyRows how many row numbers
+// it lists for each
+const (
+	maxDuplicateKeys    = 20
+	maxDuplicateKeyRows = 10
+)
+
+// CompositeKeys is the list of --unique keys, each a combination of columns that must identify every row. It
+// implements flag.Value; the flag may be repeated to check several keys.
+type CompositeKeys [][]string
+
+// String renders the keys in --unique syntax
+func (k CompositeKeys) String() string {
+	parts := make([]string, len(k))
+	for i, key := range k {
+		parts[i] = strings.Join(key, ",")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set parses one --unique key such as "OrderID,LineNo"
+func (k *CompositeKeys) Set(value string) error {
+	var key columnList
+	if err := key.Set(value); err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("empty key %q (expected comma-separated columns, e.g. OrderID,LineNo)", value)
+	}
+	seen := make(map[string]bool)
+	for _, column := range key {
+		if seen[column] {
+			return fmt.Errorf("key %q names column %s twice", value, column)
+		}
+		seen[column] = true
+	}
+	*k = append(*k, key)
+	return nil
+}
+
+// DuplicateKey is a key tuple shared by several rows
+type DuplicateKey struct {
+	Values []string `json:"values"`
+	Count  int      `json:"count"`
+	Rows   []int    `json:"rows"` // 1-based data row numbers (header excluded), at most maxDuplicateKeyRows
+}
+
+// KeyCheck is the outcome of checking that a combination of columns is unique
+type KeyCheck struct {
+	Columns  []string `json:"columns"`
+	Distinct int      `json:"distinct_keys"`
+	// DuplicateKeys counts the tuples found in more than one row and DuplicateRows the rows repeating an earlier one
+	DuplicateKeys int            `json:"duplicate_keys"`
+	DuplicateRows int            `json:"duplicate_rows"`
+	Duplicates    []DuplicateKey `json:"duplicates,omitempty"` // the first maxDuplicateKeys, withheld under privacy
+	Unique        bool           `json:"unique"`
+}
+
+// The checkKey method is part of the CSVAnalyzer struct. It groups the rows by the trimmed values of the key columns
+// and lists the tuples shared by more than one row, in the order they first appear, with the rows holding them so
+// the offending records can be looked up. Empty cells are a value of their own, as in the k-anonymity classes, so
+// rows missing part of their key collide with each other too. The tuples and row numbers point at individual
+// records, so they are withheld under differential privacy; the counts are still reported.
+// checkKey checks that a combination of columns is unique across the rows
+func (ca *CSVAnalyzer) checkKey(columns []string) KeyCheck {
+	check := KeyCheck{Columns: columns}
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = ca.columnIndex(column)
+	}
+	type keyRows struct {
+		values []string
+		rows   []int
+		count  int
+	}
+	groups := make(map[string]*keyRows)
+	var order []*keyRows
+	for rowIndex, row := range ca.dataset.Rows {
+		values := make([]string, len(indexes))
+		for i, colIndex := range indexes {
+			if colIndex >= 0 && colIndex < len(row) {
+				values[i] = strings.TrimSpace(row[colIndex])
+			}
+		}
+		id := strings.Join(values, "\x00")
+		group := groups[id]
+		if group == nil {
+			group = &keyRows{values: values}
+			groups[id] = group
+			order = append(order, group)
+		}
+		group.count++
+		if len(group.rows) < maxDuplicateKeyRows {
+			group.rows = append(group.rows, rowIndex+1)
+		}
+	}
+
+	check.Distinct = len(groups)
+	for _, group := range order {
+		if group.count < 2 {
+			continue
+		}
+		check.DuplicateKeys++
+		check.DuplicateRows += group.count - 1
+		if len(check.Duplicates) < maxDuplicateKeys && ca.privacy == nil {
+			check.Duplicates = append(check.Duplicates, DuplicateKey{Values: group.values, Count: group.count, Rows: group.rows})
+		}
+	}
+	check.Unique = check.DuplicateKeys == 0
+	return check
+}
+
+// KeyChecks checks every --unique key, in the order they were given
+func (ca *CSVAnalyzer) KeyChecks() []KeyCheck {
+	var checks []KeyCheck
+	for _, key := range ca.options.UniqueKeys {
+		checks = append(checks, ca.checkKey(key))
+	}
+	return checks
+}
+
+// describe renders a duplicated tuple with its rows on one line
+func (d DuplicateKey) describe() string {
+	values := make([]string, len(d.Values))
+	for i, value := range d.Values {
+		values[i] = fmt.Sprintf("%q", value)
+	}
+	rows := make([]string, len(d.Rows))
+	for i, row := range d.Rows {
+		rows[i] = fmt.Sprint(row)
+	}
+	text := fmt.Sprintf("(%s) in %d rows: %s", strings.Join(values, ", "), d.Count, strings.Join(rows, ", "))
+	if d.Count > len(d.Rows) {
+		text += ", ..."
+	}
+	return text
+}
+
+// printKeyChecks shows the outcome of the --unique checks in the text report, with the first duplicated tuples
+func printKeyChecks(checks []KeyCheck) {
+	if len(checks) == 0 {
+		return
+	}
+	fmt.Println("\n\nKey Uniqueness:")
+	fmt.Println("---------------")
+	for _, check := range checks {
+		columns := strings.Join(check.Columns, ", ")
+		if check.Unique {
+			fmt.Printf("  [PASS] (%s) is unique: %d keys\n", columns, check.Distinct)
+			continue
+		}
+		fmt.Printf("  [FAIL] (%s): %d keys repeated in %d extra rows\n", columns, check.DuplicateKeys, check.DuplicateRows)
+		for _, duplicate := range check.Duplicates {
+			fmt.Printf("         %s\n", duplicate.describe())
+		}
+		if hidden := check.DuplicateKeys - len(check.Duplicates); hidden > 0 && len(check.Duplicates) > 0 {
+			fmt.Printf("         ... and %d more\n", hidden)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The AnalyzeFile function runs the same analysis as the command line and returns the JSON report, for callers that
+// embed the analyzer instead of running it (see cshared.go for the C entry points used by Python and Node). Options
+// are given by flag name without dashes, e.g. {"types": "ZipCode:string", "locale": "de-DE", "strict": "true"},
+// and are parsed and validated exactly like command-line flags. Nothing is printed and the process never exits:
+// the progress bar is off, data warnings are part of the report, and --strict turns them into an error instead.
+// AnalyzeFile analyzes the file at path and returns its JSON report
+func AnalyzeFile(path string, options map[string]string) ([]byte, error) {
+	opts, err := AnalyzeOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	report, err := analyzeToReport(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding report: %v", err)
+	}
+	return data, nil
+}
+
+// analyzeToReport loads the configuration, rules and data the way main does and builds the report, returning errors
+// instead of exiting; the config, schema, rules and dictionary paths are opened as given, so serve mode only takes
+// them from the environment (see clientOptions)
+func analyzeToReport(path string, opts *Options) (Report, error) {
+	var config Config
+	var err error
+	if opts.ConfigPath != "" {
+		if config, err = LoadConfig(opts.ConfigPath); err != nil {
+			return Report{}, err
+		}
+	}
+	config.Alerts = append(config.Alerts, opts.quickCheckRules()...)
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	analyzer.SetConfig(config)
+	if opts.ExpectSchema != "" {
+		if analyzer.expectedSchema, err = LoadSchema(opts.ExpectSchema); err != nil {
+			return Report{}, err
+		}
+	}
+	if opts.RulesPath != "" {
+		if analyzer.valueRules, err = LoadRules(opts.RulesPath); err != nil {
+			return Report{}, err
+		}
+	}
+	if err := analyzer.LoadCSV(path); err != nil {
+		return Report{}, err
+	}
+	if warnings := analyzer.DataWarnings(); opts.Strict && len(warnings) > 0 {
+		return Report{}, fmt.Errorf("%d data warnings treated as errors (strict), first: %s", len(warnings), warnings[0].describe())
+	}
+	if opts.DictionaryPath != "" {
+		if err := analyzer.LoadDataDictionary(opts.DictionaryPath); err != nil {
+			return Report{}, err
+		}
+	}
+	return analyzer.BuildReport(), nil
+}
+
+// ColumnHistogram loads the file at path with options given as AnalyzeFile takes them and returns the histogram of
+// one numeric column with bins equal-width bins, so embedding applications can draw it with their own charting
+func ColumnHistogram(path, column string, bins int, options map[string]string) (*Histogram, error) {
+	opts, err := AnalyzeOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	if err := analyzer.LoadCSV(path); err != nil {
+		return nil, err
+	}
+	colIndex := analyzer.columnIndex(column)
+	if colIndex < 0 {
+		return nil, fmt.Errorf("column %q not found", column)
+	}
+	if analyzer.columnType(colIndex) != TypeNumeric {
+		return nil, fmt.Errorf("column %q is not numeric", column)
+	}
+	histogram, err := analyzer.Histogram(colIndex, bins)
+	if err == nil && histogram == nil {
+		err = fmt.Errorf("column %q has no histogram (no numeric values, or withheld under differential privacy)", column)
+	}
+	return histogram, err
+}
+
+// ConvertFileToArrow loads the file at path with options given as AnalyzeFile takes them and writes its rows to w as
+// an Arrow IPC stream, which Arrow libraries read without copying the column buffers
+func ConvertFileToArrow(path string, options map[string]string, w io.Writer) error {
+	opts, err := AnalyzeOptions(options)
+	if err != nil {
+		return err
+	}
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	if err := analyzer.LoadCSV(path); err != nil {
+		return err
+	}
+	return analyzer.WriteArrow(w)
+}
+
+// AnalyzeOptions parses and validates analyzer options given by flag name, as AnalyzeFile takes them
+func AnalyzeOptions(options map[string]string) (*Options, error) {
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	fs.SetOutput(io.Discard)
+	// Applies the options in name order so the first invalid one is always the one reported.
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := fs.Set(name, options[name]); err != nil {
+			return nil, fmt.Errorf("invalid option %s: %v", name, err)
+		}
+	}
+	opts.Progress = false
+	if opts.Format != FormatText && opts.Format != FormatJSON {
+		return nil, fmt.Errorf("format %q is not available here; the result is always the JSON report", opts.Format)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// lineageProducer identifies this tool as the producer of emitted OpenLineage events
+	lineageProducer = "https://github.com/akins11/CSV-Analyzer"
+	// lineageSpecURL is the OpenLineage spec version the events conform to
+	lineageSpecURL = "https://openlineage.io/spec/2-0-2/OpenLineage.json#/definitions/RunEvent"
+	// lineageFacetsURL is the base URL of the facet schemas we reference
+	lineageFacetsURL = "https://openlineage.io/spec/facets/1-0-1/"
+	// lineageEndpoint is the default path of the HTTP transport when the configured URL has none
+	lineageEndpoint = "/api/v1/lineage"
+)
+
+// LineageEvent is an OpenLineage RunEvent describing one analyzer run
+type LineageEvent struct {
+	EventType string           `json:"eventType"`
+	EventTime string           `json:"eventTime"`
+	Producer  string           `json:"producer"`
+	SchemaURL string           `json:"schemaURL"`
+	Run       LineageRun       `json:"run"`
+	Job       LineageJob       `json:"job"`
+	Inputs    []LineageDataset `json:"inputs"`
+	Outputs   []LineageDataset `json:"outputs"`
+}
+
+// LineageRun identifies a single execution of the job
+type LineageRun struct {
+	RunID  string                 `json:"runId"`
+	Facets map[string]interface{} `json:"facets,omitempty"`
+}
+
+// LineageJob names the job within its namespace
+type LineageJob struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// LineageDataset describes an input file together with its schema and row count facets
+type LineageDataset struct {
+	Namespace   string                 `json:"namespace"`
+	Name        string                 `json:"name"`
+	Facets      map[string]interface{} `json:"facets,omitempty"`
+	InputFacets map[string]interface{} `json:"inputFacets,omitempty"`
+}
+
+// LineageOptions configures where and under which job name lineage events are sent
+type LineageOptions struct {
+	URL       string
+	APIKey    string
+	Namespace string
+	Job       string
+}
+
+// The BuildLineageEvent method is part of the CSVAnalyzer struct. It describes the current run as an OpenLineage RunEvent:
+// the job and run identifiers, and one input dataset per source file carrying a schema facet (column names and detected
+// types) and a data-quality facet with the row count. When several files were combined via a glob pattern, each file is
+// reported as its own input dataset.
+// BuildLineageEvent creates an OpenLineage event for the loaded dataset
+func (ca *CSVAnalyzer) BuildLineageEvent(eventType, runID string, opts LineageOptions) LineageEvent {
+	// Builds the schema facet once; every source file shares the same headers.
+	var fields []map[string]string
+	for colIndex, header := range ca.dataset.Headers {
+		// Maps the detected column type onto a generic type name.
+		fieldType := "string"
+		switch ca.columnType(colIndex) {
+		case TypeNumeric:
+			fieldType = "number"
+		case TypeBoolean:
+			fieldType = "boolean"
+		case TypeDate:
+			fieldType = "timestamp"
+		}
+		fields = append(fields, map[string]string{"name": header, "type": fieldType})
+	}
+
+	// Creates the event shell with the run and job identity.
+	event := LineageEvent{
+		EventType: eventType,
+		EventTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Producer:  lineageProducer,
+		SchemaURL: lineageSpecURL,
+		Run:       LineageRun{RunID: runID},
+		Job:       LineageJob{Namespace: opts.Namespace, Name: opts.Job},
+		Inputs:    []LineageDataset{},
+		Outputs:   []LineageDataset{},
+	}
+
+	// Adds one input dataset per file that contributed rows.
+	for _, source := range ca.dataset.Sources {
+		// Uses the absolute path as the dataset name so events from different working directories agree.
+		name := source.Path
+		if abs, err := filepath.Abs(source.Path); err == nil {
+			name = abs
+		}
+		event.Inputs = append(event.Inputs, LineageDataset{
+			Namespace: "file",
+			Name:      name,
+			Facets: map[string]interface{}{
+				"schema": map[string]interface{}{
+					"_producer":  lineageProducer,
+					"_schemaURL": lineageFacetsURL + "SchemaDatasetFacet.json",
+					"fields":     fields,
+				},
+			},
+			InputFacets: map[string]interface{}{
+				"dataQualityMetrics": map[string]interface{}{
+					"_producer":     lineageProducer,
+					"_schemaURL":    lineageFacetsURL + "DataQualityMetricsInputDatasetFacet.json",
+					"rowCount":      source.Rows,
+					"columnMetrics": map[string]interface{}{},
+				},
+			},
+		})
+	}
+	// Returns the completed event.
+	return event
+}
+
+// EmitLineageEvent posts an OpenLineage event to the configured HTTP endpoint
+func EmitLineageEvent(event LineageEvent, opts LineageOptions) error {
+	// Resolves the URL the event is posted to.
+	endpoint, err := lineageEndpointURL(opts.URL)
+	if err != nil {
+		return err
+	}
+	// Encodes the event as JSON.
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding lineage event: %v", err)
+	}
+	// Builds the POST request, adding a bearer token when an API key is configured.
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating lineage request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.APIKey)
+	}
+	// Sends the request with a timeout so an unreachable endpoint cannot stall the run.
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending lineage event: %v", err)
+	}
+	defer resp.Body.Close()
+	// Any non-2xx status means the backend rejected the event.
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("lineage endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// emitLineage sends a lineage event when an endpoint is configured.
+// Lineage is best-effort: a failure is reported on stderr but never fails the run.
+func (ca *CSVAnalyzer) emitLineage(eventType, runID string, opts LineageOptions) {
+	// Does nothing unless lineage emission was enabled.
+	if opts.URL == "" {
+		return
+	}
+	if err := EmitLineageEvent(ca.BuildLineageEvent(eventType, runID, opts), opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// emitLineageFailure sends a FAIL event carrying the reason in an errorMessage run facet, when an endpoint is configured
+func (ca *CSVAnalyzer) emitLineageFailure(runID string, opts LineageOptions, message string) {
+	if opts.URL == "" {
+		return
+	}
+	event := ca.BuildLineageEvent("FAIL", runID, opts)
+	event.Run.Facets = map[string]interface{}{
+		"errorMessage": map[string]interface{}{
+			"_producer":           lineageProducer,
+			"_schemaURL":          lineageFacetsURL + "ErrorMessageRunFacet.json",
+			"message":             message,
+			"programmingLanguage": "Go",
+		},
+	}
+	if err := EmitLineageEvent(event, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// lineageEndpointURL appends the default lineage path when the configured URL is just a host
+func lineageEndpointURL(raw string) (string, error) {
+	// Parses the configured URL to inspect its path.
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid lineage URL %q: %v", raw, err)
+	}
+	// Only a bare host gets the default endpoint; explicit paths are used as given.
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = lineageEndpoint
+	}
+	return u.String(), nil
+}
+
+// newRunID generates a random (version 4) UUID to identify a run
+func newRunID() string {
+	// Fills 16 bytes from the system's secure random source.
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand never fails on supported platforms; fall back to the clock just in case.
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	// Sets the version (4) and variant (RFC 4122) bits.
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmitLineageFailureCarriesErrorMessage(t *testing.T) {
+	var events []LineageEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event LineageEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		events = append(events, event)
+	}))
+	defer server.Close()
+	opts := LineageOptions{URL: server.URL, Namespace: "test", Job: "profile"}
+	analyzer := loadTestCSV(t, "a,b\n1,x\n")
+	analyzer.emitLineage("START", "run-1", opts)
+	analyzer.emitLineageFailure("run-1", opts, "checks failed")
+	if len(events) != 2 || events[0].EventType != "START" || events[1].EventType != "FAIL" {
+		t.Fatalf("events = %+v", events)
+	}
+	facet, _ := events[1].Run.Facets["errorMessage"].(map[string]interface{})
+	if facet["message"] != "checks failed" || events[1].Run.RunID != "run-1" {
+		t.Errorf("FAIL event run = %+v", events[1].Run)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// NumberLocale describes how a locale writes numbers and dates: its decimal mark, digit-group separators (the first
+// is the one --output-locale writes) and the time layout of a date
+type NumberLocale struct {
+	Name    string
+	Decimal rune
+	Group   []rune
+	Date    string
+}
+
+// numberLocales lists the locales accepted by --locale, keyed by lower-cased tag.
+// Language-only tags ("de") and region tags ("de-de") both resolve here.
+var numberLocales = map[string]NumberLocale{
+	"en":    {Name: "en", Decimal: '.', Group: []rune{','}, Date: "01/02/2006"},
+	"en-us": {Name: "en-US", Decimal: '.', Group: []rune{','}, Date: "01/02/2006"},
+	"en-gb": {Name: "en-GB", Decimal: '.', Group: []rune{','}, Date: "02/01/2006"},
+	"en-in": {Name: "en-IN", Decimal: '.', Group: []rune{','}, Date: "02/01/2006"},
+	"ja":    {Name: "ja", Decimal: '.', Group: []rune{','}, Date: "2006/01/02"},
+	"zh":    {Name: "zh", Decimal: '.', Group: []rune{','}, Date: "2006/01/02"},
+	"de":    {Name: "de", Decimal: ',', Group: []rune{'.'}, Date: "02.01.2006"},
+	"de-de": {Name: "de-DE", Decimal: ',', Group: []rune{'.'}, Date: "02.01.2006"},
+	"de-ch": {Name: "de-CH", Decimal: '.', Group: []rune{'\'', '’'}, Date: "02.01.2006"},
+	"es":    {Name: "es", Decimal: ',', Group: []rune{'.'}, Date: "02/01/2006"},
+	"it":    {Name: "it", Decimal: ',', Group: []rune{'.'}, Date: "02/01/2006"},
+	"nl":    {Name: "nl", Decimal: ',', Group: []rune{'.'}, Date: "02-01-2006"},
+	"pt":    {Name: "pt", Decimal: ',', Group: []rune{'.'}, Date: "02/01/2006"},
+	"pt-br": {Name: "pt-BR", Decimal: ',', Group: []rune{'.'}, Date: "02/01/2006"},
+	"da":    {Name: "da", Decimal: ',', Group: []rune{'.'}, Date: "02.01.2006"},
+	"tr":    {Name: "tr", Decimal: ',', Group: []rune{'.'}, Date: "02.01.2006"},
+	"fr":    {Name: "fr", Decimal: ',', Group: []rune{' ', ' ', ' '}, Date: "02/01/2006"},
+	"fr-fr": {Name: "fr-FR", Decimal: ',', Group: []rune{' ', ' ', ' '}, Date: "02/01/2006"},
+	"ru":    {Name: "ru", Decimal: ',', Group: []rune{' ', ' ', ' '}, Date: "02.01.2006"},
+	"pl":    {Name: "pl", Decimal: ',', Group: []rune{' ', ' ', ' '}, Date: "02.01.2006"},
+	"sv":    {Name: "sv", Decimal: ',', Group: []rune{' ', ' ', ' '}, Date: "2006-01-02"},
+	"fi":    {Name: "fi", Decimal: ',', Group: []rune{' ', ' ', ' '}, Date: "02.01.2006"},
+	"nb":    {Name: "nb", Decimal: ',', Group: []rune{' ', ' ', ' '}, Date: "02.01.2006"},
+	"cs":    {Name: "cs", Decimal: ',', Group: []rune{' ', ' ', ' '}, Date: "02.01.2006"},
+}
+
+// lookupNumberLocale resolves a --locale or --output-locale value, accepting "de_DE" and "de-DE" spellings
+func lookupNumberLocale(tag string) (*NumberLocale, error) {
+	// An empty tag keeps the strict Go number syntax.
+	if tag == "" {
+		return nil, nil
+	}
+	// Normalizes underscores and case before looking the tag up.
+	key := strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if locale, ok := numberLocales[key]; ok {
+		return &locale, nil
+	}
+	// Falls back to the language part of a region tag we do not list, e.g. "es-mx" -> "es".
+	if i := strings.Index(key, "-"); i > 0 {
+		if locale, ok := numberLocales[key[:i]]; ok {
+			return &locale, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported locale %q (known: %s)", tag, strings.Join(knownLocales(), ", "))
+}
+
+// knownLocales returns the display names of all supported locales, sorted
+func knownLocales() []string {
+	var names []string
+	for _, locale := range numberLocales {
+		names = append(names, locale.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The parseNumber method is part of the CSVAnalyzer struct and is the single place where cell values are turned into
+// numbers. Without a locale it accepts exactly what strconv.ParseFloat accepts. With a locale it first strips currency
+// symbols and ISO currency codes (so "$1,299.99", "€45,00" and "1.299,99 EUR" are all numbers), treats accounting-style
+// parentheses as a minus sign, removes the locale's digit-group separators and converts its decimal mark to a point.
+// parseNumber converts a trimmed cell value to a float64
+func (ca *CSVAnalyzer) parseNumber(value string) (float64, error) {
+	// Strict mode: plain Go float syntax only.
+	if ca.numberLocale == nil {
+		return strconv.ParseFloat(value, 64)
+	}
+	return parseLocaleNumber(value, ca.numberLocale)
+}
+
+// parseLocaleNumber parses a number written according to locale, tolerating currency symbols
+func parseLocaleNumber(value string, locale *NumberLocale) (float64, error) {
+	s := strings.TrimSpace(value)
+	// Accounting notation writes negative amounts in parentheses, e.g. "(1,200.00)".
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	// Removes currency symbols and codes from either end.
+	s = stripCurrency(s)
+	// A leading sign may sit between the currency symbol and the digits ("$-5" or "-$5").
+	if strings.HasPrefix(s, "-") {
+		negative = !negative
+		s = stripCurrency(s[1:])
+	} else if strings.HasPrefix(s, "+") {
+		s = stripCurrency(s[1:])
+	}
+	if s == "" {
+		return 0, fmt.Errorf("no digits in %q", value)
+	}
+
+	// Rejects separators in impossible places, so a number written for another locale is not misread.
+	if err := checkDigitGroups(s, locale); err != nil {
+		return 0, fmt.Errorf("%q: %v", value, err)
+	}
+
+	// Rewrites the number into Go syntax: group separators dropped, decimal mark turned into '.'.
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == locale.Decimal:
+			b.WriteRune('.')
+		case containsRune(locale.Group, r):
+			// Digit-group separators carry no value.
+		case r == '.' || r == ',':
+			// The other punctuation mark is not valid in this locale.
+			return 0, fmt.Errorf("unexpected %q in %q for locale %s", r, value, locale.Name)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	// Parses the normalized text strictly.
+	number, err := strconv.ParseFloat(b.String(), 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		number = -number
+	}
+	return number, nil
+}
+
+// checkDigitGroups verifies that group separators only appear before the decimal mark and are each followed by a
+// full group of digits (three, or two for the Indian lakh/crore grouping). "45,00" is therefore not a valid en-US number.
+func checkDigitGroups(s string, locale *NumberLocale) error {
+	runes := []rune(s)
+	for i, r := range runes {
+		// Stops at the decimal mark; separators after it are handled by the caller as invalid characters.
+		if r == locale.Decimal {
+			return nil
+		}
+		if !containsRune(locale.Group, r) {
+			continue
+		}
+		// A separator needs digits on its left.
+		if i == 0 || !unicode.IsDigit(runes[i-1]) {
+			return fmt.Errorf("misplaced digit-group separator")
+		}
+		// Counts the digits up to the next separator, decimal mark or end of the number.
+		digits := 0
+		for j := i + 1; j < len(runes) && unicode.IsDigit(runes[j]); j++ {
+			digits++
+		}
+		if digits != 3 && !(digits == 2 && locale.Name == "en-IN") {
+			return fmt.Errorf("digit group of %d digits after separator", digits)
+		}
+	}
+	return nil
+}
+
+// stripCurrency removes currency symbols (Unicode category Sc) and three-letter ISO codes from both ends
+func stripCurrency(s string) string {
+	s = strings.TrimFunc(s, func(r rune) bool { return unicode.Is(unicode.Sc, r) || unicode.IsSpace(r) })
+	// Trims a leading or trailing ISO 4217 style code such as "USD" or "EUR".
+	if len(s) > 3 && isCurrencyCode(s[:3]) {
+		s = strings.TrimSpace(s[3:])
+	}
+	if len(s) > 3 && isCurrencyCode(s[len(s)-3:]) {
+		s = strings.TrimSpace(s[:len(s)-3])
+	}
+	// A symbol may remain after a code was removed, e.g. "USD $10".
+	return strings.TrimFunc(s, func(r rune) bool { return unicode.Is(unicode.Sc, r) || unicode.IsSpace(r) })
+}
+
+// isCurrencyCode reports whether s looks like an ISO 4217 code (three upper-case ASCII letters)
+func isCurrencyCode(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return len(s) == 3
+}
+
+// containsRune reports whether r is one of runes
+func containsRune(runes []rune, r rune) bool {
+	for _, candidate := range runes {
+		if candidate == r {
+			return true
+		}
+	}
+	return false
+}
+
+// reportLocale is the --output-locale of the text report, or nil for the plain Go number and ISO date formats
+var reportLocale *NumberLocale
+
+// The localizedNumber function writes a number with a fixed number of decimals the way reportLocale does: the
+// integer digits grouped in threes with the locale's separator and its decimal mark, so 14999.85 is 14,999.850 in
+// en-US, 14.999,850 in de-DE and 14 999,850 in fr-FR. Without a locale it is exactly strconv's 'f' format, and NaN
+// and infinities are left as they are.
+// localizedNumber formats v with decimals places for the report locale
+func localizedNumber(v float64, decimals int) string {
+	text := strconv.FormatFloat(v, 'f', decimals, 64)
+	if reportLocale == nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return text
+	}
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(text, ".")
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteRune(reportLocale.Group[0])
+		}
+		grouped.WriteRune(digit)
+	}
+	if hasFraction {
+		grouped.WriteRune(reportLocale.Decimal)
+		grouped.WriteString(fraction)
+	}
+	return sign + grouped.String()
+}
+
+// reportNumber formats a statistic with three decimals for the report locale, like %.3f
+func reportNumber(v float64) string {
+	return localizedNumber(v, 3)
+}
+
+// reportMetric formats a value like formatMetric, whole numbers without decimals, for the report locale
+func reportMetric(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return localizedNumber(v, 0)
+	}
+	return localizedNumber(v, 3)
+}
+
+// reportDate formats a date like formatDate, in the date layout of the report locale followed by the time of day
+// when there is one
+func reportDate(t time.Time) string {
+	if reportLocale == nil || t.IsZero() {
+		return formatDate(t)
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format(reportLocale.Date)
+	}
+	return t.Format(reportLocale.Date + " 15:04:05")
+}
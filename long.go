@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// longWriter emits (column, statistic, value) triples as CSV rows
+type longWriter struct {
+	csv *csv.Writer
+}
+
+// write emits one triple
+func (lw *longWriter) write(column, statistic, value string) {
+	lw.csv.Write([]string{column, statistic, value})
+}
+
+// number emits a triple with a floating-point value, printed without needless trailing digits
+func (lw *longWriter) number(column, statistic string, value float64) {
+	lw.write(column, statistic, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// count emits a triple with an integer value
+func (lw *longWriter) count(column, statistic string, value int) {
+	lw.write(column, statistic, strconv.Itoa(value))
+}
+
+// date emits a triple with a timestamp value, empty when there is none
+func (lw *longWriter) date(column, statistic string, value time.Time) {
+	text := ""
+	if !value.IsZero() {
+		text = value.Format(time.RFC3339)
+	}
+	lw.write(column, statistic, text)
+}
+
+// The WriteLongReport method is part of the CSVAnalyzer struct. It renders the report in "long" (tidy) form: a CSV
+// with a column,statistic,value header and one row per figure, which loads directly into a database table or a
+// plotting tool without flattening the nested JSON first. Dataset-level figures have an empty column name. Value
+// lists, such as the unique values of text columns, are left out because they are not single statistics.
+// WriteLongReport writes one (column, statistic, value) triple per line
+func (ca *CSVAnalyzer) WriteLongReport(w io.Writer) error {
+	report := ca.BuildReport()
+	lw := &longWriter{csv: csv.NewWriter(w)}
+	lw.write("column", "statistic", "value")
+
+	// Dataset-level figures come first.
+	lw.write("", "analyzer_version", report.Analyzer.Version)
+	lw.write("", "analyzer_commit", report.Analyzer.Commit)
+	lw.count("", "rows", report.Rows)
+	lw.count("", "column_count", report.ColumnCount)
+	lw.write("", "exact", strconv.FormatBool(report.Exact))
+	if quality := report.Quality; quality != nil {
+		lw.number("", "quality_score", quality.Score)
+		for _, column := range quality.Columns {
+			lw.number(column.Column, "quality_score", column.Score)
+			lw.number(column.Column, "quality_completeness", column.Completeness)
+			lw.number(column.Column, "quality_validity", column.Validity)
+			if column.Uniqueness != nil {
+				lw.number(column.Column, "quality_uniqueness", *column.Uniqueness)
+			}
+			lw.number(column.Column, "quality_consistency", column.Consistency)
+		}
+	}
+	if kAnon := report.KAnonymity; kAnon != nil {
+		lw.count("", "k_anonymity", kAnon.K)
+		lw.count("", "k_anonymity_classes", kAnon.EquivalenceClasses)
+		lw.number("", "k_anonymity_share_below_threshold", kAnon.ShareBelow)
+	}
+	if groupBy := report.GroupBy; groupBy != nil {
+		for _, column := range groupBy.Columns {
+			for _, stats := range column.Groups {
+				lw.number(column.Column, "group_mean["+stats.Group+"]", stats.Mean)
+				if stats.DeviationPct != nil {
+					lw.number(column.Column, "group_deviation_pct["+stats.Group+"]", *stats.DeviationPct)
+				}
+			}
+		}
+	}
+	if bootstrap := report.Bootstrap; bootstrap != nil {
+		for _, column := range report.Columns {
+			for _, interval := range bootstrap.Columns[column.Name] {
+				lw.number(column.Name, interval.Statistic+"_ci_lower", interval.Lower)
+				lw.number(column.Name, interval.Statistic+"_ci_upper", interval.Upper)
+			}
+		}
+	}
+	if periods := report.Periods; periods != nil {
+		for _, effect := range periods.Metrics {
+			lw.number(effect.Column, "before_mean", effect.BeforeMean)
+			lw.number(effect.Column, "after_mean", effect.AfterMean)
+			lw.number(effect.Column, "mean_difference", effect.Difference)
+			if effect.PctChange != nil {
+				lw.number(effect.Column, "pct_change", *effect.PctChange)
+			}
+			if effect.CohensD != nil {
+				lw.number(effect.Column, "cohens_d", *effect.CohensD)
+			}
+		}
+	}
+	if matrix := report.Missingness; matrix != nil {
+		for b, bucket := range matrix.Buckets {
+			for i, column := range matrix.Columns {
+				lw.number(column, "missing_share["+bucket.String()+"]", matrix.Shares[b][i])
+			}
+		}
+	}
+	if matrix := report.Correlations; matrix != nil {
+		for i, column := range matrix.Columns {
+			for j := i + 1; j < len(matrix.Columns); j++ {
+				if r := matrix.R[i][j]; r != nil {
+					lw.number(column, "correlation["+matrix.Columns[j]+"]", *r)
+				}
+			}
+		}
+	}
+	for _, pair := range report.CoMissing {
+		lw.number(pair.Columns[0], "co_missing_correlation["+pair.Columns[1]+"]", pair.Correlation)
+		lw.count(pair.Columns[0], "co_missing_rows["+pair.Columns[1]+"]", pair.Together)
+	}
+	if completeness := report.Completeness; completeness != nil {
+		for _, tier := range completeness.Tiers {
+			lw.count("", "tier_rows["+tier.Tier+"]", tier.Rows)
+		}
+		for _, column := range completeness.Columns {
+			for _, stats := range column.Groups {
+				lw.number(column.Column, "tier_mean["+stats.Group+"]", stats.Mean)
+			}
+		}
+	}
+	for _, finding := range report.PII {
+		lw.write(finding.Column, "pii_kind", finding.Kind)
+		lw.write(finding.Column, "pii_confidence", finding.Confidence)
+		lw.number(finding.Column, "pii_match_share", finding.MatchShare)
+	}
+
+	// Then every column, with the statistics of its type.
+	for _, column := range report.Columns {
+		lw.write(column.Name, "type", column.Type)
+		if stats := column.Numeric; stats != nil {
+			lw.count(column.Name, "count", stats.Count)
+			lw.count(column.Name, "missing", stats.Missing)
+			lw.write(column.Name, "na_policy", stats.NAPolicy)
+			lw.number(column.Name, "sum", stats.Sum)
+			lw.number(column.Name, "mean", stats.Mean)
+			if ci := stats.MeanCI; ci != nil {
+				lw.number(column.Name, "mean_ci_lower", ci.Lower)
+				lw.number(column.Name, "mean_ci_upper", ci.Upper)
+				lw.number(column.Name, "mean_ci_level", ci.Level)
+			}
+			if stats.GeometricMean != nil {
+				lw.number(column.Name, "geometric_mean", *stats.GeometricMean)
+			}
+			if stats.HarmonicMean != nil {
+				lw.number(column.Name, "harmonic_mean", *stats.HarmonicMean)
+			}
+			lw.number(column.Name, "trimmed_mean", stats.TrimmedMean)
+			lw.number(column.Name, "trim_fraction", stats.TrimFraction)
+			lw.number(column.Name, "median", stats.Median)
+			if stats.MedianApproximate {
+				lw.write(column.Name, "median_approximate", "true")
+			}
+			lw.number(column.Name, "std_dev", stats.StdDev)
+			lw.number(column.Name, "min", stats.Min)
+			lw.number(column.Name, "max", stats.Max)
+			if format := stats.Format; format != nil {
+				lw.write(column.Name, "format", format.Kind)
+				lw.count(column.Name, "max_integer_digits", format.MaxIntegerDigits)
+				lw.count(column.Name, "max_decimal_places", format.MaxDecimalPlaces)
+				lw.write(column.Name, "fits_int64", strconv.FormatBool(format.FitsInt64))
+				lw.write(column.Name, "sql_type", format.SQLType)
+			}
+		}
+		if stats := column.Text; stats != nil {
+			lw.count(column.Name, "total_count", stats.TotalCount)
+			lw.count(column.Name, "unique_count", stats.UniqueCount)
+			if lengths := stats.Strings; lengths != nil {
+				lw.count(column.Name, "min_length", lengths.MinLength)
+				lw.count(column.Name, "max_length", lengths.MaxLength)
+				lw.number(column.Name, "mean_length", lengths.MeanLength)
+				lw.count(column.Name, "at_max_length", lengths.AtMaxLength)
+				lw.count(column.Name, "leading_whitespace", lengths.LeadingWhitespace)
+				lw.count(column.Name, "trailing_whitespace", lengths.TrailingWhitespace)
+				lw.count(column.Name, "upper_case", lengths.UpperCase)
+				lw.count(column.Name, "lower_case", lengths.LowerCase)
+				lw.count(column.Name, "mixed_case", lengths.MixedCase)
+			}
+		}
+		if stats := column.Boolean; stats != nil {
+			lw.count(column.Name, "true_count", stats.TrueCount)
+			lw.count(column.Name, "false_count", stats.FalseCount)
+			lw.count(column.Name, "empty_count", stats.EmptyCount)
+			lw.number(column.Name, "true_ratio", stats.TrueRatio)
+		}
+		if stats := column.Date; stats != nil {
+			lw.count(column.Name, "count", stats.Count)
+			lw.count(column.Name, "invalid_count", stats.InvalidCount)
+			lw.date(column.Name, "earliest", stats.Earliest)
+			lw.date(column.Name, "latest", stats.Latest)
+			lw.number(column.Name, "span_days", stats.SpanDays)
+		}
+		if stats := column.Cardinality; stats != nil {
+			lw.count(column.Name, "distinct_count", stats.Distinct)
+			lw.write(column.Name, "distinct_approximate", strconv.FormatBool(stats.Approximate))
+			lw.number(column.Name, "uniqueness_ratio", stats.UniquenessRatio)
+			lw.write(column.Name, "primary_key_candidate", strconv.FormatBool(stats.PrimaryKey))
+			if stats.Entropy != nil {
+				lw.number(column.Name, "entropy", *stats.Entropy)
+				lw.number(column.Name, "normalized_entropy", *stats.NormalizedEntropy)
+				lw.number(column.Name, "gini_impurity", *stats.GiniImpurity)
+			}
+		}
+		if embedded := column.Embedded; embedded != nil {
+			lw.count(column.Name, "embedded_cells", embedded.Cells)
+			lw.count(column.Name, "embedded_newlines", embedded.Newlines)
+			lw.count(column.Name, "embedded_quotes", embedded.Quotes)
+			lw.count(column.Name, "embedded_delimiters", embedded.Delimiters)
+		}
+		if mixture := column.Mixture; mixture != nil {
+			lw.write(column.Name, "dominant_type", mixture.Dominant)
+			lw.number(column.Name, "dominant_share", mixture.DominantShare)
+			lw.count(column.Name, "inconsistent_cells", mixture.Inconsistent)
+		}
+		if test := column.Normality; test != nil {
+			lw.number(column.Name, "normality_statistic", test.Statistic)
+			lw.number(column.Name, "normality_p_value", test.PValue)
+			lw.write(column.Name, "normal", strconv.FormatBool(test.Normal))
+		}
+	}
+
+	// Flushes the buffered rows and reports any write error.
+	lw.csv.Flush()
+	if err := lw.csv.Error(); err != nil {
+		return fmt.Errorf("error writing long report: %v", err)
+	}
+	return nil
+}
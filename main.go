@@ -1,60 +1,431 @@
 package main
 
 import (
+	"bytes"
 	"encoding/csv"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Dataset represent our CSV data structure
 type Dataset struct {
 	Headers     []string
 	Rows        [][]string
-	NumericCols map[int]bool // track which columns are numeric
+	NumericCols map[int]bool       // track which columns are numeric
+	ColumnTypes map[int]ColumnType // type of every column (Numeric, Boolean, Date or Text)
+	Sources     []SourceFile       // files that contributed rows, in load order
+	Sampling    *SamplingInfo      // set when Rows holds a sample rather than every row
+	RowRange    *RowRangeInfo      // set when --rows loaded only some of the rows
+	// numberLocale and dateLayouts are the parsing settings of the analyzer that loaded the rows, used by the typed
+	// getters of Row
+	numberLocale *NumberLocale
+	dateLayouts  []string
+}
+
+// SourceFile records one input file and how many data rows it contributed
+type SourceFile struct {
+	Path string `json:"path"`
+	Rows int    `json:"rows"`
 }
 
 // ColumnStats holds statistical information for a column
 type ColumnStats struct {
-	Name   string
-	Count  int
-	Sum    float64
-	Mean   float64
-	Median float64
-	StdDev float64
-	Min    float64
-	Max    float64
+	Name  string  `json:"name"`
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+	Mean  float64 `json:"mean"`
+	// GeometricMean and HarmonicMean are nil unless every value is positive
+	GeometricMean *float64 `json:"geometric_mean,omitempty"`
+	HarmonicMean  *float64 `json:"harmonic_mean,omitempty"`
+	// MeanCI is the Student t confidence interval of the mean at --confidence, when there are at least two values
+	MeanCI *ConfidenceInterval `json:"mean_ci,omitempty"`
+	// TrimmedMean leaves out the TrimFraction smallest and largest values
+	TrimmedMean  float64 `json:"trimmed_mean"`
+	TrimFraction float64 `json:"trim_fraction"`
+	Median       float64 `json:"median"`
+	// MedianApproximate is set when the column was too large for an exact median (see exactQuantileLimit)
+	MedianApproximate bool    `json:"median_approximate,omitempty"`
+	StdDev            float64 `json:"std_dev"`
+	Min               float64 `json:"min"`
+	Max               float64 `json:"max"`
+	// Missing counts the empty cells of the column and NAPolicy says how they entered the statistics (see NAPolicies)
+	Missing  int    `json:"missing"`
+	NAPolicy string `json:"na_policy"`
+	// Format is how the values are written: integer, decimal or scientific, with their widths (see numericFormat)
+	Format *NumericFormat `json:"format,omitempty"`
 }
 
 // TextColumnStats holds statistical information for text columns
 type TextColumnStats struct {
-	Name         string
-	TotalCount   int
-	UniqueCount  int
-	UniqueValues []string
+	Name         string       `json:"name"`
+	TotalCount   int          `json:"total_count"`
+	UniqueCount  int          `json:"unique_count"`
+	UniqueValues []string     `json:"unique_values"`
+	Strings      *StringStats `json:"strings,omitempty"` // lengths, whitespace and letter case of the values
 }
 
 // CSVAnalyzer handles the analysis operations
 type CSVAnalyzer struct {
-	dataset *Dataset
+	dataset    *Dataset
+	options    Options
+	dictionary DataDictionary // optional column descriptions and units
+	config     Config         // optional settings from the --config file
+	// numberLocale enables currency and separator-aware number parsing (nil keeps strict parsing)
+	numberLocale *NumberLocale
+	// privacy adds differential-privacy noise to published aggregates (nil publishes exact figures)
+	privacy *privacyNoise
+	// loadWarnings are the data problems noticed while reading the input (see DataWarnings)
+	loadWarnings []DataWarning
+	// telemetry times the phases of the run for --stats-internal (nil when it is off)
+	telemetry *telemetry
+	// explain records the operations of the run for --explain (nil when it is off)
+	explain *explainPlan
+	// expectedSchema is the schema of --expect-schema the dataset is checked against (nil when there is none)
+	expectedSchema []SchemaColumn
+	// valueRules are the assertions of the --rules file, evaluated once into ruleResults (see RuleResults)
+	valueRules  []ValueRule
+	ruleResults []RuleResult
+	ruleOnce    sync.Once
+	// nullValues are the lower-cased cell values treated as missing (see blankNullValues)
+	nullValues map[string]bool
+	// dateLayouts are the preset's date formats, tried after the built-in ones (see CSVAnalyzer.parseDate)
+	dateLayouts []string
+	// numeric caches the parsed numeric columns, filled once by numericColumns
+	numeric     map[int]*numericColumn
+	numericOnce sync.Once
+	// spill holds the rows written to disk once they outgrew --max-memory, while loading (nil when they fit), and
+	// spilled the column profiles computed from them (see profileSpilled)
+	spill    *rowSpill
+	spilled  *spilledProfile
+	rowBytes int64 // estimated memory of the rows held, while --max-memory is set
+	// spillable is set by commands that can report on a spilled dataset; the others fail when it outgrows the cap
+	spillable bool
 }
 
+// exitCriticalAlert is the exit code used when a critical alert rule fails
+const exitCriticalAlert = 3
+
+// typeDetectionRows is how many rows detectNumericColumns inspects unless --type-sample-rows says otherwise
+const typeDetectionRows = 10
+
 // NewCSVAnalyzer creates a new analyzer instance
 func NewCSVAnalyzer() *CSVAnalyzer {
+	return NewCSVAnalyzerWithOptions(Options{})
+}
+
+// NewCSVAnalyzerWithOptions creates a new analyzer instance that loads and reports according to opts
+func NewCSVAnalyzerWithOptions(opts Options) *CSVAnalyzer {
+	// Options are validated by the caller, so an unknown locale simply means strict parsing here.
+	locale, _ := lookupNumberLocale(opts.Locale)
+	var dateLayouts []string
+	if preset, _ := lookupPreset(opts.Preset); preset != nil {
+		dateLayouts = preset.DateLayouts
+	}
 	return &CSVAnalyzer{
 		dataset: &Dataset{
-			NumericCols: make(map[int]bool),
+			NumericCols:  make(map[int]bool),
+			ColumnTypes:  make(map[int]ColumnType),
+			numberLocale: locale,
+			dateLayouts:  dateLayouts,
 		},
+		options:      opts,
+		numberLocale: locale,
+		privacy:      newPrivacyNoise(opts.PrivacyEpsilon),
+		nullValues:   nullValueSet(opts),
+		dateLayouts:  dateLayouts,
+		explain:      newExplainPlan(opts.Explain),
 	}
 }
 
+// SetConfig applies the settings loaded from a configuration file
+func (ca *CSVAnalyzer) SetConfig(config Config) {
+	ca.config = config
+}
+
 // LoadCSV reads and parses a CSV file
+// The filename may also be a glob pattern such as "logs/*.csv"; every matching file is loaded into one logical dataset,
+// provided they all share the same header row. The rows contributed by each file are recorded in Dataset.Sources.
 // Defines a method named 'LoadCSV' for CSVAnalyzer, taking a filename string and returning an error.
 func (ca *CSVAnalyzer) LoadCSV(filename string) error {
+	// Expands the filename into the list of files to load (a single file unless it is a glob pattern).
+	paths, err := expandInputPattern(filename)
+	// Checks if the pattern could not be expanded.
+	if err != nil {
+		return err
+	}
+	// Creates a sampler when approximate statistics were requested; nil means every row is kept.
+	sampler := newRowSampler(ca.options.Sampling)
+	// Keeps only the rows of --rows; with a row index the file is read from just before them.
+	window := newRowWindow(ca.options.Rows)
+	index := ca.rowIndexFor(paths, window)
+	kept := 0
+	endRead := ca.telemetry.phase(phaseRead)
+	// Removes the rows spilled past --max-memory once they are profiled, or if loading fails first.
+	defer ca.removeSpill()
+
+	// Loops through every file that makes up the dataset.
+	for i, path := range paths {
+		// Tracks whether the header row of this file has been seen, and how many data rows followed it.
+		headerSeen := false
+		rowCount, contributed, skipped := 0, 0, 0
+		var ragged raggedRows
+		readFile := ca.readCSVFile
+		if index != nil {
+			endSeek := ca.explain.step("seek row index", rowIndexPath(path), -1)
+			offset := window.seek(index)
+			// The rows before the offset are skipped, so row numbers continue from them.
+			rowCount, skipped = window.seen, window.seen
+			endSeek(-1)
+			readFile = func(path string, visit func(record []string) error) error {
+				return ca.readCSVFileFrom(path, index, offset, visit)
+			}
+		}
+		endScan := ca.explain.step("scan", path, -1)
+		// Streams the CSV records of the current file one by one.
+		err := readFile(path, func(record []string) error {
+			// First row is headers
+			if !headerSeen {
+				headerSeen = true
+				if i == 0 {
+					// Assigns the first file's header row as the dataset's headers.
+					ca.dataset.Headers = record
+				} else if !equalHeaders(ca.dataset.Headers, record) {
+					// Every later file must repeat exactly the same headers, otherwise the columns would not line up.
+					return fmt.Errorf("header mismatch in %s: expected %v, got %v", path, ca.dataset.Headers, record)
+				}
+				return nil
+			}
+			// Counts the data row and either samples it or appends it to the dataset's data rows.
+			rowCount++
+			var done error
+			if window != nil {
+				var keep bool
+				if keep, done = window.add(record, i, rowCount+1); !keep {
+					return done
+				}
+			}
+			// Rows with a different number of fields are kept, but noted; the header is line 1.
+			if len(record) != len(ca.dataset.Headers) {
+				ragged.add(rowCount+1, len(record))
+			}
+			contributed++
+			if err := ca.keepRow(sampler, record); err != nil {
+				return err
+			}
+			return done
+		})
+		// The end of --rows ends the reading of this file and of any later ones.
+		rangeDone := err == errRowRangeDone
+		if err != nil && !rangeDone {
+			return err
+		}
+		// Checks if no records were read, indicating an empty CSV file.
+		if !headerSeen {
+			// If empty, returns an error message naming the file.
+			return fmt.Errorf("empty csv file: %s", path)
+		}
+		endScan(rowCount - skipped)
+		// Keeps the warnings about this file, then records how many data rows came from it.
+		ca.recordLoadWarnings(path, ca.dataset.Headers, ragged)
+		ca.dataset.Sources = append(ca.dataset.Sources, SourceFile{Path: path, Rows: contributed})
+		kept += contributed
+		if rangeDone {
+			break
+		}
+	}
+	// The rows of a tail range are only known once every file was read.
+	if window != nil {
+		ragged := make(map[int]*raggedRows)
+		for _, row := range window.tailRows() {
+			if err := ca.keepRow(sampler, row.record); err != nil {
+				return err
+			}
+			ca.dataset.Sources[row.source].Rows++
+			kept++
+			if len(row.record) != len(ca.dataset.Headers) {
+				if ragged[row.source] == nil {
+					ragged[row.source] = &raggedRows{}
+				}
+				ragged[row.source].add(row.line, len(row.record))
+			}
+		}
+		for source := range ca.dataset.Sources {
+			if ragged[source] != nil {
+				ca.recordLoadWarnings(ca.dataset.Sources[source].Path, ca.dataset.Headers, *ragged[source])
+			}
+		}
+		ca.dataset.RowRange = window.info(ca.options.Rows, kept)
+	}
+
+	// When sampling, the dataset holds only the sampled rows, and we remember how they were chosen.
+	if sampler != nil {
+		// The rows were sampled while scanning, so the step itself takes no time of its own.
+		ca.explain.step("sample", "while scanning", ca.dataset.rowsRead())(len(sampler.rows))
+		ca.dataset.Rows = sampler.rows
+		ca.dataset.Sampling = sampler.info()
+	}
+
+	endRead()
+
+	// Rows spilled past --max-memory are profiled from their files a few columns at a time instead.
+	if ca.spill != nil {
+		return ca.profileSpilled()
+	}
+	return ca.prepareDataset()
+}
+
+// The prepareDataset method is part of the CSVAnalyzer struct. It takes the rows as read and readies them for
+// analysis: repeated headers are renamed, null values blanked, columns dropped, renamed, recoded and derived, types
+// detected and numeric columns parsed, and the columns named by the options are checked to exist. LoadCSV runs it on
+// the whole dataset, and profileSpilled on every batch of columns of a dataset spilled to disk.
+// prepareDataset turns the loaded rows into an analyzable dataset
+func (ca *CSVAnalyzer) prepareDataset() error {
+	// Gives repeated header names a suffix, so every column can be addressed by name (see recordLoadWarnings).
+	ca.dataset.Headers = disambiguateHeaders(ca.dataset.Headers)
+
+	// Empties the null values before anything looks at them, so every statistic counts them as missing.
+	if len(ca.nullValues) > 0 {
+		endStep := ca.explain.step("blank null values", strings.Join(sortedKeys(ca.nullValues), ","), len(ca.dataset.Rows))
+		ca.blankNullValues()
+		endStep(len(ca.dataset.Rows))
+	}
+
+	// Drops and renames columns before anything looks at them, so every option sees the final names.
+	if len(ca.options.Drop) > 0 || len(ca.options.Renames) > 0 {
+		endStep := ca.explain.step("drop/rename columns", fmt.Sprintf("%d dropped, %d renamed", len(ca.options.Drop), len(ca.options.Renames)), len(ca.dataset.Rows))
+		if err := ca.applyColumnTransforms(); err != nil {
+			return err
+		}
+		endStep(len(ca.dataset.Rows))
+	}
+	// Category values are recoded before anything counts or groups them.
+	if len(ca.options.Recode) > 0 {
+		endStep := ca.explain.step("recode categories", ca.options.Recode.String(), len(ca.dataset.Rows))
+		if err := ca.recodeCategories(); err != nil {
+			return err
+		}
+		endStep(len(ca.dataset.Rows))
+	}
+	// Derived columns are computed from the final names and typed like the columns of the file.
+	if len(ca.options.Derive) > 0 {
+		endStep := ca.explain.step("derive columns", ca.options.Derive.String(), len(ca.dataset.Rows))
+		if err := ca.deriveColumns(); err != nil {
+			return err
+		}
+		endStep(len(ca.dataset.Rows))
+	}
+
+	// Detect column types
+	// Calls the 'detectColumnTypes' method to identify numeric, boolean and text columns and apply forced types.
+	endDetect := ca.telemetry.phase("detect types")
+	endStep := ca.explain.step("detect types", ca.options.TypeDetection.String(), len(ca.dataset.Rows))
+	if err := ca.detectColumnTypes(); err != nil {
+		return err
+	}
+	endStep(len(ca.dataset.Rows))
+	// Parses the numeric columns now, concurrently, so the parse is timed as part of loading and done only once.
+	endStep = ca.explain.step("parse numeric columns", "all cores", len(ca.dataset.Rows))
+	ca.resetNumericColumns()
+	ca.numericColumns()
+	endStep(len(ca.dataset.Rows))
+	endDetect()
+	// Quasi-identifiers must name real columns, otherwise the k-anonymity figures would be meaningless.
+	if err := ca.checkColumnsExist("quasi-identifier", ca.options.QuasiIdentifiers); err != nil {
+		return err
+	}
+	if ca.options.SplitOn != "" {
+		if err := ca.checkColumnsExist("--split-on", []string{ca.options.SplitOn}); err != nil {
+			return err
+		}
+	}
+	for _, rule := range ca.options.FillMissing {
+		if err := ca.checkColumnsExist("--fill-missing", []string{rule.Column}); err != nil {
+			return err
+		}
+	}
+	for _, rule := range ca.options.TreatOutliers {
+		if err := ca.checkColumnsExist("--treat-outliers", []string{rule.Column}); err != nil {
+			return err
+		}
+	}
+	if ca.options.GroupBy != "" {
+		if err := ca.checkColumnsExist("--group-by", []string{ca.options.GroupBy}); err != nil {
+			return err
+		}
+	}
+	if ca.options.SplitBy != "" {
+		if err := ca.checkColumnsExist("--split-by", []string{ca.options.SplitBy}); err != nil {
+			return err
+		}
+	}
+	if err := ca.checkColumnsExist("--top-by", ca.options.TopBy); err != nil {
+		return err
+	}
+	if err := ca.checkTopBy(); err != nil {
+		return err
+	}
+	if err := ca.checkColumnsExist("--top-with", ca.options.TopWith); err != nil {
+		return err
+	}
+	if ca.options.Rolling.enabled() {
+		if err := ca.checkColumnsExist("--on", []string{ca.options.RollingOn}); err != nil {
+			return err
+		}
+	}
+	for _, key := range ca.options.UniqueKeys {
+		if err := ca.checkColumnsExist("--unique", key); err != nil {
+			return err
+		}
+	}
+	for column := range ca.options.NAPolicy.Columns {
+		if err := ca.checkColumnsExist("--na-policy", []string{column}); err != nil {
+			return err
+		}
+	}
+	// Rules may only name real columns, and any broken rule fails the run like a critical alert.
+	if len(ca.valueRules) > 0 {
+		if err := ca.checkColumnsExist("--rules", ruleColumns(ca.valueRules)); err != nil {
+			return err
+		}
+		rule := AlertRule{Name: "rules file", Metric: "rule_violations", Op: "==", Value: 0, Severity: SeverityCritical}
+		rule.normalize()
+		ca.config.Alerts = append(ca.config.Alerts, rule)
+	}
+	// Adds the preset's checks now that the numeric columns are known.
+	ca.config.Alerts = append(ca.config.Alerts, ca.presetRules()...)
+	// If all operations are successful, returns nil, indicating no error.
+	return nil
+}
+
+// keepRow adds a data row to the dataset, offers it to the sampler when sampling, or spills it to disk once the
+// rows outgrow --max-memory
+func (ca *CSVAnalyzer) keepRow(sampler *rowSampler, record []string) error {
+	switch {
+	case sampler != nil:
+		sampler.add(record)
+	case ca.options.MaxMemory > 0:
+		return ca.keepRowWithinMemory(record)
+	default:
+		ca.dataset.Rows = append(ca.dataset.Rows, record)
+	}
+	return nil
+}
+
+// readCSVFile opens a single CSV file and passes each of its records, header included, to visit.
+// Records are streamed rather than read all at once, so rows can be sampled without holding the whole file.
+// Files ending in .json, .jsonl or .ndjson are read as JSON instead (see readJSONRecords), .arrow or .arrows
+// files as Apache Arrow (see readArrowRecords), and .db, .db3, .sqlite or .sqlite3 files as a table of a SQLite
+// database (see readSQLiteRecords).
+func (ca *CSVAnalyzer) readCSVFile(filename string, visit func(record []string) error) error {
 	// Attempts to open the file specified by 'filename'. Returns a file object and an error (if any).
 	file, err := os.Open(filename)
 	// Checks if an error occurred during file opening.
@@ -64,32 +435,98 @@ func (ca *CSVAnalyzer) LoadCSV(filename string) error {
 	}
 	// Ensures the file is closed when the function exits, regardless of how it exits.
 	defer file.Close()
-	// Creates a new CSV reader that will read from the opened file.
-	reader := csv.NewReader(file)
-	// Reads all available CSV records from the reader into a slice of string slices.
-	records, err := reader.ReadAll()
-	// Checks if an error occurred during CSV reading.
+
+	// The file size is the 100% mark of the progress bar and decides on parallel parsing; only regular files have one.
+	var size int64
+	if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+		size = info.Size()
+	}
+	// The CSV reader consumes the file directly, or through a progress bar when one was requested.
+	var input io.Reader = file
+	if ca.options.Progress {
+		progress := newProgressReader(file, os.Stderr, filepath.Base(filename), size)
+		defer progress.finish()
+		input = progress
+	}
+	// Arrow data and SQLite databases are binary and typed, so they skip transcoding.
+	if isArrowInput(filename) {
+		return readArrowRecords(input, filename, visit)
+	}
+	if isSQLiteInput(filename) {
+		return readSQLiteRecords(input, filename, ca.options.Table, visit)
+	}
+	// Transcodes UTF-16 and single-byte encodings to UTF-8 and drops any byte order mark.
+	encoding, err := lookupEncoding(ca.options.Encoding)
+	if err != nil {
+		return err
+	}
+	input, _ = decodeInput(input, encoding)
+	// JSON and JSON Lines files are flattened into the same records a CSV file would produce.
+	if isJSONInput(filename) {
+		return readJSONRecords(input, filename, visit)
+	}
+	// Large files are split into chunks parsed on every core.
+	if ca.canReadInParallel(size) {
+		return ca.readCSVParallel(input, filename, visit)
+	}
+	// Creates a new CSV reader that will read from the opened file with the configured quoting.
+	reader, err := ca.newCSVReader(input)
 	if err != nil {
-		// If an error, wraps it with a message and returns it.
-		return fmt.Errorf("error reading CSV file: %v", err)
+		return err
 	}
-	// Checks if no records were read, indicating an empty CSV file.
-	if len(records) == 0 {
-		// If empty, returns an error message.
-		return fmt.Errorf("empty csv file")
+	// Reads the records one at a time until the end of the file.
+	for {
+		record, err := reader.Read()
+		// The end of the file is the normal way out of the loop.
+		if err == io.EOF {
+			return nil
+		}
+		// Checks if an error occurred during CSV reading.
+		if err != nil {
+			// If an error, wraps it with a message and returns it.
+			return fmt.Errorf("error reading CSV file %s: %v", filename, err)
+		}
+		// Hands the record to the caller, stopping on the first error it reports.
+		if err := visit(record); err != nil {
+			return err
+		}
 	}
+}
 
-	// First row is headers
-	// Assigns the first row of records as the dataset's headers.
-	ca.dataset.Headers = records[0]
-	// Assigns all subsequent rows (from the second row onwards) as the dataset's data rows.
-	ca.dataset.Rows = records[1:]
+// expandInputPattern turns the input argument into a sorted list of files.
+// Plain filenames are returned as-is; arguments containing glob characters are expanded.
+func expandInputPattern(pattern string) ([]string, error) {
+	// Anything without glob metacharacters is treated as a single literal path.
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+	// Expands the pattern against the file system.
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file pattern %q: %v", pattern, err)
+	}
+	// A pattern that matches nothing is almost certainly a mistake, so report it.
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match pattern %q", pattern)
+	}
+	// Sorts the matches so rows are always combined in the same order.
+	sort.Strings(matches)
+	return matches, nil
+}
 
-	// Detect numeric columns
-	// Calls the 'detectNumericColumns' method to identify numeric columns in the loaded data.
-	ca.detectNumericColumns()
-	// If all operations are successful, returns nil, indicating no error.
-	return nil
+// equalHeaders reports whether two header rows contain the same names in the same order
+func equalHeaders(a, b []string) bool {
+	// Different lengths can never match.
+	if len(a) != len(b) {
+		return false
+	}
+	// Compares the names position by position, ignoring surrounding whitespace.
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 // detectNumericColumns identifies which columns contain numeric data
@@ -104,37 +541,39 @@ func (ca *CSVAnalyzer) detectNumericColumns() {
 		return
 	}
 
+	// Picks the rows to check (by default the first 10; see --type-sample).
+	checkRows := ca.detectionRows()
+
+	// A column is numeric when at least this share of its inspected values are numbers (all of them by default).
+	threshold := ca.options.TypeDetection.numericThreshold()
+
 	// Loop through each column based on the number of headers.
 	for colIndex := range ca.dataset.Headers {
-		// Initialize a flag for the current column, assuming it's numeric until proven otherwise.
-		isNumeric := true
+		// Counts the inspected non-empty values and those that are not numbers.
+		values, others := 0, 0
 
-		// Check first few rows to determine if column is numeric
-		// checkRows := min(len(ca.dataset.Rows), 10)
-		checkRows := len(ca.dataset.Rows)
-		if checkRows > 10 {
-			checkRows = 10 // Limit to first 10 rows for numeric check
-		}
-		// Loop through the determined number of rows.
-		for rowIndex := 0; rowIndex < checkRows; rowIndex++ {
+		// Loop through the selected rows.
+		for _, rowIndex := range checkRows {
 			// Ensures the column index is within the bounds of the current row's data.
 			if colIndex < len(ca.dataset.Rows[rowIndex]) {
 				// Get the cell value and remove leading/trailing whitespace.
 				value := strings.TrimSpace(ca.dataset.Rows[rowIndex][colIndex])
 				// Check if the trimmed value is not empty.
 				if value != "" {
+					values++
 					// Attempt to convert the value to a float64; if an error occurs, it's not numeric.
-					if _, err := strconv.ParseFloat(value, 64); err != nil {
-						// Set the flag to false, indicating the column is not numeric.
-						isNumeric = false
-						// Stop checking this column as it's already identified as non-numeric.
-						break
+					if _, err := ca.parseNumber(value); err != nil {
+						others++
+						// Without a tolerance one exception is enough to decide.
+						if threshold >= 1 {
+							break
+						}
 					}
 				}
 			}
 		}
-		// Store the result (whether the column is numeric) in the dataset's map.
-		ca.dataset.NumericCols[colIndex] = isNumeric
+		// Store the result (whether the column is numeric) in the dataset's map; columns with no values stay numeric.
+		ca.dataset.NumericCols[colIndex] = values == 0 || float64(values-others) >= threshold*float64(values)
 	}
 }
 
@@ -147,48 +586,83 @@ func (ca *CSVAnalyzer) detectNumericColumns() {
 func (ca *CSVAnalyzer) CalculateStats() []ColumnStats {
 	// Declares an empty slice named 'stats' to store the calculated statistics for each column.
 	var stats []ColumnStats
-	// Iterates through the map of numeric columns (colIndex is the column index, isNumeric is a boolean indicating if it's numeric).
-	for colIndex, isNumeric := range ca.dataset.NumericCols {
-		// Checks if the column is NOT numeric OR if its index is out of bounds for the headers.
-		if !isNumeric || colIndex >= len(ca.dataset.Headers) {
-			// If either condition is true, skip to the next column.
-			continue
-		}
-		// Calls a helper method to extract all numeric values from the current column.
-		values := ca.extractNumericValues(colIndex)
-		// Checks if no numeric values were successfully extracted from the column.
-		if len(values) == 0 {
-			// If the column has no valid numeric values, skip to the next column.
-			continue
-		}
-		// Creates a new instance of the 'ColumnStats' struct.
-		colStats := ColumnStats{
-			// Assigns the column header as the name for these statistics.
-			Name: ca.dataset.Headers[colIndex],
-			// Records the number of valid numeric values found in the column.
-			Count: len(values),
-		}
-
-		// Calculate basic stats
-		// Calls a 'sum' utility function to calculate the sum of all numeric values.
-		colStats.Sum = sum(values)
-		// Calculates the mean (average) by dividing the sum by the count of values.
-		colStats.Mean = colStats.Sum / float64(len(values))
-		// Calls a 'median' utility function to calculate the median of the values.
-		colStats.Median = median(values)
-		// Calls a 'standardDeviation' utility function to calculate the standard deviation using the values and their mean.
-		colStats.StdDev = standardDeviation(values, colStats.Mean)
-		// Calls a 'min' utility function to find the minimum value in the slice (using variadic arguments).
-		colStats.Min = min(values...)
-		// Calls a 'max' utility function to find the maximum value in the slice (using variadic arguments).
-		colStats.Max = max(values...)
-		// Appends the populated 'colStats' struct to the 'stats' slice.
-		stats = append(stats, colStats)
+	// Iterates through the columns in header order so the report order is stable.
+	for colIndex := range ca.dataset.Headers {
+		// Computes the statistics for the column; non-numeric or empty columns are skipped.
+		if colStats, ok := ca.calculateColumnStats(colIndex); ok {
+			// Appends the populated 'colStats' struct to the 'stats' slice (noised when privacy is enabled).
+			stats = append(stats, ca.publishedNumericStats(colStats))
+		}
 	}
 	// Returns the slice containing statistics for all identified numeric columns.
 	return stats
 }
 
+// calculateColumnStats computes the statistics of a single numeric column.
+// The boolean result is false when the column is not numeric or holds no numeric values.
+func (ca *CSVAnalyzer) calculateColumnStats(colIndex int) (ColumnStats, bool) {
+	// Checks if the column is NOT numeric OR if its index is out of bounds for the headers.
+	if !ca.dataset.NumericCols[colIndex] || colIndex >= len(ca.dataset.Headers) {
+		// If either condition is true, there are no statistics to compute.
+		return ColumnStats{}, false
+	}
+	// Calls a helper method to extract all numeric values from the current column.
+	values := ca.extractNumericValues(colIndex)
+	// Checks if no numeric values were successfully extracted from the column.
+	if len(values) == 0 {
+		// If the column has no valid numeric values, there is nothing to summarize.
+		return ColumnStats{}, false
+	}
+	// Count, sum, mean, spread and range come from the single-pass accumulator built while parsing, with missing
+	// values added in when the column's --na-policy counts them as 0.
+	accumulated := ca.numericColumns()[colIndex].stats
+	policy := ca.options.NAPolicy.forColumn(ca.dataset.Headers[colIndex])
+	missing := ca.missingCells(colIndex)
+	values, accumulated = applyNAPolicy(policy, missing, values, accumulated)
+	// Creates a new instance of the 'ColumnStats' struct.
+	colStats := ColumnStats{
+		// Assigns the column header as the name for these statistics.
+		Name: ca.dataset.Headers[colIndex],
+		// Records the number of valid numeric values found in the column.
+		Count:    len(values),
+		Missing:  missing,
+		NAPolicy: policy,
+	}
+	colStats.Format = ca.numericFormat(colIndex)
+	// One missing value makes every figure unknown under the propagate policy.
+	if policy == NAPropagate && missing > 0 {
+		colStats.TrimFraction = ca.options.TrimFraction
+		return propagatedStats(colStats), true
+	}
+
+	// Calculate basic stats
+	colStats.Sum = accumulated.sum.value()
+	colStats.Mean = colStats.Sum / float64(accumulated.count)
+	// The geometric and harmonic means only exist for strictly positive data.
+	if mean, ok := geometricMean(values); ok {
+		colStats.GeometricMean = &mean
+	}
+	if mean, ok := harmonicMean(values); ok {
+		colStats.HarmonicMean = &mean
+	}
+	colStats.TrimFraction = ca.options.TrimFraction
+	colStats.TrimmedMean = trimmedMean(values, colStats.TrimFraction)
+	// The median is exact up to exactQuantileLimit values and estimated from the t-digest beyond, which avoids sorting
+	// the largest columns.
+	if len(values) > exactQuantileLimit {
+		colStats.Median = accumulated.digest.quantile(0.5)
+		colStats.MedianApproximate = true
+	} else {
+		colStats.Median = median(values)
+	}
+	colStats.StdDev = accumulated.stdDev()
+	colStats.MeanCI = meanConfidenceInterval(colStats.Mean, colStats.StdDev, colStats.Count, ca.options.ConfidenceLevel)
+	colStats.Min = accumulated.min
+	colStats.Max = accumulated.max
+	// Returns the populated statistics.
+	return colStats, true
+}
+
 // The extractNumericValues method is a helper function belonging to the CSVAnalyzer struct. Its sole purpose is to iterate
 // through a specific column of the loaded CSV data, attempt to convert each cell's value in that column into a float64, and
 // collect all successfully converted numeric values into a new slice of float64. This function ensures that only valid numeric
@@ -196,6 +670,10 @@ func (ca *CSVAnalyzer) CalculateStats() []ColumnStats {
 // extractNumericValues gets all numeric values from a column
 // Defines a method 'extractNumericValues' for CSVAnalyzer, taking a column index (int) and returning a slice of float64s.
 func (ca *CSVAnalyzer) extractNumericValues(colIndex int) []float64 {
+	// Numeric columns were parsed once already; callers only read the shared slice.
+	if colIndex < len(ca.dataset.NumericCols) && ca.dataset.NumericCols[colIndex] {
+		return ca.numericColumns()[colIndex].values
+	}
 	// Declares an empty slice of float64s named 'values' to store extracted numeric data.
 	var values []float64
 	// Iterates through each 'row' in the dataset's 'Rows' (which are slices of strings).
@@ -207,7 +685,7 @@ func (ca *CSVAnalyzer) extractNumericValues(colIndex int) []float64 {
 			// Checks if the trimmed string 'value' is not empty.
 			if value != "" {
 				// Attempts to parse the string 'value' into a float64. Checks if the parsing was successful (err is nil).
-				if num, err := strconv.ParseFloat(value, 64); err == nil {
+				if num, err := ca.parseNumber(value); err == nil {
 					// If parsing is successful, appends the converted float64 'num' to the 'values' slice.
 					values = append(values, num)
 				}
@@ -218,13 +696,46 @@ func (ca *CSVAnalyzer) extractNumericValues(colIndex int) []float64 {
 	return values
 }
 
+// The compensatedSum type adds up floating-point values with Neumaier's variant of Kahan summation: besides the
+// running total it keeps the low-order bits each addition rounds away and adds them back at the end, so the error
+// stays at a few units in the last place however many values there are, instead of growing with the row count.
+// It also survives the case plain Kahan summation gets wrong, a value larger than the total so far, which matters
+// for columns whose magnitudes span many orders (1e16 + 1 - 1e16 is 1, not 0).
+type compensatedSum struct {
+	total        float64
+	compensation float64
+}
+
+// add adds one value to the sum
+func (s *compensatedSum) add(v float64) {
+	t := s.total + v
+	if math.Abs(s.total) >= math.Abs(v) {
+		s.compensation += (s.total - t) + v
+	} else {
+		s.compensation += (v - t) + s.total
+	}
+	s.total = t
+}
+
+// merge adds another sum to this one, keeping both compensations
+func (s *compensatedSum) merge(other compensatedSum) {
+	s.add(other.total)
+	s.compensation += other.compensation
+}
+
+// value returns the compensated sum
+func (s compensatedSum) value() float64 {
+	return s.total + s.compensation
+}
+
 // Statistical functions
+// sum adds up the values with compensated summation
 func sum(values []float64) float64 {
-	total := 0.0
+	var total compensatedSum
 	for _, v := range values {
-		total += v
+		total.add(v)
 	}
-	return total
+	return total.value()
 }
 
 // The median function calculates the median of a given set of float64 values. The median is the middle value in a sorted list
@@ -256,27 +767,20 @@ func median(values []float64) float64 {
 	return sorted[n/2]
 }
 
-// The standardDeviation function calculates the sample standard deviation of a given set of numeric values. It takes a slice of
-// float64 values and their pre-calculated mean as input. The sample standard deviation is a measure of the amount of variation
-// or dispersion of a set of values, specifically when dealing with a sample of a larger population.
-// Defines a function named 'standardDeviation' that takes a slice of float64s and a float64 mean, returning a float64.
-func standardDeviation(values []float64, mean float64) float64 {
-	// Checks if the input slice of values is empty.
-	if len(values) == 0 {
-		// If the slice is empty, returns 0 as the standard deviation
-		return 0
-	}
-	// Initializes a variable 'variance' to 0.0 to accumulate squared differences.
-	variance := 0.0
-	// Iterates through each value 'v' in the 'values' slice.
+// The standardDeviation function calculates the sample standard deviation of a given set of numeric values. The sample
+// standard deviation is a measure of the amount of variation or dispersion of a set of values, specifically when dealing
+// with a sample of a larger population. It runs the values through a numericAccumulator, so the mean and the squared
+// deviations come from a single pass by Welford's online algorithm, the same one the column statistics and a
+// streaming reader use, without a pre-computed mean or the cancellation of the sum-of-squares formula.
+// Defines a function named 'standardDeviation' that takes a slice of float64s, returning a float64 (0 for fewer than two values).
+func standardDeviation(values []float64) float64 {
+	accumulated := newNumericAccumulator(false)
+	// Feeds every value to the accumulator once.
 	for _, v := range values {
-		// Calculates the squared difference between the current value and the mean, and adds it to 'variance'.
-		variance += math.Pow(v-mean, 2)
+		accumulated.add(v)
 	}
-	// Divides the sum of squared differences by (number of values - 1) to get the sample variance.
-	variance /= float64(len(values) - 1) // Sample standard deviation
-	// Returns the square root of the calculated variance, which is the standard deviation.
-	return math.Sqrt(variance)
+	// Returns the sample standard deviation, with the (number of values - 1) divisor.
+	return accumulated.stdDev()
 }
 
 // Utility functions for min/max
@@ -331,33 +835,43 @@ func max(values ...float64) float64 {
 func (ca *CSVAnalyzer) CalculateTextStats() []TextColumnStats {
 	// Declares an empty slice named 'stats' to store the calculated statistics for text columns.
 	var stats []TextColumnStats
-	// Iterates through each column index and its numeric status from the dataset's NumericCols map.
-	for colIndex, isNumeric := range ca.dataset.NumericCols {
-		// Checks if the column IS numeric OR if its index is out of bounds for the headers.
-		if isNumeric || colIndex >= len(ca.dataset.Headers) {
-			// If either condition is true (it's numeric or invalid index), skip to the next column as this function is for text columns.
-			continue
-		}
-		// Calls a helper method to extract all unique string values from the current column.
-		uniqueValues := ca.extractUniqueValues(colIndex)
-		// Creates a new instance of the 'TextColumnStats' struct.
-		colStats := TextColumnStats{
-			// Assigns the column header as the name for these text statistics.
-			Name: ca.dataset.Headers[colIndex],
-			// Calls a helper method to count all non-empty values in the current column.
-			TotalCount: ca.countNonEmptyValues(colIndex),
-			// Sets the count of unique values based on the length of the 'uniqueValues' slice.
-			UniqueCount: len(uniqueValues),
-			// Stores the slice of unique values found in the column.
-			UniqueValues: uniqueValues,
-		}
-		// Appends the populated 'colStats' struct to the 'stats' slice.
-		stats = append(stats, colStats)
+	// Iterates through the columns in header order so the report order is stable.
+	for colIndex := range ca.dataset.Headers {
+		// Computes the statistics for the column; numeric columns are skipped.
+		if colStats, ok := ca.calculateTextColumnStats(colIndex); ok {
+			// Appends the populated 'colStats' struct to the 'stats' slice (noised when privacy is enabled).
+			stats = append(stats, ca.publishedTextStats(colStats))
+		}
 	}
 	// Returns the slice containing statistics for all identified text columns.
 	return stats
 }
 
+// calculateTextColumnStats computes the statistics of a single text column.
+// The boolean result is false when the column is numeric or out of range.
+func (ca *CSVAnalyzer) calculateTextColumnStats(colIndex int) (TextColumnStats, bool) {
+	// Checks if the column is NOT text (numeric or boolean) OR if its index is out of bounds for the headers.
+	if ca.columnType(colIndex) != TypeText || colIndex >= len(ca.dataset.Headers) {
+		// If either condition is true (it's another type or invalid index), this is not a text column.
+		return TextColumnStats{}, false
+	}
+	// Calls a helper method to extract all unique string values from the current column.
+	uniqueValues := ca.extractUniqueValues(colIndex)
+	// Creates and returns a new instance of the 'TextColumnStats' struct.
+	return TextColumnStats{
+		// Assigns the column header as the name for these text statistics.
+		Name: ca.dataset.Headers[colIndex],
+		// Calls a helper method to count all non-empty values in the current column.
+		TotalCount: ca.countNonEmptyValues(colIndex),
+		// Sets the count of unique values based on the length of the 'uniqueValues' slice.
+		UniqueCount: len(uniqueValues),
+		// Stores the slice of unique values found in the column.
+		UniqueValues: uniqueValues,
+		// Measures the lengths, surrounding whitespace and letter case of the values.
+		Strings: ca.stringStats(colIndex),
+	}, true
+}
+
 // The extractUniqueValues method, part of the CSVAnalyzer struct, is designed to extract all distinct (unique) string values
 // from a specified column of the CSV dataset. It's particularly useful for "text" or "categorical" columns to understand the
 // variety of entries present. It also ensures that the returned list of unique values is sorted for consistent output.
@@ -426,32 +940,159 @@ func (ca *CSVAnalyzer) countNonEmptyValues(colIndex int) int {
 // PrintReport formats and displays the analysis results
 // Defines a method 'PrintReport' for CSVAnalyzer; it takes no arguments and returns nothing (only prints).
 func (ca *CSVAnalyzer) PrintReport() {
+	// A dataset spilled to disk has only its column profiles to show.
+	if ca.spilled != nil {
+		ca.printSpilledReport()
+		return
+	}
 	// Prints a title header for the report.
 	fmt.Println("=== CSV Analysis Report ===")
+	// Names the binary so results can be traced back to it.
+	fmt.Printf("Analyzer: %s\n", currentBuildInfo())
 	// Prints the total number of data rows and columns found in the dataset.
-	fmt.Printf("Dataset: %d rows, %d columns\n\n", len(ca.dataset.Rows), len(ca.dataset.Headers))
+	fmt.Printf("Dataset: %d rows, %d columns\n", ca.publishedRowCount("dataset", len(ca.dataset.Rows)), len(ca.dataset.Headers))
+	// Says which part of the input the figures cover when --rows restricted it.
+	if rowRange := ca.dataset.RowRange; rowRange != nil {
+		via := ""
+		if rowRange.Indexed {
+			via = ", read via the row index"
+		}
+		fmt.Printf("Rows: %d to %d of the input only (--rows %s%s)\n", rowRange.FirstRow, rowRange.LastRow, rowRange.Range, via)
+	}
+	// Makes it clear whether the figures below are exact or estimated from a sample.
+	if sampling := ca.dataset.Sampling; sampling != nil {
+		fmt.Printf("Statistics: APPROXIMATE, %s sample of %d out of %d rows read (seed %d)\n", sampling.Method, sampling.SampledRows, sampling.TotalRows, sampling.Seed)
+		fmt.Println("            counts and sums below refer to the sample, not the full file")
+	} else if ca.privacy == nil && ca.dataset.RowRange != nil {
+		fmt.Println("Statistics: exact (all rows in the range)")
+	} else if ca.privacy == nil {
+		fmt.Println("Statistics: exact (all rows)")
+	}
+	// Warns readers that the published figures were deliberately perturbed.
+	if ca.privacy != nil {
+		fmt.Printf("Privacy: Laplace noise applied (epsilon %g per column); value lists withheld\n", ca.privacy.epsilon)
+	}
+	// Puts the quality score up front, where it is read first.
+	quality := ca.Quality()
+	printQualitySummary(quality)
+	fmt.Println()
+
+	// Show per-file row counts when several files were combined
+	if len(ca.dataset.Sources) > 1 {
+		fmt.Printf("Source Files (%d):\n", len(ca.dataset.Sources))
+		// Prints each file with the number of rows it contributed.
+		for _, source := range ca.dataset.Sources {
+			fmt.Printf(" %s: %d rows\n", source.Path, ca.publishedRowCount(source.Path, source.Rows))
+		}
+		fmt.Println()
+	}
+
+	// Show the quality score of every column
+	printQuality(quality)
 
 	// Show column types
 	// Prints a subheading for column type information.
 	fmt.Println("Column Information")
 	// Iterates through each header and its corresponding index in the dataset.
 	for i, header := range ca.dataset.Headers {
-		// Initializes the column type as "Text" by default.
-		colType := "Text"
-		// Checks if the current column (by index 'i') was identified as numeric in the 'NumericCols' map.
-		if ca.dataset.NumericCols[i] {
-			// If it's numeric, updates the 'colType' string to "Numeric".
-			colType = "Numeric"
+		// Prints the column header and its type ("Text", "Numeric", "Boolean" or "Date").
+		fmt.Printf(" %s: %s", labelWithUnit(header, ca.annotation(header).Unit), ca.columnTypeName(i))
+		// Text columns holding several types say so instead of passing for plain text.
+		if mixture := ca.TypeMixture(i); mixture != nil {
+			fmt.Printf(" (mixed: %s)", mixture.describe())
+		}
+		// Adds the data-dictionary description so reviewers know what the column means.
+		if description := ca.annotation(header).Description; description != "" {
+			fmt.Printf(" - %s", description)
 		}
-		// Prints the column header and its determined type.
-		fmt.Printf(" %s: %s\n", header, colType)
+		fmt.Println()
 	}
 	// Prints an empty line for better formatting.
 	fmt.Println()
 
+	// Show the statistics of the numeric, text, boolean and date columns
+	found := ca.printColumnStats(ca.CalculateStats(), ca.CalculateTextStats(), ca.CalculateBooleanStats(), ca.CalculateDateStats())
+
+	// Show how many different values every column holds and which could serve as a key
+	ca.printCardinality(ca.Cardinality())
+
+	// Show the type breakdown and stray cells of columns mixing several types
+	ca.printTypeMixtures()
+
+	// Show the formats of text columns and the values that do not follow them
+	ca.printValuePatterns()
+
+	// Show the cells with line breaks, quotes or delimiters inside them
+	ca.printEmbeddedCharacters()
+
+	// Show where in the file the missing values are
+	printMissingnessMatrix(ca.MissingnessMatrix())
+
+	// Show which columns are missing in the same rows
+	printCoMissing(ca.CoMissing(), ca.options.CoMissing && ca.privacy == nil)
+
+	// Show how many rows are full, partial or mostly empty, and how each tier shifts the means
+	printCompletenessTiers(ca.CompletenessTiers())
+
+	// Show the shape of every numeric column
+	ca.printDistributions()
+
+	// Show which numeric columns are close enough to normal for mean ± std summaries
+	ca.printNormality()
+
+	// Show which columns carry near-identical content
+	printColumnClusters(ca.ColumnClusters(), ca.options.ClusterColumns)
+
+	// Show how the numeric columns move together
+	if ca.options.Correlations {
+		printCorrelationHeatmap(ca.Correlations(), useColor())
+	}
+
+	// Show how precisely the sample pins down the centre and spread of numeric columns
+	ca.printBootstrap(ca.Bootstrap())
+
+	// Show the values numeric statistics had to skip
+	if ca.options.AuditParsing {
+		printParseAudit(ca.AuditNumericParsing())
+	}
+
+	// Show the recent trend of numeric columns over time
+	printRollingStats(ca.RollingStats())
+
+	// Show how identifiable rows are through the quasi-identifiers
+	printKAnonymity(ca.KAnonymity())
+
+	// Show how each group's numeric columns compare
+	printGroupBy(ca.GroupBy())
+
+	// Show the rows with the highest and lowest values
+	printTopRows(ca.TopRows())
+
+	// Show what changed between the periods before and after the split date
+	printPeriodComparison(ca.ComparePeriods())
+
+	// Show the columns that likely hold personal data
+	printPIIFindings(ca.ScanPII(), len(ca.dataset.Headers))
+
+	// Show whether the --unique keys identify every row
+	printKeyChecks(ca.KeyChecks())
+
+	// Show which value rules of the rules file the rows break
+	printRuleResults(ca.RuleResults())
+
+	// Show the outcome of the configured alert rules
+	printAlerts(ca.EvaluateAlerts())
+
+	if !found {
+		fmt.Println("No columns found for analysis.")
+	}
+
+}
+
+// printColumnStats prints the sections of the numeric, text, boolean and date columns, and reports whether there were
+// any columns to show
+func (ca *CSVAnalyzer) printColumnStats(stats []ColumnStats, textStats []TextColumnStats, booleanStats []BooleanColumnStats, dateStats []DateColumnStats) bool {
 	// Show statistics for numeric columns
-	// Calls the 'CalculateStats' method to get the statistical results for numeric columns.
-	stats := ca.CalculateStats()
 	// Checks if the returned 'stats' slice is empty (meaning no numeric columns were found or analyzed).
 	if len(stats) > 0 {
 		// Prints a subheading for the statistical analysis section.
@@ -460,21 +1101,46 @@ func (ca *CSVAnalyzer) PrintReport() {
 		fmt.Println("----------------------------------------")
 		// Iterates through each 'ColumnStats' struct in the 'stats' slice.
 		for _, stat := range stats {
-			// Prints the name of the current column (from the 'ColumnStats' struct).
-			fmt.Printf("\n%s:\n", stat.Name)
+			// Prints the name of the current column (from the 'ColumnStats' struct), with its unit if known.
+			fmt.Printf("\n%s:\n", labelWithUnit(stat.Name, ca.annotation(stat.Name).Unit))
 			// Prints the count of numeric values for the column.
 			fmt.Printf("  Count:     %d\n", stat.Count)
-			fmt.Printf("  Sum:       %.3f\n", stat.Sum)
-			fmt.Printf("  Mean:      %.3f\n", stat.Mean)
-			fmt.Printf("  Median:    %.3f\n", stat.Median)
-			fmt.Printf("  Std Dev:   %.3f\n", stat.StdDev)
-			fmt.Printf("  Min:       %.3f\n", stat.Min)
-			fmt.Printf("  Max:       %.3f\n", stat.Max)
+			if stat.Missing > 0 || stat.NAPolicy != NASkip {
+				fmt.Printf("  Missing:   %d, %s\n", stat.Missing, describeNAPolicy(stat.NAPolicy))
+			}
+			fmt.Printf("  Sum:       %s\n", reportNumber(stat.Sum))
+			fmt.Printf("  Mean:      %s\n", reportNumber(stat.Mean))
+			if stat.MeanCI != nil {
+				fmt.Printf("  Mean CI:   %s\n", stat.MeanCI.describe())
+			}
+			// Ratio averages are only shown when they are defined for the column.
+			if stat.GeometricMean != nil {
+				fmt.Printf("  Geo Mean:  %s\n", reportNumber(*stat.GeometricMean))
+			}
+			if stat.HarmonicMean != nil {
+				fmt.Printf("  Harm Mean: %s\n", reportNumber(*stat.HarmonicMean))
+			}
+			// Small columns have nothing to trim, which makes the trimmed mean the mean.
+			if cut := trimCount(stat.Count, stat.TrimFraction); cut > 0 {
+				fmt.Printf("  Trim Mean: %s (%s trimmed from each end)\n", reportNumber(stat.TrimmedMean), countOf(cut, "value"))
+			} else {
+				fmt.Printf("  Trim Mean: %s (no values trimmed)\n", reportNumber(stat.TrimmedMean))
+			}
+			if stat.MedianApproximate {
+				fmt.Printf("  Median:    ~%s (t-digest estimate)\n", reportNumber(stat.Median))
+			} else {
+				fmt.Printf("  Median:    %s\n", reportNumber(stat.Median))
+			}
+			fmt.Printf("  Std Dev:   %s\n", reportNumber(stat.StdDev))
+			fmt.Printf("  Min:       %s\n", reportNumber(stat.Min))
+			fmt.Printf("  Max:       %s\n", reportNumber(stat.Max))
+			if stat.Format != nil {
+				fmt.Printf("  Format:    %s\n", stat.Format.describe())
+			}
 		}
 	}
 
 	// Show statistics for text columns
-	textStats := ca.CalculateTextStats()
 	if len(textStats) > 0 {
 		fmt.Println("\n\nText Analysis (Text Columns):")
 		fmt.Println("-----------------------------")
@@ -483,8 +1149,13 @@ func (ca *CSVAnalyzer) PrintReport() {
 			fmt.Printf("\n%s:\n", stat.Name)
 			fmt.Printf("  Total Count:  %d\n", stat.TotalCount)
 			fmt.Printf("  Unique Count: %d\n", stat.UniqueCount)
+			if stat.Strings != nil {
+				printStringStats(stat.Strings)
+			}
 
-			if stat.UniqueCount <= 100 {
+			if stat.UniqueValues == nil && ca.privacy != nil {
+				fmt.Println("  Unique Values: withheld (differential privacy)")
+			} else if len(stat.UniqueValues) <= 100 {
 				fmt.Printf("  Unique Values: %v\n", stat.UniqueValues)
 			} else {
 				fmt.Printf("  Unique Values (first 50): %v\n", stat.UniqueValues[:50])
@@ -493,10 +1164,38 @@ func (ca *CSVAnalyzer) PrintReport() {
 		}
 	}
 
-	if len(stats) == 0 && len(textStats) == 0 {
-		fmt.Println("No columns found for analysis.")
+	// Show the true/false breakdown of boolean columns
+	if len(booleanStats) > 0 {
+		fmt.Println("\n\nBoolean Analysis (Boolean Columns):")
+		fmt.Println("-----------------------------------")
+
+		for _, stat := range booleanStats {
+			fmt.Printf("\n%s:\n", stat.Name)
+			fmt.Printf("  True:       %d\n", stat.TrueCount)
+			fmt.Printf("  False:      %d\n", stat.FalseCount)
+			fmt.Printf("  Empty:      %d\n", stat.EmptyCount)
+			fmt.Printf("  True Ratio: %s\n", reportNumber(stat.TrueRatio))
+		}
 	}
 
+	// Show the range of date columns
+	if len(dateStats) > 0 {
+		fmt.Println("\n\nDate Analysis (Date Columns):")
+		fmt.Println("-----------------------------")
+
+		for _, stat := range dateStats {
+			fmt.Printf("\n%s:\n", stat.Name)
+			fmt.Printf("  Count:     %d\n", stat.Count)
+			fmt.Printf("  Earliest:  %s\n", reportDate(stat.Earliest))
+			fmt.Printf("  Latest:    %s\n", reportDate(stat.Latest))
+			fmt.Printf("  Span:      %s days\n", localizedNumber(stat.SpanDays, 1))
+			// Unparseable values are called out because they are silently left out of the range.
+			if stat.InvalidCount > 0 {
+				fmt.Printf("  Invalid:   %d (not recognized as dates)\n", stat.InvalidCount)
+			}
+		}
+	}
+	return len(stats) > 0 || len(textStats) > 0 || len(booleanStats) > 0 || len(dateStats) > 0
 }
 
 // The createSampleData function serves as a utility to programmatically generate a CSV file with predefined sample sales data.
@@ -547,16 +1246,100 @@ func createSampleData(filename string) error {
 }
 
 func main() {
-	// Check command line arguments
-	// Checks if the number of command-line arguments is less than 2 (program name + at least one argument).
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run . <csv-file>")
-		fmt.Println("Or: go run . sample  (to create and analyze sample data)")
+	// Subcommands have their own flags and are dispatched before the analysis flags are parsed.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "convert":
+			runConvert(os.Args[2:])
+			return
+		case "version":
+			runVersion(os.Args[2:])
+			return
+		case "sample":
+			// Plain `sample` creates the demo data; with --stratify it samples an input file instead.
+			if hasFlag(os.Args[2:], "stratify") {
+				runStratifiedSample(os.Args[2:])
+				return
+			}
+		case "capabilities":
+			runCapabilities(os.Args[2:])
+			return
+		case "self-update":
+			runSelfUpdate(os.Args[2:])
+			return
+		case "check-refs":
+			runCheckRefs(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "mask":
+			runMask(os.Args[2:])
+			return
+		case "drift":
+			runDrift(os.Args[2:])
+			return
+		case "dashboard":
+			runDashboard(os.Args[2:])
+			return
+		case "index":
+			runIndex(os.Args[2:])
+			return
+		case "rank-features":
+			runRankFeatures(os.Args[2:])
+			return
+		case "concat":
+			runConcat(os.Args[2:])
+			return
+		case "chi-square":
+			runChiSquare(os.Args[2:])
+			return
+		case "compare-groups":
+			runCompareGroups(os.Args[2:])
+			return
+		case "watch":
+			runWatch(os.Args[2:])
+			return
+		case "generate":
+			runGenerate(os.Args[2:])
+			return
+		}
+	}
+
+	// Parse command line options
+	// Registers the analyzer flags and parses them alongside the positional arguments.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	args, err := parseInterspersed(fs, os.Args[1:])
+	if err != nil {
+		// The flag package has already printed the problem; -h/--help is not a failure.
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	// Checks that at least one positional argument (the filename or "sample") was given.
+	if len(args) < 1 {
+		fs.Usage()
 		os.Exit(1)
 	}
+	// Rejects unknown report formats and conflicting options before doing any work.
+	if !validFormat(opts.Format) {
+		log.Fatalf("Unknown format %q (expected one of: %s)", opts.Format, strings.Join(outputFormats, ", "))
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+	// The text report writes its numbers and dates the way the --output-locale does; validate checked the name.
+	reportLocale, _ = lookupNumberLocale(opts.OutputLocale)
+	// Status messages go to stderr for machine-readable formats and checks so stdout stays parseable.
+	status := os.Stdout
+	if opts.Format != FormatText || opts.Check {
+		status = os.Stderr
+	}
 
-	// Retrieves the first command-line argument (which should be the filename or "sample").
-	filename := os.Args[1]
+	// Retrieves the first positional argument (which should be the filename or "sample").
+	filename := args[0]
 
 	// If user wants sample data, create it
 	// Checks if the provided argument is "sample".
@@ -564,29 +1347,323 @@ func main() {
 		// If "sample", sets the filename to a default "sample_data.csv".
 		filename = "sample_data.csv"
 		// Informs the user that sample data is being created.
-		fmt.Println("Creating sample data file:", filename)
+		fmt.Fprintln(status, "Creating sample data file:", filename)
 		// Calls the 'createSampleData' function to generate the CSV file.
 		if err := createSampleData(filename); err != nil {
 			// If an error occurs during sample data creation, logs the error and exits.
 			log.Fatal("Error creating sample data:", err)
 		}
 		// Confirms successful creation of sample data.
-		fmt.Println("Sample data created successfully!")
+		fmt.Fprintln(status, "Sample data created successfully!")
 		// Prints an empty line for formatting.
-		fmt.Println()
+		fmt.Fprintln(status)
 	}
 
+	// Reads the configuration file up front so mistakes in it are reported before any data is loaded.
+	var config Config
+	if opts.ConfigPath != "" {
+		if config, err = LoadConfig(opts.ConfigPath); err != nil {
+			log.Fatal("Error loading config:", err)
+		}
+	}
+	// The range-check shortcuts run alongside the configured alert rules.
+	config.Alerts = append(config.Alerts, opts.quickCheckRules()...)
+	var expectedSchema []SchemaColumn
+	if opts.ExpectSchema != "" {
+		if expectedSchema, err = LoadSchema(opts.ExpectSchema); err != nil {
+			log.Fatal("Error loading schema:", err)
+		}
+	}
+	var valueRules []ValueRule
+	if opts.RulesPath != "" {
+		if valueRules, err = LoadRules(opts.RulesPath); err != nil {
+			log.Fatal("Error loading rules:", err)
+		}
+	}
+	var reportTemplate *ReportTemplate
+	if opts.TemplatePath != "" {
+		if reportTemplate, err = LoadReportTemplate(opts.TemplatePath); err != nil {
+			log.Fatal("Error loading template:", err)
+		}
+	}
+
+	// Serves the report of an earlier run on the same content with the same options from the cache; --no-cache
+	// leaves the cache alone altogether.
+	var cache *reportCache
+	var cacheKey string
+	if !opts.NoCache && args[0] != "sample" && len(uncacheableOptions(opts)) == 0 {
+		var cacheErr error
+		if cache, cacheErr = openReportCache(); cacheErr == nil {
+			cacheKey, cacheErr = cache.key(filename, fs, opts, config)
+		}
+		if cacheErr != nil {
+			// The cache only ever saves time, so a problem with it is just a warning.
+			fmt.Fprintf(os.Stderr, "Warning: report cache disabled: %v\n", cacheErr)
+			cache = nil
+		} else if entry, ok := cache.load(cacheKey); ok {
+			fmt.Fprintln(status, describeCacheHit(entry))
+			os.Stderr.Write(entry.Errors)
+			os.Stdout.Write(entry.Output)
+			os.Exit(entry.ExitCode)
+		}
+	}
+
+	// Identifies this run in any lineage events that are emitted.
+	runID := newRunID()
+
 	// Create analyzer and process the file
-	// Creates a new instance of CSVAnalyzer using the constructor function.
-	analyzer := NewCSVAnalyzer()
+	// Creates a new instance of CSVAnalyzer configured with the parsed options.
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	analyzer.SetConfig(config)
+	analyzer.expectedSchema = expectedSchema
+	analyzer.valueRules = valueRules
+	analyzer.telemetry = startTelemetry(opts.StatsInternal)
+	// The report can be profiled from disk when the rows outgrow --max-memory.
+	analyzer.spillable = true
+	// Tells the lineage backend that the run started.
+	analyzer.emitLineage("START", runID, opts.Lineage)
+	// The report and the messages after it are captured for the cache from the moment the report is rendered.
+	var capture, errorCapture *outputCapture
+	// Ends the run with an error, reporting the failure to the lineage backend first.
+	fatal := func(v ...any) {
+		message := fmt.Sprint(v...)
+		// Restores stdout and stderr and flushes what was captured, which would otherwise be lost on exit.
+		if capture != nil {
+			capture.stop()
+			errorCapture.stop()
+		}
+		analyzer.emitLineageFailure(runID, opts.Lineage, message)
+		log.Fatal(message)
+	}
 	// Informs the user which CSV file is being loaded.
-	fmt.Printf("Loading CSV file: %s\n", filename)
+	fmt.Fprintf(status, "Loading CSV file: %s\n", filename)
 	// Calls the 'LoadCSV' method on the analyzer to load and parse the CSV file.
 	if err := analyzer.LoadCSV(filename); err != nil {
 		// If an error occurs during CSV loading, logs the error and exits.
-		log.Fatal("Error loading CSV:", err)
+		fatal("Error loading CSV:", err)
+	}
+
+	// Stops here under --strict if the data has warnings.
+	if opts.Strict {
+		if warnings := analyzer.DataWarnings(); len(warnings) > 0 {
+			analyzer.emitLineageFailure(runID, opts.Lineage, fmt.Sprintf("%d data warnings treated as errors (--strict)", len(warnings)))
+		}
+		analyzer.enforceStrict()
+	}
+	// The messages printed before the report are copied for the cache as they are written to stderr.
+	var messages io.Writer = os.Stderr
+	var earlyMessages bytes.Buffer
+	if cache != nil {
+		messages = io.MultiWriter(os.Stderr, &earlyMessages)
+	}
+
+	// Reports the data problems that were worked around.
+	if warnings := analyzer.DataWarnings(); len(warnings) > 0 {
+		printDataWarnings(messages, "Warning", warnings)
+	}
+
+	// Attaches column descriptions and units from the data dictionary, if one was given.
+	endPhase := analyzer.telemetry.phase("dictionary")
+	if opts.DictionaryPath != "" {
+		if err := analyzer.LoadDataDictionary(opts.DictionaryPath); err != nil {
+			fatal("Error loading data dictionary:", err)
+		}
+		// Entries that match no column are usually typos or renamed columns, so point them out.
+		if unmatched := analyzer.unmatchedDictionaryColumns(); len(unmatched) > 0 {
+			fmt.Fprintf(messages, "Warning: data dictionary entries match no column: %s\n", strings.Join(unmatched, ", "))
+		}
+	}
+
+	endPhase()
+
+	// Check mode prints only the outcome of the checks and sets the exit status.
+	if opts.Check {
+		result := analyzer.Check()
+		if err := writeCheckResult(os.Stdout, result, opts.Format); err != nil {
+			fatal(err)
+		}
+		if opts.AlertWebhook != "" {
+			if err := notifyAlerts(opts.AlertWebhook, filename, result.Checks); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		if !result.Passed {
+			analyzer.emitLineageFailure(runID, opts.Lineage, "checks failed")
+			os.Exit(exitCriticalAlert)
+		}
+		analyzer.emitLineage("COMPLETE", runID, opts.Lineage)
+		return
+	}
+
+	// Captures the report and the messages on stderr for the cache while they are printed.
+	if cache != nil {
+		var captureErr error
+		if capture, captureErr = captureOutput(&os.Stdout); captureErr == nil {
+			if errorCapture, captureErr = captureOutput(&os.Stderr); captureErr != nil {
+				capture.stop()
+				capture = nil
+			}
+		}
+		if captureErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: report cache disabled: %v\n", captureErr)
+		}
+	}
+
+	// Renders the analysis results in the requested format; machine-readable output on stdout is compressed with --compress.
+	endPhase = analyzer.telemetry.phase("render report")
+	var out io.WriteCloser = nopWriteCloser{os.Stdout}
+	if opts.Format != FormatText && opts.CardsDir == "" {
+		out = compressWriter(os.Stdout, opts.Compress)
+	}
+	switch {
+	case reportTemplate != nil:
+		err = analyzer.WriteTemplateReport(out, reportTemplate)
+	case opts.Format == FormatJSON:
+		err = analyzer.WriteJSONReport(out)
+	case opts.Format == FormatLong:
+		err = analyzer.WriteLongReport(out)
+	case opts.Format == FormatMsgpack:
+		err = analyzer.WriteMsgpackReport(out)
+	case opts.Format == FormatXLSX:
+		err = analyzer.WriteXLSXReport(out)
+	case opts.Format == FormatHTMLCards || opts.Format == FormatJSONCards:
+		// Cards are either printed one after another or written to individual files.
+		if opts.CardsDir != "" {
+			err = analyzer.WriteCardFiles(opts.CardsDir, opts.Format)
+		} else {
+			err = analyzer.WriteCards(out, opts.Format)
+		}
+	default:
+		// Calls the 'PrintReport' method on the analyzer to display the analysis results.
+		analyzer.PrintReport()
+	}
+	// Finishes the compressed stream before anything else is reported.
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
 	}
+	if err != nil {
+		fatal("Error writing report:", err)
+	}
+	endPhase()
+
+	// Export a Great Expectations suite if one was requested
+	endPhase = analyzer.telemetry.phase("exports")
+	if opts.GESuitePath != "" {
+		// Falls back to a name derived from the input file when none was given.
+		suiteName := opts.GESuiteName
+		if suiteName == "" {
+			suiteName = defaultSuiteName(filename)
+		}
+		if err := analyzer.WriteExpectationSuite(opts.GESuitePath, suiteName); err != nil {
+			fatal("Error exporting expectation suite:", err)
+		}
+		fmt.Fprintf(status, "\nGreat Expectations suite written to: %s\n", compressedPath(opts.GESuitePath, opts.Compress))
+	}
+
+	// Writes a report per value of the --split-by column if that was requested.
+	if opts.SplitBy != "" {
+		segments, err := analyzer.WriteSplitReports(opts.SplitDir, reportTemplate)
+		if err != nil {
+			fatal("Error writing split reports:", err)
+		}
+		fmt.Fprintf(status, "%d split reports by %s written to: %s\n", len(segments), opts.SplitBy, opts.SplitDir)
+	}
+
+	// Writes the SVG charts if they were requested.
+	if opts.ChartsDir != "" {
+		if err := analyzer.WriteCharts(opts.ChartsDir); err != nil {
+			fatal("Error writing charts:", err)
+		}
+		if err := analyzer.WriteMissingnessSVG(opts.ChartsDir); err != nil {
+			fatal("Error writing charts:", err)
+		}
+		fmt.Fprintf(status, "Charts written to: %s\n", opts.ChartsDir)
+	}
+
+	// Writes the correlation heatmap image if it was requested.
+	if opts.CorrelationPNG != "" {
+		if err := analyzer.WriteCorrelationPNG(opts.CorrelationPNG); err != nil {
+			fatal("Error writing correlation heatmap:", err)
+		}
+		fmt.Fprintf(status, "Correlation heatmap written to: %s\n", compressedPath(opts.CorrelationPNG, opts.Compress))
+	}
+
+	// Writes the dataset with its missing values filled if imputation was requested.
+	if opts.FillOutput != "" {
+		if err := analyzer.WriteImputed(opts.FillOutput, status); err != nil {
+			fatal("Error writing imputed data:", err)
+		}
+		fmt.Fprintf(status, "Imputed data written to: %s\n", compressedPath(opts.FillOutput, opts.Compress))
+	}
+
+	// Writes the dataset with its outliers removed or winsorized if that was requested.
+	if opts.OutlierOutput != "" {
+		if err := analyzer.WriteTreated(opts.OutlierOutput, status); err != nil {
+			fatal("Error writing treated data:", err)
+		}
+		fmt.Fprintf(status, "Treated data written to: %s\n", compressedPath(opts.OutlierOutput, opts.Compress))
+	}
+
+	// Packs the run's artifacts into a single archive if one was requested.
+	if opts.BundlePath != "" {
+		if err := analyzer.WriteBundle(opts.BundlePath); err != nil {
+			fatal("Error writing bundle:", err)
+		}
+		fmt.Fprintf(status, "Run bundle written to: %s\n", opts.BundlePath)
+	}
+
+	// Writes the summary badge if one was requested.
+	if opts.BadgePath != "" {
+		label := opts.BadgeLabel
+		if label == "" {
+			label = filepath.Base(filename)
+		}
+		if err := analyzer.WriteBadge(opts.BadgePath, label); err != nil {
+			fatal("Error writing badge:", err)
+		}
+		fmt.Fprintf(status, "Badge written to: %s\n", opts.BadgePath)
+	}
+
+	endPhase()
 
-	// Calls the 'PrintReport' method on the analyzer to display the analysis results.
-	analyzer.PrintReport()
+	// Reminds the user to clean up suppressions that no longer apply.
+	for _, suppression := range analyzer.expiredSuppressions(time.Now()) {
+		fmt.Fprintf(os.Stderr, "Warning: suppression for %s no longer applies\n", suppression.describe())
+	}
+
+	// Evaluates the alert rules once more for notification and the exit code.
+	endPhase = analyzer.telemetry.phase("alerts")
+	alerts := analyzer.EvaluateAlerts()
+	if opts.AlertWebhook != "" {
+		// Every unsuppressed failed alert is sent, whatever its severity; a failed delivery is only a warning.
+		if err := notifyAlerts(opts.AlertWebhook, filename, alerts); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	endPhase()
+
+	// The plan and the internal statistics go to stderr so they never mix with the report.
+	analyzer.explain.report(os.Stderr)
+	analyzer.telemetry.report(os.Stderr, analyzer.dataset.rowsRead(), phaseRead)
+	// Keeps the report and its exit status for the next run on the same content and options.
+	exitCode := 0
+	if hasCriticalFailure(alerts) {
+		exitCode = exitCriticalAlert
+	}
+	// Tells the lineage backend how the run ended; a failed critical alert fails it, as it does the exit status.
+	if exitCode != 0 {
+		analyzer.emitLineageFailure(runID, opts.Lineage, "critical alerts failed")
+	} else {
+		analyzer.emitLineage("COMPLETE", runID, opts.Lineage)
+	}
+	if capture != nil {
+		entry := CachedReport{Created: time.Now(), Input: filename, ExitCode: exitCode, Output: capture.stop(), Errors: append(earlyMessages.Bytes(), errorCapture.stop()...)}
+		if err := cache.store(cacheKey, entry); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	// Only critical failures affect the exit code; info and warn alerts are informational.
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
 }
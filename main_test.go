@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testOptions parses command-line flags into validated options, with the defaults the command line has
+func testOptions(t *testing.T, args ...string) Options {
+	t.Helper()
+	opts := Options{}
+	fs := newOptionsFlagSet(&opts)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+// writeTestCSV writes content to a CSV file in a temporary directory and returns its path
+func writeTestCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadTestCSV loads content with the given command-line flags the way the command line does
+func loadTestCSV(t *testing.T, content string, args ...string) *CSVAnalyzer {
+	t.Helper()
+	analyzer := NewCSVAnalyzerWithOptions(testOptions(t, args...))
+	if err := analyzer.LoadCSV(writeTestCSV(t, content)); err != nil {
+		t.Fatal(err)
+	}
+	return analyzer
+}
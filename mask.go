@@ -0,0 +1,297 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// Masking methods
+const (
+	MaskHash    = "hash"    // keyed SHA-256 digest: equal values stay equal, so joins and distinct counts survive
+	MaskRedact  = "redact"  // a fixed placeholder
+	MaskFake    = "fake"    // a consistent stand-in with the same shape (letters for letters, digits for digits)
+	MaskShuffle = "shuffle" // the column's own values in a random row order, keeping its distribution exactly
+)
+
+// maskKeyEnv holds the secret key of hash and fake masking; it is read from the environment so it stays out of
+// shell histories and process listings
+const maskKeyEnv = "CSV_ANALYZER_MASK_KEY"
+
+// maskHashLength is the number of hex digits kept from a hash digest
+const maskHashLength = 16
+
+// redactedValue replaces every non-empty cell of a redacted column
+const redactedValue = "REDACTED"
+
+// fakeEmailDomain is the domain of faked e-mail addresses, reserved for documentation so it never reaches anyone
+const fakeEmailDomain = "example.com"
+
+// MaskRule names a column and how its values are masked
+type MaskRule struct {
+	Column string
+	Method string
+}
+
+// MaskRules is a flag.Value collecting masking rules such as "Email=hash,Name=fake"
+type MaskRules []MaskRule
+
+// String renders the rules in --columns syntax
+func (m MaskRules) String() string {
+	parts := make([]string, len(m))
+	for i, rule := range m {
+		parts[i] = rule.Column + "=" + rule.Method
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a --columns value such as "Email=hash,Name=fake,SSN=redact"
+func (m *MaskRules) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		column, method, ok := strings.Cut(entry, "=")
+		column, method = strings.TrimSpace(column), strings.ToLower(strings.TrimSpace(method))
+		if !ok || column == "" {
+			return fmt.Errorf("invalid masking rule %q (expected column=method)", entry)
+		}
+		switch method {
+		case MaskHash, MaskRedact, MaskFake, MaskShuffle:
+		default:
+			return fmt.Errorf("unknown masking method %q for %s (expected hash, redact, fake or shuffle)", method, column)
+		}
+		*m = append(*m, MaskRule{Column: column, Method: method})
+	}
+	return nil
+}
+
+// MaskSummary reports what happened to one masked column
+type MaskSummary struct {
+	Column   string `json:"column"`
+	Method   string `json:"method"`
+	Masked   int    `json:"masked"`   // non-empty cells replaced
+	Distinct int    `json:"distinct"` // distinct non-empty values before masking
+}
+
+// masker turns values into their masked form with a secret key
+type masker struct {
+	key []byte
+}
+
+// digest returns the keyed SHA-256 of a value
+func (m masker) digest(value string) []byte {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// hash masks a value as a truncated hex digest
+func (m masker) hash(value string) string {
+	return hex.EncodeToString(m.digest(value))[:maskHashLength]
+}
+
+// The fake method is part of the masker struct. It replaces a value with a stand-in of the same shape: every letter
+// becomes a letter of the same case, every digit a digit, and punctuation, spaces and separators stay, so phone
+// numbers, IDs and dates keep their format and length. E-mail addresses keep an @ and get the example.com domain.
+// The replacement is derived from the keyed digest of the whole value, so equal values always get the same stand-in
+// and the column keeps its distinct count (barring rare collisions of short values).
+// fake returns a consistent, format-preserving stand-in for a value
+func (m masker) fake(value string) string {
+	if local, _, ok := strings.Cut(value, "@"); ok && local != "" {
+		return m.fakeShape(value, local) + "@" + fakeEmailDomain
+	}
+	return m.fakeShape(value, value)
+}
+
+// fakeShape replaces the letters and digits of shape with ones drawn from the digest of value
+func (m masker) fakeShape(value, shape string) string {
+	stream := m.digest(value)
+	next := func(i int) int {
+		// Extends the digest when a long value needs more pseudo-random bytes.
+		for i >= len(stream) {
+			stream = append(stream, m.digest(value+fmt.Sprint(len(stream)))...)
+		}
+		return int(stream[i])
+	}
+	var b strings.Builder
+	for i, r := range []rune(shape) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(rune('0' + next(i)%10))
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(rune('a' + next(i)%26))
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune(rune('A' + next(i)%26))
+		case r > 127:
+			// Letters outside ASCII become ASCII letters rather than leak through.
+			b.WriteRune(rune('a' + next(i)%26))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// The Mask method is part of the CSVAnalyzer struct. It applies masking rules to the loaded rows in place, so the
+// data can be shared for analysis without its sensitive values. Empty cells stay empty under every method, which keeps
+// the completeness figures of the masked file identical to the original. hash and fake are deterministic for a key,
+// so the masked columns still join across files masked with the same key; shuffle moves a column's values to random
+// rows (reproducible with seed), which keeps every statistic of the column and only breaks its link to the other
+// columns of the row.
+// Mask masks the configured columns and reports what was changed
+func (ca *CSVAnalyzer) Mask(rules MaskRules, key []byte, seed int64) ([]MaskSummary, error) {
+	for _, rule := range rules {
+		if ca.columnIndex(rule.Column) < 0 {
+			return nil, fmt.Errorf("masking column %q not found", rule.Column)
+		}
+	}
+	// The parsed numbers no longer match the masked cells.
+	defer ca.resetNumericColumns()
+	m := masker{key: key}
+	rng := mathrand.New(mathrand.NewSource(seed))
+	var summaries []MaskSummary
+	for _, rule := range rules {
+		colIndex := ca.columnIndex(rule.Column)
+		summary := MaskSummary{Column: rule.Column, Method: rule.Method}
+		distinct := make(map[string]bool)
+		var filled []int
+		for rowIndex, row := range ca.dataset.Rows {
+			if colIndex < len(row) && strings.TrimSpace(row[colIndex]) != "" {
+				filled = append(filled, rowIndex)
+				distinct[row[colIndex]] = true
+			}
+		}
+		summary.Distinct = len(distinct)
+		summary.Masked = len(filled)
+
+		// Shuffling permutes the non-empty values among the rows that had one.
+		if rule.Method == MaskShuffle {
+			values := make([]string, len(filled))
+			for i, rowIndex := range filled {
+				values[i] = ca.dataset.Rows[rowIndex][colIndex]
+			}
+			rng.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+			for i, rowIndex := range filled {
+				ca.dataset.Rows[rowIndex][colIndex] = values[i]
+			}
+			summaries = append(summaries, summary)
+			continue
+		}
+		for _, rowIndex := range filled {
+			value := ca.dataset.Rows[rowIndex][colIndex]
+			switch rule.Method {
+			case MaskHash:
+				value = m.hash(value)
+			case MaskRedact:
+				value = redactedValue
+			case MaskFake:
+				value = m.fake(value)
+			}
+			ca.dataset.Rows[rowIndex][colIndex] = value
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// maskKey returns the key from CSV_ANALYZER_MASK_KEY, or a random one that masks this run only
+func maskKey() ([]byte, bool, error) {
+	if key := os.Getenv(maskKeyEnv); key != "" {
+		return []byte(key), true, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, false, fmt.Errorf("error generating masking key: %v", err)
+	}
+	return key, false, nil
+}
+
+// runMask implements `mask --columns Email=hash,Name=fake <file>`: it writes the input as CSV with the named columns
+// masked and prints a summary on stderr
+func runMask(args []string) {
+	// Reuses the analyzer flags so --types, --drop and the other loading options apply too.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	var rules MaskRules
+	fs.Var(&rules, "columns", "mask columns as `column=method` pairs: hash, redact, fake or shuffle (e.g. \"Email=hash,Name=fake\")")
+	output := fs.String("output", "", "write the masked data to `file` instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . mask --columns <column=method,...> [--output file] [--sample-seed seed] [options] <csv-file>")
+		fmt.Fprintf(os.Stderr, "\nhash and fake use the secret in %s, so the same value masks the same way in every file;\n", maskKeyEnv)
+		fmt.Fprintln(os.Stderr, "without it a random key is used and the output cannot be joined with other masked files.")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 1 || len(rules) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+	// The sampling seed flag doubles as the seed of the shuffles.
+	seed := opts.Sampling.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	key, stable, err := maskKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	if err := analyzer.LoadCSV(positional[0]); err != nil {
+		log.Fatal("Error loading CSV:", err)
+	}
+	analyzer.enforceStrict()
+	summaries, err := analyzer.Mask(rules, key, seed)
+	if err != nil {
+		log.Fatal("Error masking:", err)
+	}
+
+	// Writes the header and the masked rows, compressed when --compress is set.
+	w := compressWriter(os.Stdout, opts.Compress)
+	if *output != "" {
+		if w, err = createOutputFile(*output, opts.Compress); err != nil {
+			log.Fatal("Error creating output file:", err)
+		}
+	}
+	err = writeCSVRecords(w, append([][]string{analyzer.dataset.Headers}, analyzer.dataset.Rows...))
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal("Error writing masked data:", err)
+	}
+	printMaskSummaries(os.Stderr, summaries, stable, seed)
+}
+
+// printMaskSummaries shows which columns were masked and how
+func printMaskSummaries(w io.Writer, summaries []MaskSummary, stableKey bool, seed int64) {
+	fmt.Fprintln(w, "Masked columns:")
+	fmt.Fprintf(w, "  %-24s %-8s %8s %9s\n", "Column", "Method", "Masked", "Distinct")
+	for _, summary := range summaries {
+		fmt.Fprintf(w, "  %-24s %-8s %8d %9d\n", summary.Column, summary.Method, summary.Masked, summary.Distinct)
+	}
+	if !stableKey {
+		fmt.Fprintf(w, "Note: %s is not set, so hashes and fakes are only consistent within this file\n", maskKeyEnv)
+	}
+	fmt.Fprintf(w, "Shuffle seed: %d\n", seed)
+}
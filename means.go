@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// defaultTrimFraction is the share of values cut from each end for the trimmed mean
+const defaultTrimFraction = 0.05
+
+// geometricMean returns the nth root of the product of the values, computed through logarithms so it cannot
+// overflow. It is the right average for growth rates and ratios, and is only defined when every value is positive.
+func geometricMean(values []float64) (float64, bool) {
+	logSum := 0.0
+	for _, v := range values {
+		if v <= 0 {
+			return 0, false
+		}
+		logSum += math.Log(v)
+	}
+	if len(values) == 0 {
+		return 0, false
+	}
+	return math.Exp(logSum / float64(len(values))), true
+}
+
+// harmonicMean returns the number of values divided by the sum of their reciprocals, the right average for rates
+// such as speeds or prices per unit. It is only defined when every value is positive.
+func harmonicMean(values []float64) (float64, bool) {
+	reciprocalSum := 0.0
+	for _, v := range values {
+		if v <= 0 {
+			return 0, false
+		}
+		reciprocalSum += 1 / v
+	}
+	if len(values) == 0 {
+		return 0, false
+	}
+	return float64(len(values)) / reciprocalSum, true
+}
+
+// trimCount returns how many of count values the trimmed mean drops from each end
+func trimCount(count int, trim float64) int {
+	return int(float64(count) * trim)
+}
+
+// trimmedMean returns the mean of the values left after dropping the fraction trim of them (rounded down) from each
+// end of the sorted list, which keeps a few extreme values from dragging the average
+func trimmedMean(values []float64, trim float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	cut := trimCount(len(sorted), trim)
+	kept := sorted[cut : len(sorted)-cut]
+	return sum(kept) / float64(len(kept))
+}
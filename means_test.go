@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTrimmedMeanLabelCountsTrimmedValues(t *testing.T) {
+	for rows, want := range map[int]string{
+		10: "(no values trimmed)",
+		19: "(no values trimmed)",
+		20: "(1 value trimmed from each end)",
+		45: "(2 values trimmed from each end)",
+	} {
+		var b strings.Builder
+		b.WriteString("n\n")
+		for i := 1; i <= rows; i++ {
+			fmt.Fprintf(&b, "%d\n", i*i)
+		}
+		analyzer := loadTestCSV(t, b.String())
+		text, err := renderTextReport(func() { analyzer.printColumnStats(analyzer.CalculateStats(), nil, nil, nil) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(text), "Trim Mean: ") || !strings.Contains(string(text), want) {
+			t.Errorf("%d values: want %s in\n%s", rows, want, text)
+		}
+	}
+}
+
+func TestTrimmedMeanOfFewValuesIsTheMean(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 100, 6, 7, 8, 9, 10}
+	if got, want := trimmedMean(values, 0.05), sum(values)/10; got != want {
+		t.Errorf("trimmedMean of 10 values = %g, want the mean %g", got, want)
+	}
+	// One zero and the 100 are dropped.
+	if got := trimmedMean(append(values, make([]float64, 10)...), 0.05); got != 50.0/18 {
+		t.Errorf("trimmedMean of 20 values = %g, want %g", got, 50.0/18)
+	}
+}
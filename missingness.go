@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// missingMatrixBuckets is the number of row ranges the missingness matrix splits the file into
+const missingMatrixBuckets = 20
+
+// missingClusterShare is the missing share a row range must reach, and exceed the column's overall rate twice over,
+// to be called out as a cluster
+const missingClusterShare = 0.5
+
+// coMissingThreshold is the missingness correlation at or above which two columns count as missing together
+const coMissingThreshold = 0.5
+
+// Layout of the SVG missingness matrix
+const (
+	svgMatrixCell  = 14  // width and height of one cell
+	svgMatrixLabel = 100 // room for the row-range labels on the left
+)
+
+// missingShades are the terminal cells of the matrix: none missing, then a quarter, half, three quarters and more
+var missingShades = []string{"·", "░", "▒", "▓", "█"}
+
+// RowRange is a contiguous range of data rows, numbered from 1
+type RowRange struct {
+	First int `json:"first"`
+	Last  int `json:"last"`
+}
+
+// String renders the range as "first-last"
+func (r RowRange) String() string {
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// MissingCluster is a row range where a column's missing values concentrate
+type MissingCluster struct {
+	Column      string   `json:"column"`
+	Rows        RowRange `json:"rows"`
+	Share       float64  `json:"share"`        // missing share within the range
+	OverallRate float64  `json:"overall_rate"` // missing share of the whole column
+}
+
+// MissingnessMatrix records the share of missing cells of every incomplete column in each row range
+type MissingnessMatrix struct {
+	Buckets  []RowRange       `json:"buckets"`
+	Columns  []string         `json:"columns"`  // the columns with at least one missing cell
+	Complete int              `json:"complete"` // columns without missing cells, left out of the matrix
+	Shares   [][]float64      `json:"shares"`   // Shares[bucket][column]
+	Clusters []MissingCluster `json:"clusters,omitempty"`
+}
+
+// CoMissingPair is two columns whose missing values tend to fall in the same rows
+type CoMissingPair struct {
+	Columns     [2]string `json:"columns"`
+	Correlation float64   `json:"correlation"` // phi coefficient of the two missing-value indicators
+	Together    int       `json:"together"`    // rows missing both
+	Missing     [2]int    `json:"missing"`     // rows missing each column
+}
+
+// The MissingnessMatrix method is part of the CSVAnalyzer struct. It cuts the rows, in file order, into up to
+// missingMatrixBuckets ranges of equal size and works out which share of each range is missing (empty, blank or beyond
+// the end of a short row) in every column with missing values. Counts alone tell how much is missing; the matrix shows
+// where, so a feed that stopped filling a column partway through, or a block of rows from a broken source, stands
+// out. Ranges holding at least half of their cells missing and twice the column's overall rate are listed as clusters.
+// The matrix is withheld under --dp-epsilon, since a range can be only a few rows.
+// MissingnessMatrix returns the matrix, or nil when it was not requested or nothing is missing
+func (ca *CSVAnalyzer) MissingnessMatrix() *MissingnessMatrix {
+	if !ca.options.MissingMatrix || ca.privacy != nil || len(ca.dataset.Rows) == 0 {
+		return nil
+	}
+	rows := ca.dataset.Rows
+	buckets := missingMatrixBuckets
+	if len(rows) < buckets {
+		buckets = len(rows)
+	}
+	matrix := &MissingnessMatrix{}
+	for b := 0; b < buckets; b++ {
+		matrix.Buckets = append(matrix.Buckets, RowRange{First: b*len(rows)/buckets + 1, Last: (b + 1) * len(rows) / buckets})
+	}
+
+	for colIndex, header := range ca.dataset.Headers {
+		// Counts the missing cells of every range.
+		counts := make([]int, buckets)
+		total := 0
+		for b, bucket := range matrix.Buckets {
+			for _, row := range rows[bucket.First-1 : bucket.Last] {
+				if colIndex >= len(row) || strings.TrimSpace(row[colIndex]) == "" {
+					counts[b]++
+				}
+			}
+			total += counts[b]
+		}
+		if total == 0 {
+			matrix.Complete++
+			continue
+		}
+		matrix.Columns = append(matrix.Columns, header)
+		overall := float64(total) / float64(len(rows))
+		for b, bucket := range matrix.Buckets {
+			share := float64(counts[b]) / float64(bucket.Last-bucket.First+1)
+			if len(matrix.Shares) <= b {
+				matrix.Shares = append(matrix.Shares, nil)
+			}
+			matrix.Shares[b] = append(matrix.Shares[b], share)
+			if share >= missingClusterShare && share >= 2*overall {
+				matrix.Clusters = append(matrix.Clusters, MissingCluster{Column: header, Rows: bucket, Share: share, OverallRate: overall})
+			}
+		}
+	}
+	if len(matrix.Columns) == 0 {
+		return nil
+	}
+	return matrix
+}
+
+// missingShade returns the terminal cell of a missing share
+func missingShade(share float64) string {
+	if share == 0 {
+		return missingShades[0]
+	}
+	// Any missing value gets at least the lightest shade.
+	level := 1 + int(share*4)
+	if level >= len(missingShades) {
+		level = len(missingShades) - 1
+	}
+	return missingShades[level]
+}
+
+// printMissingnessMatrix shows the missingness matrix in the text report: one line per row range, one cell per column
+func printMissingnessMatrix(matrix *MissingnessMatrix) {
+	if matrix == nil {
+		return
+	}
+	fmt.Println("\n\nMissingness Matrix (rows down, columns across):")
+	fmt.Println("-----------------------------------------------")
+	// Cells are as wide as the largest column number heading them.
+	width := len(matrix.Buckets[len(matrix.Buckets)-1].String())
+	cellWidth := len(fmt.Sprint(len(matrix.Columns)))
+	fmt.Printf("  %*s", width, "rows")
+	for i := range matrix.Columns {
+		fmt.Printf(" %*d", cellWidth, i+1)
+	}
+	fmt.Println()
+	for b, bucket := range matrix.Buckets {
+		fmt.Printf("  %*s", width, bucket)
+		for _, share := range matrix.Shares[b] {
+			fmt.Print(" " + strings.Repeat(missingShade(share), cellWidth))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("\n  %s none  %s under 25%%  %s under 50%%  %s under 75%%  %s 75%% or more missing\n", missingShades[0], missingShades[1], missingShades[2], missingShades[3], missingShades[4])
+	for i, header := range matrix.Columns {
+		fmt.Printf("  %*d: %s\n", cellWidth, i+1, header)
+	}
+	if matrix.Complete > 0 {
+		fmt.Printf("  (%d complete columns not shown)\n", matrix.Complete)
+	}
+	for _, cluster := range matrix.Clusters {
+		fmt.Printf("  %s: %.0f%% missing in rows %s (%.0f%% overall)\n", cluster.Column, 100*cluster.Share, cluster.Rows, 100*cluster.OverallRate)
+	}
+}
+
+// missingnessSVG draws the matrix as a heatmap, darker where more is missing, with the share of each cell on hover
+func missingnessSVG(matrix *MissingnessMatrix) string {
+	top := svgChartMargin + 60
+	width := svgMatrixLabel + len(matrix.Columns)*svgMatrixCell + svgChartMargin
+	height := top + len(matrix.Buckets)*svgMatrixCell + svgChartMargin
+	var body strings.Builder
+	// Column names run diagonally above their cells.
+	for i, header := range matrix.Columns {
+		x := svgMatrixLabel + i*svgMatrixCell + svgMatrixCell/2
+		fmt.Fprintf(&body, "  <text x=\"%d\" y=\"%d\" transform=\"rotate(-45 %d %d)\">%s</text>\n", x, top-4, x, top-4, html.EscapeString(header))
+	}
+	for b, bucket := range matrix.Buckets {
+		y := top + b*svgMatrixCell
+		fmt.Fprintf(&body, "  <text x=\"%d\" y=\"%d\" text-anchor=\"end\">%s</text>\n", svgMatrixLabel-4, y+svgMatrixCell-3, bucket)
+		for i, share := range matrix.Shares[b] {
+			fmt.Fprintf(&body, "  <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#e05d44\" fill-opacity=\"%.2f\" stroke=\"#eee\"><title>%s, rows %s: %.1f%% missing</title></rect>\n",
+				svgMatrixLabel+i*svgMatrixCell, y, svgMatrixCell, svgMatrixCell, share, html.EscapeString(matrix.Columns[i]), bucket, 100*share)
+		}
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">
+  <title>Missingness matrix</title>
+  <rect width="100%%" height="100%%" fill="#fff"/>
+  <text x="%d" y="20" font-size="13" font-weight="bold">Missingness matrix</text>
+%s</svg>
+`, width, height, svgChartMargin, body.String())
+}
+
+// WriteMissingnessSVG writes the missingness matrix as missingness.svg into dir, when there is one
+func (ca *CSVAnalyzer) WriteMissingnessSVG(dir string) error {
+	matrix := ca.MissingnessMatrix()
+	if matrix == nil {
+		return nil
+	}
+	if err := writeOutputFile(filepath.Join(dir, "missingness.svg"), ca.options.Compress, []byte(missingnessSVG(matrix))); err != nil {
+		return fmt.Errorf("error writing missingness matrix: %v", err)
+	}
+	return nil
+}
+
+// The CoMissing method is part of the CSVAnalyzer struct. It finds the columns that tend to be missing together: for
+// every pair of incomplete columns it correlates their missing-value indicators (the phi coefficient of the 2×2 table
+// of missing/present), and pairs at or above coMissingThreshold are reported, strongest first. Columns that empty out
+// in the same rows usually come from the same upstream system or record segment, which points straight at where the
+// data was dropped. Columns missing in every row, or in none, have no variation and are left out. The pairs are
+// withheld under --dp-epsilon.
+// CoMissing returns the strongly co-missing column pairs, or nil when the analysis was not requested
+func (ca *CSVAnalyzer) CoMissing() []CoMissingPair {
+	if !ca.options.CoMissing || ca.privacy != nil {
+		return nil
+	}
+	rows := ca.dataset.Rows
+	// Collects, per row, the incomplete columns it is missing, and the missing count of every column.
+	missing := make([]int, len(ca.dataset.Headers))
+	rowMissing := make([][]int, len(rows))
+	for rowIndex, row := range rows {
+		for colIndex := range ca.dataset.Headers {
+			if colIndex >= len(row) || strings.TrimSpace(row[colIndex]) == "" {
+				missing[colIndex]++
+				rowMissing[rowIndex] = append(rowMissing[rowIndex], colIndex)
+			}
+		}
+	}
+	// Counts the rows missing both columns of every pair.
+	both := make(map[[2]int]int)
+	for _, columns := range rowMissing {
+		for i, a := range columns {
+			for _, b := range columns[i+1:] {
+				both[[2]int{a, b}]++
+			}
+		}
+	}
+
+	var pairs []CoMissingPair
+	n := float64(len(rows))
+	for pair, together := range both {
+		a, b := float64(missing[pair[0]]), float64(missing[pair[1]])
+		if a == n || b == n {
+			continue
+		}
+		// phi = (n11·n00 − n10·n01) / sqrt(n1·n0·m1·m0), which simplifies to this with the marginal counts.
+		phi := (n*float64(together) - a*b) / math.Sqrt(a*(n-a)*b*(n-b))
+		if phi < coMissingThreshold {
+			continue
+		}
+		pairs = append(pairs, CoMissingPair{
+			Columns:     [2]string{ca.dataset.Headers[pair[0]], ca.dataset.Headers[pair[1]]},
+			Correlation: phi,
+			Together:    together,
+			Missing:     [2]int{missing[pair[0]], missing[pair[1]]},
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Correlation != pairs[j].Correlation {
+			return pairs[i].Correlation > pairs[j].Correlation
+		}
+		return pairs[i].Columns[0]+"\x00"+pairs[i].Columns[1] < pairs[j].Columns[0]+"\x00"+pairs[j].Columns[1]
+	})
+	return pairs
+}
+
+// printCoMissing shows the column pairs that tend to be missing together in the text report
+func printCoMissing(pairs []CoMissingPair, requested bool) {
+	if !requested {
+		return
+	}
+	fmt.Println("\n\nColumns Missing Together:")
+	fmt.Println("-------------------------")
+	if len(pairs) == 0 {
+		fmt.Printf("  No pair of columns has a missingness correlation of %.1f or more\n", coMissingThreshold)
+		return
+	}
+	for _, pair := range pairs {
+		fmt.Printf("  %s + %s: correlation %.2f, both missing in %d rows (%d and %d missing)\n",
+			pair.Columns[0], pair.Columns[1], pair.Correlation, pair.Together, pair.Missing[0], pair.Missing[1])
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxMixedExamples is how many inconsistent cells a type mixture lists
+const maxMixedExamples = 10
+
+// MixedCell is a cell of a mixed-type column whose value is not of the column's dominant type
+type MixedCell struct {
+	Row   int    `json:"row"` // 1-based data row number (header excluded)
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// TypeMixture is the breakdown of a text column whose values are of more than one type
+type TypeMixture struct {
+	Counts        map[string]int `json:"counts"` // non-empty values per type (Numeric, Date or Text)
+	Dominant      string         `json:"dominant_type"`
+	DominantShare float64        `json:"dominant_share"`
+	Inconsistent  int            `json:"inconsistent_cells"` // values not of the dominant type
+	Examples      []MixedCell    `json:"examples,omitempty"` // the first inconsistent cells, at most maxMixedExamples
+}
+
+// valueType classifies a single non-empty cell value; numbers are tried before dates so "20240101" stays a number
+func (ca *CSVAnalyzer) valueType(value string) ColumnType {
+	if _, err := ca.parseNumber(value); err == nil {
+		return TypeNumeric
+	}
+	if _, ok := ca.parseDate(value); ok {
+		return TypeDate
+	}
+	return TypeText
+}
+
+// The TypeMixture method is part of the CSVAnalyzer struct. A column only becomes Numeric or Date when all of its
+// detection rows parse, so a few stray strings ("n/a", "unknown", a unit typed into the cell) make a mostly-numeric
+// column Text and its statistics disappear. This method classifies every non-empty value of a text column on its own
+// and, when more than one type occurs, returns the share of each, the dominant type and the cells that disagree with
+// it, so the report can say what the column really is and where the offending values are. Ties go to the type
+// that sorts first (Date, Numeric, Text), which keeps the result stable.
+// TypeMixture returns the type breakdown of a text column, or nil when the column is not text or holds a single type
+func (ca *CSVAnalyzer) TypeMixture(colIndex int) *TypeMixture {
+	if ca.columnType(colIndex) != TypeText {
+		return nil
+	}
+	counts := make(map[string]int)
+	types := make([]ColumnType, len(ca.dataset.Rows))
+	total := 0
+	for rowIndex, row := range ca.dataset.Rows {
+		if colIndex >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[colIndex])
+		if value == "" {
+			continue
+		}
+		types[rowIndex] = ca.valueType(value)
+		counts[string(types[rowIndex])]++
+		total++
+	}
+	if len(counts) < 2 {
+		return nil
+	}
+
+	// Picks the most common type.
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mixture := &TypeMixture{Counts: counts}
+	for _, name := range names {
+		if counts[name] > counts[mixture.Dominant] {
+			mixture.Dominant = name
+		}
+	}
+	mixture.DominantShare = float64(counts[mixture.Dominant]) / float64(total)
+	mixture.Inconsistent = total - counts[mixture.Dominant]
+
+	// Lists the first cells that are not of the dominant type.
+	for rowIndex, colType := range types {
+		if colType == "" || string(colType) == mixture.Dominant {
+			continue
+		}
+		if len(mixture.Examples) == maxMixedExamples {
+			break
+		}
+		mixture.Examples = append(mixture.Examples, MixedCell{Row: rowIndex + 1, Value: strings.TrimSpace(ca.dataset.Rows[rowIndex][colIndex]), Type: string(colType)})
+	}
+	return mixture
+}
+
+// describe renders the breakdown as shares of each type, the dominant type first
+func (m *TypeMixture) describe() string {
+	total := 0
+	names := make([]string, 0, len(m.Counts))
+	for name, count := range m.Counts {
+		names = append(names, name)
+		total += count
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if m.Counts[names[i]] != m.Counts[names[j]] {
+			return m.Counts[names[i]] > m.Counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%.1f%% %s", 100*float64(m.Counts[name])/float64(total), name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeExamples renders the listed inconsistent cells on one line
+func (m *TypeMixture) describeExamples() string {
+	parts := make([]string, len(m.Examples))
+	for i, cell := range m.Examples {
+		parts[i] = fmt.Sprintf("row %d %q", cell.Row, cell.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// printTypeMixtures shows the breakdown and inconsistent cells of every mixed-type column in the text report
+func (ca *CSVAnalyzer) printTypeMixtures() {
+	printed := false
+	for colIndex, header := range ca.dataset.Headers {
+		mixture := ca.TypeMixture(colIndex)
+		if mixture == nil {
+			continue
+		}
+		if !printed {
+			fmt.Println("\n\nMixed-Type Columns:")
+			fmt.Println("-------------------")
+			printed = true
+		}
+		fmt.Printf("\n%s:\n", header)
+		fmt.Printf("  Breakdown:     %s\n", mixture.describe())
+		fmt.Printf("  Dominant type: %s (%.1f%%)\n", mixture.Dominant, 100*mixture.DominantShare)
+		fmt.Printf("  Inconsistent:  %d cells\n", mixture.Inconsistent)
+		// Values are withheld under differential privacy like every other value list.
+		if ca.privacy == nil {
+			for _, cell := range mixture.Examples {
+				fmt.Printf("    row %d: %q (%s)\n", cell.Row, cell.Value, cell.Type)
+			}
+		}
+	}
+}
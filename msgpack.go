@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// The WriteMsgpackReport method is part of the CSVAnalyzer struct. It renders the report as MessagePack, a binary
+// encoding of the same structure as --format json that is usually well under half its size and much cheaper to
+// parse, which matters for automated runs that archive thousands of reports a day. The report goes through its
+// JSON form first so field names, omitted fields and nesting are identical in both formats; object keys are written
+// in sorted order so identical reports produce identical bytes.
+// WriteMsgpackReport writes the full report as MessagePack
+func (ca *CSVAnalyzer) WriteMsgpackReport(w io.Writer) error {
+	// Converts the report into generic values, keeping integers apart from floats.
+	data, err := json.Marshal(ca.BuildReport())
+	if err != nil {
+		return fmt.Errorf("error encoding report: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("error encoding report: %v", err)
+	}
+
+	// Encodes the values and flushes them in one go.
+	buffered := bufio.NewWriter(w)
+	encodeMsgpack(buffered, value)
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("error writing MessagePack report: %v", err)
+	}
+	return nil
+}
+
+// encodeMsgpack appends the MessagePack encoding of a decoded JSON value to w, using the smallest representation
+// the specification allows for each value
+func encodeMsgpack(w *bufio.Writer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		w.WriteByte(0xc0)
+	case bool:
+		if v {
+			w.WriteByte(0xc3)
+		} else {
+			w.WriteByte(0xc2)
+		}
+	case json.Number:
+		// Whole numbers become integers; everything else is a 64-bit float.
+		if n, err := v.Int64(); err == nil {
+			encodeMsgpackInt(w, n)
+		} else if f, err := v.Float64(); err == nil {
+			w.WriteByte(0xcb)
+			binary.Write(w, binary.BigEndian, math.Float64bits(f))
+		} else {
+			encodeMsgpackString(w, v.String())
+		}
+	case string:
+		encodeMsgpackString(w, v)
+	case []interface{}:
+		encodeMsgpackLength(w, len(v), 0x90, 0xdc)
+		for _, item := range v {
+			encodeMsgpack(w, item)
+		}
+	case map[string]interface{}:
+		encodeMsgpackLength(w, len(v), 0x80, 0xde)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encodeMsgpackString(w, key)
+			encodeMsgpack(w, v[key])
+		}
+	}
+}
+
+// encodeMsgpackInt writes a signed integer in the narrowest integer format that holds it
+func encodeMsgpackInt(w *bufio.Writer, n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		w.WriteByte(byte(n)) // positive fixint
+	case n < 0 && n >= -32:
+		w.WriteByte(byte(int8(n))) // negative fixint
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		w.WriteByte(0xd0)
+		w.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		w.WriteByte(0xd1)
+		binary.Write(w, binary.BigEndian, int16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		w.WriteByte(0xd2)
+		binary.Write(w, binary.BigEndian, int32(n))
+	default:
+		w.WriteByte(0xd3)
+		binary.Write(w, binary.BigEndian, n)
+	}
+}
+
+// encodeMsgpackString writes a UTF-8 string with its length prefix
+func encodeMsgpackString(w *bufio.Writer, s string) {
+	switch n := len(s); {
+	case n <= 31:
+		w.WriteByte(0xa0 | byte(n)) // fixstr
+	case n <= math.MaxUint8:
+		w.WriteByte(0xd9)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(0xda)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(0xdb)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+	w.WriteString(s)
+}
+
+// encodeMsgpackLength writes the header of an array or map: fix is the fixarray/fixmap prefix and wide the 16-bit
+// prefix, which is followed by the 32-bit one
+func encodeMsgpackLength(w *bufio.Writer, n int, fix, wide byte) {
+	switch {
+	case n <= 15:
+		w.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(wide)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(wide + 1)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
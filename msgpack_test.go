@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// msgpackDecoder reads the MessagePack formats the encoder writes back into generic values: integers as int64,
+// floats as float64, maps as map[string]interface{} and arrays as []interface{}
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next n bytes
+func (d *msgpackDecoder) next(n int) []byte {
+	if d.pos+n > len(d.data) {
+		panic(fmt.Sprintf("truncated at byte %d", d.pos))
+	}
+	d.pos += n
+	return d.data[d.pos-n : d.pos]
+}
+
+// decode reads one value
+func (d *msgpackDecoder) decode() interface{} {
+	b := d.next(1)[0]
+	switch {
+	case b <= 0x7f:
+		return int64(b)
+	case b >= 0xe0:
+		return int64(int8(b))
+	case b&0xf0 == 0x80:
+		return d.decodeMap(int(b & 0x0f))
+	case b&0xf0 == 0x90:
+		return d.decodeArray(int(b & 0x0f))
+	case b&0xe0 == 0xa0:
+		return string(d.next(int(b & 0x1f)))
+	}
+	switch b {
+	case 0xc0:
+		return nil
+	case 0xc2:
+		return false
+	case 0xc3:
+		return true
+	case 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(d.next(8)))
+	case 0xd0:
+		return int64(int8(d.next(1)[0]))
+	case 0xd1:
+		return int64(int16(binary.BigEndian.Uint16(d.next(2))))
+	case 0xd2:
+		return int64(int32(binary.BigEndian.Uint32(d.next(4))))
+	case 0xd3:
+		return int64(binary.BigEndian.Uint64(d.next(8)))
+	case 0xd9:
+		return string(d.next(int(d.next(1)[0])))
+	case 0xda:
+		return string(d.next(int(binary.BigEndian.Uint16(d.next(2)))))
+	case 0xdb:
+		return string(d.next(int(binary.BigEndian.Uint32(d.next(4)))))
+	case 0xdc:
+		return d.decodeArray(int(binary.BigEndian.Uint16(d.next(2))))
+	case 0xdd:
+		return d.decodeArray(int(binary.BigEndian.Uint32(d.next(4))))
+	case 0xde:
+		return d.decodeMap(int(binary.BigEndian.Uint16(d.next(2))))
+	case 0xdf:
+		return d.decodeMap(int(binary.BigEndian.Uint32(d.next(4))))
+	}
+	panic(fmt.Sprintf("unexpected format byte 0x%02x at byte %d", b, d.pos-1))
+}
+
+// decodeArray reads n array items
+func (d *msgpackDecoder) decodeArray(n int) []interface{} {
+	items := make([]interface{}, n)
+	for i := range items {
+		items[i] = d.decode()
+	}
+	return items
+}
+
+// decodeMap reads n key-value pairs, checking the keys are strings in sorted order
+func (d *msgpackDecoder) decodeMap(n int) map[string]interface{} {
+	entries := make(map[string]interface{}, n)
+	previous := ""
+	for i := 0; i < n; i++ {
+		key, ok := d.decode().(string)
+		if !ok {
+			panic(fmt.Sprintf("map key is not a string before byte %d", d.pos))
+		}
+		if i > 0 && key <= previous {
+			panic(fmt.Sprintf("map key %q follows %q", key, previous))
+		}
+		entries[key], previous = d.decode(), key
+	}
+	return entries
+}
+
+// decodeTestMsgpack decodes a single MessagePack value, failing the test on malformed data or trailing bytes
+func decodeTestMsgpack(t *testing.T, data []byte) (value interface{}) {
+	t.Helper()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			t.Fatalf("malformed MessagePack: %v", recovered)
+		}
+	}()
+	d := &msgpackDecoder{data: data}
+	value = d.decode()
+	if d.pos != len(data) {
+		t.Fatalf("%d bytes left after the value", len(data)-d.pos)
+	}
+	return value
+}
+
+// msgpackValue converts a JSON value decoded with UseNumber to what the MessagePack decoder returns for it
+func msgpackValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = msgpackValue(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = msgpackValue(v[key])
+		}
+	}
+	return value
+}
+
+// encodeTestMsgpack returns the encoding of a value as hex
+func encodeTestMsgpack(value interface{}) string {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	encodeMsgpack(w, value)
+	w.Flush()
+	return hex.EncodeToString(b.Bytes())
+}
+
+func TestMsgpackReportMatchesJSON(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("id,price,name,active,day\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&b, "%d,%d.5,name %d,%t,2024-01-%02d\n", i*1000, -i, i%7, i%2 == 0, i%28+1)
+	}
+	b.WriteString(",,,,\n")
+	analyzer := loadTestCSV(t, b.String())
+
+	var jsonReport, msgpackReport bytes.Buffer
+	if err := analyzer.WriteJSONReport(&jsonReport); err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzer.WriteMsgpackReport(&msgpackReport); err != nil {
+		t.Fatal(err)
+	}
+	decoder := json.NewDecoder(&jsonReport)
+	decoder.UseNumber()
+	var want interface{}
+	if err := decoder.Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeTestMsgpack(t, msgpackReport.Bytes()); !reflect.DeepEqual(got, msgpackValue(want)) {
+		t.Errorf("MessagePack report differs from the JSON report:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestEncodeMsgpackIntegers(t *testing.T) {
+	for n, want := range map[int64]string{
+		0: "00", 127: "7f", -1: "ff", -32: "e0",
+		128: "d10080", -33: "d0df", -128: "d080", -129: "d1ff7f",
+		32767: "d17fff", 32768: "d200008000", -32769: "d2ffff7fff",
+		math.MaxInt32: "d27fffffff", math.MaxInt32 + 1: "d30000000080000000", math.MinInt64: "d38000000000000000",
+	} {
+		if got := encodeTestMsgpack(json.Number(fmt.Sprint(n))); got != want {
+			t.Errorf("%d encoded as %s, want %s", n, got, want)
+		}
+	}
+	if got := encodeTestMsgpack(json.Number("1.5")); got != "cb3ff8000000000000" {
+		t.Errorf("1.5 encoded as %s", got)
+	}
+}
+
+func TestEncodeMsgpackLengths(t *testing.T) {
+	for n, prefix := range map[int]string{0: "a0", 31: "bf", 32: "d920", 255: "d9ff", 256: "da0100", 65535: "daffff", 65536: "db00010000"} {
+		if got := encodeTestMsgpack(strings.Repeat("x", n)); !strings.HasPrefix(got, prefix) || len(got) != len(prefix)+2*n {
+			t.Errorf("%d-byte string encoded with prefix %.12s, want %s", n, got, prefix)
+		}
+	}
+	for n, prefix := range map[int]string{0: "90", 15: "9f", 16: "dc0010", 65535: "dcffff", 65536: "dd00010000"} {
+		if got := encodeTestMsgpack(make([]interface{}, n)); !strings.HasPrefix(got, prefix) || len(got) != len(prefix)+2*n {
+			t.Errorf("%d-item array encoded with prefix %.12s, want %s", n, got, prefix)
+		}
+	}
+	for n, prefix := range map[int]string{0: "80", 15: "8f", 16: "de0010", 65536: "df00010000"} {
+		entries := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			entries[fmt.Sprintf("%05d", i)] = nil
+		}
+		if got := encodeTestMsgpack(entries); !strings.HasPrefix(got, prefix) || len(got) != len(prefix)+14*n {
+			t.Errorf("%d-entry map encoded with prefix %.12s, want %s", n, got, prefix)
+		}
+	}
+}
+
+func TestEncodeMsgpackSortsKeys(t *testing.T) {
+	// {"a": true, "b": false, "c": nil} whatever order the map iterates in.
+	if got := encodeTestMsgpack(map[string]interface{}{"c": nil, "a": true, "b": false}); got != "83a161c3a162c2a163c0" {
+		t.Errorf("map encoded as %s", got)
+	}
+}
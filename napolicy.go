@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The ways missing values can enter the statistics of a numeric column
+const (
+	NASkip      = "skip"      // leave them out, the default
+	NAZero      = "zero"      // count them as 0
+	NAPropagate = "propagate" // make every statistic but the count NaN, as in IEEE arithmetic
+)
+
+// NAPolicies is the --na-policy setting: a default policy and overrides per column. It implements flag.Value.
+type NAPolicies struct {
+	Default string
+	Columns map[string]string
+}
+
+// String renders the policies in --na-policy syntax
+func (n NAPolicies) String() string {
+	var parts []string
+	if n.Default != "" {
+		parts = append(parts, n.Default)
+	}
+	var overrides []string
+	for column, policy := range n.Columns {
+		overrides = append(overrides, column+"="+policy)
+	}
+	sort.Strings(overrides)
+	parts = append(parts, overrides...)
+	return strings.Join(parts, ",")
+}
+
+// Set parses a --na-policy value such as "zero" or "skip,Price=zero,Rating=propagate"
+func (n *NAPolicies) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		column, policy, perColumn := strings.Cut(entry, "=")
+		if !perColumn {
+			column, policy = "", column
+		}
+		column, policy = strings.TrimSpace(column), strings.ToLower(strings.TrimSpace(policy))
+		switch policy {
+		case NASkip, NAZero, NAPropagate:
+		default:
+			return fmt.Errorf("unknown missing-value policy %q (expected skip, zero or propagate)", policy)
+		}
+		switch {
+		case !perColumn:
+			n.Default = policy
+		case column == "":
+			return fmt.Errorf("invalid missing-value policy %q (expected policy or column=policy)", entry)
+		default:
+			if n.Columns == nil {
+				n.Columns = make(map[string]string)
+			}
+			n.Columns[column] = policy
+		}
+	}
+	return nil
+}
+
+// forColumn returns the policy of a column: its override, else the default, else skip
+func (n NAPolicies) forColumn(column string) string {
+	if policy, ok := n.Columns[column]; ok {
+		return policy
+	}
+	if n.Default != "" {
+		return n.Default
+	}
+	return NASkip
+}
+
+// describeNAPolicy says what a policy did to the missing values, for the text report
+func describeNAPolicy(policy string) string {
+	switch policy {
+	case NAZero:
+		return "counted as 0"
+	case NAPropagate:
+		return "propagated, statistics are NaN"
+	}
+	return "skipped"
+}
+
+// missingCells counts the rows whose cell in a column is empty or absent
+func (ca *CSVAnalyzer) missingCells(colIndex int) int {
+	missing := 0
+	for _, row := range ca.dataset.Rows {
+		if colIndex >= len(row) || strings.TrimSpace(row[colIndex]) == "" {
+			missing++
+		}
+	}
+	return missing
+}
+
+// The applyNAPolicy function turns the values and running summary of a numeric
+// column into the ones its statistics are computed from under the column's --na-policy. With skip they are used as
+// they are. With zero every missing cell adds a 0, so the count is the number of rows and the mean is the total per
+// row, which suits columns where an empty cell means none (sales, defects); the summary is then rebuilt rather
+// than changed, since the cached one is shared. With propagate the column is left alone and the caller reports NaN,
+// the way one missing value spoils a sum in SQL or pandas with skipna=False.
+// applyNAPolicy returns the values and summary the statistics of a numeric column are computed from
+func applyNAPolicy(policy string, missing int, values []float64, accumulated *numericAccumulator) ([]float64, *numericAccumulator) {
+	if policy != NAZero || missing == 0 {
+		return values, accumulated
+	}
+	filled := make([]float64, len(values), len(values)+missing)
+	copy(filled, values)
+	for i := 0; i < missing; i++ {
+		filled = append(filled, 0)
+	}
+	rebuilt := newNumericAccumulator(len(filled) > exactQuantileLimit)
+	for _, v := range filled {
+		rebuilt.add(v)
+	}
+	return filled, rebuilt
+}
+
+// propagatedStats returns the statistics of a column whose missing values propagate: the count of the values that
+// are there, and NaN for everything computed from them
+func propagatedStats(stats ColumnStats) ColumnStats {
+	nan := math.NaN()
+	stats.Sum, stats.Mean, stats.TrimmedMean, stats.Median = nan, nan, nan, nan
+	stats.StdDev, stats.Min, stats.Max = nan, nan, nan
+	return stats
+}
+
+// MarshalJSON encodes the statistics with non-finite figures as null
+func (s ColumnStats) MarshalJSON() ([]byte, error) {
+	return marshalFiniteJSON(reflect.ValueOf(s))
+}
+
+// MarshalJSON encodes the interval with non-finite bounds, which NaN or Inf cells produce, as null
+func (c ConfidenceInterval) MarshalJSON() ([]byte, error) {
+	return marshalFiniteJSON(reflect.ValueOf(c))
+}
+
+// The marshalFiniteJSON function writes a struct as encoding/json would, field by field in declaration order and
+// honouring omitempty, except that NaN and infinities, in float64 fields or behind *float64 ones, are written as
+// null: JSON has no numbers for them and encoding/json refuses to write them, yet --na-policy propagate produces them
+// on purpose and NaN or Inf cells in the data produce them too.
+// marshalFiniteJSON encodes a struct with non-finite figures as null
+func marshalFiniteJSON(value reflect.Value) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if options == "omitempty" && value.Field(i).IsZero() {
+			continue
+		}
+		var data []byte
+		f := value.Field(i)
+		if f.Kind() == reflect.Pointer && !f.IsNil() && f.Elem().Kind() == reflect.Float64 {
+			f = f.Elem()
+		}
+		if f.Kind() == reflect.Float64 && (math.IsNaN(f.Float()) || math.IsInf(f.Float(), 0)) {
+			data = []byte("null")
+		} else {
+			var err error
+			if data, err = json.Marshal(f.Interface()); err != nil {
+				return nil, err
+			}
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(data)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
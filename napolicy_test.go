@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// nonFiniteStatsCSV has NaN and infinite cells, which make the sum, mean and geometric mean non-finite
+const nonFiniteStatsCSV = "a,b,c\n1,x,2\nNaN,y,\nInf,z,4\n-Inf,w,8\n3,v,16\n"
+
+func TestMachineReportsWithNonFiniteCells(t *testing.T) {
+	for _, args := range [][]string{nil, {"--dp-epsilon", "1"}, {"--na-policy", "propagate"}} {
+		analyzer := loadTestCSV(t, nonFiniteStatsCSV, args...)
+		writers := map[string]func(*bytes.Buffer) error{
+			FormatJSON:      func(b *bytes.Buffer) error { return analyzer.WriteJSONReport(b) },
+			FormatMsgpack:   func(b *bytes.Buffer) error { return analyzer.WriteMsgpackReport(b) },
+			FormatJSONCards: func(b *bytes.Buffer) error { return analyzer.WriteCards(b, FormatJSONCards) },
+		}
+		for format, write := range writers {
+			var b bytes.Buffer
+			if err := write(&b); err != nil {
+				t.Errorf("%s %v: %v", format, args, err)
+			}
+		}
+	}
+}
+
+func TestColumnStatsMarshalJSONNullsNonFinite(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	stats := ColumnStats{
+		Name: "a", Count: 3, Sum: inf, Mean: nan, Median: 2, GeometricMean: &nan, HarmonicMean: &inf,
+		MeanCI: &ConfidenceInterval{Level: 0.95, Lower: -inf, Upper: inf},
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"sum":null`, `"mean":null`, `"median":2`, `"geometric_mean":null`,
+		`"harmonic_mean":null`, `"mean_ci":{"level":0.95,"lower":null,"upper":null}`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %s in %s", want, data)
+		}
+	}
+	// Unset optional figures are still left out.
+	stats.GeometricMean, stats.HarmonicMean, stats.MeanCI = nil, nil, nil
+	if data, _ = json.Marshal(stats); strings.Contains(string(data), "geometric_mean") {
+		t.Errorf("nil geometric mean written: %s", data)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// normalityMinValues is the smallest number of values the normality test is run on
+const normalityMinValues = 8
+
+// normalityMaxValues caps the values a normality test looks at; larger columns are tested on an evenly spaced sample,
+// because with far more values any test rejects normality over deviations too small to matter
+const normalityMaxValues = 5000
+
+// normalityAlpha is the significance level below which a column is reported as not normal
+const normalityAlpha = 0.05
+
+// NormalityTest is the outcome of the Anderson–Darling normality test of one numeric column
+type NormalityTest struct {
+	Test           string  `json:"test"`
+	Statistic      float64 `json:"statistic"` // A², adjusted for the estimated mean and standard deviation
+	PValue         float64 `json:"p_value"`
+	Values         int     `json:"values"`  // the number of values tested
+	Sampled        bool    `json:"sampled"` // set when the column was larger than normalityMaxValues
+	Skewness       float64 `json:"skewness"`
+	ExcessKurtosis float64 `json:"excess_kurtosis"`
+	Normal         bool    `json:"normal"`
+	Verdict        string  `json:"verdict"`
+}
+
+// normalCDF is the cumulative distribution function of the standard normal distribution
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// andersonDarlingPValue approximates the p-value of an adjusted A² statistic (D'Agostino and Stephens, 1986)
+func andersonDarlingPValue(a2 float64) float64 {
+	var p float64
+	switch {
+	case a2 >= 0.6:
+		p = math.Exp(1.2937 - 5.709*a2 + 0.0186*a2*a2)
+	case a2 >= 0.34:
+		p = math.Exp(0.9177 - 4.279*a2 - 1.38*a2*a2)
+	case a2 >= 0.2:
+		p = 1 - math.Exp(-8.318+42.796*a2-59.938*a2*a2)
+	default:
+		p = 1 - math.Exp(-13.436+101.14*a2-223.73*a2*a2)
+	}
+	return math.Max(0, math.Min(1, p))
+}
+
+// normalityVerdict explains a test outcome in terms of which summaries suit the column
+func normalityVerdict(test NormalityTest) string {
+	if test.Normal {
+		return "consistent with a normal distribution; mean ± std is a fair summary"
+	}
+	shape := "departs from a normal distribution"
+	switch {
+	case test.Skewness > 0.5:
+		shape = "right-skewed"
+	case test.Skewness < -0.5:
+		shape = "left-skewed"
+	case test.ExcessKurtosis > 1:
+		shape = "heavy-tailed"
+	case test.ExcessKurtosis < -1:
+		shape = "light-tailed (flat or multi-peaked)"
+	}
+	return "not normal, " + shape + "; prefer median and IQR over mean ± std"
+}
+
+// The Normality method is part of the CSVAnalyzer struct. It runs the Anderson–Darling test on a numeric column: the
+// values are standardized with their own mean and sample standard deviation and compared with the normal
+// distribution, weighting the tails more than a Kolmogorov–Smirnov test would, which is where mean ± std summaries
+// mislead first. A p-value below 0.05 marks the column as not normal, and the verdict names the shape from the
+// skewness and excess kurtosis. Columns above normalityMaxValues values are tested on an evenly spaced sample in file
+// order. The test is skipped for columns with fewer than normalityMinValues values, constant columns and under
+// --dp-epsilon.
+// Normality returns the normality test of a numeric column, or nil when it does not apply
+func (ca *CSVAnalyzer) Normality(colIndex int) *NormalityTest {
+	if ca.privacy != nil || ca.columnType(colIndex) != TypeNumeric {
+		return nil
+	}
+	values := ca.extractNumericValues(colIndex)
+	if len(values) < normalityMinValues {
+		return nil
+	}
+	test := NormalityTest{Test: "anderson-darling"}
+	if len(values) > normalityMaxValues {
+		// Takes every k-th value so the sample spans the whole file.
+		sample := make([]float64, normalityMaxValues)
+		for i := range sample {
+			sample[i] = values[i*len(values)/normalityMaxValues]
+		}
+		values, test.Sampled = sample, true
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := float64(len(sorted))
+	mean := sum(sorted) / n
+	sd := standardDeviation(sorted)
+	if sd == 0 {
+		return nil
+	}
+
+	// Sums the tail-weighted distances between the empirical and the normal distribution, with the moments alongside.
+	var a2, m3, m4 float64
+	for i, v := range sorted {
+		z := (v - mean) / sd
+		m3 += z * z * z
+		m4 += z * z * z * z
+		// Clamps the probabilities so extreme values do not take the logarithm of zero.
+		lower := math.Max(normalCDF(z), math.SmallestNonzeroFloat64)
+		upper := math.Max(1-normalCDF((sorted[len(sorted)-1-i]-mean)/sd), math.SmallestNonzeroFloat64)
+		a2 += float64(2*i+1) * (math.Log(lower) + math.Log(upper))
+	}
+	a2 = -n - a2/n
+	// Corrects for the mean and standard deviation being estimated from the same values.
+	test.Statistic = a2 * (1 + 0.75/n + 2.25/(n*n))
+	test.PValue = andersonDarlingPValue(test.Statistic)
+	test.Values = len(sorted)
+	test.Skewness = m3 / n
+	test.ExcessKurtosis = m4/n - 3
+	test.Normal = test.PValue >= normalityAlpha
+	test.Verdict = normalityVerdict(test)
+	return &test
+}
+
+// printNormality shows the normality verdict of every numeric column in the text report
+func (ca *CSVAnalyzer) printNormality() {
+	printed := false
+	for colIndex, header := range ca.dataset.Headers {
+		test := ca.Normality(colIndex)
+		if test == nil {
+			continue
+		}
+		if !printed {
+			fmt.Println("\n\nNormality (Anderson-Darling):")
+			fmt.Println("-----------------------------")
+			printed = true
+		}
+		fmt.Printf("\n%s:\n", header)
+		fmt.Printf("  A² = %.3f, p = %.4f", test.Statistic, test.PValue)
+		if test.Sampled {
+			fmt.Printf(" (on %d evenly spaced values)", test.Values)
+		}
+		fmt.Printf("\n  Skewness %.2f, excess kurtosis %.2f\n", test.Skewness, test.ExcessKurtosis)
+		fmt.Printf("  Verdict: %s\n", test.Verdict)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxSQLDecimalPrecision is the largest precision suggested as NUMERIC(p,s); wider columns are suggested as
+// DOUBLE PRECISION, since most databases cap exact decimals around 38 digits
+const maxSQLDecimalPrecision = 38
+
+// NumericFormat describes how the values of a numeric column are written, for choosing a database type for it
+type NumericFormat struct {
+	// Kind is "integer" when every value is written without a fraction or exponent, "scientific" when any value has
+	// an exponent and "decimal" otherwise
+	Kind string `json:"kind"`
+	// Values by how they are written; NonFinite counts NaN and infinities
+	Integers   int `json:"integers"`
+	Decimals   int `json:"decimals"`
+	Scientific int `json:"scientific"`
+	NonFinite  int `json:"non_finite,omitempty"`
+	// MaxIntegerDigits and MaxDecimalPlaces are the most digits written before and after the decimal mark, leading
+	// zeros left out and trailing zeros kept ("0.50" has 0 and 2); exponents are not counted
+	MaxIntegerDigits int `json:"max_integer_digits"`
+	MaxDecimalPlaces int `json:"max_decimal_places"`
+	// FitsInt64 is set when every value is a whole number within the int64 range, written with a fraction or not
+	FitsInt64 bool `json:"fits_int64"`
+	// SQLType is the narrowest standard SQL type that holds every value exactly, when there is one
+	SQLType string `json:"sql_type"`
+}
+
+// The numericFormat method is part of the CSVAnalyzer struct. It reads the values of a numeric column as they are
+// written, not as the floats they parse to, since "2.50" and "2.5" are the same number but need different decimal
+// scales: it counts the integers, decimals and values in scientific notation, the widest integer part and the most
+// decimal places, and whether every value fits an int64 (checked on the digits, so 9223372036854775807 does while
+// 9223372036854775808 does not, although both round to the same float). With a locale the locale's decimal mark is
+// used and digit-group separators and currency symbols are ignored. The widths describe single records, so the
+// format is withheld under differential privacy along with the other per-value figures.
+// numericFormat returns the written format of a numeric column, or nil when it has no values
+func (ca *CSVAnalyzer) numericFormat(colIndex int) *NumericFormat {
+	decimal := '.'
+	if ca.numberLocale != nil {
+		decimal = ca.numberLocale.Decimal
+	}
+	format := &NumericFormat{FitsInt64: true}
+	count := 0
+	for _, row := range ca.dataset.Rows {
+		if colIndex >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[colIndex])
+		if value == "" {
+			continue
+		}
+		number, err := ca.parseNumber(value)
+		if err != nil {
+			continue
+		}
+		count++
+		if math.IsNaN(number) || math.IsInf(number, 0) {
+			format.NonFinite++
+			format.FitsInt64 = false
+			continue
+		}
+		shape := numberShape(value, decimal)
+		switch {
+		case shape.exponent:
+			format.Scientific++
+		case shape.fraction:
+			format.Decimals++
+		default:
+			format.Integers++
+		}
+		if len(shape.integer) > format.MaxIntegerDigits {
+			format.MaxIntegerDigits = len(shape.integer)
+		}
+		if shape.places > format.MaxDecimalPlaces {
+			format.MaxDecimalPlaces = shape.places
+		}
+		if format.FitsInt64 {
+			format.FitsInt64 = shape.fitsInt64(number)
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	switch {
+	case format.Scientific > 0:
+		format.Kind = "scientific"
+	case format.Decimals > 0:
+		format.Kind = "decimal"
+	default:
+		format.Kind = "integer"
+	}
+	format.SQLType = format.sqlType()
+	return format
+}
+
+// writtenNumber is the shape of a number as written: its integer digits without leading zeros, whether it has a
+// fraction or an exponent, how many decimal places it shows and its sign
+type writtenNumber struct {
+	integer  string
+	places   int
+	fraction bool
+	exponent bool
+	negative bool
+}
+
+// numberShape splits a number that parsed into its written parts; anything that is not a digit, the decimal mark, a
+// sign or an exponent (group separators, currency symbols and codes, spaces) is skipped
+func numberShape(value string, decimal rune) writtenNumber {
+	var shape writtenNumber
+	var integer strings.Builder
+	afterMark, digitSeen := false, false
+	runes := []rune(value)
+	for i, r := range runes {
+		switch {
+		case r >= '0' && r <= '9':
+			digitSeen = true
+			if afterMark {
+				shape.places++
+			} else if integer.Len() > 0 || r != '0' {
+				integer.WriteRune(r)
+			}
+		case r == decimal && !shape.exponent:
+			afterMark, shape.fraction = true, true
+		case (r == 'e' || r == 'E') && digitSeen && isExponent(runes[i+1:]):
+			// The exponent ends the mantissa; its digits are not counted.
+			shape.integer = integer.String()
+			shape.exponent = true
+			return shape
+		case r == '-' || r == '(':
+			shape.negative = !shape.negative
+		}
+	}
+	shape.integer = integer.String()
+	return shape
+}
+
+// isExponent reports whether the runes after an 'e' are an exponent: an optional sign and at least one digit
+func isExponent(rest []rune) bool {
+	if len(rest) > 0 && (rest[0] == '+' || rest[0] == '-') {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return false
+	}
+	for _, r := range rest {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// fitsInt64 reports whether a number is a whole int64; integers are checked on their digits, which are exact where
+// the float is not
+func (w writtenNumber) fitsInt64(number float64) bool {
+	if number != math.Trunc(number) {
+		return false
+	}
+	if !w.fraction && !w.exponent {
+		digits := w.integer
+		if digits == "" {
+			return true
+		}
+		if w.negative {
+			digits = "-" + digits
+		}
+		_, err := strconv.ParseInt(digits, 10, 64)
+		return err == nil
+	}
+	return number >= -(1<<63) && number < 1<<63
+}
+
+// sqlType suggests a column type: the smallest integer type for integers, NUMERIC(p,s) for decimals and DOUBLE
+// PRECISION for scientific notation, non-finite values and decimals too wide for an exact type
+func (f *NumericFormat) sqlType() string {
+	if f.Kind == "integer" && f.FitsInt64 {
+		switch {
+		case f.MaxIntegerDigits <= 4:
+			return "SMALLINT"
+		case f.MaxIntegerDigits <= 9:
+			return "INTEGER"
+		default:
+			return "BIGINT"
+		}
+	}
+	precision := f.integerDigits() + f.MaxDecimalPlaces
+	if f.Kind == "scientific" || f.NonFinite > 0 || precision > maxSQLDecimalPrecision {
+		return "DOUBLE PRECISION"
+	}
+	return fmt.Sprintf("NUMERIC(%d,%d)", precision, f.MaxDecimalPlaces)
+}
+
+// integerDigits is the width of the integer part, counting the 0 of values below 1
+func (f *NumericFormat) integerDigits() int {
+	if f.MaxIntegerDigits == 0 {
+		return 1
+	}
+	return f.MaxIntegerDigits
+}
+
+// countOf writes a count with its noun, adding an s unless there is exactly one: "1 digit", "3 digits"
+func countOf(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// describe summarizes the format on one line of the text report
+func (f *NumericFormat) describe() string {
+	// A column of NaN and infinities has no digits or places to speak of.
+	if f.NonFinite > 0 && f.Integers+f.Decimals+f.Scientific == 0 {
+		return fmt.Sprintf("no finite values, %d NaN or infinite (%s)", f.NonFinite, f.SQLType)
+	}
+	var text string
+	switch f.Kind {
+	case "integer":
+		text = "integer, up to " + countOf(f.integerDigits(), "digit")
+	case "decimal":
+		text = "decimal, up to " + countOf(f.MaxDecimalPlaces, "place")
+	default:
+		text = "scientific notation in " + countOf(f.Scientific, "value")
+	}
+	if f.FitsInt64 && f.Kind != "integer" {
+		text += ", all whole"
+	} else if !f.FitsInt64 && f.NonFinite == 0 && f.Kind == "integer" {
+		text += ", exceeds int64"
+	}
+	if f.NonFinite > 0 {
+		text += fmt.Sprintf(", %d NaN or infinite", f.NonFinite)
+	}
+	return fmt.Sprintf("%s (%s)", text, f.SQLType)
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestNumericFormatDescribePlurals(t *testing.T) {
+	cases := []struct {
+		format NumericFormat
+		want   string
+	}{
+		{NumericFormat{Kind: "integer", MaxIntegerDigits: 1, FitsInt64: true, SQLType: "SMALLINT"}, "integer, up to 1 digit (SMALLINT)"},
+		{NumericFormat{Kind: "integer", MaxIntegerDigits: 3, FitsInt64: true, SQLType: "SMALLINT"}, "integer, up to 3 digits (SMALLINT)"},
+		{NumericFormat{Kind: "decimal", MaxDecimalPlaces: 1, SQLType: "NUMERIC(2,1)"}, "decimal, up to 1 place (NUMERIC(2,1))"},
+		{NumericFormat{Kind: "decimal", MaxDecimalPlaces: 2, SQLType: "NUMERIC(3,2)"}, "decimal, up to 2 places (NUMERIC(3,2))"},
+		{NumericFormat{Kind: "scientific", Scientific: 1, SQLType: "DOUBLE PRECISION"}, "scientific notation in 1 value (DOUBLE PRECISION)"},
+		{NumericFormat{Kind: "integer", NonFinite: 3, SQLType: "DOUBLE PRECISION"}, "no finite values, 3 NaN or infinite (DOUBLE PRECISION)"},
+		{NumericFormat{Kind: "integer", Integers: 2, NonFinite: 1, MaxIntegerDigits: 2, SQLType: "DOUBLE PRECISION"}, "integer, up to 2 digits, 1 NaN or infinite (DOUBLE PRECISION)"},
+	}
+	for _, c := range cases {
+		if got := c.format.describe(); got != c.want {
+			t.Errorf("describe() = %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestNumericFormatOfNonFiniteColumn(t *testing.T) {
+	analyzer := loadTestCSV(t, "x,n\nNaN,1\nInf,2\n-Inf,3\n", "--types", "x:float")
+	format := analyzer.numericFormat(0)
+	if format == nil || format.Integers != 0 || format.MaxIntegerDigits != 0 || format.NonFinite != 3 {
+		t.Fatalf("format %+v, want 3 non-finite values and no digits", format)
+	}
+	if got, want := format.describe(), "no finite values, 3 NaN or infinite (DOUBLE PRECISION)"; got != want {
+		t.Errorf("describe() = %q, want %q", got, want)
+	}
+}
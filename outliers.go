@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Outlier treatments of --treat-outliers
+const (
+	OutlierRemove    = "remove"    // drop the rows whose value lies outside the bounds
+	OutlierWinsorize = "winsorize" // clip the value to the nearest bound
+)
+
+// iqrFenceFactor is how many interquartile ranges beyond the quartiles the IQR bounds lie, the box plot's fences
+const iqrFenceFactor = 1.5
+
+// OutlierRule names a numeric column and how its outliers are treated
+type OutlierRule struct {
+	Column string
+	Method string
+}
+
+// OutlierRules is a flag.Value collecting outlier treatments such as "Price=winsorize,Revenue=remove"
+type OutlierRules []OutlierRule
+
+// String renders the rules in --treat-outliers syntax
+func (o OutlierRules) String() string {
+	parts := make([]string, len(o))
+	for i, rule := range o {
+		parts[i] = rule.Column + "=" + rule.Method
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a --treat-outliers value such as "Price=winsorize,Revenue=remove"
+func (o *OutlierRules) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		column, method, ok := strings.Cut(entry, "=")
+		column, method = strings.TrimSpace(column), strings.ToLower(strings.TrimSpace(method))
+		if !ok || column == "" {
+			return fmt.Errorf("invalid outlier rule %q (expected column=method)", entry)
+		}
+		if method != OutlierRemove && method != OutlierWinsorize {
+			return fmt.Errorf("unknown outlier treatment %q for %s (expected remove or winsorize)", method, column)
+		}
+		*o = append(*o, OutlierRule{Column: column, Method: method})
+	}
+	return nil
+}
+
+// OutlierBounds is the --outlier-bounds flag: the box plot fences (iqr, the default) or a pair of percentiles
+// such as 5:95, between which values are not outliers. It implements flag.Value.
+type OutlierBounds struct {
+	Low, High float64 // percentiles from 0 to 100; both zero for the IQR fences
+}
+
+// String renders the bounds in flag syntax
+func (b OutlierBounds) String() string {
+	if b.Low == 0 && b.High == 0 {
+		return "iqr"
+	}
+	return formatMetric(b.Low) + ":" + formatMetric(b.High)
+}
+
+// Set parses "iqr" or "LOW:HIGH" percentiles
+func (b *OutlierBounds) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "iqr") {
+		*b = OutlierBounds{}
+		return nil
+	}
+	lowText, highText, ok := strings.Cut(value, ":")
+	low, lowErr := strconv.ParseFloat(strings.TrimSpace(lowText), 64)
+	high, highErr := strconv.ParseFloat(strings.TrimSpace(highText), 64)
+	if !ok || lowErr != nil || highErr != nil || low < 0 || high > 100 || low >= high {
+		return fmt.Errorf("invalid outlier bounds %q (expected iqr or percentiles such as 5:95)", value)
+	}
+	*b = OutlierBounds{Low: low, High: high}
+	return nil
+}
+
+// bounds returns the range of the sorted values outside which a value is an outlier
+func (b OutlierBounds) bounds(sorted []float64) (low, high float64) {
+	if b.Low == 0 && b.High == 0 {
+		q1, q3 := percentile(sorted, 0.25), percentile(sorted, 0.75)
+		fence := iqrFenceFactor * (q3 - q1)
+		return q1 - fence, q3 + fence
+	}
+	return percentile(sorted, b.Low/100), percentile(sorted, b.High/100)
+}
+
+// OutlierSummary reports how the outliers of one column were treated
+type OutlierSummary struct {
+	Column   string  `json:"column"`
+	Method   string  `json:"method"`
+	Low      float64 `json:"low"`
+	High     float64 `json:"high"`
+	Affected int     `json:"affected"` // values clipped, or rows removed because of this column
+}
+
+// The TreatedRows method is part of the CSVAnalyzer struct. It returns a copy of the rows with the outliers of the
+// --treat-outliers columns dealt with: values outside the --outlier-bounds are clipped to the nearest bound
+// (winsorize) or their rows are dropped (remove). The bounds of every column come from the values as loaded, so
+// the order of the rules does not matter, and a row outside the bounds of several remove columns is dropped once and
+// counted for the first. Cells that are empty or not numbers are left as they are. The loaded data is untouched, so
+// the report still describes the outliers that were found.
+// TreatedRows returns the rows with outliers treated and what was done per column
+func (ca *CSVAnalyzer) TreatedRows() ([][]string, []OutlierSummary, error) {
+	type treatment struct {
+		colIndex int
+		values   []float64 // aligned with the rows, NaN where there is no number
+		summary  OutlierSummary
+	}
+	treatments := make([]*treatment, 0, len(ca.options.TreatOutliers))
+	for _, rule := range ca.options.TreatOutliers {
+		colIndex := ca.columnIndex(rule.Column)
+		if colIndex < 0 {
+			return nil, nil, fmt.Errorf("outlier column %q not found", rule.Column)
+		}
+		if ca.columnType(colIndex) != TypeNumeric {
+			return nil, nil, fmt.Errorf("cannot treat outliers of %s: the column is %s, not numeric", rule.Column, ca.columnType(colIndex))
+		}
+		sorted := finiteValues(ca.extractNumericValues(colIndex))
+		if len(sorted) == 0 {
+			return nil, nil, fmt.Errorf("cannot treat outliers of %s: the column has no numeric values", rule.Column)
+		}
+		sort.Float64s(sorted)
+		t := &treatment{colIndex: colIndex, values: ca.alignedNumericValues(colIndex), summary: OutlierSummary{Column: rule.Column, Method: rule.Method}}
+		t.summary.Low, t.summary.High = ca.options.OutlierBounds.bounds(sorted)
+		treatments = append(treatments, t)
+	}
+
+	var rows [][]string
+	for rowIndex, row := range ca.dataset.Rows {
+		row = append([]string(nil), row...)
+		removed := false
+		for _, t := range treatments {
+			v := t.values[rowIndex]
+			if math.IsNaN(v) || (v >= t.summary.Low && v <= t.summary.High) {
+				continue
+			}
+			if t.summary.Method == OutlierRemove {
+				if !removed {
+					t.summary.Affected++
+				}
+				removed = true
+				continue
+			}
+			row[t.colIndex] = formatMetric(math.Max(t.summary.Low, math.Min(t.summary.High, v)))
+			t.summary.Affected++
+		}
+		if !removed {
+			rows = append(rows, row)
+		}
+	}
+	summaries := make([]OutlierSummary, len(treatments))
+	for i, t := range treatments {
+		summaries[i] = t.summary
+	}
+	return rows, summaries, nil
+}
+
+// The WriteTreated method is part of the CSVAnalyzer struct. It writes the dataset with its outliers treated as CSV
+// to path (compressed with --compress) and prints per column the bounds and how many values or rows were affected,
+// and the rows written, on w.
+// WriteTreated writes the dataset with outliers removed or winsorized to path
+func (ca *CSVAnalyzer) WriteTreated(path string, w io.Writer) error {
+	rows, summaries, err := ca.TreatedRows()
+	if err != nil {
+		return err
+	}
+	out, err := createOutputFile(path, ca.options.Compress)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	err = writeCSVRecords(out, append([][]string{ca.dataset.Headers}, rows...))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	bounds := ca.options.OutlierBounds.String()
+	if bounds != "iqr" {
+		bounds = "percentiles " + bounds
+	}
+	for _, summary := range summaries {
+		if summary.Method == OutlierRemove {
+			fmt.Fprintf(w, "Removed %d rows with %s outside [%s, %s] (%s)\n", summary.Affected, summary.Column, formatMetric(summary.Low), formatMetric(summary.High), bounds)
+		} else {
+			fmt.Fprintf(w, "Winsorized %d %s values to [%s, %s] (%s)\n", summary.Affected, summary.Column, formatMetric(summary.Low), formatMetric(summary.High), bounds)
+		}
+	}
+	fmt.Fprintf(w, "Kept %d of %d rows\n", len(rows), len(ca.dataset.Rows))
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// parallelReadMinBytes is the file size from which CSV files are parsed by several goroutines; below it splitting
+// the file costs more than it saves
+const parallelReadMinBytes = 8 << 20
+
+// parallelChunkBytes is about how much of the file one worker parses at a time
+const parallelChunkBytes = 1 << 20
+
+// csvChunk is a run of whole records of the input, parsed by one worker
+type csvChunk struct {
+	data    []byte
+	line    int // line number of the chunk's first line in the file, from 1
+	records [][]string
+	err     error
+	done    chan struct{} // closed once records or err are set
+}
+
+// The canReadInParallel method is part of the CSVAnalyzer struct. It reports whether a CSV input of size bytes is
+// split into chunks for parallel parsing. Chunks are cut between records by counting quote characters, which only
+// works when every quote opens or closes a quoted field (or is a doubled quote inside one): --lazy-quotes accepts
+// stray quotes and comment lines may hold any number of them, so both keep the sequential reader.
+// canReadInParallel reports whether an input is parsed by several goroutines
+func (ca *CSVAnalyzer) canReadInParallel(size int64) bool {
+	if size < parallelReadMinBytes || runtime.GOMAXPROCS(0) < 2 || ca.options.LazyQuotes {
+		return false
+	}
+	_, comment, err := ca.options.csvDialect()
+	return err == nil && comment == 0
+}
+
+// recordBoundary returns the offset just after the last line break of data that lies outside quoted fields, or -1
+// when there is none; data must start outside a quoted field
+func recordBoundary(data []byte, quote byte) int {
+	// odd is whether data[:i+1] holds an odd number of quotes, that is whether position i+1 is inside quotes.
+	odd := bytes.Count(data, []byte{quote})%2 == 1
+	for i := len(data) - 1; i >= 0; i-- {
+		if data[i] == quote {
+			odd = !odd
+			continue
+		}
+		if data[i] == '\n' && !odd {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// The readCSVParallel method is part of the CSVAnalyzer struct. It parses a large CSV input on all cores: one
+// goroutine reads the input in blocks of parallelChunkBytes and cuts each at its last record boundary (see
+// recordBoundary), carrying the rest into the next block, and a pool of GOMAXPROCS workers parses the chunks with
+// their own CSV readers. The records are handed to visit in file order, so callers see exactly what the sequential
+// reader produces, and at most two chunks per worker are held at once. Parse errors carry the line numbers of the
+// file, not of the chunk. Stopping early (visit returning an error) stops the reading too.
+// readCSVParallel reads the records of a CSV input with parallel parsers
+func (ca *CSVAnalyzer) readCSVParallel(input io.Reader, filename string, visit func(record []string) error) error {
+	quote, _, err := ca.options.csvDialect()
+	if err != nil {
+		return err
+	}
+	if quote == 0 {
+		quote = '"'
+	}
+	workers := runtime.GOMAXPROCS(0)
+	ordered := make(chan *csvChunk, workers)
+	jobs := make(chan *csvChunk)
+	stop := make(chan struct{})
+
+	// Stopping comes first on the way out, so the reader and the workers are never left blocked.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(stop)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range jobs {
+				chunk.records, chunk.err = ca.parseCSVChunk(chunk)
+				close(chunk.done)
+			}
+		}()
+	}
+
+	// Cuts the input into chunks of whole records and queues them both in order and for the workers.
+	var readErr error
+	go func() {
+		defer close(ordered)
+		defer close(jobs)
+		var carry []byte
+		line := 1
+		block := make([]byte, parallelChunkBytes)
+		for {
+			n, err := io.ReadFull(input, block)
+			eof := err == io.EOF || err == io.ErrUnexpectedEOF
+			if err != nil && !eof {
+				readErr = err
+				return
+			}
+			data := append(carry, block[:n]...)
+			cut := len(data)
+			if !eof {
+				if cut = recordBoundary(data, quote); cut < 0 {
+					// A record longer than the block continues into the next one.
+					carry = data
+					continue
+				}
+			}
+			carry = append([]byte(nil), data[cut:]...)
+			if cut > 0 {
+				chunk := &csvChunk{data: data[:cut], line: line, done: make(chan struct{})}
+				line += bytes.Count(chunk.data, []byte{'\n'})
+				select {
+				case ordered <- chunk:
+				case <-stop:
+					return
+				}
+				select {
+				case jobs <- chunk:
+				case <-stop:
+					return
+				}
+			}
+			if eof {
+				return
+			}
+		}
+	}()
+
+	for chunk := range ordered {
+		<-chunk.done
+		if chunk.err != nil {
+			return fmt.Errorf("error reading CSV file %s: %v", filename, chunk.err)
+		}
+		for _, record := range chunk.records {
+			if err := visit(record); err != nil {
+				return err
+			}
+		}
+	}
+	if readErr != nil {
+		return fmt.Errorf("error reading CSV file %s: %v", filename, readErr)
+	}
+	return nil
+}
+
+// parseCSVChunk parses the records of one chunk with the configured dialect, numbering error lines from the file
+func (ca *CSVAnalyzer) parseCSVChunk(chunk *csvChunk) ([][]string, error) {
+	reader, err := ca.newCSVReader(bytes.NewReader(chunk.data))
+	if err != nil {
+		return nil, err
+	}
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				parseErr.StartLine += chunk.line - 1
+				parseErr.Line += chunk.line - 1
+			}
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRecordBoundary(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		quote byte
+		want  int
+	}{
+		{"empty", "", '"', -1},
+		{"no line break", `a,b,"c"`, '"', -1},
+		{"whole records", "a,b\n1,2\n", '"', 8},
+		{"partial last record", "a,b\n1,2", '"', 4},
+		{"line break inside quotes", "a,\"x\ny\"", '"', -1},
+		{"record before an open quoted field", "a\n\"x\ny", '"', 2},
+		{"quoted line break then a record", "\"a\nb\"\nc", '"', 6},
+		{"doubled quotes", "\"say \"\"hi\"\"\n\"\"\"\nnext", '"', 16},
+		{"doubled quotes left open", "x\n\"\"\"\n", '"', 2},
+		{"empty quoted field", "\"\"\n", '"', 3},
+		{"crlf", "a\r\nb\r\n", '"', 6},
+		{"crlf inside quotes", "a\r\n\"b\r\nc", '"', 3},
+		{"other quote character", "'a\nb',\"\nc", '\'', 8},
+	}
+	for _, test := range tests {
+		if got := recordBoundary([]byte(test.data), test.quote); got != test.want {
+			t.Errorf("%s: recordBoundary(%q) = %d, want %d", test.name, test.data, got, test.want)
+		}
+	}
+}
+
+// quotedTestCSV generates rows whose fields mix plain values, commas, doubled quotes, CRLF and LF line breaks inside
+// quoted fields and, every so often, a quoted field longer than a read block
+func quotedTestCSV(rows int, quote string) []byte {
+	random := rand.New(rand.NewSource(1))
+	var b bytes.Buffer
+	b.WriteString("id,text,note\r\n")
+	for i := 0; i < rows; i++ {
+		var text string
+		switch random.Intn(6) {
+		case 0:
+			text = fmt.Sprint("plain ", i)
+		case 1:
+			text = quote + fmt.Sprint("comma, ", i) + quote
+		case 2:
+			text = quote + "two\nlines " + quote + quote + "quoted" + quote + quote + quote
+		case 3:
+			text = quote + "crlf\r\ninside\r\n" + quote
+		case 4:
+			text = quote + quote
+		default:
+			text = quote + strings.Repeat("\n", random.Intn(4)) + quote
+		}
+		if i%5000 == 4999 {
+			text = quote + strings.Repeat("long\n"+quote+quote, parallelChunkBytes/6) + quote
+		}
+		ending := "\n"
+		if i%2 == 0 {
+			ending = "\r\n"
+		}
+		fmt.Fprintf(&b, "%d,%s,n%d%s", i, text, i%7, ending)
+	}
+	return b.Bytes()
+}
+
+// sequentialTestRecords reads data with the sequential CSV reader
+func sequentialTestRecords(t *testing.T, analyzer *CSVAnalyzer, data []byte) ([][]string, error) {
+	t.Helper()
+	reader, err := analyzer.newCSVReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, fmt.Errorf("error reading CSV file test.csv: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+// parallelTestRecords reads data with the parallel CSV reader
+func parallelTestRecords(analyzer *CSVAnalyzer, data []byte) ([][]string, error) {
+	var records [][]string
+	err := analyzer.readCSVParallel(bytes.NewReader(data), "test.csv", func(record []string) error {
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+func TestReadCSVParallelMatchesSequential(t *testing.T) {
+	for _, quote := range []string{`"`, `'`} {
+		analyzer := NewCSVAnalyzerWithOptions(testOptions(t, "--quote-char", quote))
+		data := quotedTestCSV(20000, quote)
+		if len(data) < 4*parallelChunkBytes {
+			t.Fatalf("only %d bytes of test data", len(data))
+		}
+		want, err := sequentialTestRecords(t, analyzer, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parallelTestRecords(analyzer, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("quote %s: %d records in parallel, %d sequentially", quote, len(got), len(want))
+		}
+		for i := range want {
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Fatalf("quote %s: record %d = %.80q, want %.80q", quote, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestReadCSVParallelReportsFileLineNumbers(t *testing.T) {
+	analyzer := NewCSVAnalyzerWithOptions(testOptions(t))
+	data := append(quotedTestCSV(12000, `"`), "1,bare \"quote,x\n2,after,y\n"...)
+	_, want := sequentialTestRecords(t, analyzer, data)
+	if want == nil {
+		t.Fatal("the sequential reader accepted the bare quote")
+	}
+	if _, err := parallelTestRecords(analyzer, data); err == nil || err.Error() != want.Error() {
+		t.Errorf("parallel error %v, want %v", err, want)
+	}
+}
+
+func TestReadCSVParallelStopsEarly(t *testing.T) {
+	analyzer := NewCSVAnalyzerWithOptions(testOptions(t))
+	stop := errors.New("stop")
+	visited := 0
+	err := analyzer.readCSVParallel(bytes.NewReader(quotedTestCSV(20000, `"`)), "test.csv", func([]string) error {
+		if visited++; visited == 10 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || visited != 10 {
+		t.Errorf("stopped after %d records with %v, want 10 and the visit error", visited, err)
+	}
+}
+
+func TestCanReadInParallel(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	tests := []struct {
+		args []string
+		size int64
+		want bool
+	}{
+		{nil, parallelReadMinBytes, true},
+		{nil, parallelReadMinBytes - 1, false},
+		{[]string{"--quote-char", "'"}, parallelReadMinBytes, true},
+		// Stray quotes and quotes in comments would throw the quote count off.
+		{[]string{"--lazy-quotes"}, parallelReadMinBytes, false},
+		{[]string{"--comment-char", "#"}, parallelReadMinBytes, false},
+	}
+	for _, test := range tests {
+		analyzer := NewCSVAnalyzerWithOptions(testOptions(t, test.args...))
+		if got := analyzer.canReadInParallel(test.size); got != test.want {
+			t.Errorf("%v with %d bytes: %t, want %t", test.args, test.size, got, test.want)
+		}
+	}
+	runtime.GOMAXPROCS(1)
+	if NewCSVAnalyzerWithOptions(testOptions(t)).canReadInParallel(parallelReadMinBytes) {
+		t.Error("reads in parallel on a single core")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxValuePatterns is how many of the most common patterns a pattern profile lists
+const maxValuePatterns = 5
+
+// patternMaxLength is the length above which a value is profiled as long text rather than by its shape, since the
+// shapes of sentences and descriptions are all different
+const patternMaxLength = 32
+
+// patternDominantShare is the share of values the most common pattern must exceed for the column to have a format;
+// below it the column is free-form and its other patterns are not inconsistencies
+const patternDominantShare = 0.5
+
+// Names of the patterns recognized by meaning rather than by shape
+const (
+	PatternEmail    = "<email>"
+	PatternUUID     = "<uuid>"
+	PatternURL      = "<url>"
+	PatternLongText = "<long text>"
+)
+
+// Patterns of the values recognized by meaning; emails reuse the PII scan's emailPattern
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	urlPattern  = regexp.MustCompile(`^(?i)((https?|ftp)://|www\.)[^\s/?#]+\.[^\s]*$`)
+)
+
+// ValuePattern is one pattern of a text column with how many values follow it
+type ValuePattern struct {
+	Pattern string  `json:"pattern"`
+	Count   int     `json:"count"`
+	Share   float64 `json:"share"`
+	Example string  `json:"example,omitempty"` // the first value of the pattern, withheld under differential privacy
+}
+
+// PatternProfile is the breakdown of a text column's values by their pattern, most common first
+type PatternProfile struct {
+	Patterns []ValuePattern `json:"patterns"` // at most maxValuePatterns
+	Distinct int            `json:"distinct_patterns"`
+	// Dominant is set when the most common pattern covers more than patternDominantShare of the values, and
+	// Inconsistent counts the values that do not follow it
+	Dominant     string `json:"dominant_pattern,omitempty"`
+	Inconsistent int    `json:"inconsistent_values,omitempty"`
+}
+
+// valuePattern returns the pattern of a single non-empty value: <email>, <uuid> or <url> when it is one, <long text>
+// above patternMaxLength characters, and otherwise its shape, with upper-case letters as A, lower-case letters as a
+// and digits as 9 while punctuation and spaces stay as they are, so "ABC-1234" becomes "AAA-9999"
+func valuePattern(value string) string {
+	switch {
+	case emailPattern.MatchString(value):
+		return PatternEmail
+	case uuidPattern.MatchString(value):
+		return PatternUUID
+	case urlPattern.MatchString(value):
+		return PatternURL
+	case utf8.RuneCountInString(value) > patternMaxLength:
+		return PatternLongText
+	}
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteByte('9')
+		case unicode.IsUpper(r):
+			b.WriteByte('A')
+		case unicode.IsLetter(r):
+			b.WriteByte('a')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// The ValuePatterns method is part of the CSVAnalyzer struct. It profiles the formats of a text column by reducing
+// every non-empty value to its pattern (see valuePattern) and counting them. Codes, IDs and phone numbers usually
+// follow one pattern, so when the most common pattern covers more than half the values the others are counted as
+// inconsistent: a "AA-9999" among "AAA-9999" codes or a lower-case "aaa-9999" is what a format check would trip
+// over later. Columns with at most one value are not profiled. Examples are withheld under differential privacy.
+// ValuePatterns returns the pattern profile of a text column, or nil when it does not apply
+func (ca *CSVAnalyzer) ValuePatterns(colIndex int) *PatternProfile {
+	if ca.columnType(colIndex) != TypeText {
+		return nil
+	}
+	counts := make(map[string]int)
+	examples := make(map[string]string)
+	total := 0
+	for _, row := range ca.dataset.Rows {
+		if colIndex >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[colIndex])
+		if value == "" {
+			continue
+		}
+		pattern := valuePattern(value)
+		if counts[pattern] == 0 {
+			examples[pattern] = value
+		}
+		counts[pattern]++
+		total++
+	}
+	if total < 2 {
+		return nil
+	}
+
+	// Ties go to the pattern that sorts first, which keeps the result stable.
+	patterns := make([]string, 0, len(counts))
+	for pattern := range counts {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if counts[patterns[i]] != counts[patterns[j]] {
+			return counts[patterns[i]] > counts[patterns[j]]
+		}
+		return patterns[i] < patterns[j]
+	})
+	profile := &PatternProfile{Distinct: len(patterns)}
+	for _, pattern := range patterns {
+		if len(profile.Patterns) == maxValuePatterns {
+			break
+		}
+		entry := ValuePattern{Pattern: pattern, Count: counts[pattern], Share: float64(counts[pattern]) / float64(total)}
+		if ca.privacy == nil {
+			entry.Example = examples[pattern]
+		}
+		profile.Patterns = append(profile.Patterns, entry)
+	}
+	if profile.Patterns[0].Share > patternDominantShare {
+		profile.Dominant = profile.Patterns[0].Pattern
+		profile.Inconsistent = total - profile.Patterns[0].Count
+	}
+	return profile
+}
+
+// printValuePatterns shows the most common patterns of every text column in the text report, and whether the
+// values stray from the column's format
+func (ca *CSVAnalyzer) printValuePatterns() {
+	printed := false
+	for colIndex, header := range ca.dataset.Headers {
+		profile := ca.ValuePatterns(colIndex)
+		if profile == nil {
+			continue
+		}
+		if !printed {
+			fmt.Println("\n\nValue Patterns (A upper-case, a lower-case, 9 digit):")
+			fmt.Println("-----------------------------------------------------")
+			printed = true
+		}
+		fmt.Printf("\n%s:\n", header)
+		// Free-form columns such as names have a pattern per value, which are not worth listing.
+		if profile.Dominant == "" {
+			fmt.Printf("  Format: free-form, %d patterns and none covers more than half the values\n", profile.Distinct)
+			continue
+		}
+		for _, pattern := range profile.Patterns {
+			line := fmt.Sprintf("  %s %6.1f%%  (%d)", fit(pattern.Pattern, 24), 100*pattern.Share, pattern.Count)
+			if pattern.Example != "" {
+				line += fmt.Sprintf(", e.g. %q", pattern.Example)
+			}
+			fmt.Println(line)
+		}
+		if hidden := profile.Distinct - len(profile.Patterns); hidden > 0 {
+			fmt.Printf("  ... and %d more patterns\n", hidden)
+		}
+		if profile.Inconsistent == 0 {
+			fmt.Printf("  Format: every value follows %s\n", profile.Dominant)
+		} else {
+			fmt.Printf("  Format: %d values do not follow %s\n", profile.Inconsistent, profile.Dominant)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kinds of personal data the PII scan recognizes
+const (
+	PIIEmail       = "email"
+	PIIPhone       = "phone"
+	PIICreditCard  = "credit_card"
+	PIINationalID  = "national_id"
+	PIIPersonName  = "person_name"   // recognized by column name only
+	PIIAddress     = "address"       // recognized by column name only
+	PIIDateOfBirth = "date_of_birth" // recognized by column name only
+)
+
+// Confidence levels of a PII finding
+const (
+	PIIHigh   = "high"   // most values match, or the name agrees with the values
+	PIIMedium = "medium" // many values match, though the name says nothing
+	PIILow    = "low"    // only the column name suggests personal data
+)
+
+// Share of a column's non-empty values that must match a pattern for the scan to flag it
+const (
+	piiMinShare  = 0.5
+	piiHighShare = 0.8
+)
+
+// Patterns of personal data found in cell values
+var (
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[A-Za-z]{2,}$`)
+	// Phone numbers: an optional country code, then groups of digits separated by spaces, dots or dashes.
+	phonePattern = regexp.MustCompile(`^(\+\d{1,3}[\s.-]?)?(\(\d{1,4}\)[\s.-]?)?\d{2,4}([\s.-]?\d{2,4}){1,4}$`)
+	cardPattern  = regexp.MustCompile(`^\d{4}([\s-]?\d{3,4}){2,3}([\s-]?\d{1,4})?$`)
+	// US social security numbers (never area 000, 666 or 900-999) and UK national insurance numbers.
+	ssnPattern  = regexp.MustCompile(`^(00[1-9]|0[1-9]\d|[1-578]\d\d|6[0-57-9]\d|66[0-57-9])-\d{2}-\d{4}$`)
+	ninoPattern = regexp.MustCompile(`^[A-CEGHJ-PR-TW-Z]{2}\s?\d{2}\s?\d{2}\s?\d{2}\s?[A-D]$`)
+)
+
+// piiNameHints are the normalized column names (lower case, letters and digits only) that point to personal data;
+// a hint ending in "*" matches any name containing it
+var piiNameHints = map[string][]string{
+	PIIEmail:       {"email*", "mail"},
+	PIIPhone:       {"phone*", "mobile*", "tel", "telephone*", "cell", "cellphone*", "fax*"},
+	PIICreditCard:  {"creditcard*", "cardnumber*", "ccnumber*", "ccnum", "pan"},
+	PIINationalID:  {"ssn*", "socialsecurity*", "nationalid*", "nino", "nationalinsurance*", "passport*", "taxid*", "tin"},
+	PIIPersonName:  {"name", "fullname*", "firstname*", "lastname*", "surname*", "givenname*", "familyname*", "customername*", "contactname*"},
+	PIIAddress:     {"address*", "street*", "homeaddress*"},
+	PIIDateOfBirth: {"dob", "dateofbirth*", "birthdate*", "birthday*"},
+}
+
+// PIIFinding flags a column that likely holds personal data
+type PIIFinding struct {
+	Column     string  `json:"column"`
+	Kind       string  `json:"kind"`
+	Confidence string  `json:"confidence"`
+	MatchShare float64 `json:"match_share"` // share of non-empty values matching the kind's pattern
+	NameMatch  bool    `json:"name_match"`  // whether the column name suggests the kind
+}
+
+// luhnValid reports whether a digit string passes the Luhn checksum used by payment card numbers
+func luhnValid(digits string) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// countDigits returns the number of decimal digits in s
+func countDigits(s string) int {
+	count := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			count++
+		}
+	}
+	return count
+}
+
+// The piiKind method is part of the CSVAnalyzer struct. It returns the kind of personal data a single value looks
+// like, or "" for none. Card numbers must also pass the Luhn check. Phone numbers need 7 to 15 digits and must not
+// parse as an ordinary number, so amounts such as 1234.5678 are not mistaken for them.
+// piiKind classifies a non-empty value by the personal data pattern it matches
+func (ca *CSVAnalyzer) piiKind(value string) string {
+	switch {
+	case emailPattern.MatchString(value):
+		return PIIEmail
+	case ssnPattern.MatchString(value) || ninoPattern.MatchString(strings.ToUpper(value)):
+		return PIINationalID
+	case cardPattern.MatchString(value):
+		digits := strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, value)
+		if len(digits) >= 13 && len(digits) <= 19 && luhnValid(digits) {
+			return PIICreditCard
+		}
+	}
+	if digits := countDigits(value); digits >= 7 && digits <= 15 && phonePattern.MatchString(value) {
+		if _, err := ca.parseNumber(value); err != nil {
+			return PIIPhone
+		}
+	}
+	return ""
+}
+
+// piiNameKind returns the kind of personal data a column name suggests, or "" for none
+func piiNameKind(header string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, header)
+	// Walks the kinds in a fixed order so a name matching two of them always gets the same one.
+	kinds := make([]string, 0, len(piiNameHints))
+	for kind := range piiNameHints {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		for _, hint := range piiNameHints[kind] {
+			if prefix, ok := strings.CutSuffix(hint, "*"); (ok && strings.Contains(normalized, prefix)) || normalized == hint {
+				return kind
+			}
+		}
+	}
+	return ""
+}
+
+// The ScanPII method is part of the CSVAnalyzer struct. It flags the columns that likely hold personal data, for a
+// compliance review before files are loaded into shared systems. Every non-empty value is matched against patterns
+// for e-mail addresses, phone numbers, payment card numbers and national IDs (US SSN, UK NINO), and the column name
+// is matched against common names of those and of person names, addresses and birth dates. A column is flagged when
+// at least half its values match one kind, or when its name suggests one; the confidence says which evidence was
+// found. Like k-anonymity the scan is for the data owner, so it runs on the exact values even under --dp-epsilon;
+// the findings contain no values.
+// ScanPII returns the columns likely to contain personal data, or nil when the scan was not requested
+func (ca *CSVAnalyzer) ScanPII() []PIIFinding {
+	if !ca.options.ScanPII {
+		return nil
+	}
+	findings := []PIIFinding{}
+	for colIndex, header := range ca.dataset.Headers {
+		// Counts the values matching each kind.
+		counts := make(map[string]int)
+		total := 0
+		for _, row := range ca.dataset.Rows {
+			if colIndex >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[colIndex])
+			if value == "" {
+				continue
+			}
+			total++
+			if kind := ca.piiKind(value); kind != "" {
+				counts[kind]++
+			}
+		}
+		finding := PIIFinding{Column: header}
+		for _, kind := range []string{PIIEmail, PIIPhone, PIICreditCard, PIINationalID} {
+			if total > 0 && counts[kind] > 0 && float64(counts[kind])/float64(total) > finding.MatchShare {
+				finding.Kind, finding.MatchShare = kind, float64(counts[kind])/float64(total)
+			}
+		}
+		nameKind := piiNameKind(header)
+
+		// Weighs the evidence from the values and the name.
+		switch {
+		case finding.MatchShare >= piiMinShare:
+			finding.NameMatch = nameKind == finding.Kind
+			finding.Confidence = PIIMedium
+			if finding.NameMatch || finding.MatchShare >= piiHighShare {
+				finding.Confidence = PIIHigh
+			}
+		case nameKind != "":
+			finding.Kind, finding.NameMatch, finding.Confidence = nameKind, true, PIILow
+			finding.MatchShare = 0
+			if total > 0 {
+				finding.MatchShare = float64(counts[nameKind]) / float64(total)
+			}
+		default:
+			continue
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// printPIIFindings shows the columns the PII scan flagged in the text report
+func printPIIFindings(findings []PIIFinding, columns int) {
+	if findings == nil {
+		return
+	}
+	fmt.Println("\n\nPII Scan:")
+	fmt.Println("---------")
+	if len(findings) == 0 {
+		fmt.Println("  No columns look like they contain personal data")
+		return
+	}
+	fmt.Printf("  %-24s %-14s %-10s %s\n", "Column", "Kind", "Confidence", "Evidence")
+	for _, finding := range findings {
+		var evidence []string
+		if finding.MatchShare > 0 {
+			evidence = append(evidence, fmt.Sprintf("%.1f%% of values match", 100*finding.MatchShare))
+		}
+		if finding.NameMatch {
+			evidence = append(evidence, "column name")
+		}
+		fmt.Printf("  %-24s %-14s %-10s %s\n", finding.Column, finding.Kind, finding.Confidence, strings.Join(evidence, ", "))
+	}
+	fmt.Printf("  %d of %d columns likely contain personal data; review them before sharing the file\n", len(findings), columns)
+}
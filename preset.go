@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Preset bundles the settings that suit data from one domain, so a useful profile needs no configuration first
+type Preset struct {
+	Description string
+	// NullValues are cell values treated as missing, compared case-insensitively
+	NullValues []string
+	// DateLayouts are tried after the built-in dateLayouts when parsing dates
+	DateLayouts []string
+	// Sections are the flags of the report sections the preset turns on
+	Sections []string
+	// Checks are warnings on numeric columns whose names suggest a valid range
+	Checks []PresetCheck
+}
+
+// PresetCheck is an alert rule applied to every numeric column whose lower-cased name contains one of Keywords
+type PresetCheck struct {
+	Keywords []string
+	Metric   string
+	Op       string
+	Value    float64
+}
+
+// presets are the presets selectable with --preset
+var presets = map[string]Preset{
+	"finance": {
+		Description: "transactions, prices and ledgers",
+		NullValues:  []string{"NA", "N/A", "#N/A", "-", "--", "null", "none"},
+		DateLayouts: []string{"2006-01", "Jan 2006", "January 2, 2006", "02-01-2006"},
+		Sections:    []string{"audit-parsing", "completeness-tiers"},
+		Checks: []PresetCheck{
+			{Keywords: []string{"price", "quantity", "qty", "volume", "fee"}, Metric: "min", Op: ">=", Value: 0},
+		},
+	},
+	"web-analytics": {
+		Description: "page views, sessions and campaign exports",
+		NullValues:  []string{"(not set)", "(none)", "(not provided)", "-", "null", "undefined"},
+		DateLayouts: []string{"02/Jan/2006:15:04:05 -0700", "2006-01-02 15:04:05 MST"},
+		Sections:    []string{"scan-pii", "co-missing"},
+		Checks: []PresetCheck{
+			{Keywords: []string{"session", "pageview", "page_view", "visit", "user", "click", "impression"}, Metric: "min", Op: ">=", Value: 0},
+			{Keywords: []string{"bounce", "conversion_rate", "ctr"}, Metric: "min", Op: ">=", Value: 0},
+			{Keywords: []string{"bounce", "conversion_rate", "ctr"}, Metric: "max", Op: "<=", Value: 100},
+		},
+	},
+	"iot": {
+		Description: "sensor and device telemetry",
+		NullValues:  []string{"NaN", "null", "ERR", "N/A", "-999", "-9999"},
+		DateLayouts: []string{"2006-01-02T15:04:05Z0700", "2006-01-02 15:04:05Z07:00", "2006/01/02 15:04:05"},
+		Sections:    []string{"missing-matrix", "co-missing", "completeness-tiers"},
+		Checks: []PresetCheck{
+			{Keywords: []string{"humidity", "battery"}, Metric: "min", Op: ">=", Value: 0},
+			{Keywords: []string{"humidity", "battery"}, Metric: "max", Op: "<=", Value: 100},
+			{Keywords: []string{"rssi"}, Metric: "max", Op: "<=", Value: 0},
+		},
+	},
+}
+
+// presetNames lists the presets in name order
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupPreset finds a preset by name; an empty name selects none
+func lookupPreset(name string) (*Preset, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if preset, ok := presets[strings.ToLower(name)]; ok {
+		return &preset, nil
+	}
+	return nil, fmt.Errorf("unknown preset %q (expected one of: %s)", name, strings.Join(presetNames(), ", "))
+}
+
+// presetSections maps the flag names presets may list in Sections to the options they turn on
+func (opts *Options) presetSections() map[string]*bool {
+	return map[string]*bool{
+		"audit-parsing":      &opts.AuditParsing,
+		"completeness-tiers": &opts.CompletenessTiers,
+		"scan-pii":           &opts.ScanPII,
+		"co-missing":         &opts.CoMissing,
+		"missing-matrix":     &opts.MissingMatrix,
+	}
+}
+
+// The applyPreset method is part of the Options struct. It turns on the report sections of the --preset, on top of
+// whatever was given on the command line. The preset's null values, date layouts and checks are not copied into the
+// options; the analyzer reads them from the preset when it loads the data.
+// applyPreset enables the report sections of the selected preset
+func (opts *Options) applyPreset() error {
+	preset, err := lookupPreset(opts.Preset)
+	if err != nil || preset == nil {
+		return err
+	}
+	sections := opts.presetSections()
+	for _, section := range preset.Sections {
+		*sections[section] = true
+	}
+	return nil
+}
+
+// The presetRules method is part of the CSVAnalyzer struct. It turns the checks of the --preset into warning-level
+// alert rules on the numeric columns whose names match them. The match is a guess from the column name, so a failing
+// preset check warns instead of failing the run.
+// presetRules returns the alert rules of the selected preset's checks
+func (ca *CSVAnalyzer) presetRules() []AlertRule {
+	preset, _ := lookupPreset(ca.options.Preset)
+	if preset == nil {
+		return nil
+	}
+	var rules []AlertRule
+	for colIndex, header := range ca.dataset.Headers {
+		if ca.columnType(colIndex) != TypeNumeric {
+			continue
+		}
+		name := strings.ToLower(header)
+		for _, check := range preset.Checks {
+			for _, keyword := range check.Keywords {
+				if strings.Contains(name, keyword) {
+					rule := AlertRule{
+						Name:   fmt.Sprintf("%s %s %s %s (%s preset)", header, check.Metric, check.Op, formatMetric(check.Value), strings.ToLower(ca.options.Preset)),
+						Column: header, Metric: check.Metric, Op: check.Op, Value: check.Value, Severity: SeverityWarn,
+					}
+					rule.normalize()
+					rules = append(rules, rule)
+					break
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// nullValueSet collects the --null-values and the preset's null values, lower-cased for case-insensitive matching
+func nullValueSet(opts Options) map[string]bool {
+	values := append([]string(nil), opts.NullValues...)
+	if preset, _ := lookupPreset(opts.Preset); preset != nil {
+		values = append(values, preset.NullValues...)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[strings.ToLower(strings.TrimSpace(value))] = true
+	}
+	return set
+}
+
+// blankNullValues empties every cell holding a null value, so all statistics count it as missing
+func (ca *CSVAnalyzer) blankNullValues() {
+	for _, row := range ca.dataset.Rows {
+		for i, cell := range row {
+			if ca.nullValues[strings.ToLower(strings.TrimSpace(cell))] {
+				row[i] = ""
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// numericStatsReleased is how many independently noised statistics a numeric column publishes
+// (count, missing, sum, median, std dev, min, max, geometric, harmonic and trimmed mean); the mean is derived from the noisy
+// sum and count.
+const numericStatsReleased = 10
+
+// privacyNoise adds Laplace noise to published aggregates so reports on sensitive data can be shared more widely.
+// Noise for a given statistic is derived from a per-run secret and the statistic's name, so the text and JSON
+// renderings of one run agree while every run draws fresh noise.
+type privacyNoise struct {
+	epsilon float64
+	secret  uint64
+}
+
+// newPrivacyNoise returns a noise source for the given privacy budget, or nil when epsilon is not positive
+func newPrivacyNoise(epsilon float64) *privacyNoise {
+	if epsilon <= 0 {
+		return nil
+	}
+	// Draws the per-run secret from the system's secure random source.
+	var b [8]byte
+	rand.Read(b[:])
+	return &privacyNoise{epsilon: epsilon, secret: binary.LittleEndian.Uint64(b[:])}
+}
+
+// laplace returns Laplace(0, sensitivity/epsilon) noise for the statistic identified by key
+func (p *privacyNoise) laplace(key string, sensitivity, epsilon float64) float64 {
+	// Hashes the key with the run secret into a uniform value in (-0.5, 0.5).
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, p.secret)
+	h.Write([]byte(key))
+	u := (float64(h.Sum64()>>11)+0.5)/float64(1<<53) - 0.5
+	// Inverts the Laplace CDF.
+	scale := sensitivity / epsilon
+	return -scale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+}
+
+// count returns a noisy, non-negative version of a count (sensitivity 1)
+func (p *privacyNoise) count(key string, n int, epsilon float64) int {
+	noisy := math.Round(float64(n) + p.laplace(key, 1, epsilon))
+	if noisy < 0 {
+		return 0
+	}
+	return int(noisy)
+}
+
+// The numeric method is part of the privacyNoise struct. It splits the privacy budget evenly across the statistics a
+// numeric column publishes and perturbs each one with noise scaled to how much a single row could change it: 1 for the
+// count and the missing count, the largest absolute value for the sum, the value range for the median, minimum and maximum, and range/sqrt(n)
+// for the standard deviation. The mean is recomputed from the noisy sum and count, which costs no extra budget. The
+// sensitivities use the observed range, which is a simplification; data with natural bounds gives stronger guarantees.
+// numeric returns a noised copy of a numeric column's statistics
+func (p *privacyNoise) numeric(stats ColumnStats) ColumnStats {
+	// Each released statistic receives an equal share of epsilon.
+	epsilon := p.epsilon / numericStatsReleased
+	valueRange := stats.Max - stats.Min
+	bound := math.Max(math.Abs(stats.Min), math.Abs(stats.Max))
+	key := "numeric/" + stats.Name + "/"
+
+	// Perturbs every published statistic.
+	noisy := stats
+	noisy.Count = p.count(key+"count", stats.Count, epsilon)
+	noisy.Missing = p.count(key+"missing", stats.Missing, epsilon)
+	noisy.Sum = stats.Sum + p.laplace(key+"sum", bound, epsilon)
+	noisy.Median = stats.Median + p.laplace(key+"median", valueRange, epsilon)
+	noisy.StdDev = math.Abs(stats.StdDev + p.laplace(key+"stddev", valueRange/math.Sqrt(float64(stats.Count)), epsilon))
+	noisy.Min = stats.Min + p.laplace(key+"min", valueRange, epsilon)
+	noisy.Max = stats.Max + p.laplace(key+"max", valueRange, epsilon)
+	// Keeps the published figures internally consistent.
+	if noisy.Min > noisy.Max {
+		noisy.Min, noisy.Max = noisy.Max, noisy.Min
+	}
+	noisy.Median = math.Max(noisy.Min, math.Min(noisy.Max, noisy.Median))
+	// The other means lie within the value range as well, so one row moves them by at most the range.
+	noisy.TrimmedMean = math.Max(noisy.Min, math.Min(noisy.Max, stats.TrimmedMean+p.laplace(key+"trimmed_mean", valueRange, epsilon)))
+	if stats.GeometricMean != nil {
+		mean := math.Max(noisy.Min, math.Min(noisy.Max, *stats.GeometricMean+p.laplace(key+"geometric_mean", valueRange, epsilon)))
+		noisy.GeometricMean = &mean
+	}
+	if stats.HarmonicMean != nil {
+		mean := math.Max(noisy.Min, math.Min(noisy.Max, *stats.HarmonicMean+p.laplace(key+"harmonic_mean", valueRange, epsilon)))
+		noisy.HarmonicMean = &mean
+	}
+	// The interval is centred on the exact mean, which would give it away.
+	noisy.MeanCI = nil
+	// The widest value and the most decimal places describe single records.
+	noisy.Format = nil
+	if noisy.Count > 0 {
+		noisy.Mean = noisy.Sum / float64(noisy.Count)
+	} else {
+		noisy.Mean = 0
+	}
+	return noisy
+}
+
+// text returns a noised copy of a text column's statistics; the raw value list is withheld entirely
+func (p *privacyNoise) text(stats TextColumnStats) TextColumnStats {
+	epsilon := p.epsilon / 2
+	key := "text/" + stats.Name + "/"
+	noisy := stats
+	noisy.TotalCount = p.count(key+"total", stats.TotalCount, epsilon)
+	noisy.UniqueCount = p.count(key+"unique", stats.UniqueCount, epsilon)
+	// Listing the distinct values would publish individual records verbatim.
+	noisy.UniqueValues = nil
+	// The lengths of the shortest and longest value describe single records too.
+	noisy.Strings = nil
+	return noisy
+}
+
+// boolean returns a noised copy of a boolean column's counts, with the ratio recomputed from them
+func (p *privacyNoise) boolean(stats BooleanColumnStats) BooleanColumnStats {
+	epsilon := p.epsilon / 3
+	key := "boolean/" + stats.Name + "/"
+	noisy := stats
+	noisy.TrueCount = p.count(key+"true", stats.TrueCount, epsilon)
+	noisy.FalseCount = p.count(key+"false", stats.FalseCount, epsilon)
+	noisy.EmptyCount = p.count(key+"empty", stats.EmptyCount, epsilon)
+	noisy.TrueRatio = 0
+	if total := noisy.TrueCount + noisy.FalseCount; total > 0 {
+		noisy.TrueRatio = float64(noisy.TrueCount) / float64(total)
+	}
+	return noisy
+}
+
+// publishedNumericStats applies privacy noise when it is enabled
+func (ca *CSVAnalyzer) publishedNumericStats(stats ColumnStats) ColumnStats {
+	if ca.privacy == nil {
+		return stats
+	}
+	return ca.privacy.numeric(stats)
+}
+
+// publishedTextStats applies privacy noise when it is enabled
+func (ca *CSVAnalyzer) publishedTextStats(stats TextColumnStats) TextColumnStats {
+	if ca.privacy == nil {
+		return stats
+	}
+	return ca.privacy.text(stats)
+}
+
+// publishedBooleanStats applies privacy noise when it is enabled
+func (ca *CSVAnalyzer) publishedBooleanStats(stats BooleanColumnStats) BooleanColumnStats {
+	if ca.privacy == nil {
+		return stats
+	}
+	return ca.privacy.boolean(stats)
+}
+
+// publishedRowCount returns the number of rows to show in reports, noised when privacy is enabled
+func (ca *CSVAnalyzer) publishedRowCount(key string, n int) int {
+	if ca.privacy == nil {
+		return n
+	}
+	return ca.privacy.count("rows/"+key, n, ca.privacy.epsilon)
+}
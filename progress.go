@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// progressInterval is the minimum time between two redraws of the progress bar
+	progressInterval = 100 * time.Millisecond
+	// progressWidth is the number of characters inside the bar's brackets
+	progressWidth = 30
+)
+
+// progressReader wraps a reader and draws a bytes-read progress bar with an ETA while it is consumed
+type progressReader struct {
+	reader   io.Reader
+	out      io.Writer
+	label    string
+	total    int64
+	read     int64
+	start    time.Time
+	lastDraw time.Time
+	drawn    bool
+}
+
+// newProgressReader creates a progress reader for a file of the given total size.
+// The first draw only happens after progressInterval, so small files load without any output.
+func newProgressReader(reader io.Reader, out io.Writer, label string, total int64) *progressReader {
+	now := time.Now()
+	return &progressReader{reader: reader, out: out, label: label, total: total, start: now, lastDraw: now}
+}
+
+// Read passes the read through and redraws the bar when enough time has passed
+func (p *progressReader) Read(b []byte) (int, error) {
+	// Reads from the underlying file and counts the bytes.
+	n, err := p.reader.Read(b)
+	p.read += int64(n)
+	// Throttles drawing so the terminal is not flooded.
+	if time.Since(p.lastDraw) >= progressInterval {
+		p.draw()
+	}
+	return n, err
+}
+
+// finish draws the final state of the bar and ends its line, if the bar was ever shown
+func (p *progressReader) finish() {
+	if !p.drawn {
+		return
+	}
+	p.draw()
+	fmt.Fprintln(p.out)
+}
+
+// draw renders the bar, percentage, byte counts and ETA on the current line
+func (p *progressReader) draw() {
+	p.lastDraw = time.Now()
+	p.drawn = true
+	// Works out the completed fraction, guarding against unknown or zero sizes.
+	fraction := 1.0
+	if p.total > 0 {
+		fraction = float64(p.read) / float64(p.total)
+		if fraction > 1 {
+			fraction = 1
+		}
+	}
+	// Builds the bar itself, e.g. "[=========>          ]".
+	filled := int(fraction * progressWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressWidth {
+		bar += ">" + strings.Repeat(" ", progressWidth-filled-1)
+	}
+	// Estimates the remaining time from the average throughput so far.
+	elapsed := time.Since(p.start)
+	eta := "ETA --"
+	if p.read > 0 && fraction < 1 {
+		remaining := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+		eta = "ETA " + remaining.Round(time.Second).String()
+	} else if fraction >= 1 {
+		eta = "done in " + elapsed.Round(time.Millisecond).String()
+	}
+	// Redraws the line in place using a carriage return.
+	fmt.Fprintf(p.out, "\r%s [%s] %3.0f%% %s/%s %s\033[K",
+		p.label, bar, fraction*100, formatBytes(p.read), formatBytes(p.total), eta)
+}
+
+// formatBytes renders a byte count with a binary unit suffix, e.g. 1536 -> "1.5KiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	// Finds the largest unit the value reaches.
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isTerminal reports whether the file is attached to a terminal (character device)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
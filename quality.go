@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Thresholds of the uniqueness part of the quality score
+const (
+	// qualityNearUniqueRatio is the distinct share above which a column is taken to be meant as unique
+	qualityNearUniqueRatio = 0.95
+	// qualityNearUniqueMinRows is how many values a column needs before near-uniqueness counts, since a handful of
+	// values are distinct by chance
+	qualityNearUniqueMinRows = 20
+	// qualityLowestShown is how many of the lowest-scoring columns the report header names
+	qualityLowestShown = 3
+)
+
+// identifierColumnPattern matches header names of columns expected to hold one distinct value per row: id, user_id,
+// CustomerID, order-key, uuid, ...
+var identifierColumnPattern = regexp.MustCompile(`(^|[^A-Za-z])(?i:id|key|uuid|guid)$|[a-z](Id|ID|Key|UUID)$`)
+
+// booleanSpellings groups the boolean tokens into the pairs a column is written with
+var booleanSpellings = map[string]string{
+	"true": "true/false", "false": "true/false",
+	"yes": "yes/no", "no": "yes/no",
+	"y": "y/n", "n": "y/n",
+	"t": "t/f", "f": "t/f",
+	"1": "1/0", "0": "1/0",
+}
+
+// ColumnQuality is the quality score of one column, 0 to 100, with the shares (0 to 1) it is made of
+type ColumnQuality struct {
+	Column       string   `json:"column"`
+	Score        float64  `json:"score"`
+	Completeness float64  `json:"completeness"`         // non-empty cells of all rows
+	Validity     float64  `json:"validity"`             // non-empty values readable as the column's type
+	Uniqueness   *float64 `json:"uniqueness,omitempty"` // distinct values, only for columns expected to be unique
+	Consistency  float64  `json:"consistency"`          // values written in the column's most common format
+}
+
+// QualityReport is the overall quality score of the dataset, 0 to 100, and the scores of its columns
+type QualityReport struct {
+	Score   float64         `json:"score"`
+	Columns []ColumnQuality `json:"columns"`
+}
+
+// The Quality method is part of the CSVAnalyzer struct. It scores every column from 0 to 100 as the mean of the
+// parts that apply to it: completeness, the share of rows with a value; validity, the share of values readable as
+// the detected type (numbers in a numeric column, dates in a date column, boolean tokens in a boolean one, and for a
+// text column the share of its dominant type when it is really a numeric or date column with strays); uniqueness,
+// the share of distinct values, only for columns expected to be unique because their name says so (id, CustomerID,
+// order_key) or because over 95% of at least 20 values are already distinct; and consistency, the share of values
+// written the way most are (the pattern of text and date values, "AAA-9999" or "9999-99-99"; plain rather than
+// scientific notation for numbers; one spelling such as yes/no in one case for booleans). Free text, with no
+// dominant pattern, is consistent by definition. The dataset score is the mean of the column scores, so one bad
+// column of ten costs at most ten points. Rows spilled to disk were scored a few columns at a time while loading.
+// Quality returns the quality scores
+func (ca *CSVAnalyzer) Quality() *QualityReport {
+	if ca.spilled != nil {
+		return newQualityReport(ca.spilled.quality)
+	}
+	columns := []ColumnQuality{}
+	for colIndex, header := range ca.dataset.Headers {
+		column := ca.columnQuality(colIndex)
+		column.Column = header
+		columns = append(columns, column)
+	}
+	return newQualityReport(columns)
+}
+
+// newQualityReport scores the dataset as the mean of its column scores
+func newQualityReport(columns []ColumnQuality) *QualityReport {
+	report := &QualityReport{Columns: append([]ColumnQuality{}, columns...)}
+	if len(columns) == 0 {
+		return report
+	}
+	total := 0.0
+	for _, column := range columns {
+		total += column.Score
+	}
+	report.Score = total / float64(len(columns))
+	return report
+}
+
+// columnQuality scores one column; the caller fills in its name
+func (ca *CSVAnalyzer) columnQuality(colIndex int) ColumnQuality {
+	colType := ca.columnType(colIndex)
+	var values []string
+	for _, row := range ca.dataset.Rows {
+		if colIndex < len(row) {
+			if value := strings.TrimSpace(row[colIndex]); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	decimal := '.'
+	if ca.numberLocale != nil {
+		decimal = ca.numberLocale.Decimal
+	}
+	quality := ColumnQuality{Completeness: 1, Validity: 1, Consistency: 1}
+	if len(ca.dataset.Rows) > 0 {
+		quality.Completeness = float64(len(values)) / float64(len(ca.dataset.Rows))
+	}
+	// A text column that is mostly numbers or dates is judged as one, its strays counting as invalid.
+	if mixture := ca.TypeMixture(colIndex); mixture != nil && mixture.Dominant != string(TypeText) {
+		colType = ColumnType(mixture.Dominant)
+	}
+	if len(values) > 0 {
+		// The format of the valid values is compared; invalid ones already cost validity.
+		valid := 0
+		shapes := make(map[string]int)
+		for _, value := range values {
+			switch colType {
+			case TypeNumeric:
+				if _, err := ca.parseNumber(value); err == nil {
+					valid++
+					shapes[fmt.Sprint(numberShape(value, decimal).exponent)]++
+				}
+			case TypeDate:
+				if _, ok := ca.parseDate(value); ok {
+					valid++
+					shapes[valuePattern(value)]++
+				}
+			case TypeBoolean:
+				if _, ok := parseBoolean(value); ok {
+					valid++
+					shapes[booleanSpelling(value)]++
+				}
+			default:
+				valid++
+				shapes[valuePattern(value)]++
+			}
+		}
+		quality.Validity = float64(valid) / float64(len(values))
+		dominant := 0
+		for _, count := range shapes {
+			if count > dominant {
+				dominant = count
+			}
+		}
+		// Text without a dominant pattern is free text, which has no format to stray from.
+		if share := float64(dominant) / float64(valid); valid > 0 && (colType != TypeText || share > patternDominantShare) {
+			quality.Consistency = share
+		}
+	}
+	parts := []float64{quality.Completeness, quality.Validity, quality.Consistency}
+	if cardinality := ca.calculateCardinality(colIndex); cardinality.NonEmpty > 0 {
+		expected := identifierColumnPattern.MatchString(ca.dataset.Headers[colIndex]) ||
+			(cardinality.NonEmpty >= qualityNearUniqueMinRows && cardinality.UniquenessRatio > qualityNearUniqueRatio)
+		if expected {
+			uniqueness := cardinality.UniquenessRatio
+			quality.Uniqueness = &uniqueness
+			parts = append(parts, uniqueness)
+		}
+	}
+	quality.Score = 100 * sum(parts) / float64(len(parts))
+	return quality
+}
+
+// booleanSpelling is the token pair and letter case a boolean value is written in, such as "yes/no lower"
+func booleanSpelling(value string) string {
+	letterCase := "mixed"
+	switch value {
+	case strings.ToLower(value):
+		letterCase = "lower"
+	case strings.ToUpper(value):
+		letterCase = "upper"
+	}
+	return booleanSpellings[strings.ToLower(value)] + " " + letterCase
+}
+
+// printQualitySummary shows the overall score and the lowest-scoring columns in the header of the text report
+func printQualitySummary(report *QualityReport) {
+	if report == nil || len(report.Columns) == 0 {
+		return
+	}
+	lowest := append([]ColumnQuality(nil), report.Columns...)
+	sort.SliceStable(lowest, func(i, j int) bool { return lowest[i].Score < lowest[j].Score })
+	var names []string
+	for _, column := range lowest {
+		if len(names) == qualityLowestShown || column.Score >= 100 {
+			break
+		}
+		names = append(names, fmt.Sprintf("%s %s", column.Column, localizedNumber(column.Score, 0)))
+	}
+	line := fmt.Sprintf("Quality: %s/100", localizedNumber(report.Score, 1))
+	if len(names) > 0 {
+		line += " (lowest: " + strings.Join(names, ", ") + ")"
+	}
+	fmt.Println(line)
+}
+
+// printQuality shows the score of every column and its parts in the text report
+func printQuality(report *QualityReport) {
+	if report == nil || len(report.Columns) == 0 {
+		return
+	}
+	fmt.Println("Data Quality (0-100; shares of complete, valid, unique and consistent values):")
+	fmt.Printf("  %-24s %6s %9s %7s %7s %11s\n", "Column", "Score", "Complete", "Valid", "Unique", "Consistent")
+	percent := func(share float64) string { return localizedNumber(100*share, 1) + "%" }
+	for _, column := range report.Columns {
+		unique := "-"
+		if column.Uniqueness != nil {
+			unique = percent(*column.Uniqueness)
+		}
+		fmt.Printf("  %s %6s %9s %7s %7s %11s\n", fit(column.Column, 24), localizedNumber(column.Score, 1),
+			percent(column.Completeness), percent(column.Validity), unique, percent(column.Consistency))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RangeCheck requires every value of a numeric column to lie within bounds; a nil bound is open
+type RangeCheck struct {
+	Column string
+	Min    *float64
+	Max    *float64
+}
+
+// RangeChecks is the list of --between checks. It implements flag.Value, so the flag may also be repeated.
+type RangeChecks []RangeCheck
+
+// String renders the checks in --between syntax
+func (r RangeChecks) String() string {
+	parts := make([]string, len(r))
+	for i, check := range r {
+		parts[i] = check.Column + "=" + formatBound(check.Min) + ".." + formatBound(check.Max)
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatBound renders an optional bound, empty when it is open
+func formatBound(bound *float64) string {
+	if bound == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*bound, 'g', -1, 64)
+}
+
+// Set parses a --between value such as "Rating=1..5,Age=0..120"; either bound may be left out ("Price=0..")
+func (r *RangeChecks) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Splits on the last equals sign so column names containing one still work.
+		sep := strings.LastIndex(entry, "=")
+		low, high, ok := strings.Cut(entry[sep+1:], "..")
+		if sep <= 0 || !ok {
+			return fmt.Errorf("invalid range check %q (expected Column=min..max)", entry)
+		}
+		check := RangeCheck{Column: strings.TrimSpace(entry[:sep])}
+		var err error
+		if check.Min, err = parseBound(low); err != nil {
+			return fmt.Errorf("invalid lower bound in %q: %v", entry, err)
+		}
+		if check.Max, err = parseBound(high); err != nil {
+			return fmt.Errorf("invalid upper bound in %q: %v", entry, err)
+		}
+		if check.Min == nil && check.Max == nil {
+			return fmt.Errorf("range check %q has no bounds", entry)
+		}
+		if check.Min != nil && check.Max != nil && *check.Min > *check.Max {
+			return fmt.Errorf("range check %q has its lower bound above its upper bound", entry)
+		}
+		*r = append(*r, check)
+	}
+	return nil
+}
+
+// parseBound parses one side of a range, returning nil for an empty (open) side
+func parseBound(text string) (*float64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	bound, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &bound, nil
+}
+
+// The quickCheckRules method is part of the Options struct. It turns the --nonnegative and --between shortcuts into
+// ordinary alert rules on the column minimum and maximum, and every --unique key into a rule on its duplicate_keys,
+// so they are evaluated, reported, suppressed and notified exactly like the rules of a configuration file. They are sanity constraints, so a failing check is critical and
+// makes the run exit with status 3.
+// quickCheckRules returns the alert rules of the built-in range checks
+func (opts *Options) quickCheckRules() []AlertRule {
+	var rules []AlertRule
+	for _, column := range opts.NonNegative {
+		rules = append(rules, AlertRule{Name: column + " nonnegative", Column: column, Metric: "min", Op: ">=", Value: 0, Severity: SeverityCritical})
+	}
+	for _, check := range opts.Between {
+		name := check.Column + " between " + formatBound(check.Min) + ".." + formatBound(check.Max)
+		if check.Min != nil {
+			rules = append(rules, AlertRule{Name: name, Column: check.Column, Metric: "min", Op: ">=", Value: *check.Min, Severity: SeverityCritical})
+		}
+		if check.Max != nil {
+			rules = append(rules, AlertRule{Name: name, Column: check.Column, Metric: "max", Op: "<=", Value: *check.Max, Severity: SeverityCritical})
+		}
+	}
+	for _, key := range opts.UniqueKeys {
+		columns := strings.Join(key, ",")
+		rules = append(rules, AlertRule{Name: columns + " unique", Column: columns, Metric: "duplicate_keys", Op: "==", Value: 0, Severity: SeverityCritical})
+	}
+	return rules
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxOrphanExamples is how many orphan key values a reference check lists
+const maxOrphanExamples = 10
+
+// KeyMapping names a foreign-key column of the checked file and the key column of the referenced file it points to
+type KeyMapping struct {
+	Column    string
+	Reference string
+}
+
+// KeyMappings is the list of --key values. It implements flag.Value, so the flag may be repeated or given several
+// comma-separated "column=reference" pairs.
+type KeyMappings []KeyMapping
+
+// String renders the mappings in --key syntax
+func (k KeyMappings) String() string {
+	parts := make([]string, len(k))
+	for i, mapping := range k {
+		parts[i] = mapping.Column + "=" + mapping.Reference
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a --key value such as "customer_id=id"
+func (k *KeyMappings) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		column, reference, ok := strings.Cut(entry, "=")
+		column, reference = strings.TrimSpace(column), strings.TrimSpace(reference)
+		if !ok || column == "" || reference == "" {
+			return fmt.Errorf("invalid key mapping %q (expected column=referenced_column)", entry)
+		}
+		*k = append(*k, KeyMapping{Column: column, Reference: reference})
+	}
+	return nil
+}
+
+// OrphanKey is a foreign-key value with no matching row in the referenced file
+type OrphanKey struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+	Rows  []int  `json:"rows"` // 1-based data row numbers of the checked file, at most maxAuditRows
+}
+
+// RefCheck is the outcome of checking one foreign-key column against its referenced key column
+type RefCheck struct {
+	Column         string      `json:"column"`
+	Reference      string      `json:"reference"`
+	CheckedValues  int         `json:"checked_values"` // non-empty foreign-key cells
+	OrphanRows     int         `json:"orphan_rows"`
+	DistinctOrphan int         `json:"distinct_orphans"`
+	Orphans        []OrphanKey `json:"orphans,omitempty"` // most frequent first, at most maxOrphanExamples
+}
+
+// The CheckReferences method is part of the CSVAnalyzer struct. It checks that every non-empty value of a
+// foreign-key column of this dataset appears in the key column of the referenced dataset, the way a database
+// enforces a foreign-key constraint. Values are compared as text after trimming surrounding whitespace, so "42" and
+// "42.0" do not match; empty cells are treated as a missing reference, not an orphan.
+// CheckReferences reports the foreign-key values of one column that the referenced dataset does not contain
+func (ca *CSVAnalyzer) CheckReferences(mapping KeyMapping, referenced *CSVAnalyzer) (RefCheck, error) {
+	colIndex := ca.columnIndex(mapping.Column)
+	if colIndex < 0 {
+		return RefCheck{}, fmt.Errorf("foreign-key column %q not found", mapping.Column)
+	}
+	refIndex := referenced.columnIndex(mapping.Reference)
+	if refIndex < 0 {
+		return RefCheck{}, fmt.Errorf("referenced column %q not found", mapping.Reference)
+	}
+	// Collects the keys of the referenced file.
+	endStep := ca.explain.step("build key set", mapping.Reference, len(referenced.dataset.Rows))
+	keys := make(map[string]bool)
+	for _, value := range referenced.extractUniqueValues(refIndex) {
+		keys[value] = true
+	}
+	endStep(len(keys))
+
+	// Groups the orphan cells by value, remembering the first rows of each.
+	check := RefCheck{Column: mapping.Column, Reference: mapping.Reference}
+	endStep = ca.explain.step("anti-join", mapping.Column+" = "+mapping.Reference, len(ca.dataset.Rows))
+	defer func() { endStep(check.OrphanRows) }()
+	orphans := make(map[string]*OrphanKey)
+	for rowIndex, row := range ca.dataset.Rows {
+		if colIndex >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[colIndex])
+		if value == "" {
+			continue
+		}
+		check.CheckedValues++
+		if keys[value] {
+			continue
+		}
+		check.OrphanRows++
+		orphan, ok := orphans[value]
+		if !ok {
+			orphan = &OrphanKey{Value: value}
+			orphans[value] = orphan
+		}
+		orphan.Count++
+		if len(orphan.Rows) < maxAuditRows {
+			orphan.Rows = append(orphan.Rows, rowIndex+1)
+		}
+	}
+	// Lists the most frequent values first, breaking ties by value so the output is stable.
+	check.DistinctOrphan = len(orphans)
+	for _, orphan := range orphans {
+		check.Orphans = append(check.Orphans, *orphan)
+	}
+	sort.Slice(check.Orphans, func(i, j int) bool {
+		if check.Orphans[i].Count != check.Orphans[j].Count {
+			return check.Orphans[i].Count > check.Orphans[j].Count
+		}
+		return check.Orphans[i].Value < check.Orphans[j].Value
+	})
+	if len(check.Orphans) > maxOrphanExamples {
+		check.Orphans = check.Orphans[:maxOrphanExamples]
+	}
+	return check, nil
+}
+
+// runCheckRefs implements `check-refs --key column=referenced_column <file> <referenced-file>`: it reports the
+// foreign-key values of the first file that are missing from the second and exits with status 3 when there are any
+func runCheckRefs(args []string) {
+	var keys KeyMappings
+	fs := flag.NewFlagSet("check-refs", flag.ContinueOnError)
+	fs.Var(&keys, "key", "foreign-key `mapping` column=referenced_column, e.g. \"customer_id=id\" (repeatable)")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	explain := fs.Bool("explain", false, "print the steps of the check with their row counts and timings on stderr")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . check-refs --key <column=referenced_column> [--json] [--explain] <csv-file> <referenced-csv-file>")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 2 || len(keys) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	// Loads both files; the progress bar would interleave, so it stays off.
+	var analyzers [2]*CSVAnalyzer
+	// Both files share one plan, so the steps of loading them and joining them are listed together.
+	plan := newExplainPlan(*explain)
+	for i, path := range positional {
+		analyzers[i] = NewCSVAnalyzerWithOptions(Options{TypeDetection: TypeDetection{Strategy: DetectHead, Rows: typeDetectionRows}})
+		analyzers[i].explain = plan
+		if err := analyzers[i].LoadCSV(path); err != nil {
+			log.Fatal("Error loading CSV:", err)
+		}
+	}
+	var checks []RefCheck
+	orphaned := false
+	for _, mapping := range keys {
+		check, err := analyzers[0].CheckReferences(mapping, analyzers[1])
+		if err != nil {
+			log.Fatal("Error checking references:", err)
+		}
+		checks = append(checks, check)
+		orphaned = orphaned || check.OrphanRows > 0
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(checks)
+	} else {
+		printRefChecks(positional[0], positional[1], checks)
+	}
+	plan.report(os.Stderr)
+	// Orphans fail the run like a critical alert does.
+	if orphaned {
+		os.Exit(exitCriticalAlert)
+	}
+}
+
+// printRefChecks shows the outcome of every reference check
+func printRefChecks(file, referenced string, checks []RefCheck) {
+	for _, check := range checks {
+		fmt.Printf("%s.%s -> %s.%s: ", file, check.Column, referenced, check.Reference)
+		if check.OrphanRows == 0 {
+			fmt.Printf("OK (%d values checked)\n", check.CheckedValues)
+			continue
+		}
+		fmt.Printf("%d of %d values are orphans (%d distinct)\n", check.OrphanRows, check.CheckedValues, check.DistinctOrphan)
+		for _, orphan := range check.Orphans {
+			fmt.Printf("  %q: %d rows %v\n", orphan.Value, orphan.Count, orphan.Rows)
+		}
+		if check.DistinctOrphan > len(check.Orphans) {
+			fmt.Printf("  ... and %d more values\n", check.DistinctOrphan-len(check.Orphans))
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Output formats accepted by --format
+const (
+	FormatText      = "text"
+	FormatJSON      = "json"
+	FormatHTMLCards = "html-cards"
+	FormatJSONCards = "json-cards"
+	FormatLong      = "long"
+	FormatMsgpack   = "msgpack"
+	FormatXLSX      = "xlsx"
+)
+
+// outputFormats lists every supported --format value, in the order shown in help text
+var outputFormats = []string{FormatText, FormatJSON, FormatHTMLCards, FormatJSONCards, FormatLong, FormatMsgpack, FormatXLSX}
+
+// Report is the structured form of an analysis, shared by all machine-readable output formats
+type Report struct {
+	Analyzer     BuildInfo           `json:"analyzer"`
+	Rows         int                 `json:"rows"`
+	ColumnCount  int                 `json:"column_count"`
+	Sources      []SourceFile        `json:"sources,omitempty"`
+	Exact        bool                `json:"exact"`
+	Sampling     *SamplingInfo       `json:"sampling,omitempty"`
+	RowRange     *RowRangeInfo       `json:"row_range,omitempty"`
+	Privacy      *PrivacyInfo        `json:"privacy,omitempty"`
+	Spill        *SpillInfo          `json:"spill,omitempty"`
+	Quality      *QualityReport      `json:"quality,omitempty"`
+	Warnings     []DataWarning       `json:"warnings,omitempty"`
+	Columns      []ColumnReport      `json:"columns"`
+	PrimaryKeys  []string            `json:"primary_key_candidates,omitempty"`
+	Clusters     []ColumnCluster     `json:"column_clusters,omitempty"`
+	Correlations *CorrelationMatrix  `json:"correlations,omitempty"`
+	Missingness  *MissingnessMatrix  `json:"missingness_matrix,omitempty"`
+	CoMissing    []CoMissingPair     `json:"co_missing,omitempty"`
+	Completeness *CompletenessReport `json:"completeness_tiers,omitempty"`
+	Bootstrap    *BootstrapReport    `json:"bootstrap,omitempty"`
+	ParseAudit   []ParseAudit        `json:"parse_audit,omitempty"`
+	Rolling      *RollingReport      `json:"rolling,omitempty"`
+	KAnonymity   *KAnonymityReport   `json:"k_anonymity,omitempty"`
+	PII          []PIIFinding        `json:"pii,omitempty"`
+	GroupBy      *GroupByReport      `json:"group_by,omitempty"`
+	TopRows      *TopRowsReport      `json:"top_rows,omitempty"`
+	Periods      *PeriodComparison   `json:"period_comparison,omitempty"`
+	Keys         []KeyCheck          `json:"unique_keys,omitempty"`
+	Rules        []RuleResult        `json:"rules,omitempty"`
+	Alerts       []AlertResult       `json:"alerts,omitempty"`
+}
+
+// PrivacyInfo records that differential-privacy noise was applied to the published figures
+type PrivacyInfo struct {
+	Mechanism string  `json:"mechanism"`
+	Epsilon   float64 `json:"epsilon"`
+}
+
+// ColumnReport holds everything the analyzer knows about a single column
+type ColumnReport struct {
+	Name        string              `json:"name"`
+	Type        string              `json:"type"`
+	Description string              `json:"description,omitempty"`
+	Unit        string              `json:"unit,omitempty"`
+	Numeric     *ColumnStats        `json:"numeric,omitempty"`
+	Text        *TextColumnStats    `json:"text,omitempty"`
+	Boolean     *BooleanColumnStats `json:"boolean,omitempty"`
+	Date        *DateColumnStats    `json:"date,omitempty"`
+	Cardinality *ColumnCardinality  `json:"cardinality,omitempty"`
+	Mixture     *TypeMixture        `json:"type_mixture,omitempty"`
+	Patterns    *PatternProfile     `json:"patterns,omitempty"`
+	Embedded    *EmbeddedCharacters `json:"embedded_characters,omitempty"`
+	Normality   *NormalityTest      `json:"normality,omitempty"`
+	Histogram   *Histogram          `json:"histogram,omitempty"`
+}
+
+// The BuildReport method is part of the CSVAnalyzer struct. It gathers the dataset shape, the source files and the
+// per-column statistics into a single Report value, with columns in header order. Every non-text output format is
+// rendered from this structure so they always agree with each other.
+// BuildReport assembles the structured analysis report
+func (ca *CSVAnalyzer) BuildReport() Report {
+	// A dataset spilled to disk was profiled while loading.
+	if ca.spilled != nil {
+		return ca.buildSpilledReport()
+	}
+	// Fills in the dataset-level information.
+	report := Report{
+		Analyzer:    currentBuildInfo(),
+		Rows:        ca.publishedRowCount("dataset", len(ca.dataset.Rows)),
+		ColumnCount: len(ca.dataset.Headers),
+		Sources:     ca.dataset.Sources,
+		Exact:       ca.dataset.Sampling == nil && ca.privacy == nil,
+		Sampling:    ca.dataset.Sampling,
+		RowRange:    ca.dataset.RowRange,
+	}
+	// Noises the per-file row counts too, and records the privacy settings.
+	if ca.privacy != nil {
+		report.Privacy = &PrivacyInfo{Mechanism: "laplace", Epsilon: ca.privacy.epsilon}
+		report.Sources = nil
+		for _, source := range ca.dataset.Sources {
+			report.Sources = append(report.Sources, SourceFile{Path: source.Path, Rows: ca.publishedRowCount(source.Path, source.Rows)})
+		}
+	}
+	// Scores the quality of every column and of the dataset.
+	report.Quality = ca.Quality()
+	// Lists the data problems that were worked around.
+	report.Warnings = ca.DataWarnings()
+	// Profiles the distinct values of every column (withheld under differential privacy).
+	cardinality := ca.Cardinality()
+	report.PrimaryKeys = ca.primaryKeyCandidates(cardinality)
+	// Adds one entry per column, attaching whichever statistics apply to its type.
+	for colIndex, header := range ca.dataset.Headers {
+		column := ColumnReport{Name: header, Type: ca.columnTypeName(colIndex)}
+		// Copies the data-dictionary annotation onto the column, if there is one.
+		annotation := ca.annotation(header)
+		column.Description, column.Unit = annotation.Description, annotation.Unit
+		// Published statistics carry privacy noise when it is enabled.
+		if stats, ok := ca.calculateColumnStats(colIndex); ok {
+			stats = ca.publishedNumericStats(stats)
+			column.Numeric = &stats
+		}
+		if stats, ok := ca.calculateTextColumnStats(colIndex); ok {
+			stats = ca.publishedTextStats(stats)
+			column.Text = &stats
+		}
+		if stats, ok := ca.calculateBooleanColumnStats(colIndex); ok {
+			stats = ca.publishedBooleanStats(stats)
+			column.Boolean = &stats
+		}
+		if stats, ok := ca.calculateDateColumnStats(colIndex); ok {
+			column.Date = &stats
+		}
+		if cardinality != nil {
+			column.Cardinality = &cardinality[colIndex]
+		}
+		// Breaks down text columns holding values of several types; the cell values are withheld under privacy.
+		if mixture := ca.TypeMixture(colIndex); mixture != nil {
+			if ca.privacy != nil {
+				mixture.Examples = nil
+			}
+			column.Mixture = mixture
+		}
+		// Profiles the formats of text columns, so codes that stray from the usual shape stand out.
+		column.Patterns = ca.ValuePatterns(colIndex)
+		// Counts the line breaks, quotes and delimiters inside cells, which trip up naive parsers downstream.
+		column.Embedded = ca.EmbeddedCharacters(colIndex)
+		// Tests numeric columns for normality (withheld under differential privacy).
+		column.Normality = ca.Normality(colIndex)
+		// Adds the bin data of numeric columns when --histogram-bins was given (validated, so it cannot fail).
+		if ca.options.HistogramBins > 0 {
+			column.Histogram, _ = ca.Histogram(colIndex, ca.options.HistogramBins)
+		}
+		report.Columns = append(report.Columns, column)
+	}
+	// Adds where the missing values are when the matrix was requested.
+	report.Missingness = ca.MissingnessMatrix()
+	// Adds the columns that are missing together when that was requested.
+	report.CoMissing = ca.CoMissing()
+	// Adds the completeness tiers of the rows when they were requested.
+	report.Completeness = ca.CompletenessTiers()
+	// Adds the groups of similar columns when clustering was requested.
+	report.Clusters = ca.ColumnClusters()
+	// Adds the correlation matrix when it was requested.
+	if ca.options.Correlations {
+		report.Correlations = ca.Correlations()
+	}
+	// Adds the resampled confidence intervals when --bootstrap was given.
+	report.Bootstrap = ca.Bootstrap()
+	// Adds the parsing audit when it was requested.
+	if ca.options.AuditParsing {
+		report.ParseAudit = ca.AuditNumericParsing()
+	}
+	// Adds the rolling series when a window was requested.
+	report.Rolling = ca.RollingStats()
+	// Adds the k-anonymity assessment when quasi-identifiers were given.
+	report.KAnonymity = ca.KAnonymity()
+	// Adds the columns likely to hold personal data when the scan was requested.
+	report.PII = ca.ScanPII()
+	// Adds the per-group summaries when a grouping column was given.
+	report.GroupBy = ca.GroupBy()
+	// Adds the top and bottom rows when --top was given.
+	report.TopRows = ca.TopRows()
+	// Adds the before/after effect sizes when a split date was given.
+	report.Periods = ca.ComparePeriods()
+	// Records the outcome of the key checks and of every alert rule, passed or failed.
+	report.Keys = ca.KeyChecks()
+	report.Rules = ca.RuleResults()
+	report.Alerts = ca.EvaluateAlerts()
+	// Returns the assembled report.
+	return report
+}
+
+// columnTypeName returns the display name of a column's detected type
+func (ca *CSVAnalyzer) columnTypeName(colIndex int) string {
+	return string(ca.columnType(colIndex))
+}
+
+// WriteJSONReport writes the full report as indented JSON
+func (ca *CSVAnalyzer) WriteJSONReport(w io.Writer) error {
+	// Uses an encoder with indentation so the output stays readable.
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(ca.BuildReport()); err != nil {
+		return fmt.Errorf("error encoding JSON report: %v", err)
+	}
+	return nil
+}
+
+// validFormat reports whether name is one of the supported output formats
+func validFormat(name string) bool {
+	for _, format := range outputFormats {
+		if format == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// retentionSweepInterval is how often serve mode prunes stored data that has aged past the retention period
+const retentionSweepInterval = time.Hour
+
+// Retention limits how much a tenant store keeps; zero values keep everything
+type Retention struct {
+	// KeepRuns is the number of most recent reports kept per dataset
+	KeepRuns int
+	// KeepDays is the age in days after which reports and uploads are deleted
+	KeepDays int
+}
+
+// enabled reports whether the policy prunes anything
+func (r Retention) enabled() bool {
+	return r.KeepRuns > 0 || r.KeepDays > 0
+}
+
+// String describes the policy for the startup log
+func (r Retention) String() string {
+	var parts []string
+	if r.KeepRuns > 0 {
+		parts = append(parts, fmt.Sprintf("last %d runs", r.KeepRuns))
+	}
+	if r.KeepDays > 0 {
+		parts = append(parts, fmt.Sprintf("%d days", r.KeepDays))
+	}
+	if len(parts) == 0 {
+		return "everything"
+	}
+	return strings.Join(parts, ", at most ")
+}
+
+// The pruneHistory method is part of the tenantStore struct. It applies the retention policy to the history of one
+// dataset of a tenant: reports beyond the KeepRuns most recent ones and reports older than KeepDays are deleted.
+// History files are named by their UTC time, so their names sort chronologically and no report needs to be opened.
+// pruneHistory deletes the reports of a dataset that the retention policy no longer keeps and returns their number
+func (t tenantStore) pruneHistory(tenant, dataset string, now time.Time) (int, error) {
+	dir := filepath.Join(t.dir, tenant, "history", dataset)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading history: %v", err)
+	}
+	var runs []string
+	for _, file := range files {
+		if _, err := time.Parse(historyTimeLayout, strings.TrimSuffix(file.Name(), ".json")); err == nil {
+			runs = append(runs, file.Name())
+		}
+	}
+	// Newest first, so the runs to keep come before the ones to delete.
+	sort.Sort(sort.Reverse(sort.StringSlice(runs)))
+	cutoff := now.AddDate(0, 0, -t.retention.KeepDays)
+	removed := 0
+	for i, name := range runs {
+		at, _ := time.Parse(historyTimeLayout, strings.TrimSuffix(name, ".json"))
+		tooMany := t.retention.KeepRuns > 0 && i >= t.retention.KeepRuns
+		tooOld := t.retention.KeepDays > 0 && at.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return removed, fmt.Errorf("error pruning history: %v", err)
+		}
+		removed++
+	}
+	// Drops the directory of a dataset whose whole history has expired.
+	if removed == len(files) {
+		os.Remove(dir)
+	}
+	return removed, nil
+}
+
+// The prune method is part of the tenantStore struct. It applies the retention policy to everything in the store:
+// the history of every dataset of every tenant, and stored uploads that have not been replaced within KeepDays.
+// Serve mode runs it at startup and then every retentionSweepInterval, because age limits expire data even when no
+// new uploads arrive. It returns the number of files deleted.
+// prune deletes all stored data the retention policy no longer keeps
+func (t tenantStore) prune(now time.Time) (int, error) {
+	if !t.retention.enabled() {
+		return 0, nil
+	}
+	tenants, err := os.ReadDir(t.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading data directory: %v", err)
+	}
+	removed := 0
+	for _, tenant := range tenants {
+		if !tenant.IsDir() {
+			continue
+		}
+		datasets, _ := os.ReadDir(filepath.Join(t.dir, tenant.Name(), "history"))
+		for _, dataset := range datasets {
+			count, err := t.pruneHistory(tenant.Name(), dataset.Name(), now)
+			removed += count
+			if err != nil {
+				return removed, err
+			}
+		}
+		if t.retention.KeepDays == 0 {
+			continue
+		}
+		// An upload is replaced by every new analysis, so its modification time is its dataset's last run.
+		uploads, _ := os.ReadDir(filepath.Join(t.dir, tenant.Name(), "datasets"))
+		cutoff := now.AddDate(0, 0, -t.retention.KeepDays)
+		for _, upload := range uploads {
+			info, err := upload.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(t.dir, tenant.Name(), "datasets", upload.Name())); err != nil {
+				return removed, fmt.Errorf("error pruning upload: %v", err)
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// sweep prunes the store now and then every retentionSweepInterval, logging what was deleted
+func (t tenantStore) sweep() {
+	for {
+		if removed, err := t.prune(time.Now()); err != nil {
+			log.Printf("Retention: %v", err)
+		} else if removed > 0 {
+			log.Printf("Retention: deleted %d expired files", removed)
+		}
+		time.Sleep(retentionSweepInterval)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rollingPreviewPoints is how many of the most recent points the text report shows per column
+const rollingPreviewPoints = 10
+
+// RollingWindow is the size of a rolling window: either a number of rows or a span of time.
+// It implements flag.Value so --rolling accepts "7" (rows), "7d" (days) or a Go duration like "36h".
+type RollingWindow struct {
+	Rows int
+	Span time.Duration
+}
+
+// String renders the window in --rolling syntax
+func (w RollingWindow) String() string {
+	switch {
+	case w.Rows > 0:
+		return strconv.Itoa(w.Rows)
+	case w.Span > 0 && w.Span%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", w.Span/(24*time.Hour))
+	case w.Span > 0:
+		return w.Span.String()
+	}
+	return ""
+}
+
+// Set parses a --rolling value
+func (w *RollingWindow) Set(value string) error {
+	value = strings.TrimSpace(value)
+	*w = RollingWindow{}
+	// A plain number is a count of rows.
+	if rows, err := strconv.Atoi(value); err == nil {
+		if rows < 1 {
+			return fmt.Errorf("rolling window must be at least 1 row")
+		}
+		w.Rows = rows
+		return nil
+	}
+	// Days are not a Go duration unit, so they are handled separately.
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid rolling window %q", value)
+		}
+		w.Span = time.Duration(n * float64(24*time.Hour))
+		return nil
+	}
+	span, err := time.ParseDuration(value)
+	if err != nil || span <= 0 {
+		return fmt.Errorf("invalid rolling window %q (expected rows like 7, or a span like 7d or 12h)", value)
+	}
+	w.Span = span
+	return nil
+}
+
+// enabled reports whether a window was configured
+func (w RollingWindow) enabled() bool {
+	return w.Rows > 0 || w.Span > 0
+}
+
+// RollingPoint holds the windowed statistics ending at one row
+type RollingPoint struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"` // numeric values inside the window
+	Sum   float64   `json:"sum"`
+	Mean  float64   `json:"mean"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+}
+
+// RollingSeries is the rolling statistics of one numeric column, in time order
+type RollingSeries struct {
+	Column string         `json:"column"`
+	Points []RollingPoint `json:"points"`
+}
+
+// RollingReport holds the rolling statistics of every numeric column
+type RollingReport struct {
+	On          string          `json:"on"`
+	Window      string          `json:"window"`
+	SkippedRows int             `json:"skipped_rows"` // rows whose time value could not be parsed
+	Series      []RollingSeries `json:"series"`
+}
+
+// The RollingStats method is part of the CSVAnalyzer struct. It orders the rows by the --on time column and, for every
+// numeric column, computes the count, sum, mean, minimum and maximum over a window ending at each row. Row windows
+// hold the current row and the ones before it; time windows hold every row whose time is within the span before the
+// current row's, so irregular data is handled correctly. Windows at the start of the data are simply shorter. Rows
+// whose time cannot be parsed are left out and counted; missing numeric values are skipped inside the window.
+// RollingStats returns the rolling statistics, or nil when no window was configured
+func (ca *CSVAnalyzer) RollingStats() *RollingReport {
+	window := ca.options.Rolling
+	if !window.enabled() {
+		return nil
+	}
+	// LoadCSV has already checked that the time column exists.
+	onIndex := ca.columnIndex(ca.options.RollingOn)
+	report := &RollingReport{On: ca.options.RollingOn, Window: window.String()}
+	endSort := ca.explain.stepOnce("sort by time", ca.options.RollingOn, len(ca.dataset.Rows))
+
+	// Pairs every row with its parsed time, dropping rows without one.
+	type timedRow struct {
+		time time.Time
+		row  []string
+	}
+	var rows []timedRow
+	for _, row := range ca.dataset.Rows {
+		if onIndex < len(row) {
+			if t, ok := ca.parseDate(row[onIndex]); ok {
+				rows = append(rows, timedRow{time: t, row: row})
+				continue
+			}
+		}
+		report.SkippedRows++
+	}
+	// A stable sort keeps the file order of rows sharing a timestamp.
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].time.Before(rows[j].time) })
+	endSort(len(rows))
+	endWindow := ca.explain.stepOnce("rolling window", window.String(), len(rows))
+	defer func() { endWindow(len(rows)) }()
+
+	for colIndex, header := range ca.dataset.Headers {
+		if !ca.dataset.NumericCols[colIndex] || colIndex == onIndex {
+			continue
+		}
+		// Parses the column once; NaN marks a missing or non-numeric value.
+		values := make([]float64, len(rows))
+		for i, r := range rows {
+			values[i] = math.NaN()
+			if colIndex < len(r.row) {
+				if v, err := ca.parseNumber(strings.TrimSpace(r.row[colIndex])); err == nil {
+					values[i] = v
+				}
+			}
+		}
+
+		// Slides the window along the rows, keeping a running sum and monotonic queues of candidate minima and maxima.
+		series := RollingSeries{Column: header}
+		start, count, total := 0, 0, 0.0
+		var minQueue, maxQueue []int
+		for i, r := range rows {
+			if v := values[i]; !math.IsNaN(v) {
+				count++
+				total += v
+				for len(minQueue) > 0 && values[minQueue[len(minQueue)-1]] >= v {
+					minQueue = minQueue[:len(minQueue)-1]
+				}
+				minQueue = append(minQueue, i)
+				for len(maxQueue) > 0 && values[maxQueue[len(maxQueue)-1]] <= v {
+					maxQueue = maxQueue[:len(maxQueue)-1]
+				}
+				maxQueue = append(maxQueue, i)
+			}
+			// Drops rows that have fallen out of the window.
+			for start < i && !window.contains(start, i, rows[start].time, r.time) {
+				if v := values[start]; !math.IsNaN(v) {
+					count--
+					total -= v
+				}
+				start++
+			}
+			for len(minQueue) > 0 && minQueue[0] < start {
+				minQueue = minQueue[1:]
+			}
+			for len(maxQueue) > 0 && maxQueue[0] < start {
+				maxQueue = maxQueue[1:]
+			}
+
+			point := RollingPoint{Time: r.time, Count: count, Sum: total}
+			if count > 0 {
+				point.Mean = total / float64(count)
+				point.Min = values[minQueue[0]]
+				point.Max = values[maxQueue[0]]
+			}
+			series.Points = append(series.Points, point)
+		}
+		report.Series = append(report.Series, series)
+	}
+	return report
+}
+
+// contains reports whether the row at position first (with time from) is still inside the window ending at position last
+func (w RollingWindow) contains(first, last int, from, to time.Time) bool {
+	if w.Rows > 0 {
+		return last-first < w.Rows
+	}
+	return to.Sub(from) < w.Span
+}
+
+// printRollingStats shows the most recent rolling points of every numeric column in the text report
+func printRollingStats(report *RollingReport) {
+	if report == nil || len(report.Series) == 0 {
+		return
+	}
+	fmt.Printf("\n\nRolling Statistics (window %s on %s):\n", report.Window, report.On)
+	fmt.Println("--------------------------------------")
+	if report.SkippedRows > 0 {
+		fmt.Printf("  %d rows skipped (no valid %s value)\n", report.SkippedRows, report.On)
+	}
+	for _, series := range report.Series {
+		fmt.Printf("\n%s:\n", series.Column)
+		// Only the latest points fit in a text report; the JSON report has all of them.
+		points := series.Points
+		if len(points) > rollingPreviewPoints {
+			fmt.Printf("  (last %d of %d points; use --format json for the full series)\n", rollingPreviewPoints, len(points))
+			points = points[len(points)-rollingPreviewPoints:]
+		}
+		fmt.Printf("  %-20s %12s %12s %12s %12s\n", "Time", "Mean", "Sum", "Min", "Max")
+		for _, point := range points {
+			fmt.Printf("  %-20s %12.3f %12.3f %12.3f %12.3f\n", formatDate(point.Time), point.Mean, point.Sum, point.Min, point.Max)
+		}
+	}
+}
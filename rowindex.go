@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rowIndexStride is the number of rows between two offsets of a row index; reading from an offset skips at most
+// this many rows to reach the first row of a range
+const rowIndexStride = 1000
+
+// rowIndexSuffix is appended to the name of a CSV file to name its row index
+const rowIndexSuffix = ".rowidx"
+
+// errRowRangeDone stops reading a file once the last row of --rows was read
+var errRowRangeDone = errors.New("row range complete")
+
+// RowSelection selects the data rows to load, numbered from 1 across all input files. It implements flag.Value so
+// --rows accepts "100:200", ":100" (the first 100 rows), "5000:" (row 5000 to the end) or "-100" (the last 100).
+type RowSelection struct {
+	First int // 0 when the range starts at the first row
+	Last  int // 0 when the range runs to the last row
+	Tail  int // set instead of First and Last for the last rows
+}
+
+// String renders the range in --rows syntax
+func (r RowSelection) String() string {
+	switch {
+	case r.Tail > 0:
+		return "-" + strconv.Itoa(r.Tail)
+	case !r.enabled():
+		return ""
+	}
+	text := ":"
+	if r.First > 0 {
+		text = strconv.Itoa(r.First) + text
+	}
+	if r.Last > 0 {
+		text += strconv.Itoa(r.Last)
+	}
+	return text
+}
+
+// Set parses a --rows value
+func (r *RowSelection) Set(value string) error {
+	value = strings.TrimSpace(value)
+	*r = RowSelection{}
+	if tail, ok := strings.CutPrefix(value, "-"); ok {
+		n, err := strconv.Atoi(tail)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid row range %q (expected -N with N at least 1)", value)
+		}
+		r.Tail = n
+		return nil
+	}
+	first, last, found := strings.Cut(value, ":")
+	// A single row number selects just that row.
+	if !found {
+		last = first
+	}
+	for _, bound := range []struct {
+		text   string
+		target *int
+	}{{first, &r.First}, {last, &r.Last}} {
+		if bound.text == "" {
+			continue
+		}
+		n, err := strconv.Atoi(bound.text)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid row range %q (expected START:END with row numbers from 1, :END, START: or -N)", value)
+		}
+		*bound.target = n
+	}
+	if r.Last > 0 && r.Last < r.First {
+		return fmt.Errorf("invalid row range %q: the last row comes before the first", value)
+	}
+	if !r.enabled() {
+		return fmt.Errorf("invalid row range %q: give at least one row number", value)
+	}
+	return nil
+}
+
+// enabled reports whether a range was configured
+func (r RowSelection) enabled() bool {
+	return r.First > 0 || r.Last > 0 || r.Tail > 0
+}
+
+// RowRangeInfo describes the rows --rows loaded, for the reports
+type RowRangeInfo struct {
+	Range    string `json:"range"`
+	FirstRow int    `json:"first_row"` // 1-based over the input; after LastRow when the range was empty
+	LastRow  int    `json:"last_row"`
+	Indexed  bool   `json:"indexed"` // the rows before the range were skipped with the row index
+}
+
+// windowRow is a row kept for a tail range, with the index of the file it came from and its line in that file
+type windowRow struct {
+	record []string
+	source int
+	line   int
+}
+
+// rowWindow applies --rows while the input is read: rows before the range are dropped and reading stops after its
+// last row. The last rows of a tail range are only known at the end, so they are kept in a ring buffer until then.
+type rowWindow struct {
+	first, last int // 1-based and inclusive; last 0 means to the end
+	tail        int
+	seen        int // data rows read so far, or skipped by an index seek
+	ring        []windowRow
+	indexed     bool
+}
+
+// newRowWindow returns the window of a row range, or nil when no range was given
+func newRowWindow(r RowSelection) *rowWindow {
+	if !r.enabled() {
+		return nil
+	}
+	first := r.First
+	if first == 0 {
+		first = 1
+	}
+	return &rowWindow{first: first, last: r.Last, tail: r.Tail}
+}
+
+// add offers the next data row: it reports whether to keep the row now, and returns errRowRangeDone with the last
+// row of the range
+func (w *rowWindow) add(record []string, source, line int) (bool, error) {
+	w.seen++
+	if w.tail > 0 {
+		entry := windowRow{record: record, source: source, line: line}
+		if len(w.ring) < w.tail {
+			w.ring = append(w.ring, entry)
+		} else {
+			w.ring[(w.seen-1)%w.tail] = entry
+		}
+		return false, nil
+	}
+	keep := w.seen >= w.first
+	if w.last > 0 && w.seen >= w.last {
+		return keep, errRowRangeDone
+	}
+	return keep, nil
+}
+
+// tailRows returns the rows of a tail range in file order
+func (w *rowWindow) tailRows() []windowRow {
+	if w.tail == 0 || len(w.ring) < w.tail {
+		return w.ring
+	}
+	start := w.seen % w.tail
+	return append(append([]windowRow(nil), w.ring[start:]...), w.ring[:start]...)
+}
+
+// info describes the rows the window kept, given how many it kept
+func (w *rowWindow) info(r RowSelection, kept int) *RowRangeInfo {
+	first := w.first
+	if w.tail > 0 {
+		first = w.seen - len(w.ring) + 1
+	}
+	return &RowRangeInfo{Range: r.String(), FirstRow: first, LastRow: first + kept - 1, Indexed: w.indexed}
+}
+
+// RowIndex holds the byte offsets of every rowIndexStride-th row of a CSV file, so --rows can seek to a range
+// instead of reading the file from the start. The size, modification time and parsing options of the file are
+// recorded so an index that no longer matches it is not used.
+type RowIndex struct {
+	Version     int       `json:"version"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	QuoteChar   string    `json:"quote_char"`
+	CommentChar string    `json:"comment_char,omitempty"`
+	LazyQuotes  bool      `json:"lazy_quotes,omitempty"`
+	Start       int64     `json:"start"` // offset of the header row, after any byte order mark
+	Rows        int       `json:"rows"`
+	Stride      int       `json:"stride"`
+	Offsets     []int64   `json:"offsets"` // Offsets[i] is where data row i*Stride+1 starts
+}
+
+// rowIndexPath is where the row index of a file is kept
+func rowIndexPath(path string) string {
+	return path + rowIndexSuffix
+}
+
+// matches reports whether an index was built from the file as it is now, with the same parsing options
+func (index *RowIndex) matches(info os.FileInfo, opts Options) bool {
+	return index.Version == 1 && index.Size == info.Size() && index.ModTime.Equal(info.ModTime()) &&
+		index.QuoteChar == opts.QuoteChar && index.CommentChar == opts.CommentChar && index.LazyQuotes == opts.LazyQuotes
+}
+
+// The BuildRowIndex method is part of the CSVAnalyzer struct. It reads a CSV file once with the parsing options of
+// the analyzer and records where every rowIndexStride-th data row starts. The offsets come from the CSV reader, so
+// quoted fields spanning several lines are handled. Offsets into transcoded text would not be offsets into the file,
+// so only UTF-8 files can be indexed; JSON, Arrow and SQLite inputs are not line-based and cannot be either.
+// BuildRowIndex builds the row index of a CSV file
+func (ca *CSVAnalyzer) BuildRowIndex(path string) (*RowIndex, error) {
+	if isJSONInput(path) || isArrowInput(path) || isSQLiteInput(path) {
+		return nil, fmt.Errorf("only CSV files can be indexed: %s", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	head := make([]byte, encodingSniffSize)
+	n, _ := io.ReadFull(file, head)
+	if _, encoding := decodeInput(bytes.NewReader(head[:n]), ca.options.Encoding); encoding != EncodingUTF8 {
+		return nil, fmt.Errorf("only UTF-8 files can be indexed: %s is %s", path, encoding)
+	}
+	index := &RowIndex{Version: 1, Size: info.Size(), ModTime: info.ModTime(), QuoteChar: ca.options.QuoteChar,
+		CommentChar: ca.options.CommentChar, LazyQuotes: ca.options.LazyQuotes, Stride: rowIndexStride}
+	if bytes.HasPrefix(head[:n], []byte{0xEF, 0xBB, 0xBF}) {
+		index.Start = 3
+	}
+	if _, err := file.Seek(index.Start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading CSV file %s: %v", path, err)
+	}
+	reader, err := ca.newCSVReader(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reader.Read(); err == io.EOF {
+		return nil, fmt.Errorf("empty csv file: %s", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading CSV file %s: %v", path, err)
+	}
+	for {
+		start := index.Start + reader.InputOffset()
+		if _, err := reader.Read(); err == io.EOF {
+			return index, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading CSV file %s: %v", path, err)
+		}
+		if index.Rows%rowIndexStride == 0 {
+			index.Offsets = append(index.Offsets, start)
+		}
+		index.Rows++
+	}
+}
+
+// WriteRowIndex writes a row index as JSON next to the file it belongs to
+func WriteRowIndex(path string, index *RowIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("error encoding row index: %v", err)
+	}
+	if err := os.WriteFile(rowIndexPath(path), data, 0o644); err != nil {
+		return fmt.Errorf("error writing row index: %v", err)
+	}
+	return nil
+}
+
+// The rowIndexFor method is part of the CSVAnalyzer struct. It returns the row index to seek with when --rows
+// is set on a single CSV file that has an up-to-date index, and nil whenever the file must be read from the start:
+// no range, several files, no index, or an index that no longer matches the file or the parsing options. A stale
+// index is reported on stderr, since reading the whole file is slower than the user expected.
+// rowIndexFor returns the usable row index of the input, or nil
+func (ca *CSVAnalyzer) rowIndexFor(paths []string, window *rowWindow) *RowIndex {
+	if window == nil || len(paths) != 1 || isJSONInput(paths[0]) || isArrowInput(paths[0]) || isSQLiteInput(paths[0]) {
+		return nil
+	}
+	if ca.options.Encoding != EncodingAuto && ca.options.Encoding != EncodingUTF8 {
+		return nil
+	}
+	data, err := os.ReadFile(rowIndexPath(paths[0]))
+	if err != nil {
+		return nil
+	}
+	var index RowIndex
+	info, err := os.Stat(paths[0])
+	if err != nil || json.Unmarshal(data, &index) != nil || !index.matches(info, ca.options) {
+		fmt.Fprintf(os.Stderr, "Note: %s is out of date or was built with other parsing options; reading the whole file (rebuild it with `index`)\n", rowIndexPath(paths[0]))
+		return nil
+	}
+	return &index
+}
+
+// The seek method of rowWindow moves the window to the index offset nearest before its first row, resolving a tail
+// range with the row count of the index. It returns the offset to read from; the rows between it and the range are
+// still read and dropped by add, at most rowIndexStride-1 of them.
+// seek returns the offset to read the data rows from
+func (w *rowWindow) seek(index *RowIndex) int64 {
+	if w.tail > 0 {
+		w.first, w.last, w.tail = index.Rows-w.tail+1, index.Rows, 0
+		if w.first < 1 {
+			w.first = 1
+		}
+	}
+	w.indexed = true
+	entry := (w.first - 1) / index.Stride
+	if entry >= len(index.Offsets) {
+		// The range starts after the last row, so nothing is left to read.
+		w.seen = index.Rows
+		return index.Size
+	}
+	w.seen = entry * index.Stride
+	return index.Offsets[entry]
+}
+
+// readCSVFileFrom reads the header of an indexed CSV file, then its records from offset onwards
+func (ca *CSVAnalyzer) readCSVFileFrom(filename string, index *RowIndex, offset int64, visit func(record []string) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	for i, start := range []int64{index.Start, offset} {
+		if _, err := file.Seek(start, io.SeekStart); err != nil {
+			return fmt.Errorf("error reading CSV file %s: %v", filename, err)
+		}
+		// A new reader starts at each offset; the first only reads the header.
+		reader, err := ca.newCSVReader(file)
+		if err != nil {
+			return err
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("error reading CSV file %s: %v", filename, err)
+			}
+			if err := visit(record); err != nil {
+				return err
+			}
+			if i == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// runIndex implements the index subcommand, which builds the row index of a CSV file for fast --rows access
+func runIndex(args []string) {
+	// Reuses the analyzer flags so the parsing options the index depends on can be given.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . index [options] <csv-file>")
+		fmt.Fprintf(os.Stderr, "\nWrites <csv-file>%s with the byte offset of every %dth row, which later runs with --rows\n", rowIndexSuffix, rowIndexStride)
+		fmt.Fprintln(os.Stderr, "use to seek instead of reading the file from the start. Rebuild it when the file changes.")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	index, err := analyzer.BuildRowIndex(positional[0])
+	if err != nil {
+		log.Fatal("Error indexing CSV:", err)
+	}
+	if err := WriteRowIndex(positional[0], index); err != nil {
+		log.Fatal("Error indexing CSV:", err)
+	}
+	fmt.Printf("Indexed %d rows of %s in %s\n", index.Rows, positional[0], rowIndexPath(positional[0]))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors of the typed Row getters, wrapped in a CellError; check them with errors.Is
+var (
+	ErrUnknownColumn = errors.New("unknown column")
+	ErrMissingValue  = errors.New("missing value")
+	ErrInvalidValue  = errors.New("invalid value")
+)
+
+// CellError is a cell a typed Row getter could not return, with where it is and why
+type CellError struct {
+	Row    int // 1-based data row number (header excluded)
+	Column string
+	Value  string
+	Want   string // what an invalid value should have been, e.g. "number" or "date"
+	Err    error  // ErrUnknownColumn, ErrMissingValue or ErrInvalidValue
+}
+
+// Error describes the cell and the problem
+func (e *CellError) Error() string {
+	if e.Err == ErrInvalidValue {
+		return fmt.Sprintf("row %d, column %s: %q is not a %s", e.Row, e.Column, e.Value, e.Want)
+	}
+	return fmt.Sprintf("row %d, column %s: %v", e.Row, e.Column, e.Err)
+}
+
+// Unwrap returns the kind of problem, so errors.Is matches ErrUnknownColumn, ErrMissingValue and ErrInvalidValue
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// The Iter method is part of the Dataset struct. It returns an iterator over the rows in order, for use with range:
+//
+//	for row := range analyzer.Dataset().Iter() {
+//		price, err := row.Float("Price")
+//		...
+//	}
+//
+// The typed getters parse values the way the analyzer that loaded the dataset does, with its --locale and the date
+// formats of its --preset, and return a CellError naming the row and column when a value is missing or invalid.
+// Iter returns an iterator over the rows of the dataset
+func (d *Dataset) Iter() iter.Seq[Row] {
+	return func(yield func(Row) bool) {
+		for rowIndex, cells := range d.Rows {
+			if !yield(Row{Index: rowIndex, dataset: d, cells: cells}) {
+				return
+			}
+		}
+	}
+}
+
+// cell returns the trimmed value of a column, failing for unknown columns and empty cells
+func (r Row) cell(column string) (string, error) {
+	colIndex := r.dataset.headerIndex(column)
+	if colIndex < 0 {
+		return "", &CellError{Row: r.Index + 1, Column: column, Err: ErrUnknownColumn}
+	}
+	value := ""
+	if colIndex < len(r.cells) {
+		value = strings.TrimSpace(r.cells[colIndex])
+	}
+	if value == "" {
+		return "", &CellError{Row: r.Index + 1, Column: column, Err: ErrMissingValue}
+	}
+	return value, nil
+}
+
+// String returns the value of a column without surrounding whitespace; an empty cell is "" and not an error
+func (r Row) String(column string) (string, error) {
+	value, err := r.cell(column)
+	if errors.Is(err, ErrMissingValue) {
+		return "", nil
+	}
+	return value, err
+}
+
+// Float returns the value of a column as a number, parsed with the dataset's locale when it has one
+func (r Row) Float(column string) (float64, error) {
+	value, err := r.cell(column)
+	if err != nil {
+		return 0, err
+	}
+	var number float64
+	if r.dataset.numberLocale != nil {
+		number, err = parseLocaleNumber(value, r.dataset.numberLocale)
+	} else {
+		number, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		return 0, &CellError{Row: r.Index + 1, Column: column, Value: value, Want: "number", Err: ErrInvalidValue}
+	}
+	return number, nil
+}
+
+// Int returns the value of a column as a whole number; values with a fraction are an error rather than truncated
+func (r Row) Int(column string) (int64, error) {
+	number, err := r.Float(column)
+	if err != nil {
+		return 0, err
+	}
+	if number != math.Trunc(number) || math.Abs(number) > 1<<53 {
+		value, _ := r.cell(column)
+		return 0, &CellError{Row: r.Index + 1, Column: column, Value: value, Want: "whole number", Err: ErrInvalidValue}
+	}
+	return int64(number), nil
+}
+
+// Bool returns the value of a column as a boolean, accepting the tokens boolean columns are detected from (true/false,
+// yes/no, 1/0 and the like)
+func (r Row) Bool(column string) (bool, error) {
+	value, err := r.cell(column)
+	if err != nil {
+		return false, err
+	}
+	result, ok := parseBoolean(value)
+	if !ok {
+		return false, &CellError{Row: r.Index + 1, Column: column, Value: value, Want: "boolean", Err: ErrInvalidValue}
+	}
+	return result, nil
+}
+
+// Time returns the value of a column as a date, trying the built-in date formats and then those of the dataset's preset
+func (r Row) Time(column string) (time.Time, error) {
+	value, err := r.cell(column)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t, ok := parseDate(value); ok {
+		return t, nil
+	}
+	for _, layout := range r.dataset.dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, &CellError{Row: r.Index + 1, Column: column, Value: value, Want: "date", Err: ErrInvalidValue}
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of the value rules of a --rules file
+const (
+	RuleMin       = "min"
+	RuleMax       = "max"
+	RuleBetween   = "between"
+	RuleIn        = "in"
+	RuleNotNull   = "not_null"
+	RuleUnique    = "unique"
+	RulePattern   = "pattern"
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+)
+
+// maxRuleExamples is how many violating cells a rule result lists
+const maxRuleExamples = 10
+
+// ValueRule is one assertion of a --rules file about every value of a column
+type ValueRule struct {
+	Column string
+	Kind   string
+	Name   string // e.g. "Rating between 1..5", used in reports
+	Min    float64
+	Max    float64  // the bounds of min, max and between, or the lengths of min_length and max_length
+	Values []string // the allowed values of in
+	// pattern is the compiled regular expression of a pattern rule, anchored to the whole value
+	pattern *regexp.Regexp
+}
+
+// RuleViolation is a cell that breaks a rule
+type RuleViolation struct {
+	Row   int    `json:"row"` // 1-based data row number (header excluded)
+	Value string `json:"value"`
+}
+
+// RuleResult is the outcome of one value rule over every row
+type RuleResult struct {
+	Rule       string          `json:"rule"`
+	Column     string          `json:"column"`
+	Kind       string          `json:"kind"`
+	Checked    int             `json:"checked"` // the cells the rule applies to
+	Violations int             `json:"violations"`
+	Examples   []RuleViolation `json:"examples,omitempty"` // the first violations, at most maxRuleExamples
+}
+
+// yamlMap is a YAML mapping that keeps its keys in file order, so rules are reported in the order they were written
+type yamlMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// yamlLine is a line of a YAML document without its comment, with the indentation measured
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// The parseYAML function reads the subset of YAML that rules files need: block mappings (key: value) nested by
+// indentation, block sequences (- item), and the flow forms {key: value, ...} and [item, ...] on a single line, with
+// plain, 'single' or "double" quoted scalars and # comments. Scalars are returned as strings, mappings as *yamlMap and
+// sequences as []any; JSON is a flow mapping, so JSON files parse too. Anchors, multi-line strings and multi-line flow
+// collections are not supported and are reported rather than misread.
+// parseYAML parses a YAML document into strings, sequences and mappings
+func parseYAML(data string) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		text := stripYAMLComment(raw)
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot indent YAML", i+1)
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: strings.TrimRight(trimmed, " \t")})
+	}
+	if len(lines) == 0 {
+		return &yamlMap{values: map[string]any{}}, nil
+	}
+	// A document that is one flow collection, like a JSON file, may span lines.
+	if first := lines[0].text[0]; first == '{' || first == '[' {
+		texts := make([]string, len(lines))
+		for i, line := range lines {
+			texts[i] = line.text
+		}
+		return parseYAMLFlow(strings.Join(texts, " "), lines[0].number)
+	}
+	value, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].number)
+	}
+	return value, nil
+}
+
+// stripYAMLComment cuts a line at a # that starts a comment, that is one outside quotes at the start of the line or
+// after a blank
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseYAMLBlock parses the block mapping or sequence starting at lines[start] with the given indentation, and
+// returns it with the index of the first line after it
+func parseYAMLBlock(lines []yamlLine, start, indent int) (any, int, error) {
+	if strings.HasPrefix(lines[start].text, "- ") || lines[start].text == "-" {
+		var items []any
+		i := start
+		for i < len(lines) && lines[i].indent == indent && (strings.HasPrefix(lines[i].text, "- ") || lines[i].text == "-") {
+			item, err := parseYAMLFlow(strings.TrimSpace(strings.TrimPrefix(lines[i].text, "-")), lines[i].number)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			i++
+		}
+		return items, i, nil
+	}
+
+	mapping := &yamlMap{values: make(map[string]any)}
+	i := start
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		key, rest, err := splitYAMLKey(line.text, line.number)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, ok := mapping.values[key]; ok {
+			return nil, 0, fmt.Errorf("line %d: duplicate key", line.number)
+		}
+		var value any = ""
+		i++
+		switch {
+		case rest != "":
+			if value, err = parseYAMLFlow(rest, line.number); err != nil {
+				return nil, 0, err
+			}
+		case i < len(lines) && lines[i].indent > indent:
+			if value, i, err = parseYAMLBlock(lines, i, lines[i].indent); err != nil {
+				return nil, 0, err
+			}
+		case i < len(lines) && lines[i].indent == indent && strings.HasPrefix(lines[i].text, "- "):
+			// Sequences may sit at the indentation of their key.
+			if value, i, err = parseYAMLBlock(lines, i, indent); err != nil {
+				return nil, 0, err
+			}
+		}
+		mapping.keys = append(mapping.keys, key)
+		mapping.values[key] = value
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, fmt.Errorf("line %d: unexpected indentation", lines[i].number)
+	}
+	return mapping, i, nil
+}
+
+// splitYAMLKey splits a "key: value" line into its key, unquoted, and the rest after the colon
+func splitYAMLKey(text string, number int) (string, string, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		key, rest, err := readYAMLQuoted(text)
+		if err != nil {
+			return "", "", fmt.Errorf("line %d: %v", number, err)
+		}
+		if rest = strings.TrimLeft(rest, " "); !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("line %d: expected a colon after the key", number)
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("line %d: expected key: value", number)
+}
+
+// readYAMLQuoted reads the quoted scalar at the start of text and returns it with the text after it
+func readYAMLQuoted(text string) (string, string, error) {
+	quote := text[0]
+	if quote == '"' {
+		// Double-quoted scalars have the escapes of JSON strings.
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\\' {
+				i++
+			} else if text[i] == '"' {
+				value, err := strconv.Unquote(text[:i+1])
+				if err != nil {
+					return "", "", errors.New("invalid quoted string")
+				}
+				return value, text[i+1:], nil
+			}
+		}
+		return "", "", errors.New("unterminated string")
+	}
+	// Single-quoted scalars escape a quote by doubling it.
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		if text[i] == '\'' {
+			if i+1 < len(text) && text[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), text[i+1:], nil
+		}
+		b.WriteByte(text[i])
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+// parseYAMLFlow parses a value written on one line: a flow mapping, a flow sequence or a scalar
+func parseYAMLFlow(text string, number int) (any, error) {
+	value, rest, err := readYAMLFlow(text, false)
+	if err == nil && strings.TrimSpace(rest) != "" {
+		err = errors.New("unexpected text after the value")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", number, err)
+	}
+	return value, nil
+}
+
+// readYAMLFlow reads one flow value from the start of text and returns it with the text after it; inside a
+// collection, plain scalars end at a comma or a closing bracket
+func readYAMLFlow(text string, nested bool) (any, string, error) {
+	text = strings.TrimLeft(text, " ")
+	switch {
+	case text == "":
+		return "", "", nil
+	case text[0] == '"' || text[0] == '\'':
+		return readYAMLQuoted(text)
+	case text[0] == '[':
+		var items []any
+		rest := strings.TrimLeft(text[1:], " ")
+		if strings.HasPrefix(rest, "]") {
+			return []any{}, rest[1:], nil
+		}
+		for {
+			item, after, err := readYAMLFlow(rest, true)
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, item)
+			after = strings.TrimLeft(after, " ")
+			switch {
+			case strings.HasPrefix(after, ","):
+				rest = after[1:]
+			case strings.HasPrefix(after, "]"):
+				return items, after[1:], nil
+			default:
+				return nil, "", errors.New("unterminated sequence")
+			}
+		}
+	case text[0] == '{':
+		mapping := &yamlMap{values: make(map[string]any)}
+		rest := strings.TrimLeft(text[1:], " ")
+		if strings.HasPrefix(rest, "}") {
+			return mapping, rest[1:], nil
+		}
+		for {
+			keyValue, after, err := readYAMLFlow(rest, true)
+			if err != nil {
+				return nil, "", err
+			}
+			key, ok := keyValue.(string)
+			if after = strings.TrimLeft(after, " "); !ok || !strings.HasPrefix(after, ":") {
+				return nil, "", errors.New("expected key: value in a mapping")
+			}
+			value, after, err := readYAMLFlow(after[1:], true)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, ok := mapping.values[key]; ok {
+				return nil, "", errors.New("duplicate key")
+			}
+			mapping.keys = append(mapping.keys, key)
+			mapping.values[key] = value
+			after = strings.TrimLeft(after, " ")
+			switch {
+			case strings.HasPrefix(after, ","):
+				rest = after[1:]
+			case strings.HasPrefix(after, "}"):
+				return mapping, after[1:], nil
+			default:
+				return nil, "", errors.New("unterminated mapping")
+			}
+		}
+	}
+	if !nested {
+		return strings.TrimSpace(text), "", nil
+	}
+	end := strings.IndexAny(text, ",]}")
+	// A colon followed by a blank ends a key of a flow mapping.
+	if colon := strings.Index(text, ": "); colon >= 0 && (end < 0 || colon < end) {
+		end = colon
+	} else if strings.HasSuffix(text, ":") && end < 0 {
+		end = len(text) - 1
+	}
+	if end < 0 {
+		end = len(text)
+	}
+	return strings.TrimSpace(text[:end]), text[end:], nil
+}
+
+// ruleNumber reads the numeric argument of a rule
+func ruleNumber(column, kind string, value any) (float64, error) {
+	text, ok := value.(string)
+	number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("%s: %s needs a number, got %v", column, kind, describeYAML(value))
+	}
+	return number, nil
+}
+
+// ruleFlag reads the true/false argument of a rule
+func ruleFlag(column, kind string, value any) (bool, error) {
+	if text, ok := value.(string); ok {
+		if flag, ok := parseBoolean(text); ok {
+			return flag, nil
+		}
+	}
+	return false, fmt.Errorf("%s: %s needs true or false, got %v", column, kind, describeYAML(value))
+}
+
+// describeYAML renders a parsed value for error messages
+func describeYAML(value any) string {
+	switch value := value.(type) {
+	case *yamlMap:
+		return "a mapping"
+	case []any:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", value)
+	}
+}
+
+// The LoadRules function reads a --rules file: a mapping from column names to the rules their values must follow,
+// in YAML (or JSON), e.g. "Price: {min: 0}" or "Category: {in: [Electronics, Kitchen]}". The rules are min and max
+// (numeric bounds, inclusive), between: [low, high], in: [allowed values], not_null: true, unique: true, pattern: a
+// regular expression the whole value must match, and min_length and max_length in characters. Unknown rule names
+// and malformed arguments are errors, so a typo cannot silently disable a check.
+// LoadRules reads the value rules of a rules file
+func LoadRules(path string) ([]ValueRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules file: %v", err)
+	}
+	document, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rules file %s: %v", path, err)
+	}
+	columns, ok := document.(*yamlMap)
+	if !ok {
+		return nil, fmt.Errorf("error parsing rules file %s: expected a mapping of column names to rules", path)
+	}
+	var rules []ValueRule
+	for _, column := range columns.keys {
+		checks, ok := columns.values[column].(*yamlMap)
+		if !ok {
+			return nil, fmt.Errorf("invalid rules in %s: %s needs a mapping of rules, e.g. {min: 0}", path, column)
+		}
+		for _, kind := range checks.keys {
+			rule, err := newValueRule(column, kind, checks.values[kind])
+			if err != nil {
+				return nil, fmt.Errorf("invalid rules in %s: %v", path, err)
+			}
+			if rule != nil {
+				rules = append(rules, *rule)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// newValueRule builds the rule of one entry of a rules file; not_null and unique set to false give no rule
+func newValueRule(column, kind string, value any) (*ValueRule, error) {
+	rule := &ValueRule{Column: column, Kind: strings.ToLower(kind)}
+	var err error
+	switch rule.Kind {
+	case RuleMin, RuleMax:
+		var bound float64
+		if bound, err = ruleNumber(column, kind, value); err == nil {
+			rule.Min, rule.Max = bound, bound
+			rule.Name = fmt.Sprintf("%s %s %s", column, rule.Kind, formatMetric(bound))
+		}
+	case RuleBetween:
+		bounds, ok := value.([]any)
+		if !ok || len(bounds) != 2 {
+			return nil, fmt.Errorf("%s: between needs [low, high], got %v", column, describeYAML(value))
+		}
+		if rule.Min, err = ruleNumber(column, kind, bounds[0]); err == nil {
+			rule.Max, err = ruleNumber(column, kind, bounds[1])
+		}
+		if err == nil && rule.Min > rule.Max {
+			err = fmt.Errorf("%s: between needs low <= high, got [%s, %s]", column, formatMetric(rule.Min), formatMetric(rule.Max))
+		}
+		rule.Name = fmt.Sprintf("%s between %s..%s", column, formatMetric(rule.Min), formatMetric(rule.Max))
+	case RuleIn:
+		values, ok := value.([]any)
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("%s: in needs a list of allowed values, got %v", column, describeYAML(value))
+		}
+		for _, allowed := range values {
+			text, ok := allowed.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: in needs plain values, got %v", column, describeYAML(allowed))
+			}
+			rule.Values = append(rule.Values, strings.TrimSpace(text))
+		}
+		rule.Name = fmt.Sprintf("%s in [%s]", column, strings.Join(rule.Values, ", "))
+	case RuleNotNull, RuleUnique:
+		var enabled bool
+		if enabled, err = ruleFlag(column, kind, value); err == nil && !enabled {
+			return nil, nil
+		}
+		rule.Name = column + " " + strings.ReplaceAll(rule.Kind, "_", " ")
+	case RulePattern:
+		text, ok := value.(string)
+		if !ok || text == "" {
+			return nil, fmt.Errorf("%s: pattern needs a regular expression, got %v", column, describeYAML(value))
+		}
+		if rule.pattern, err = regexp.Compile(`^(?:` + text + `)$`); err != nil {
+			err = fmt.Errorf("%s: invalid pattern %q: %v", column, text, err)
+		}
+		rule.Name = fmt.Sprintf("%s matches %s", column, text)
+	case RuleMinLength, RuleMaxLength:
+		var length float64
+		if length, err = ruleNumber(column, kind, value); err == nil && (length < 0 || length != float64(int(length))) {
+			err = fmt.Errorf("%s: %s needs a whole number of characters, got %s", column, kind, formatMetric(length))
+		}
+		rule.Min, rule.Max = length, length
+		rule.Name = fmt.Sprintf("%s %s %s", column, strings.ReplaceAll(rule.Kind, "_", " "), formatMetric(length))
+	default:
+		return nil, fmt.Errorf("%s: unknown rule %q (expected min, max, between, in, not_null, unique, pattern, min_length or max_length)", column, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// ruleColumns lists the columns the value rules refer to
+func ruleColumns(rules []ValueRule) []string {
+	columns := make([]string, len(rules))
+	for i, rule := range rules {
+		columns[i] = rule.Column
+	}
+	return columns
+}
+
+// violates reports whether a cell breaks a rule; seen holds the values of the column so far, for unique
+func (ca *CSVAnalyzer) violates(rule ValueRule, value string, seen map[string]bool) (applies, broken bool) {
+	if value == "" {
+		return rule.Kind == RuleNotNull, rule.Kind == RuleNotNull
+	}
+	switch rule.Kind {
+	case RuleMin, RuleMax, RuleBetween:
+		// A value that is not a number, NaN included, cannot satisfy a numeric bound.
+		number, err := ca.parseNumber(value)
+		if err != nil || math.IsNaN(number) {
+			return true, true
+		}
+		return true, (rule.Kind != RuleMax && number < rule.Min) || (rule.Kind != RuleMin && number > rule.Max)
+	case RuleIn:
+		for _, allowed := range rule.Values {
+			if value == allowed {
+				return true, false
+			}
+		}
+		return true, true
+	case RuleUnique:
+		broken = seen[value]
+		seen[value] = true
+		return true, broken
+	case RulePattern:
+		return true, !rule.pattern.MatchString(value)
+	case RuleMinLength:
+		return true, float64(utf8.RuneCountInString(value)) < rule.Min
+	case RuleMaxLength:
+		return true, float64(utf8.RuneCountInString(value)) > rule.Max
+	}
+	return true, false
+}
+
+// The RuleResults method is part of the CSVAnalyzer struct. It evaluates every value rule of the --rules file
+// against every row, in the order of the file, and returns per rule how many cells it applied to, how many broke it
+// and the first of them. Empty cells only count for not_null, so a column may be optional and still constrained when
+// present; values are compared after trimming, and the numeric bounds use the same number parsing as the statistics
+// (including --locale), with unparsable values breaking them. The results are computed once and cached, since the
+// alerts and every report format ask for them.
+// RuleResults returns the outcome of every value rule
+func (ca *CSVAnalyzer) RuleResults() []RuleResult {
+	ca.ruleOnce.Do(func() {
+		if len(ca.valueRules) == 0 {
+			return
+		}
+		defer ca.explain.step("evaluate rules", fmt.Sprintf("%d rules", len(ca.valueRules)), len(ca.dataset.Rows))(len(ca.dataset.Rows))
+		for _, rule := range ca.valueRules {
+			result := RuleResult{Rule: rule.Name, Column: rule.Column, Kind: rule.Kind}
+			colIndex := ca.columnIndex(rule.Column)
+			seen := make(map[string]bool)
+			for rowIndex, row := range ca.dataset.Rows {
+				value := ""
+				if colIndex >= 0 && colIndex < len(row) {
+					value = strings.TrimSpace(row[colIndex])
+				}
+				applies, broken := ca.violates(rule, value, seen)
+				if applies {
+					result.Checked++
+				}
+				if !broken {
+					continue
+				}
+				result.Violations++
+				if len(result.Examples) < maxRuleExamples && ca.privacy == nil {
+					result.Examples = append(result.Examples, RuleViolation{Row: rowIndex + 1, Value: value})
+				}
+			}
+			ca.ruleResults = append(ca.ruleResults, result)
+		}
+	})
+	return ca.ruleResults
+}
+
+// ruleViolations is the number of cells breaking any value rule, the rule_violations alert metric
+func (ca *CSVAnalyzer) ruleViolations() int {
+	total := 0
+	for _, result := range ca.RuleResults() {
+		total += result.Violations
+	}
+	return total
+}
+
+// describeExamples renders the listed violations on one line
+func (r RuleResult) describeExamples() string {
+	parts := make([]string, len(r.Examples))
+	for i, violation := range r.Examples {
+		parts[i] = fmt.Sprintf("row %d %q", violation.Row, violation.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// printRuleResults shows the outcome of the value rules in the text report, broken rules with their first violations
+func printRuleResults(results []RuleResult) {
+	if len(results) == 0 {
+		return
+	}
+	broken := 0
+	for _, result := range results {
+		if result.Violations > 0 {
+			broken++
+		}
+	}
+	fmt.Println("\n\nRule Checks:")
+	fmt.Println("------------")
+	fmt.Printf("%d of %d rules held\n", len(results)-broken, len(results))
+	for _, result := range results {
+		if result.Violations == 0 {
+			fmt.Printf("  [PASS] %s (%d values)\n", result.Rule, result.Checked)
+			continue
+		}
+		fmt.Printf("  [FAIL] %s: %d of %d values\n", result.Rule, result.Violations, result.Checked)
+		if len(result.Examples) > 0 {
+			fmt.Printf("         %s\n", result.describeExamples())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNumericRulesRejectNaN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte("a: {between: [0, 10]}\nb: {min: 0}\nc: {max: 10}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	analyzer := loadTestCSV(t, "a,b,c\n1,1,1\nNaN,nan,NaN\n5,Inf,-Inf\n")
+	var err error
+	if analyzer.valueRules, err = LoadRules(path); err != nil {
+		t.Fatal(err)
+	}
+	// NaN breaks every bound; Inf is above any maximum and -Inf below any minimum, but within the other side.
+	want := map[string]int{"a": 1, "b": 1, "c": 1}
+	for _, result := range analyzer.RuleResults() {
+		if result.Violations != want[result.Column] {
+			t.Errorf("%s: %d violations, want %d (%v)", result.Rule, result.Violations, want[result.Column], result.Examples)
+		}
+	}
+}
+
+func TestRulesParseErrorsGiveLineNumbersOnly(t *testing.T) {
+	for content, want := range map[string]string{
+		"root:x:0:0:root:/root:/bin/bash\n":     "line 1: expected key: value",
+		"# comment\na: \"unterminated secret\n": "line 2: unterminated string",
+		"a: {min: 0} secret\n":                  "line 1: unexpected text after the value",
+		"a: [1, secret\n":                       "line 1: unterminated sequence",
+		"a: {secret}\n":                         "line 1: expected key: value in a mapping",
+		"a: {secret: 1, secret: 2}\n":           "line 1: duplicate key",
+		"secret: {}\nsecret: {}\n":              "line 2: duplicate key",
+	} {
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadRules(path)
+		if want = "error parsing rules file " + path + ": " + want; err == nil || err.Error() != want {
+			t.Errorf("%q: error %v, want %s", content, err, want)
+		}
+	}
+}
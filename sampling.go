@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SamplingOptions controls approximate statistics computed from a random sample of rows
+type SamplingOptions struct {
+	// Size keeps a uniform sample of exactly this many rows (reservoir sampling); 0 disables it
+	Size int
+	// Fraction keeps each row independently with this probability (Bernoulli sampling); 0 disables it
+	Fraction float64
+	// Seed makes the sample reproducible; 0 picks a time-based seed
+	Seed int64
+}
+
+// SamplingInfo describes how the rows of a sampled dataset were selected
+type SamplingInfo struct {
+	Method      string  `json:"method"`
+	Size        int     `json:"size,omitempty"`
+	Fraction    float64 `json:"fraction,omitempty"`
+	Seed        int64   `json:"seed"`
+	TotalRows   int     `json:"total_rows"`
+	SampledRows int     `json:"sampled_rows"`
+}
+
+// rowSampler selects rows while the file is streamed, so only the sample is ever held in memory
+type rowSampler struct {
+	options SamplingOptions
+	rng     *rand.Rand
+	seen    int
+	rows    [][]string
+}
+
+// newRowSampler returns a sampler for the given options, or nil when sampling is disabled
+func newRowSampler(opts SamplingOptions) *rowSampler {
+	// Neither a size nor a fraction means exact statistics over every row.
+	if opts.Size <= 0 && opts.Fraction <= 0 {
+		return nil
+	}
+	// Picks a time-based seed when none was given, and remembers it so the run can be reproduced.
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	return &rowSampler{options: opts, rng: rand.New(rand.NewSource(opts.Seed))}
+}
+
+// The add method is part of the rowSampler struct. It offers one streamed row to the sample. With a fixed size it runs
+// Algorithm R: the first Size rows fill the reservoir, and after that the n-th row replaces a random slot with
+// probability Size/n, which leaves every row equally likely to be kept. With a fraction each row is kept independently.
+// add offers a row to the sample
+func (s *rowSampler) add(row []string) {
+	// Counts every row offered, kept or not.
+	s.seen++
+	// Bernoulli sampling keeps each row with the configured probability.
+	if s.options.Size <= 0 {
+		if s.rng.Float64() < s.options.Fraction {
+			s.rows = append(s.rows, row)
+		}
+		return
+	}
+	// Fills the reservoir until it reaches the requested size.
+	if len(s.rows) < s.options.Size {
+		s.rows = append(s.rows, row)
+		return
+	}
+	// Replaces a random slot so that every row seen so far has the same chance of being in the sample.
+	if j := s.rng.Intn(s.seen); j < s.options.Size {
+		s.rows[j] = row
+	}
+}
+
+// info summarizes the finished sample for the report
+func (s *rowSampler) info() *SamplingInfo {
+	info := &SamplingInfo{
+		Seed:        s.options.Seed,
+		TotalRows:   s.seen,
+		SampledRows: len(s.rows),
+	}
+	// Records which algorithm and parameter produced the sample.
+	if s.options.Size > 0 {
+		info.Method = "reservoir"
+		info.Size = s.options.Size
+	} else {
+		info.Method = "bernoulli"
+		info.Fraction = s.options.Fraction
+	}
+	return info
+}
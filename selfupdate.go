@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Release settings, set at link time like the build metadata in version.go:
+// updateURL is the release manifest to check and updatePublicKey the base64 Ed25519 key its signature is checked with.
+var (
+	updateURL       = "https://github.com/akins11/CSV-Analyzer/releases/latest/download/manifest.json"
+	updatePublicKey = ""
+)
+
+// updateTimeout bounds every request made while updating
+const updateTimeout = 5 * time.Minute
+
+// ReleaseManifest describes the latest release; it is published next to a detached signature at <url>.sig
+type ReleaseManifest struct {
+	Version string         `json:"version"`
+	Assets  []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is the binary of a release for one platform
+type ReleaseAsset struct {
+	OS     string `json:"os"`
+	Arch   string `json:"arch"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// The runSelfUpdate function implements the self-update subcommand. It downloads the release manifest and its
+// signature, and only trusts the manifest when the signature verifies against the public key compiled into this
+// binary. If the release is newer than the running version, it downloads the binary for this platform next to the
+// current executable, checks it against the SHA-256 checksum from the signed manifest, and swaps it into place.
+// Nothing is replaced unless every check passes. --check only reports whether an update is available.
+// runSelfUpdate checks for a newer release and replaces the running binary with it
+func runSelfUpdate(args []string) {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	manifestURL := fs.String("url", envOrDefault("CSV_ANALYZER_UPDATE_URL", updateURL), "release manifest `url` (env CSV_ANALYZER_UPDATE_URL)")
+	checkOnly := fs.Bool("check", false, "only report whether a newer release is available")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	// Fetches and authenticates the manifest before looking at anything in it.
+	client := &http.Client{Timeout: updateTimeout}
+	manifest, err := fetchReleaseManifest(client, *manifestURL)
+	if err != nil {
+		log.Fatal("Error checking for updates: ", err)
+	}
+	current := currentBuildInfo().Version
+	if !newerVersion(manifest.Version, current) {
+		fmt.Printf("csv-analyzer %s is up to date (latest release: %s)\n", current, manifest.Version)
+		return
+	}
+	if *checkOnly {
+		fmt.Printf("Update available: %s -> %s (run `self-update` to install)\n", current, manifest.Version)
+		return
+	}
+
+	// Picks the binary built for this platform.
+	var asset *ReleaseAsset
+	for i := range manifest.Assets {
+		if manifest.Assets[i].OS == runtime.GOOS && manifest.Assets[i].Arch == runtime.GOARCH {
+			asset = &manifest.Assets[i]
+		}
+	}
+	if asset == nil {
+		log.Fatalf("Release %s has no binary for %s/%s", manifest.Version, runtime.GOOS, runtime.GOARCH)
+	}
+	fmt.Printf("Updating csv-analyzer %s -> %s ...\n", current, manifest.Version)
+	if err := installReleaseAsset(client, *asset); err != nil {
+		log.Fatal("Error updating: ", err)
+	}
+	fmt.Printf("Updated to csv-analyzer %s\n", manifest.Version)
+}
+
+// fetchReleaseManifest downloads the manifest and its signature, returning the manifest only if the signature is valid
+func fetchReleaseManifest(client *http.Client, url string) (ReleaseManifest, error) {
+	var manifest ReleaseManifest
+	// A build without a public key cannot tell a genuine release from a tampered one, so it refuses to update.
+	if updatePublicKey == "" {
+		return manifest, fmt.Errorf("this build has no release signing key; install updates manually")
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(updatePublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return manifest, fmt.Errorf("invalid release signing key in this build")
+	}
+
+	body, err := httpGet(client, url, 1<<20)
+	if err != nil {
+		return manifest, err
+	}
+	signatureText, err := httpGet(client, url+".sig", 4<<10)
+	if err != nil {
+		return manifest, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signatureText)))
+	if err != nil || !ed25519.Verify(publicKey, body, signature) {
+		return manifest, fmt.Errorf("release manifest signature is invalid")
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return manifest, fmt.Errorf("error parsing release manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+// installReleaseAsset downloads a release binary, verifies its checksum and replaces the running executable with it
+func installReleaseAsset(client *http.Client, asset ReleaseAsset) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate the running binary: %v", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return fmt.Errorf("cannot locate the running binary: %v", err)
+	}
+	expected, err := hex.DecodeString(asset.SHA256)
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("release manifest has an invalid checksum for %s", asset.URL)
+	}
+
+	// Downloads into the executable's directory so the final rename stays on one file system.
+	response, err := client.Get(asset.URL)
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %v", asset.URL, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("error downloading %s: %s", asset.URL, response.Status)
+	}
+	download, err := os.CreateTemp(filepath.Dir(executable), ".csv-analyzer-update-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %v", err)
+	}
+	// Removes the download unless it was moved into place.
+	defer os.Remove(download.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(download, hash), response.Body)
+	if closeErr := download.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %v", asset.URL, err)
+	}
+	if got := hash.Sum(nil); hex.EncodeToString(got) != hex.EncodeToString(expected) {
+		return fmt.Errorf("checksum mismatch for %s: got %x", asset.URL, got)
+	}
+	if err := os.Chmod(download.Name(), 0755); err != nil {
+		return fmt.Errorf("error making the update executable: %v", err)
+	}
+
+	// Moves the running binary aside first, which is required on Windows, and restores it if the swap fails.
+	previous := executable + ".old"
+	os.Remove(previous)
+	if err := os.Rename(executable, previous); err != nil {
+		return fmt.Errorf("error replacing %s: %v", executable, err)
+	}
+	if err := os.Rename(download.Name(), executable); err != nil {
+		os.Rename(previous, executable)
+		return fmt.Errorf("error replacing %s: %v", executable, err)
+	}
+	// The old binary may still be in use on Windows; it is then cleaned up by the next update.
+	os.Remove(previous)
+	return nil
+}
+
+// httpGet fetches a small document, refusing anything larger than limit bytes
+func httpGet(client *http.Client, url string, limit int64) ([]byte, error) {
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: %s", url, response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", url, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("error fetching %s: response too large", url)
+	}
+	return body, nil
+}
+
+// newerVersion reports whether semantic version candidate is newer than current.
+// Development builds ("dev") are always considered older than a release.
+func newerVersion(candidate, current string) bool {
+	a, b := parseVersion(candidate), parseVersion(current)
+	if a == nil {
+		return false
+	}
+	if b == nil {
+		return true
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// parseVersion splits "v1.2.3" (pre-release and build suffixes ignored) into its numbers, or returns nil
+func parseVersion(text string) []int {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "v")
+	if i := strings.IndexAny(text, "-+"); i >= 0 {
+		text = text[:i]
+	}
+	parts := strings.Split(text, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil
+		}
+		numbers[i] = n
+	}
+	return numbers
+}
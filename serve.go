@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// serveEnvPrefix prefixes the environment variables that configure serve mode, e.g. CSV_ANALYZER_ADDR
+const serveEnvPrefix = "CSV_ANALYZER_"
+
+// Defaults of serve mode
+const (
+	defaultServeAddr       = ":8080"
+	defaultMaxUploadMB     = 100
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// clientOptions are the analyzer flags a request may set; every other flag, in particular those naming files,
+// directories or endpoints on the server, can only be set through the environment, so new flags stay server-only
+// until they are added here
+var clientOptions = map[string]bool{
+	"audit-parsing": true, "between": true, "bootstrap": true, "bootstrap-seed": true, "charts": true, "check": true,
+	"cluster-columns": true, "cluster-similarity": true, "co-missing": true, "comment-char": true,
+	"completeness-tiers": true, "confidence": true, "correlations": true, "derive": true, "dp-epsilon": true,
+	"drop": true, "encoding": true, "fill-missing": true, "format": true, "group-by": true, "group-deviation": true,
+	"histogram-bins": true, "k-threshold": true, "lazy-quotes": true, "locale": true, "max-duplicates": true,
+	"max-missing-pct": true, "missing-matrix": true, "na-policy": true, "nonnegative": true, "null-values": true,
+	"on": true, "outlier-bounds": true, "output-locale": true, "preset": true, "quasi-identifiers": true,
+	"quote-char": true, "recode": true, "rename": true, "rolling": true, "rows": true, "sample": true,
+	"sample-frac": true, "sample-seed": true, "scan-pii": true, "split-date": true, "split-on": true, "strict": true,
+	"table": true, "top": true, "top-by": true, "top-with": true, "treat-outliers": true, "trim": true,
+	"trim-leading-space": true, "type-sample": true, "type-sample-rows": true, "type-threshold": true, "types": true,
+	"unique": true,
+}
+
+// analysisServer answers analysis requests over HTTP
+type analysisServer struct {
+	// defaults are the analyzer options every request starts from (see serveOptionDefaults)
+	defaults map[string]string
+	// maxUpload is the largest accepted request body in bytes
+	maxUpload int64
+	// ready is cleared when shutdown starts, so /readyz sends new traffic elsewhere
+	ready atomic.Bool
+	// keys authenticate tenants; without any, every request belongs to defaultTenant
+	keys []tenantKey
+	// store keeps uploads and report history per tenant (nil when --data-dir is not set)
+	store *tenantStore
+}
+
+// envName returns the environment variable that sets a flag in serve mode, e.g. "type-sample" -> CSV_ANALYZER_TYPE_SAMPLE
+func envName(flagName string) string {
+	return serveEnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// serveOptionDefaults reads the analyzer options set through the environment, one variable per analyzer flag
+func serveOptionDefaults() map[string]string {
+	defaults := make(map[string]string)
+	newOptionsFlagSet(&Options{}).VisitAll(func(f *flag.Flag) {
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			defaults[f.Name] = value
+		}
+	})
+	return defaults
+}
+
+// The handleAnalyze method is part of the analysisServer struct. It answers POST /analyze: the request body is the
+// file to analyze and the query parameters are analyzer options by flag name (?locale=de-DE&strict=true), applied on
+// top of the defaults from the environment; only the analysis options of clientOptions are accepted. The name parameter
+// gives the upload's file name, whose extension decides between CSV and JSON input. The body is spooled to a
+// temporary file so it is read exactly like a file on disk. The response is the JSON report, or {"error": "..."}
+// with status 400 when the data or options are invalid. With tenants configured the request must carry an API key,
+// and with --data-dir the upload and report are stored under the key's tenant.
+// handleAnalyze analyzes an uploaded file and responds with its JSON report
+func (s *analysisServer) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST with the file as the request body")
+		return
+	}
+	tenant, ok := s.authenticate(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "missing or unknown API key")
+		return
+	}
+	query := r.URL.Query()
+	name := datasetName(query.Get("name"))
+	query.Del("name")
+	requested := make(map[string]string)
+	for key := range query {
+		requested[key] = query.Get(key)
+	}
+	options, err := s.requestOptions(requested)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Spools the upload into a temporary file that keeps its extension.
+	upload, err := os.CreateTemp("", "csv-analyzer-*-"+name)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("error storing upload: %v", err))
+		return
+	}
+	defer os.Remove(upload.Name())
+	_, err = io.Copy(upload, http.MaxBytesReader(w, r.Body, s.maxUpload))
+	if closeErr := upload.Close(); err == nil {
+		err = closeErr
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload is larger than %d bytes", s.maxUpload))
+		return
+	case err != nil:
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("error reading upload: %v", err))
+		return
+	}
+
+	report, err := AnalyzeFile(upload.Name(), options)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Keeps the upload and its report in the tenant's own namespace.
+	if s.store != nil {
+		if err := s.store.save(tenant, name, upload.Name(), report, time.Now()); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(report)
+}
+
+// requestOptions applies the analyzer options of a request on top of the defaults from the environment, refusing
+// the flags that are not client options
+func (s *analysisServer) requestOptions(requested map[string]string) (map[string]string, error) {
+	options := make(map[string]string)
+	for name, value := range s.defaults {
+		options[name] = value
+	}
+	for key, value := range requested {
+		// Names that are no flag at all are left to AnalyzeOptions to report.
+		if !clientOptions[key] && newOptionsFlagSet(&Options{}).Lookup(key) != nil {
+			return nil, fmt.Errorf("option %s can only be set on the server (%s)", key, envName(key))
+		}
+		options[key] = value
+	}
+	return options, nil
+}
+
+// handleHealth answers /healthz: the process is up and serving HTTP
+func (s *analysisServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReady answers /readyz: the server accepts new analyses, which stops being true once shutdown begins
+func (s *analysisServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+// writeJSONError sends {"error": message} with the given status
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// The runServe function implements the serve subcommand, which runs the analyzer as a long-lived HTTP service for
+// containers. Every setting can come from the environment (CSV_ANALYZER_ADDR, CSV_ANALYZER_MAX_UPLOAD_MB,
+// CSV_ANALYZER_SHUTDOWN_TIMEOUT, CSV_ANALYZER_DATA_DIR, CSV_ANALYZER_KEEP_RUNS, CSV_ANALYZER_KEEP_DAYS, and CSV_ANALYZER_<FLAG> for any analyzer flag), so an image
+// needs no arguments. CSV_ANALYZER_API_KEYS lists "tenant=key" pairs; when set, every analysis needs a key and is kept
+// apart from other tenants' data.
+// On SIGTERM or SIGINT the server reports not-ready, stops accepting connections and lets running analyses finish
+// for up to the shutdown timeout before exiting.
+// runServe serves analyses over HTTP until it is told to stop
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", envOrDefault(serveEnvPrefix+"ADDR", defaultServeAddr), "`address` to listen on (env CSV_ANALYZER_ADDR)")
+	grpcAddr := fs.String("grpc-addr", os.Getenv(serveEnvPrefix+"GRPC_ADDR"), "also serve the gRPC Analyzer service of analyzer.proto on `address`, e.g. :9090 (env CSV_ANALYZER_GRPC_ADDR)")
+	maxUploadMB := fs.Int64("max-upload-mb", defaultMaxUploadMB, "largest accepted upload in `MiB` (env CSV_ANALYZER_MAX_UPLOAD_MB)")
+	dataDir := fs.String("data-dir", os.Getenv(serveEnvPrefix+"DATA_DIR"), "keep uploads and report history per tenant under `dir` (env CSV_ANALYZER_DATA_DIR)")
+	keepRuns := fs.Int("keep-runs", 0, "with --data-dir, keep only the latest `N` reports per dataset; 0 keeps all (env CSV_ANALYZER_KEEP_RUNS)")
+	keepDays := fs.Int("keep-days", 0, "with --data-dir, delete reports and uploads older than `days`; 0 keeps them (env CSV_ANALYZER_KEEP_DAYS)")
+	shutdownTimeout := fs.Duration("shutdown-timeout", defaultShutdownTimeout, "how long running analyses may take to finish on SIGTERM, as a `duration` (env CSV_ANALYZER_SHUTDOWN_TIMEOUT)")
+	// The environment provides the defaults; flags given on the command line win.
+	for _, name := range []string{"max-upload-mb", "keep-runs", "keep-days", "shutdown-timeout"} {
+		if value, ok := os.LookupEnv(envName(name)); ok {
+			if err := fs.Set(name, value); err != nil {
+				log.Fatalf("Invalid %s: %v", envName(name), err)
+			}
+		}
+	}
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . serve [--addr :8080] [--grpc-addr :9090] [--data-dir dir [--keep-runs N] [--keep-days days]] [--max-upload-mb 100] [--shutdown-timeout 30s]")
+		fmt.Fprintln(os.Stderr, "\nAnalyzer options are set per request as query parameters, or for every request with CSV_ANALYZER_<FLAG> variables.")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if *maxUploadMB < 1 {
+		log.Fatal("Invalid options: --max-upload-mb must be at least 1")
+	}
+	if *keepRuns < 0 || *keepDays < 0 {
+		log.Fatal("Invalid options: --keep-runs and --keep-days cannot be negative")
+	}
+	// Checks the environment defaults once at startup instead of failing every request.
+	defaults := serveOptionDefaults()
+	if _, err := AnalyzeOptions(defaults); err != nil {
+		log.Fatal("Invalid analyzer options in the environment: ", err)
+	}
+
+	// API keys are only read from the environment so they never show up in process listings.
+	keys, err := parseTenantKeys(os.Getenv(serveEnvPrefix + "API_KEYS"))
+	if err != nil {
+		log.Fatal("Invalid CSV_ANALYZER_API_KEYS: ", err)
+	}
+
+	server := &analysisServer{defaults: defaults, maxUpload: *maxUploadMB << 20, keys: keys}
+	if *dataDir != "" {
+		server.store = &tenantStore{dir: *dataDir, retention: Retention{KeepRuns: *keepRuns, KeepDays: *keepDays}}
+		log.Printf("Keeping %s of stored data in %s", server.store.retention, *dataDir)
+		if server.store.retention.enabled() {
+			go server.store.sweep()
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze", server.handleAnalyze)
+	mux.HandleFunc("/history", server.handleHistory)
+	mux.HandleFunc("/healthz", server.handleHealth)
+	mux.HandleFunc("/readyz", server.handleReady)
+	httpServer := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	// gRPC clients speak HTTP/2 without TLS (h2c) from the first byte, so the gRPC listener accepts nothing else.
+	var grpcServer *http.Server
+	if *grpcAddr != "" {
+		grpcServer = &http.Server{Addr: *grpcAddr, Handler: http.HandlerFunc(server.handleGRPC), ReadHeaderTimeout: 10 * time.Second}
+		grpcServer.Protocols = new(http.Protocols)
+		grpcServer.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	// Shuts down gracefully on the signals container runtimes send.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		sig := <-stop
+		log.Printf("Received %v, shutting down", sig)
+		server.ready.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown did not complete: %v", err)
+		}
+		if grpcServer != nil {
+			if err := grpcServer.Shutdown(ctx); err != nil {
+				log.Printf("gRPC shutdown did not complete: %v", err)
+			}
+		}
+		close(done)
+	}()
+
+	server.ready.Store(true)
+	if grpcServer != nil {
+		go func() {
+			log.Printf("Serving gRPC analyses on %s", *grpcAddr)
+			if err := grpcServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal("Error serving gRPC:", err)
+			}
+		}()
+	}
+	log.Printf("Serving analyses on %s", *addr)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal("Error serving:", err)
+	}
+	<-done
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer returns a ready server without API keys or storage whose requests start from defaults
+func newTestServer(defaults map[string]string) *analysisServer {
+	if defaults == nil {
+		defaults = map[string]string{}
+	}
+	s := &analysisServer{defaults: defaults, maxUpload: 1 << 20}
+	s.ready.Store(true)
+	return s
+}
+
+// postTestAnalyze sends POST /analyze with the given options to s and returns the status and the decoded response
+func postTestAnalyze(t *testing.T, s *analysisServer, options url.Values, body string) (int, map[string]any) {
+	t.Helper()
+	options.Set("name", "data.csv")
+	w := httptest.NewRecorder()
+	s.handleAnalyze(w, httptest.NewRequest(http.MethodPost, "/analyze?"+options.Encode(), strings.NewReader(body)))
+	var response map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, response
+}
+
+// grpcTestRequest encodes an AnalyzeFileRequest for data.csv with the given content and options
+func grpcTestRequest(content string, options map[string]string) []byte {
+	request := appendProtoBytes(nil, 1, []byte("data.csv"))
+	request = appendProtoBytes(request, 2, []byte(content))
+	for key, value := range options {
+		entry := appendProtoBytes(appendProtoBytes(nil, 1, []byte(key)), 2, []byte(value))
+		request = appendProtoBytes(request, 3, entry)
+	}
+	return request
+}
+
+func TestServerOnlyOptionsAreRefused(t *testing.T) {
+	// A readable file on the server, which the request must not get to open.
+	path := writeTestCSV(t, "secret,contents\n")
+	for _, option := range []string{"config", "dictionary", "expect-schema", "rules"} {
+		want := "option " + option + " can only be set on the server (" + envName(option) + ")"
+		status, response := postTestAnalyze(t, newTestServer(nil), url.Values{option: {path}}, "a\n1\n")
+		if status != http.StatusBadRequest || response["error"] != want {
+			t.Errorf("REST %s: status %d, %v; want 400 and %q", option, status, response, want)
+		}
+		for _, method := range []string{"AnalyzeFile", "GetStats"} {
+			_, code, message := callTestGRPC(t, newTestServer(nil), method, grpcTestRequest("a\n1\n", map[string]string{option: path}))
+			if code != "3" || message != want {
+				t.Errorf("gRPC %s %s: status %s %q, want 3 (invalid argument) and %q", method, option, code, message, want)
+			}
+		}
+	}
+}
+
+func TestServerFileOptionsComeFromTheEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.yaml")
+	schema := filepath.Join(dir, "schema.json")
+	secret := filepath.Join(dir, "passwd")
+	for path, content := range map[string]string{
+		rules:  "a: {max: 1}\n",
+		schema: `[{"name": "a", "type": "Numeric", "nullable": false}]`,
+		secret: "root:x:0:0:root:/root:/bin/bash\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defaults := map[string]string{"rules": rules, "expect-schema": schema}
+
+	// The server's own files are loaded through analyzeToReport...
+	status, response := postTestAnalyze(t, newTestServer(defaults), url.Values{}, "a\n1\n5\n")
+	if results, _ := response["rules"].([]any); status != http.StatusOK || len(results) != 1 {
+		t.Fatalf("REST with server rules: status %d, rules %v", status, response["rules"])
+	}
+	reply, code, message := callTestGRPC(t, newTestServer(defaults), "AnalyzeFile", grpcTestRequest("a\n1\n5\n", nil))
+	if code != "0" || !strings.Contains(string(reply), `"rules":[{`) {
+		t.Fatalf("gRPC with server rules: status %s %q", code, message)
+	}
+
+	// ...while a client cannot point them at other files, or learn what those files hold.
+	for _, option := range []string{"rules", "expect-schema"} {
+		status, response := postTestAnalyze(t, newTestServer(defaults), url.Values{option: {secret}}, "a\n1\n")
+		if text := fmt.Sprint(response["error"]); status != http.StatusBadRequest || !strings.Contains(text, "can only be set on the server") || strings.Contains(text, "root") {
+			t.Errorf("REST %s: status %d, error %q", option, status, text)
+		}
+		_, code, message := callTestGRPC(t, newTestServer(defaults), "AnalyzeFile", grpcTestRequest("a\n1\n", map[string]string{option: secret}))
+		if code != "3" || !strings.Contains(message, "can only be set on the server") || strings.Contains(message, "root") {
+			t.Errorf("gRPC %s: status %s %q", option, code, message)
+		}
+	}
+}
+
+func TestClientOptionsNameNoServerFiles(t *testing.T) {
+	flags := newOptionsFlagSet(&Options{})
+	for name := range clientOptions {
+		if flags.Lookup(name) == nil {
+			t.Errorf("client option %s is not an analyzer flag", name)
+		}
+	}
+	flags.VisitAll(func(f *flag.Flag) {
+		if placeholder, _ := flag.UnquoteUsage(f); clientOptions[f.Name] && (placeholder == "file" || placeholder == "dir" || placeholder == "url") {
+			t.Errorf("client option %s takes a %s", f.Name, placeholder)
+		}
+	})
+}
+
+func TestRequestOptionsAllowOnlyClientOptions(t *testing.T) {
+	s := newTestServer(map[string]string{"locale": "en-US"})
+	options, err := s.requestOptions(map[string]string{"locale": "de-DE", "strict": "true"})
+	if err != nil || options["locale"] != "de-DE" || options["strict"] != "true" {
+		t.Errorf("client options: %v, %v", options, err)
+	}
+	// Flags that are not client options are refused, whatever they take.
+	for _, option := range []string{"split-dir", "fill-output", "template", "max-memory", "no-cache"} {
+		want := "option " + option + " can only be set on the server (" + envName(option) + ")"
+		if _, err := s.requestOptions(map[string]string{option: "x"}); err == nil || err.Error() != want {
+			t.Errorf("%s: %v, want %s", option, err, want)
+		}
+	}
+	status, response := postTestAnalyze(t, s, url.Values{"no-such-flag": {"1"}}, "a\n1\n")
+	if text := fmt.Sprint(response["error"]); status != http.StatusBadRequest || !strings.HasPrefix(text, "invalid option no-such-flag") {
+		t.Errorf("unknown option: status %d, error %q", status, text)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Row is one data row as a Where predicate sees it, with its cells looked up by column name
+type Row struct {
+	Index   int // position of the row in the dataset it was taken from, from 0
+	dataset *Dataset
+	cells   []string
+}
+
+// Value returns the cell of the named column without surrounding whitespace, or "" when the row or dataset lacks it
+func (r Row) Value(column string) string {
+	colIndex := r.dataset.headerIndex(column)
+	if colIndex < 0 || colIndex >= len(r.cells) {
+		return ""
+	}
+	return strings.TrimSpace(r.cells[colIndex])
+}
+
+// Number returns the cell of the named column as a number, and false when it is empty or not one (see Float)
+func (r Row) Number(column string) (float64, bool) {
+	value, err := r.Float(column)
+	return value, err == nil
+}
+
+// Cells returns a copy of the row's cells in column order
+func (r Row) Cells() []string {
+	return append([]string(nil), r.cells...)
+}
+
+// derive returns a dataset with the same columns, types and sources holding the given rows
+func (d *Dataset) derive(rows [][]string) *Dataset {
+	derived := &Dataset{
+		Headers:     append([]string(nil), d.Headers...),
+		Rows:        rows,
+		NumericCols: make(map[int]bool, len(d.NumericCols)),
+		ColumnTypes: make(map[int]ColumnType, len(d.ColumnTypes)),
+		Sources:     append([]SourceFile(nil), d.Sources...),
+		Sampling:    d.Sampling,
+		RowRange:    d.RowRange,
+		// Slices parse their values the way their source does.
+		numberLocale: d.numberLocale,
+		dateLayouts:  d.dateLayouts,
+	}
+	for colIndex, numeric := range d.NumericCols {
+		derived.NumericCols[colIndex] = numeric
+	}
+	for colIndex, colType := range d.ColumnTypes {
+		derived.ColumnTypes[colIndex] = colType
+	}
+	return derived
+}
+
+// The Head method is part of the Dataset struct. Like the other slicing methods (Tail, SampleRows, Where and Select)
+// it returns a new Dataset and leaves the receiver untouched, so they compose: d.Where(paid).Head(100). The new
+// dataset keeps the column types detected on the full data, so a slice is profiled the same way as its source. Rows
+// are shared rather than copied, so callers must not modify the cells of either.
+// Head returns the first n rows of the dataset, or all of them when it has fewer
+func (d *Dataset) Head(n int) *Dataset {
+	n = clampRows(n, len(d.Rows))
+	return d.derive(append([][]string(nil), d.Rows[:n]...))
+}
+
+// Tail returns the last n rows of the dataset, or all of them when it has fewer
+func (d *Dataset) Tail(n int) *Dataset {
+	n = clampRows(n, len(d.Rows))
+	return d.derive(append([][]string(nil), d.Rows[len(d.Rows)-n:]...))
+}
+
+// clampRows limits a requested row count to what the dataset has, treating negative counts as zero
+func clampRows(n, rows int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > rows {
+		return rows
+	}
+	return n
+}
+
+// SampleRows returns a uniform random sample of n rows drawn by reservoir sampling like --sample, reproducible for
+// a given seed (0 picks a time-based seed); the dataset's Sampling records how they were chosen
+func (d *Dataset) SampleRows(n int, seed int64) *Dataset {
+	if n <= 0 {
+		return d.derive(nil)
+	}
+	sampler := newRowSampler(SamplingOptions{Size: n, Seed: seed})
+	for _, row := range d.Rows {
+		sampler.add(row)
+	}
+	sampled := d.derive(sampler.rows)
+	sampled.Sampling = sampler.info()
+	return sampled
+}
+
+// Where returns the rows for which keep returns true, in their original order
+func (d *Dataset) Where(keep func(Row) bool) *Dataset {
+	var rows [][]string
+	for rowIndex, cells := range d.Rows {
+		if keep(Row{Index: rowIndex, dataset: d, cells: cells}) {
+			rows = append(rows, cells)
+		}
+	}
+	return d.derive(rows)
+}
+
+// The Select method is part of the Dataset struct. It returns a dataset of only the named columns, in the order
+// they are named, with their types moved along. Unlike the other slicing methods it copies the rows, since every row
+// is rebuilt from the chosen cells. Unknown and repeated names are refused before anything is built.
+// Select returns the named columns of the dataset
+func (d *Dataset) Select(columns ...string) (*Dataset, error) {
+	indexes := make([]int, len(columns))
+	seen := make(map[int]bool)
+	for i, column := range columns {
+		colIndex := d.headerIndex(column)
+		if colIndex < 0 {
+			return nil, fmt.Errorf("cannot select unknown column %q", column)
+		}
+		if seen[colIndex] {
+			return nil, fmt.Errorf("column %q is selected twice", column)
+		}
+		seen[colIndex] = true
+		indexes[i] = colIndex
+	}
+
+	selected := d.derive(make([][]string, len(d.Rows)))
+	selected.Headers = make([]string, len(indexes))
+	selected.NumericCols = make(map[int]bool)
+	selected.ColumnTypes = make(map[int]ColumnType)
+	for to, from := range indexes {
+		selected.Headers[to] = d.Headers[from]
+		if d.NumericCols[from] {
+			selected.NumericCols[to] = true
+		}
+		if colType, ok := d.ColumnTypes[from]; ok {
+			selected.ColumnTypes[to] = colType
+		}
+	}
+	// Cells beyond the end of a ragged row stay empty.
+	for rowIndex, row := range d.Rows {
+		cells := make([]string, len(indexes))
+		for to, from := range indexes {
+			if from < len(row) {
+				cells[to] = row[from]
+			}
+		}
+		selected.Rows[rowIndex] = cells
+	}
+	return selected, nil
+}
+
+// Dataset returns the loaded dataset, to slice with Head, Tail, SampleRows, Where and Select
+func (ca *CSVAnalyzer) Dataset() *Dataset {
+	return ca.dataset
+}
+
+// The WithDataset method is part of the CSVAnalyzer struct. It returns a new analyzer over d that keeps this one's
+// options, configuration, rules, data dictionary and load warnings, so a slice of the data is profiled exactly like
+// the file it came from: sub := analyzer.WithDataset(analyzer.Dataset().Where(paid).Head(1000)). Derived values such
+// as the parsed numeric columns are computed afresh for the new rows.
+// WithDataset returns an analyzer of the same settings over another dataset
+func (ca *CSVAnalyzer) WithDataset(d *Dataset) *CSVAnalyzer {
+	return &CSVAnalyzer{
+		dataset:        d,
+		options:        ca.options,
+		dictionary:     ca.dictionary,
+		config:         ca.config,
+		numberLocale:   ca.numberLocale,
+		privacy:        ca.privacy,
+		loadWarnings:   ca.loadWarnings,
+		explain:        ca.explain,
+		expectedSchema: ca.expectedSchema,
+		valueRules:     ca.valueRules,
+		nullValues:     ca.nullValues,
+		dateLayouts:    ca.dateLayouts,
+	}
+}
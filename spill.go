@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// minMaxMemory is the smallest --max-memory accepted; below it almost every row would be a chunk of its own
+const minMaxMemory = 1 << 20
+
+// ByteSize is a size in bytes, given as a number with an optional unit: B, or K, M, G and T with or without a
+// trailing B or iB, all multiples of 1024 (512M, 512MB and 512MiB are the same). It implements flag.Value.
+type ByteSize int64
+
+// byteUnits are the unit suffixes of a ByteSize, longest first so "MiB" is not read as "B"
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// String renders the size with a binary unit, as the progress bar does
+func (b ByteSize) String() string {
+	return formatBytes(int64(b))
+}
+
+// Set parses a size such as "512MB", "1.5G" or "1048576"
+func (b *ByteSize) Set(value string) error {
+	number, unit := strings.ToUpper(strings.TrimSpace(value)), int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.size
+			break
+		}
+	}
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("invalid size %q (expected e.g. 512MB or 2GiB)", value)
+	}
+	*b = ByteSize(size * float64(unit))
+	return nil
+}
+
+// rowFootprint estimates the memory a row takes in the dataset: its slice header plus a string header and the bytes
+// of every cell
+func rowFootprint(record []string) int64 {
+	size := int64(24)
+	for _, cell := range record {
+		size += 16 + int64(len(cell))
+	}
+	return size
+}
+
+// The rowSpill type holds the rows of a dataset that outgrew --max-memory: chunks of rows written as CSV files to a
+// temporary directory, read back in streaming passes. It also keeps the estimated in-memory size of every column's
+// cells, to decide how many columns a pass can hold.
+type rowSpill struct {
+	dir         string
+	files       []string
+	rows        int
+	columnBytes []int64
+}
+
+// newRowSpill creates the temporary directory the chunks are written to
+func newRowSpill() (*rowSpill, error) {
+	dir, err := os.MkdirTemp("", "csv-analyzer-spill-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating spill directory: %v", err)
+	}
+	return &rowSpill{dir: dir}, nil
+}
+
+// write adds rows to the spill as one more chunk file
+func (s *rowSpill) write(rows [][]string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	path := filepath.Join(s.dir, fmt.Sprintf("chunk-%05d.csv", len(s.files)))
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating spill file: %v", err)
+	}
+	buffered := bufio.NewWriter(file)
+	writer := csv.NewWriter(buffered)
+	err = writer.WriteAll(rows)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing spill file: %v", err)
+	}
+	for _, row := range rows {
+		for colIndex, cell := range row {
+			for len(s.columnBytes) <= colIndex {
+				s.columnBytes = append(s.columnBytes, 0)
+			}
+			s.columnBytes[colIndex] += 16 + int64(len(cell))
+		}
+	}
+	s.files = append(s.files, path)
+	s.rows += len(rows)
+	return nil
+}
+
+// readColumns streams the spilled rows in order and passes the cells of the given file columns of each to visit,
+// with "" for cells a short row lacks
+func (s *rowSpill) readColumns(columns []int, visit func(cells []string)) error {
+	for _, path := range s.files {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error opening spill file: %v", err)
+		}
+		reader := csv.NewReader(bufio.NewReader(file))
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return fmt.Errorf("error reading spill file: %v", err)
+			}
+			cells := make([]string, len(columns))
+			for i, colIndex := range columns {
+				if colIndex < len(record) {
+					// Cloned, since a cell would otherwise keep the whole line it was read from in memory.
+					cells[i] = strings.Clone(record[colIndex])
+				}
+			}
+			visit(cells)
+		}
+		file.Close()
+	}
+	return nil
+}
+
+// batches groups columns, given by their position in the files, into passes whose cells fit within limit bytes;
+// a column larger than the limit gets a pass of its own
+func (s *rowSpill) batches(columns []int, limit int64) [][]int {
+	var batches [][]int
+	var batch []int
+	size := int64(0)
+	for _, colIndex := range columns {
+		columnSize := int64(0)
+		if colIndex < len(s.columnBytes) {
+			columnSize = s.columnBytes[colIndex]
+		}
+		if len(batch) > 0 && size+columnSize > limit {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		if len(batch) == 0 {
+			// Every pass holds the slice headers of its rows as well.
+			size = 24 * int64(s.rows)
+		}
+		batch = append(batch, colIndex)
+		size += columnSize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// SpillInfo records that the rows outgrew --max-memory and were profiled from disk
+type SpillInfo struct {
+	MaxMemory int64 `json:"max_memory_bytes"`
+	Chunks    int   `json:"chunks"`
+	Passes    int   `json:"passes"`
+}
+
+// spilledProfile is the report material of a spilled dataset: the profile and quality score of every column and the
+// warnings and alert results of the passes that computed them
+type spilledProfile struct {
+	info     SpillInfo
+	rows     int
+	columns  []ColumnReport
+	quality  []ColumnQuality
+	warnings []DataWarning
+	alerts   []AlertResult
+}
+
+// The spillConflicts method is part of the CSVAnalyzer struct. It lists the options that need every column or every
+// row in memory at once (relationships between columns, per-row checks, exports of the rows) and so cannot run on a
+// dataset spilled to disk, which is profiled a few columns at a time.
+// spillConflicts returns the flags that rule out spilling the rows
+func (ca *CSVAnalyzer) spillConflicts() []string {
+	opts := ca.options
+	checks := []struct {
+		flag string
+		set  bool
+	}{
+		{"--format " + FormatXLSX, opts.Format == FormatXLSX},
+		{"--check", opts.Check},
+		{"--expect-schema", opts.ExpectSchema != ""},
+		{"--rules", opts.RulesPath != "" || len(ca.valueRules) > 0},
+		{"alert rules (--config, --nonnegative, --between)", len(ca.config.Alerts) > 0},
+		{"--unique", len(opts.UniqueKeys) > 0},
+		{"--derive", len(opts.Derive) > 0},
+		{"--group-by", opts.GroupBy != ""},
+		{"--top", opts.TopN > 0},
+		{"--split-by", opts.SplitBy != ""},
+		{"--fill-output", opts.FillOutput != ""},
+		{"--outlier-output", opts.OutlierOutput != ""},
+		{"--split-on", opts.SplitOn != ""},
+		{"--scan-pii", opts.ScanPII},
+		{"--missing-matrix", opts.MissingMatrix},
+		{"--co-missing", opts.CoMissing},
+		{"--completeness-tiers", opts.CompletenessTiers},
+		{"--bootstrap", opts.Bootstrap > 0},
+		{"--correlations", opts.Correlations},
+		{"--correlation-png", opts.CorrelationPNG != ""},
+		{"--cluster-columns", opts.ClusterColumns},
+		{"--charts", opts.Charts},
+		{"--charts-dir", opts.ChartsDir != ""},
+		{"--audit-parsing", opts.AuditParsing},
+		{"--quasi-identifiers", len(opts.QuasiIdentifiers) > 0},
+		{"--rolling", opts.Rolling.enabled()},
+		{"--dp-epsilon", opts.PrivacyEpsilon > 0},
+		{"--bundle", opts.BundlePath != ""},
+		{"--ge-suite", opts.GESuitePath != ""},
+		{"--badge", opts.BadgePath != ""},
+		{"--openlineage-url", opts.Lineage.URL != ""},
+	}
+	var conflicts []string
+	for _, check := range checks {
+		if check.set {
+			conflicts = append(conflicts, check.flag)
+		}
+	}
+	return conflicts
+}
+
+// keepRowWithinMemory adds a row to the dataset and, once the rows outgrow --max-memory, writes them to a spill chunk
+func (ca *CSVAnalyzer) keepRowWithinMemory(record []string) error {
+	ca.dataset.Rows = append(ca.dataset.Rows, record)
+	ca.rowBytes += rowFootprint(record)
+	if ca.rowBytes <= int64(ca.options.MaxMemory) {
+		return nil
+	}
+	if ca.spill == nil {
+		if !ca.spillable {
+			return fmt.Errorf("the rows outgrow --max-memory %s, and this command needs all of them in memory", ca.options.MaxMemory)
+		}
+		if conflicts := ca.spillConflicts(); len(conflicts) > 0 {
+			return fmt.Errorf("the rows outgrow --max-memory %s and are spilled to disk, where %s cannot run; raise the cap or leave them out", ca.options.MaxMemory, strings.Join(conflicts, ", "))
+		}
+		spill, err := newRowSpill()
+		if err != nil {
+			return err
+		}
+		ca.spill = spill
+	}
+	if err := ca.spill.write(ca.dataset.Rows); err != nil {
+		return err
+	}
+	ca.dataset.Rows, ca.rowBytes = nil, 0
+	return nil
+}
+
+// The profileSpilled method is part of the CSVAnalyzer struct. It profiles a dataset whose rows were spilled to
+// disk under --max-memory. Columns are analyzed independently of each other, so the spill is read back in streaming
+// passes that each load only as many columns as fit within the cap, and every batch runs through prepareDataset and
+// BuildReport like a dataset of its own: types, statistics, cardinality, patterns and histograms come out the same
+// as in memory. Drops and renames are worked out once on the header, against a probe row of column numbers that says
+// where every remaining column is in the files. LoadCSV removes the spill files afterwards; a single column larger
+// than the cap is still loaded whole, so the cap bounds the rows of a pass rather than of every column.
+// profileSpilled computes the column profiles of a spilled dataset
+func (ca *CSVAnalyzer) profileSpilled() error {
+	if err := ca.spill.write(ca.dataset.Rows); err != nil {
+		return err
+	}
+	ca.dataset.Rows, ca.rowBytes = nil, 0
+	ca.dataset.Headers = disambiguateHeaders(ca.dataset.Headers)
+
+	probe := make([]string, len(ca.dataset.Headers))
+	for i := range probe {
+		probe[i] = strconv.Itoa(i)
+	}
+	ca.dataset.Rows = [][]string{probe}
+	if err := ca.applyColumnTransforms(); err != nil {
+		return err
+	}
+	columns := make([]int, len(ca.dataset.Headers))
+	position := make(map[int]int)
+	for i, cell := range ca.dataset.Rows[0] {
+		columns[i], _ = strconv.Atoi(cell)
+		position[columns[i]] = i
+	}
+	ca.dataset.Rows = nil
+	// Every pass sees only its own columns, so the names of --types, --na-policy and --recode are checked against all
+	// of them here.
+	for column := range ca.options.TypeOverrides {
+		if err := ca.checkColumnsExist("--types", []string{column}); err != nil {
+			return err
+		}
+	}
+	for column := range ca.options.NAPolicy.Columns {
+		if err := ca.checkColumnsExist("--na-policy", []string{column}); err != nil {
+			return err
+		}
+	}
+	if err := ca.checkColumnsExist("--recode", ca.options.Recode.columns()); err != nil {
+		return err
+	}
+
+	batches := ca.spill.batches(columns, int64(ca.options.MaxMemory))
+	endStep := ca.explain.step("profile spilled rows", fmt.Sprintf("%d chunks, %d passes", len(ca.spill.files), len(batches)), ca.spill.rows)
+	profile := &spilledProfile{
+		info: SpillInfo{MaxMemory: int64(ca.options.MaxMemory), Chunks: len(ca.spill.files), Passes: len(batches)},
+		rows: ca.spill.rows,
+	}
+	for _, batch := range batches {
+		names := make([]string, len(batch))
+		for i, colIndex := range batch {
+			names[i] = ca.dataset.Headers[position[colIndex]]
+		}
+		pass := NewCSVAnalyzerWithOptions(ca.spilledPassOptions(names))
+		pass.dataset.Headers = names
+		if err := ca.spill.readColumns(batch, func(cells []string) {
+			pass.dataset.Rows = append(pass.dataset.Rows, cells)
+		}); err != nil {
+			return err
+		}
+		if err := pass.prepareDataset(); err != nil {
+			return err
+		}
+		report := pass.BuildReport()
+		profile.columns = append(profile.columns, report.Columns...)
+		if report.Quality != nil {
+			profile.quality = append(profile.quality, report.Quality.Columns...)
+		}
+		profile.warnings = append(profile.warnings, report.Warnings...)
+		profile.alerts = append(profile.alerts, report.Alerts...)
+	}
+	// Restores the header order, since a pass holds the columns in file order.
+	sort.SliceStable(profile.columns, func(i, j int) bool {
+		return ca.columnIndex(profile.columns[i].Name) < ca.columnIndex(profile.columns[j].Name)
+	})
+	sort.SliceStable(profile.quality, func(i, j int) bool {
+		return ca.columnIndex(profile.quality[i].Column) < ca.columnIndex(profile.quality[j].Column)
+	})
+	endStep(ca.spill.rows)
+	ca.spilled = profile
+	return nil
+}
+
+// removeSpill deletes the spill files, once they were profiled or when loading failed
+func (ca *CSVAnalyzer) removeSpill() {
+	if ca.spill != nil {
+		os.RemoveAll(ca.spill.dir)
+		ca.spill = nil
+	}
+}
+
+// spilledPassOptions are the options of a pass over some columns of a spilled dataset: the run's own, without the
+// steps already applied to the whole header and with the per-column settings narrowed to the pass's columns
+func (ca *CSVAnalyzer) spilledPassOptions(names []string) Options {
+	opts := ca.options
+	opts.MaxMemory, opts.Drop, opts.Renames, opts.Explain = 0, nil, nil, false
+	in := make(map[string]bool)
+	for _, name := range names {
+		in[name] = true
+	}
+	opts.TypeOverrides = nil
+	for column, colType := range ca.options.TypeOverrides {
+		if in[column] {
+			if opts.TypeOverrides == nil {
+				opts.TypeOverrides = make(TypeOverrides)
+			}
+			opts.TypeOverrides[column] = colType
+		}
+	}
+	opts.NAPolicy.Columns = nil
+	for column, policy := range ca.options.NAPolicy.Columns {
+		if in[column] {
+			if opts.NAPolicy.Columns == nil {
+				opts.NAPolicy.Columns = make(map[string]string)
+			}
+			opts.NAPolicy.Columns[column] = policy
+		}
+	}
+	opts.Recode = nil
+	for _, recode := range ca.options.Recode {
+		if in[recode.Column] {
+			opts.Recode = append(opts.Recode, recode)
+		}
+	}
+	return opts
+}
+
+// buildSpilledReport assembles the report of a spilled dataset from its column profiles
+func (ca *CSVAnalyzer) buildSpilledReport() Report {
+	report := Report{
+		Analyzer:    currentBuildInfo(),
+		Rows:        ca.spilled.rows,
+		ColumnCount: len(ca.dataset.Headers),
+		Sources:     ca.dataset.Sources,
+		Exact:       true,
+		RowRange:    ca.dataset.RowRange,
+		Spill:       &ca.spilled.info,
+		Quality:     ca.Quality(),
+		Warnings:    ca.DataWarnings(),
+		Alerts:      ca.spilled.alerts,
+	}
+	for _, column := range ca.spilled.columns {
+		// The data dictionary is loaded after the passes ran, so its annotations are added here.
+		annotation := ca.annotation(column.Name)
+		column.Description, column.Unit = annotation.Description, annotation.Unit
+		report.Columns = append(report.Columns, column)
+	}
+	report.PrimaryKeys = ca.primaryKeyCandidates(spilledCardinality(report.Columns))
+	return report
+}
+
+// spilledCardinality collects the cardinality of every column profiled from a spill, in header order
+func spilledCardinality(columns []ColumnReport) []ColumnCardinality {
+	cardinality := make([]ColumnCardinality, 0, len(columns))
+	for _, column := range columns {
+		if column.Cardinality == nil {
+			return nil
+		}
+		cardinality = append(cardinality, *column.Cardinality)
+	}
+	return cardinality
+}
+
+// printSpilledReport prints the text report of a spilled dataset: its shape and quality score, the statistics of
+// every column and their cardinality
+func (ca *CSVAnalyzer) printSpilledReport() {
+	report := ca.BuildReport()
+	fmt.Println("=== CSV Analysis Report ===")
+	fmt.Printf("Analyzer: %s\n", report.Analyzer)
+	fmt.Printf("Dataset: %d rows, %d columns\n", report.Rows, report.ColumnCount)
+	if rowRange := report.RowRange; rowRange != nil {
+		fmt.Printf("Rows: %d to %d of the input only (--rows %s)\n", rowRange.FirstRow, rowRange.LastRow, rowRange.Range)
+	}
+	fmt.Println("Statistics: exact (all rows)")
+	fmt.Printf("Memory: rows over --max-memory %s spilled to disk in %d chunks and profiled in %d passes\n",
+		formatBytes(report.Spill.MaxMemory), report.Spill.Chunks, report.Spill.Passes)
+	fmt.Println("        only column statistics, quality and cardinality are shown; --format json has the full column profiles")
+	printQualitySummary(report.Quality)
+	fmt.Println()
+	if len(report.Sources) > 1 {
+		fmt.Printf("Source Files (%d):\n", len(report.Sources))
+		for _, source := range report.Sources {
+			fmt.Printf(" %s: %d rows\n", source.Path, source.Rows)
+		}
+		fmt.Println()
+	}
+	printQuality(report.Quality)
+
+	fmt.Println("Column Information")
+	var stats []ColumnStats
+	var textStats []TextColumnStats
+	var booleanStats []BooleanColumnStats
+	var dateStats []DateColumnStats
+	for _, column := range report.Columns {
+		fmt.Printf(" %s: %s", labelWithUnit(column.Name, column.Unit), column.Type)
+		if column.Mixture != nil {
+			fmt.Printf(" (mixed: %s)", column.Mixture.describe())
+		}
+		if column.Description != "" {
+			fmt.Printf(" - %s", column.Description)
+		}
+		fmt.Println()
+		if column.Numeric != nil {
+			stats = append(stats, *column.Numeric)
+		}
+		if column.Text != nil {
+			textStats = append(textStats, *column.Text)
+		}
+		if column.Boolean != nil {
+			booleanStats = append(booleanStats, *column.Boolean)
+		}
+		if column.Date != nil {
+			dateStats = append(dateStats, *column.Date)
+		}
+	}
+	fmt.Println()
+	found := ca.printColumnStats(stats, textStats, booleanStats, dateStats)
+	ca.printCardinality(spilledCardinality(report.Columns))
+	printAlerts(report.Alerts)
+	if !found {
+		fmt.Println("No columns found for analysis.")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpilledQualityMatchesInMemory(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("id,amount,status,note\n")
+	for i := 0; i < 30000; i++ {
+		amount, status := fmt.Sprint(i%977), []string{"open", "closed", "OPEN", ""}[i%4]
+		if i%50 == 0 {
+			amount = "n/a"
+		}
+		fmt.Fprintf(&b, "%d,%s,%s,note number %d for the spill test\n", i%29000, amount, status, i)
+	}
+	inMemory := loadTestCSV(t, b.String()).Quality()
+	spilledAnalyzer := NewCSVAnalyzerWithOptions(testOptions(t, "--max-memory", "1M"))
+	spilledAnalyzer.spillable = true
+	if err := spilledAnalyzer.LoadCSV(writeTestCSV(t, b.String())); err != nil {
+		t.Fatal(err)
+	}
+	if spilledAnalyzer.spilled == nil {
+		t.Fatal("rows were not spilled")
+	}
+	spilled := spilledAnalyzer.Quality()
+	if !reflect.DeepEqual(spilled, inMemory) {
+		t.Errorf("spilled quality %+v, in memory %+v", spilled, inMemory)
+	}
+	text, err := renderTextReport(spilledAnalyzer.PrintReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\nQuality: ", "\nData Quality (0-100"} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("spilled text report lacks %q", strings.TrimSpace(want))
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// splitReportExtensions maps a report format to the file extension of the per-segment reports
+var splitReportExtensions = map[string]string{
+	FormatText:      ".txt",
+	FormatJSON:      ".json",
+	FormatHTMLCards: ".html",
+	FormatJSONCards: ".jsonl",
+	FormatLong:      ".csv",
+	FormatMsgpack:   ".msgpack",
+	FormatXLSX:      ".xlsx",
+}
+
+// SplitSegment is one value of the --split-by column and the files written for its rows
+type SplitSegment struct {
+	Value  string
+	Rows   int
+	Report string
+	CSV    string
+}
+
+// The WriteSplitReports method is part of the CSVAnalyzer struct. With --split-by it splits the rows by the value
+// of a column, largest segment first (empty values form a segment of their own), and writes a full report of each
+// segment in the chosen --format, or through the --template, into --split-dir, profiled with the same options and
+// column types as the whole file; --split-csv also writes each segment's rows as CSV. Files are named after their position and value
+// (01_Electronics.json), which keeps them unique when two values map to the same safe name, and index.csv lists
+// which value went into which files. Text reports are written uncoloured.
+// WriteSplitReports writes a report per segment and returns the segments
+func (ca *CSVAnalyzer) WriteSplitReports(dir string, tmpl *ReportTemplate) ([]SplitSegment, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating split directory: %v", err)
+	}
+	endStep := ca.explain.stepOnce("split", ca.options.SplitBy, len(ca.dataset.Rows))
+	byIndex := ca.columnIndex(ca.options.SplitBy)
+	rowsOf := make(map[string][][]string)
+	for _, row := range ca.dataset.Rows {
+		value := ""
+		if byIndex < len(row) {
+			value = strings.TrimSpace(row[byIndex])
+		}
+		rowsOf[value] = append(rowsOf[value], row)
+	}
+	values := make([]string, 0, len(rowsOf))
+	for value := range rowsOf {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(rowsOf[values[i]]) != len(rowsOf[values[j]]) {
+			return len(rowsOf[values[i]]) > len(rowsOf[values[j]])
+		}
+		return values[i] < values[j]
+	})
+
+	var segments []SplitSegment
+	for i, value := range values {
+		name := value
+		if name == "" {
+			name = "empty"
+		}
+		base := fmt.Sprintf("%02d_%s", i+1, safeFileName(name))
+		segment := SplitSegment{Value: value, Rows: len(rowsOf[value]), Report: base + splitReportExtensions[ca.options.Format]}
+		if tmpl != nil {
+			segment.Report = base + tmpl.extension()
+		}
+		sub := ca.WithDataset(ca.dataset.derive(rowsOf[value]))
+		out, err := createOutputFile(filepath.Join(dir, segment.Report), ca.options.Compress)
+		if err != nil {
+			return nil, fmt.Errorf("error creating split report: %v", err)
+		}
+		err = sub.writeSplitReport(out, tmpl)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ca.options.SplitCSV {
+			segment.CSV = base + ".data.csv"
+			if err := sub.writeSplitRows(filepath.Join(dir, segment.CSV)); err != nil {
+				return nil, err
+			}
+		}
+		segment.Report = compressedPath(segment.Report, ca.options.Compress)
+		if segment.CSV != "" {
+			segment.CSV = compressedPath(segment.CSV, ca.options.Compress)
+		}
+		segments = append(segments, segment)
+	}
+	endStep(len(segments))
+	return segments, writeSplitIndex(filepath.Join(dir, "index.csv"), ca.options.SplitBy, segments)
+}
+
+// writeSplitReport writes the report of a segment through the template, or else in the chosen format
+func (ca *CSVAnalyzer) writeSplitReport(w io.Writer, tmpl *ReportTemplate) error {
+	if tmpl != nil {
+		return ca.WriteTemplateReport(w, tmpl)
+	}
+	switch ca.options.Format {
+	case FormatJSON:
+		return ca.WriteJSONReport(w)
+	case FormatLong:
+		return ca.WriteLongReport(w)
+	case FormatMsgpack:
+		return ca.WriteMsgpackReport(w)
+	case FormatXLSX:
+		return ca.WriteXLSXReport(w)
+	case FormatHTMLCards, FormatJSONCards:
+		return ca.WriteCards(w, ca.options.Format)
+	}
+	text, err := renderTextReport(ca.PrintReport)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(text)
+	return err
+}
+
+// writeSplitRows writes the rows of a segment, with the header, as CSV
+func (ca *CSVAnalyzer) writeSplitRows(path string) error {
+	out, err := createOutputFile(path, ca.options.Compress)
+	if err != nil {
+		return fmt.Errorf("error creating split CSV: %v", err)
+	}
+	err = writeCSVRecords(out, append([][]string{ca.dataset.Headers}, ca.dataset.Rows...))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeSplitIndex writes index.csv, the value, row count and files of every segment
+func writeSplitIndex(path, column string, segments []SplitSegment) error {
+	records := [][]string{{column, "rows", "report", "csv"}}
+	for _, segment := range segments {
+		records = append(records, []string{segment.Value, strconv.Itoa(segment.Rows), segment.Report, segment.CSV})
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating split index: %v", err)
+	}
+	err = writeCSVRecords(out, records)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// The renderTextReport function runs a function that prints to stdout, such as PrintReport, with stdout redirected
+// to a temporary file and colours off, and returns what it printed. A file rather than a pipe keeps a large report
+// from blocking on a full pipe buffer.
+// renderTextReport returns the text printed by print
+func renderTextReport(print func()) ([]byte, error) {
+	file, err := os.CreateTemp("", "csv-analyzer-report-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary report file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	original, terminal := os.Stdout, stdoutIsTerminal
+	os.Stdout, stdoutIsTerminal = file, false
+	print()
+	os.Stdout, stdoutIsTerminal = original, terminal
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading temporary report file: %v", err)
+	}
+	return io.ReadAll(file)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQL dialects of convert --to sql
+const (
+	SQLPostgres = "postgres"
+	SQLMySQL    = "mysql"
+	SQLSQLite   = "sqlite"
+)
+
+// sqlDialects lists the --dialect values, the default first
+var sqlDialects = []string{SQLPostgres, SQLMySQL, SQLSQLite}
+
+// defaultSQLBatchSize is how many rows convert --to sql puts in one INSERT statement
+const defaultSQLBatchSize = 500
+
+// sqlColumn is a column of the exported table: its name and declared type, and how its cells are written
+type sqlColumn struct {
+	name     string
+	declared string
+	colType  ColumnType
+	dateOnly bool
+}
+
+// quoteSQLIdentifier quotes a table or column name for a dialect: in backticks for MySQL, double quotes otherwise
+func quoteSQLIdentifier(dialect, name string) string {
+	if dialect == SQLMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return quoteSQLiteIdentifier(name)
+}
+
+// quoteSQLString writes a string literal; MySQL also treats backslashes as escapes by default
+func quoteSQLString(dialect, value string) string {
+	if dialect == SQLMySQL {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// The sqlColumns method is part of the CSVAnalyzer struct. It declares the columns of the exported table from their
+// detected types: numeric columns get the narrowest type their written format fits (SMALLINT to BIGINT, NUMERIC(p,s)
+// or DOUBLE PRECISION, as in the numeric format of the report), booleans BOOLEAN, dates DATE, or TIMESTAMP (DATETIME
+// in MySQL) when any value has a time of day, and text TEXT. SQLite gets the INTEGER, REAL and TEXT of convert --to
+// sqlite, since it has no separate date or boolean types.
+// sqlColumns returns the columns of the exported table
+func (ca *CSVAnalyzer) sqlColumns(dialect string) []sqlColumn {
+	columns := make([]sqlColumn, len(ca.dataset.Headers))
+	for colIndex, name := range sqlColumnNames(ca.dataset.Headers) {
+		column := sqlColumn{name: name, declared: "TEXT", colType: ca.columnType(colIndex), dateOnly: true}
+		if column.colType == TypeDate {
+			dates, _ := ca.extractDateValues(colIndex)
+			for _, t := range dates {
+				column.dateOnly = column.dateOnly && t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+			}
+		}
+		switch {
+		case dialect == SQLSQLite:
+			column.declared = ca.sqliteColumnType(colIndex)
+		case column.colType == TypeNumeric:
+			column.declared = "DOUBLE PRECISION"
+			if format := ca.numericFormat(colIndex); format != nil {
+				column.declared = format.SQLType
+			}
+		case column.colType == TypeBoolean:
+			column.declared = "BOOLEAN"
+		case column.colType == TypeDate && column.dateOnly:
+			column.declared = "DATE"
+		case column.colType == TypeDate && dialect == SQLMySQL:
+			column.declared = "DATETIME"
+		case column.colType == TypeDate:
+			column.declared = "TIMESTAMP"
+		}
+		columns[colIndex] = column
+	}
+	return columns
+}
+
+// sqlLiteral writes a cell as a literal of its column, reporting false in ok when the cell does not parse as the
+// column's type and is written as NULL; SQLite keeps such cells as text, which its flexible typing allows
+func (ca *CSVAnalyzer) sqlLiteral(dialect string, colIndex int, column sqlColumn, cell string) (literal string, ok bool) {
+	value := strings.TrimSpace(cell)
+	if value == "" {
+		return "NULL", true
+	}
+	switch column.colType {
+	case TypeNumeric:
+		// NaN and the infinities have no standard literal.
+		if num, err := ca.parseNumber(value); err == nil && !math.IsNaN(num) && !math.IsInf(num, 0) {
+			switch {
+			case column.declared == "INTEGER" || column.declared == "SMALLINT" || column.declared == "BIGINT":
+				return strconv.FormatFloat(num, 'f', 0, 64), true
+			case strings.HasPrefix(column.declared, "NUMERIC"):
+				return strconv.FormatFloat(num, 'f', -1, 64), true
+			}
+			return strconv.FormatFloat(num, 'g', -1, 64), true
+		}
+	case TypeBoolean:
+		if truth, parsed := parseBoolean(value); parsed {
+			switch {
+			case dialect == SQLSQLite && truth:
+				return "1", true
+			case dialect == SQLSQLite:
+				return "0", true
+			case truth:
+				return "TRUE", true
+			default:
+				return "FALSE", true
+			}
+		}
+	case TypeDate:
+		// Times are written in UTC without a zone, which every dialect's timestamp type reads; SQLite keeps the
+		// ISO 8601 text of convert --to sqlite.
+		if t, parsed := ca.parseDate(value); parsed {
+			if dialect == SQLSQLite {
+				return quoteSQLString(dialect, formatDate(t)), true
+			}
+			if column.dateOnly {
+				return quoteSQLString(dialect, t.Format("2006-01-02")), true
+			}
+			return quoteSQLString(dialect, t.UTC().Format(time.DateTime)), true
+		}
+	default:
+		return quoteSQLString(dialect, cell), true
+	}
+	if dialect == SQLSQLite {
+		return quoteSQLString(dialect, cell), true
+	}
+	return "NULL", false
+}
+
+// The WriteSQL method is part of the CSVAnalyzer struct. It writes the dataset as a SQL script for a dialect
+// (postgres, mysql or sqlite): a CREATE TABLE with the column types of sqlColumns followed by INSERT statements of
+// batchSize rows each, all in one transaction, so a profiled CSV can be loaded with psql, mysql or sqlite3 right away.
+// Empty cells become NULL, booleans TRUE and FALSE (1 and 0 in SQLite) and dates ISO 8601 strings. Cells that do not
+// parse as their column's type would make the INSERT fail, so they are written as NULL and counted; the rows are
+// written as they are read, without holding the script in memory.
+// WriteSQL writes the dataset as SQL statements and returns how many cells were written as NULL for not parsing
+func (ca *CSVAnalyzer) WriteSQL(w io.Writer, table, dialect string, batchSize int) (int, error) {
+	if len(ca.dataset.Headers) == 0 {
+		return 0, fmt.Errorf("error writing SQL: the data has no columns")
+	}
+	columns := ca.sqlColumns(dialect)
+	quotedTable := quoteSQLIdentifier(dialect, table)
+	definitions := make([]string, len(columns))
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteSQLIdentifier(dialect, column.name)
+		definitions[i] = "  " + names[i] + " " + column.declared
+	}
+
+	buffered := bufio.NewWriter(w)
+	fmt.Fprintf(buffered, "-- %d rows written by %s\n", len(ca.dataset.Rows), currentBuildInfo())
+	if dialect == SQLMySQL {
+		buffered.WriteString("START TRANSACTION;\n")
+	} else {
+		buffered.WriteString("BEGIN;\n")
+	}
+	fmt.Fprintf(buffered, "CREATE TABLE %s (\n%s\n);\n", quotedTable, strings.Join(definitions, ",\n"))
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", quotedTable, strings.Join(names, ", "))
+	unparsed := 0
+	literals := make([]string, len(columns))
+	for rowIndex, row := range ca.dataset.Rows {
+		if rowIndex%batchSize == 0 {
+			buffered.WriteString(insert)
+		}
+		for colIndex, column := range columns {
+			cell := ""
+			if colIndex < len(row) {
+				cell = row[colIndex]
+			}
+			literal, ok := ca.sqlLiteral(dialect, colIndex, column, cell)
+			if !ok {
+				unparsed++
+			}
+			literals[colIndex] = literal
+		}
+		end := ",\n"
+		if rowIndex%batchSize == batchSize-1 || rowIndex == len(ca.dataset.Rows)-1 {
+			end = ";\n"
+		}
+		buffered.WriteString("  (" + strings.Join(literals, ", ") + ")" + end)
+	}
+	buffered.WriteString("COMMIT;\n")
+	if err := buffered.Flush(); err != nil {
+		return unparsed, fmt.Errorf("error writing SQL: %v", err)
+	}
+	return unparsed, nil
+}
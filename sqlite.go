@@ -0,0 +1,785 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// sqliteMagic starts every SQLite 3 database file
+const sqliteMagic = "SQLite format 3\x00"
+
+// B-tree page types of the SQLite file format
+const (
+	sqliteInteriorIndex = 0x02
+	sqliteInteriorTable = 0x05
+	sqliteLeafIndex     = 0x0a
+	sqliteLeafTable     = 0x0d
+)
+
+// Limits of the SQLite writer: the column limit is SQLite's default SQLITE_MAX_COLUMN, and the page sizes are tried
+// from the smallest until the table definition fits on the first page
+const (
+	sqliteMaxColumns   = 2000
+	sqliteMinPageSize  = 4096
+	sqliteMaxPageSize  = 65536
+	sqliteMaxDepth     = 64      // b-tree levels followed before a file is considered corrupt
+	sqliteVersionStamp = 3045000 // the SQLITE_VERSION_NUMBER written into the header
+)
+
+// isSQLiteInput reports whether a file should be read as a SQLite database, judging by its extension
+func isSQLiteInput(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".db", ".db3", ".sqlite", ".sqlite3":
+		return true
+	}
+	return false
+}
+
+// appendSQLiteVarint appends v in SQLite's big-endian variable-length encoding: seven bits per byte with the high
+// bit marking continuation, except that a ninth byte carries eight
+func appendSQLiteVarint(buf []byte, v uint64) []byte {
+	if v > 0x00ffffffffffffff {
+		var full [9]byte
+		full[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			full[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, full[:]...)
+	}
+	var groups [8]byte
+	n := 0
+	for {
+		groups[n] = byte(v & 0x7f)
+		n++
+		if v >>= 7; v == 0 {
+			break
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		if i > 0 {
+			groups[i] |= 0x80
+		}
+		buf = append(buf, groups[i])
+	}
+	return buf
+}
+
+// sqliteVarint decodes a varint and returns it with the number of bytes it took
+func sqliteVarint(buf []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(buf[i]&0x7f)
+		if buf[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(buf[8]), 9
+}
+
+// sqliteVarintLen is the encoded length of a varint
+func sqliteVarintLen(v uint64) int {
+	return len(appendSQLiteVarint(nil, v))
+}
+
+// sqliteRecord encodes values (nil, int64, float64 or string) in SQLite's record format: a header of serial types
+// followed by the values, integers in the fewest bytes that hold them
+func sqliteRecord(values []interface{}) []byte {
+	var types, body []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			types = appendSQLiteVarint(types, 0)
+		case int64:
+			switch {
+			case v == 0:
+				types = appendSQLiteVarint(types, 8)
+			case v == 1:
+				types = appendSQLiteVarint(types, 9)
+			default:
+				// Serial types 1 to 6 hold 1, 2, 3, 4, 6 and 8 bytes.
+				serial, size := uint64(6), 8
+				for i, bytes := range []int{1, 2, 3, 4, 6} {
+					if limit := int64(1) << (8*bytes - 1); v >= -limit && v < limit {
+						serial, size = uint64(i+1), bytes
+						break
+					}
+				}
+				types = appendSQLiteVarint(types, serial)
+				for shift := 8 * (size - 1); shift >= 0; shift -= 8 {
+					body = append(body, byte(v>>shift))
+				}
+			}
+		case float64:
+			types = appendSQLiteVarint(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types = appendSQLiteVarint(types, uint64(13+2*len(v)))
+			body = append(body, v...)
+		}
+	}
+	// The header length counts its own varint.
+	headerLen := len(types) + 1
+	for len(types)+sqliteVarintLen(uint64(headerLen)) != headerLen {
+		headerLen = len(types) + sqliteVarintLen(uint64(headerLen))
+	}
+	record := appendSQLiteVarint(nil, uint64(headerLen))
+	record = append(record, types...)
+	return append(record, body...)
+}
+
+// sqliteLocalPayload returns how many bytes of a table leaf cell's payload are stored on the page itself; the rest
+// goes to a chain of overflow pages
+func sqliteLocalPayload(payload, usable int) int {
+	maxLocal := usable - 35
+	if payload <= maxLocal {
+		return payload
+	}
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (payload-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	return local
+}
+
+// sqliteChild is a page of a b-tree level with the largest rowid stored beneath it
+type sqliteChild struct {
+	page     int
+	maxRowid int64
+}
+
+// sqliteBuilder assembles the pages of a new database in memory; pages[0] is page 1
+type sqliteBuilder struct {
+	pageSize int
+	pages    [][]byte
+}
+
+// newPage appends an empty page and returns its number
+func (b *sqliteBuilder) newPage() int {
+	b.pages = append(b.pages, make([]byte, b.pageSize))
+	return len(b.pages)
+}
+
+// leafCell encodes a table leaf cell, writing the part of the payload that does not fit to overflow pages
+func (b *sqliteBuilder) leafCell(rowid int64, payload []byte) []byte {
+	cell := appendSQLiteVarint(nil, uint64(len(payload)))
+	cell = appendSQLiteVarint(cell, uint64(rowid))
+	local := sqliteLocalPayload(len(payload), b.pageSize)
+	cell = append(cell, payload[:local]...)
+	if local == len(payload) {
+		return cell
+	}
+	// Each overflow page starts with the number of the next one, zero on the last.
+	rest := payload[local:]
+	next := b.newPage()
+	cell = binary.BigEndian.AppendUint32(cell, uint32(next))
+	for len(rest) > 0 {
+		page := b.pages[next-1]
+		n := copy(page[4:], rest)
+		if rest = rest[n:]; len(rest) > 0 {
+			next = b.newPage()
+			binary.BigEndian.PutUint32(page, uint32(next))
+		}
+	}
+	return cell
+}
+
+// writeBtreePage lays out a b-tree page: the header at offset (100 on page 1), the cell pointers after it and the
+// cells packed against the end of the page
+func writeBtreePage(page []byte, offset int, kind byte, cells [][]byte, rightChild int) {
+	page[offset] = kind
+	header := 8
+	if kind == sqliteInteriorTable {
+		header = 12
+		binary.BigEndian.PutUint32(page[offset+8:], uint32(rightChild))
+	}
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	content := len(page)
+	for i, cell := range cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[offset+header+2*i:], uint16(content))
+	}
+	// A content area starting at 65536 is written as zero.
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(content))
+}
+
+// The writeTable method of sqliteBuilder stores rows as a table b-tree: the records are packed into leaf pages in
+// rowid order, and levels of interior pages are added above them until a single root remains. It returns the number
+// of the root page.
+// writeTable writes a table b-tree and returns its root page
+func (b *sqliteBuilder) writeTable(rows func(yield func(rowid int64, record []byte))) int {
+	var level []sqliteChild
+	var cells [][]byte
+	used := 8
+	var lastRowid int64
+	flush := func() {
+		page := b.newPage()
+		writeBtreePage(b.pages[page-1], 0, sqliteLeafTable, cells, 0)
+		level = append(level, sqliteChild{page: page, maxRowid: lastRowid})
+		cells, used = nil, 8
+	}
+	rows(func(rowid int64, record []byte) {
+		cell := b.leafCell(rowid, record)
+		if len(cells) > 0 && used+2+len(cell) > b.pageSize {
+			flush()
+		}
+		cells = append(cells, cell)
+		used += 2 + len(cell)
+		lastRowid = rowid
+	})
+	if len(cells) > 0 || len(level) == 0 {
+		flush()
+	}
+
+	// An interior cell is a 4-byte child page and a key of at most 9 bytes, plus its 2-byte pointer.
+	perPage := (b.pageSize-12)/15 + 1
+	for len(level) > 1 {
+		var parents []sqliteChild
+		for start := 0; start < len(level); start += perPage {
+			end := start + perPage
+			if end > len(level) {
+				end = len(level)
+			}
+			children := level[start:end]
+			var interior [][]byte
+			for _, child := range children[:len(children)-1] {
+				cell := binary.BigEndian.AppendUint32(nil, uint32(child.page))
+				interior = append(interior, appendSQLiteVarint(cell, uint64(child.maxRowid)))
+			}
+			page := b.newPage()
+			last := children[len(children)-1]
+			writeBtreePage(b.pages[page-1], 0, sqliteInteriorTable, interior, last.page)
+			parents = append(parents, sqliteChild{page: page, maxRowid: last.maxRowid})
+		}
+		level = parents
+	}
+	return level[0].page
+}
+
+// quoteSQLiteIdentifier quotes a table or column name for SQL
+func quoteSQLiteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlColumnNames returns the headers as SQL column names, naming empty ones column_N and making repeated ones
+// unique with a suffix, case-insensitively as SQL compares names
+func sqlColumnNames(headers []string) []string {
+	names := make([]string, len(headers))
+	used := make(map[string]bool)
+	for colIndex, header := range headers {
+		name := header
+		if strings.TrimSpace(name) == "" {
+			name = fmt.Sprintf("column_%d", colIndex+1)
+		}
+		for suffix := 2; used[strings.ToLower(name)]; suffix++ {
+			name = fmt.Sprintf("%s_%d", header, suffix)
+		}
+		used[strings.ToLower(name)] = true
+		names[colIndex] = name
+	}
+	return names
+}
+
+// sqliteTableName is the name of the table convert --to sqlite or sql writes: --table, or else the input file's base
+// name
+func sqliteTableName(opts Options, filename string) string {
+	if opts.Table != "" {
+		return opts.Table
+	}
+	base := filepath.Base(filename)
+	for ext := filepath.Ext(base); ext != ""; ext = filepath.Ext(base) {
+		base = strings.TrimSuffix(base, ext)
+	}
+	if base == "" || base == "." || strings.HasPrefix(strings.ToLower(base), "sqlite_") {
+		return "data"
+	}
+	return base
+}
+
+// sqliteColumnType infers the declared type of a column: INTEGER for booleans and for numbers that are all whole,
+// REAL for other numbers and TEXT for dates and text, which SQLite has no separate type for
+func (ca *CSVAnalyzer) sqliteColumnType(colIndex int) string {
+	switch ca.columnType(colIndex) {
+	case TypeBoolean:
+		return "INTEGER"
+	case TypeNumeric:
+		for _, v := range ca.extractNumericValues(colIndex) {
+			if v != math.Trunc(v) || math.Abs(v) >= 1<<63 {
+				return "REAL"
+			}
+		}
+		return "INTEGER"
+	}
+	return "TEXT"
+}
+
+// sqliteValue converts a cell to the value stored in a column of the declared type; like typedValue, cells that do
+// not parse as their column's type are kept as text, which SQLite's flexible typing allows
+func (ca *CSVAnalyzer) sqliteValue(colIndex int, declared, cell string) interface{} {
+	value := strings.TrimSpace(cell)
+	if value == "" {
+		return nil
+	}
+	switch ca.columnType(colIndex) {
+	case TypeNumeric:
+		// SQLite cannot store NaN and would turn it into NULL anyway.
+		if num, err := ca.parseNumber(value); err == nil && !math.IsNaN(num) {
+			if declared == "INTEGER" {
+				return int64(num)
+			}
+			return num
+		}
+	case TypeBoolean:
+		if truth, ok := parseBoolean(value); ok {
+			if truth {
+				return int64(1)
+			}
+			return int64(0)
+		}
+	case TypeDate:
+		// ISO 8601 text is what SQLite's date and time functions understand.
+		if t, ok := ca.parseDate(value); ok {
+			return formatDate(t)
+		}
+	}
+	return cell
+}
+
+// The WriteSQLite method is part of the CSVAnalyzer struct. It writes the dataset as a SQLite 3 database file with a
+// single table named by --table (the input file's base name by default), so the data can be queried with sqlite3 or
+// any SQLite driver. Column types are inferred as in sqliteColumnType, empty cells become NULL and the rows get
+// rowids in file order. The file is built in memory, without journal or indexes, and written in one go; duplicate
+// and empty column names are made unique since SQL does not allow them.
+// WriteSQLite writes the dataset as a SQLite database with table table
+func (ca *CSVAnalyzer) WriteSQLite(w io.Writer, table string) error {
+	headers := ca.dataset.Headers
+	if len(headers) == 0 {
+		return fmt.Errorf("error writing SQLite database: the data has no columns")
+	}
+	if len(headers) > sqliteMaxColumns {
+		return fmt.Errorf("error writing SQLite database: %d columns exceed SQLite's limit of %d", len(headers), sqliteMaxColumns)
+	}
+	declared := make([]string, len(headers))
+	definitions := make([]string, len(headers))
+	for colIndex, name := range sqlColumnNames(headers) {
+		declared[colIndex] = ca.sqliteColumnType(colIndex)
+		definitions[colIndex] = quoteSQLiteIdentifier(name) + " " + declared[colIndex]
+	}
+	createSQL := "CREATE TABLE " + quoteSQLiteIdentifier(table) + " (" + strings.Join(definitions, ", ") + ")"
+
+	// The schema row is the only cell of page 1, after the 100-byte file header, so the page must hold it whole.
+	schemaSize := len(sqliteRecord([]interface{}{"table", table, table, int64(sqliteMaxPageSize), createSQL})) + 20
+	pageSize := sqliteMinPageSize
+	for pageSize <= sqliteMaxPageSize && (schemaSize+110 > pageSize || schemaSize > pageSize-35) {
+		pageSize *= 2
+	}
+	if pageSize > sqliteMaxPageSize {
+		return fmt.Errorf("error writing SQLite database: the table definition is too long for a SQLite page")
+	}
+
+	b := &sqliteBuilder{pageSize: pageSize}
+	b.newPage()
+	root := b.writeTable(func(yield func(int64, []byte)) {
+		values := make([]interface{}, len(headers))
+		for rowIndex, row := range ca.dataset.Rows {
+			for colIndex := range headers {
+				values[colIndex] = nil
+				if colIndex < len(row) {
+					values[colIndex] = ca.sqliteValue(colIndex, declared[colIndex], row[colIndex])
+				}
+			}
+			yield(int64(rowIndex+1), sqliteRecord(values))
+		}
+	})
+	schema := sqliteRecord([]interface{}{"table", table, table, int64(root), createSQL})
+	writeBtreePage(b.pages[0], 100, sqliteLeafTable, [][]byte{b.leafCell(1, schema)}, 0)
+
+	header := b.pages[0]
+	copy(header, sqliteMagic)
+	// Page size 65536 does not fit the two bytes and is written as 1.
+	binary.BigEndian.PutUint16(header[16:], uint16(pageSize&0xffff|pageSize>>16))
+	header[18], header[19] = 1, 1 // legacy rollback journal
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1) // file change counter
+	binary.BigEndian.PutUint32(header[28:], uint32(len(b.pages)))
+	binary.BigEndian.PutUint32(header[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(header[44:], 4) // schema format
+	binary.BigEndian.PutUint32(header[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(header[92:], 1) // the page count above is valid for change 1
+	binary.BigEndian.PutUint32(header[96:], sqliteVersionStamp)
+	for _, page := range b.pages {
+		if _, err := w.Write(page); err != nil {
+			return fmt.Errorf("error writing SQLite database: %v", err)
+		}
+	}
+	return nil
+}
+
+// sqliteFile is a SQLite database read into memory
+type sqliteFile struct {
+	data     []byte
+	pageSize int
+	usable   int // the page size less the bytes reserved at the end of each page
+	encoding uint32
+}
+
+// page returns the bytes of a page by its number
+func (f *sqliteFile) page(n int) ([]byte, error) {
+	if n < 1 || n*f.pageSize > len(f.data) {
+		return nil, fmt.Errorf("page %d is out of range", n)
+	}
+	return f.data[(n-1)*f.pageSize : n*f.pageSize], nil
+}
+
+// The walkTable method of sqliteFile visits the rows of a table b-tree in rowid order, passing each row's rowid and
+// its record with any overflow pages reassembled. Index b-trees, which hold WITHOUT ROWID tables, are rejected.
+// walkTable visits every row of the table b-tree rooted at page root
+func (f *sqliteFile) walkTable(root, depth int, visit func(rowid int64, record []byte) error) error {
+	if depth > sqliteMaxDepth {
+		return fmt.Errorf("the b-tree is deeper than %d levels", sqliteMaxDepth)
+	}
+	page, err := f.page(root)
+	if err != nil {
+		return err
+	}
+	offset := 0
+	if root == 1 {
+		offset = 100
+	}
+	kind := page[offset]
+	cells := int(binary.BigEndian.Uint16(page[offset+3:]))
+	switch kind {
+	case sqliteInteriorTable:
+		for i := 0; i < cells; i++ {
+			cell := int(binary.BigEndian.Uint16(page[offset+12+2*i:]))
+			if err := f.walkTable(int(binary.BigEndian.Uint32(page[cell:])), depth+1, visit); err != nil {
+				return err
+			}
+		}
+		return f.walkTable(int(binary.BigEndian.Uint32(page[offset+8:])), depth+1, visit)
+	case sqliteLeafTable:
+		for i := 0; i < cells; i++ {
+			cell := page[binary.BigEndian.Uint16(page[offset+8+2*i:]):]
+			size, n := sqliteVarint(cell)
+			rowid, m := sqliteVarint(cell[n:])
+			local := sqliteLocalPayload(int(size), f.usable)
+			record := append([]byte(nil), cell[n+m:n+m+local]...)
+			// The rest of the payload follows the chain of overflow pages.
+			for next := 0; len(record) < int(size); {
+				if next == 0 {
+					next = int(binary.BigEndian.Uint32(cell[n+m+local:]))
+				}
+				overflow, err := f.page(next)
+				if err != nil {
+					return err
+				}
+				chunk := overflow[4:f.usable]
+				if missing := int(size) - len(record); len(chunk) > missing {
+					chunk = chunk[:missing]
+				}
+				record = append(record, chunk...)
+				next = int(binary.BigEndian.Uint32(overflow))
+			}
+			if err := visit(int64(rowid), record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case sqliteInteriorIndex, sqliteLeafIndex:
+		return fmt.Errorf("page %d is an index b-tree (WITHOUT ROWID tables are not supported)", root)
+	}
+	return fmt.Errorf("page %d has unknown b-tree type %d", root, kind)
+}
+
+// text decodes a string of the database's text encoding
+func (f *sqliteFile) text(raw []byte) string {
+	if f.encoding <= 1 || len(raw) < 2 {
+		return string(raw)
+	}
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		if f.encoding == 2 {
+			units[i] = binary.LittleEndian.Uint16(raw[2*i:])
+		} else {
+			units[i] = binary.BigEndian.Uint16(raw[2*i:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// The values method of sqliteFile decodes a record into the text form of each value: integers and reals as numbers,
+// text as is, blobs in hex and NULL as the empty cell. Records written before an ALTER TABLE ADD COLUMN have fewer
+// values than the table has columns.
+// values decodes the values of a record
+func (f *sqliteFile) values(record []byte) ([]string, error) {
+	headerLen, pos := sqliteVarint(record)
+	body := int(headerLen)
+	var values []string
+	for pos < int(headerLen) {
+		serial, n := sqliteVarint(record[pos:])
+		pos += n
+		switch {
+		case serial == 0:
+			values = append(values, "")
+		case serial <= 6:
+			size := []int{0, 1, 2, 3, 4, 6, 8}[serial]
+			// Sign-extends the big-endian integer from its first byte.
+			v := int64(int8(record[body]))
+			for _, c := range record[body+1 : body+size] {
+				v = v<<8 | int64(c)
+			}
+			values = append(values, strconv.FormatInt(v, 10))
+			body += size
+		case serial == 7:
+			v := math.Float64frombits(binary.BigEndian.Uint64(record[body:]))
+			format := byte('f')
+			if math.Abs(v) >= 1e21 {
+				format = 'g'
+			}
+			values = append(values, strconv.FormatFloat(v, format, -1, 64))
+			body += 8
+		case serial == 8 || serial == 9:
+			values = append(values, strconv.Itoa(int(serial-8)))
+		case serial >= 12:
+			size := int(serial-12) / 2
+			raw := record[body : body+size]
+			if serial%2 == 0 {
+				values = append(values, hex.EncodeToString(raw))
+			} else {
+				values = append(values, f.text(raw))
+			}
+			body += size
+		default:
+			return nil, fmt.Errorf("reserved serial type %d in a record", serial)
+		}
+	}
+	return values, nil
+}
+
+// sqliteSplitDefinitions splits the column list of a CREATE TABLE statement at the commas outside quotes and
+// parentheses, and returns the text after the list (e.g. WITHOUT ROWID)
+func sqliteSplitDefinitions(sql string) ([]string, string, error) {
+	var definitions []string
+	depth, start := 0, -1
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; c {
+		case '\'', '"', '`', '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			// Doubled quotes inside a quoted name are skipped along with the rest of it.
+			end := strings.IndexByte(sql[i+1:], closing)
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated quote in %q", sql)
+			}
+			i += end + 1
+		case '(':
+			if depth++; depth == 1 {
+				start = i + 1
+			}
+		case ')':
+			if depth--; depth == 0 {
+				definitions = append(definitions, sql[start:i])
+				return definitions, sql[i+1:], nil
+			}
+		case ',':
+			if depth == 1 {
+				definitions = append(definitions, sql[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("no column list in %q", sql)
+}
+
+// sqliteIdentifier reads the possibly quoted name at the start of a column definition and returns it with the rest
+func sqliteIdentifier(definition string) (string, string) {
+	definition = strings.TrimSpace(definition)
+	if definition == "" {
+		return "", ""
+	}
+	closing := map[byte]byte{'"': '"', '`': '`', '[': ']', '\'': '\''}[definition[0]]
+	if closing == 0 {
+		end := strings.IndexAny(definition, " \t\r\n(")
+		if end < 0 {
+			return definition, ""
+		}
+		return definition[:end], definition[end:]
+	}
+	var name strings.Builder
+	for i := 1; i < len(definition); i++ {
+		if definition[i] != closing {
+			name.WriteByte(definition[i])
+			continue
+		}
+		// A doubled closing quote stands for the character itself.
+		if closing != ']' && i+1 < len(definition) && definition[i+1] == closing {
+			name.WriteByte(closing)
+			i++
+			continue
+		}
+		return name.String(), definition[i+1:]
+	}
+	return name.String(), ""
+}
+
+// The sqliteColumns function extracts the column names of a table from its CREATE TABLE statement, skipping table
+// constraints. It also returns the index of the INTEGER PRIMARY KEY column, whose values SQLite keeps as the rowid
+// and stores as NULL in the records, or -1 when there is none.
+// sqliteColumns returns the column names and the rowid column of a table definition
+func sqliteColumns(sql string) ([]string, int, error) {
+	definitions, trailer, err := sqliteSplitDefinitions(sql)
+	if err != nil {
+		return nil, -1, err
+	}
+	if strings.Contains(strings.ToUpper(trailer), "WITHOUT ROWID") {
+		return nil, -1, fmt.Errorf("WITHOUT ROWID tables are not supported")
+	}
+	var columns, types []string
+	rowidColumn := -1
+	var primaryKey string
+	for _, definition := range definitions {
+		upper := strings.ToUpper(definition)
+		fields := strings.Fields(upper)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			// A table-level key on a single column makes it the rowid just like a column-level one.
+			key := strings.Index(upper, "PRIMARY KEY")
+			if open, end := strings.IndexByte(definition, '('), strings.LastIndexByte(definition, ')'); key >= 0 && open > key && end > open {
+				if name, rest := sqliteIdentifier(definition[open+1 : end]); !strings.Contains(rest, ",") {
+					primaryKey = name
+				}
+			}
+			continue
+		}
+		name, rest := sqliteIdentifier(definition)
+		rest = strings.ToUpper(rest)
+		declared := ""
+		if words := strings.Fields(rest); len(words) > 0 {
+			declared = words[0]
+		}
+		if declared == "INTEGER" && strings.Contains(rest, "PRIMARY KEY") && !strings.Contains(rest, "PRIMARY KEY DESC") {
+			rowidColumn = len(columns)
+		}
+		columns = append(columns, name)
+		types = append(types, declared)
+	}
+	if primaryKey != "" && rowidColumn < 0 {
+		for i, column := range columns {
+			if strings.EqualFold(column, primaryKey) && types[i] == "INTEGER" {
+				rowidColumn = i
+			}
+		}
+	}
+	return columns, rowidColumn, nil
+}
+
+// sqliteTable is a table listed in the schema of a database
+type sqliteTable struct {
+	name string
+	root int
+	sql  string
+}
+
+// The readSQLiteRecords function reads a table of a SQLite 3 database as if it were a CSV file: the column names of
+// its CREATE TABLE statement first, then every row in rowid order. The table is --table, or the only table of the
+// database. The file is read into memory whole; changes still in a -wal file that was not checkpointed are not seen,
+// and views, virtual and WITHOUT ROWID tables are not supported.
+// readSQLiteRecords passes the header and then every row of a SQLite table to visit
+func readSQLiteRecords(input io.Reader, filename, table string, visit func(record []string) error) (err error) {
+	// Bounds are not checked field by field; a malformed file panics and is reported as an error here.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("error reading SQLite file %s: malformed data (%v)", filename, recovered)
+		}
+	}()
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return fmt.Errorf("error reading SQLite file %s: %v", filename, err)
+	}
+	if len(data) < 100 || string(data[:len(sqliteMagic)]) != sqliteMagic {
+		return fmt.Errorf("error reading SQLite file %s: not a SQLite 3 database", filename)
+	}
+	f := &sqliteFile{data: data, pageSize: int(binary.BigEndian.Uint16(data[16:])), encoding: binary.BigEndian.Uint32(data[56:])}
+	if f.pageSize == 1 {
+		f.pageSize = sqliteMaxPageSize
+	}
+	f.usable = f.pageSize - int(data[20])
+
+	// Page 1 holds the schema table: type, name, tbl_name, rootpage and sql.
+	var tables []sqliteTable
+	err = f.walkTable(1, 0, func(_ int64, record []byte) error {
+		values, err := f.values(record)
+		if err != nil || len(values) < 5 || values[0] != "table" || strings.HasPrefix(values[1], "sqlite_") {
+			return err
+		}
+		root, _ := strconv.Atoi(values[3])
+		tables = append(tables, sqliteTable{name: values[1], root: root, sql: values[4]})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading SQLite file %s: %v", filename, err)
+	}
+	var selected *sqliteTable
+	names := make([]string, len(tables))
+	for i := range tables {
+		names[i] = tables[i].name
+		if strings.EqualFold(tables[i].name, table) || (table == "" && len(tables) == 1) {
+			selected = &tables[i]
+		}
+	}
+	if selected == nil {
+		if table == "" {
+			return fmt.Errorf("error reading SQLite file %s: choose a table with --table (found: %s)", filename, strings.Join(names, ", "))
+		}
+		return fmt.Errorf("error reading SQLite file %s: no table %q (found: %s)", filename, table, strings.Join(names, ", "))
+	}
+	if selected.root == 0 {
+		return fmt.Errorf("error reading SQLite file %s: table %s is a virtual table", filename, selected.name)
+	}
+	columns, rowidColumn, err := sqliteColumns(selected.sql)
+	if err != nil {
+		return fmt.Errorf("error reading SQLite file %s: table %s: %v", filename, selected.name, err)
+	}
+	if err := visit(columns); err != nil {
+		return err
+	}
+	// Errors of visit are passed on as they are; the others are about the file.
+	var visitErr error
+	err = f.walkTable(selected.root, 0, func(rowid int64, record []byte) error {
+		values, err := f.values(record)
+		if err != nil {
+			return err
+		}
+		// Columns added later are missing from older records and hold their default, taken as empty here.
+		for len(values) < len(columns) {
+			values = append(values, "")
+		}
+		if rowidColumn >= 0 {
+			values[rowidColumn] = strconv.FormatInt(rowid, 10)
+		}
+		visitErr = visit(values[:len(columns)])
+		return visitErr
+	})
+	if visitErr != nil {
+		return visitErr
+	}
+	if err != nil {
+		return fmt.Errorf("error reading SQLite file %s: %v", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sqliteRoundTrip writes the dataset of analyzer as a SQLite database and reads its table back
+func sqliteRoundTrip(t *testing.T, analyzer *CSVAnalyzer) [][]string {
+	t.Helper()
+	var database bytes.Buffer
+	if err := analyzer.WriteSQLite(&database, "data"); err != nil {
+		t.Fatal(err)
+	}
+	var records [][]string
+	if err := readSQLiteRecords(&database, "test.db", "data", func(record []string) error {
+		records = append(records, append([]string(nil), record...))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	analyzer := loadTestCSV(t, "id,price,active,name,day\n1,1.5,true,Widget,2024-01-05\n2,,false,\"Gadget, \"\"large\"\"\",2024-02-01\n3,-3,,Ünïcode ✓,\n")
+	want := [][]string{
+		{"id", "price", "active", "name", "day"},
+		// Booleans are stored as 0 and 1 and empty cells as NULL, which reads back empty.
+		{"1", "1.5", "1", "Widget", "2024-01-05"},
+		{"2", "", "0", `Gadget, "large"`, "2024-02-01"},
+		{"3", "-3", "", "Ünïcode ✓", ""},
+	}
+	if got := sqliteRoundTrip(t, analyzer); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSQLiteRoundTripIntegerSizes(t *testing.T) {
+	// Each value needs a different serial type: the constants 0 and 1, then 1, 2, 3, 4, 6 and 8 bytes.
+	values := []string{"0", "1", "-128", "127", "-32768", "32767", "8388607", "-8388609", "2147483648", "-140737488355328", "140737488355328", "-4611686018427387904"}
+	var b strings.Builder
+	b.WriteString("n\n")
+	for _, v := range values {
+		b.WriteString(v + "\n")
+	}
+	analyzer := loadTestCSV(t, b.String())
+	if got := analyzer.sqliteColumnType(0); got != "INTEGER" {
+		t.Fatalf("column declared %s, want INTEGER", got)
+	}
+	records := sqliteRoundTrip(t, analyzer)
+	for i, v := range values {
+		if got := records[i+1][0]; got != v {
+			t.Errorf("%s read back as %s", v, got)
+		}
+	}
+}
+
+func TestSQLiteRoundTripRenamesColumns(t *testing.T) {
+	records := sqliteRoundTrip(t, loadTestCSV(t, "name,Name,,\"two words\"\na,b,c,d\n"))
+	if want := []string{"name", "Name_2", "column_3", "two words"}; !reflect.DeepEqual(records[0], want) {
+		t.Errorf("columns read back as %q, want %q", records[0], want)
+	}
+}
+
+func TestSQLiteRoundTripOverflowPages(t *testing.T) {
+	long := strings.Repeat("0123456789", 2000)
+	records := sqliteRoundTrip(t, loadTestCSV(t, "id,text\n1,"+long+"\n2,short\n"))
+	if got := records[1][1]; got != long {
+		t.Errorf("a %d-byte cell read back with %d bytes", len(long), len(got))
+	}
+	if got := records[2]; !reflect.DeepEqual(got, []string{"2", "short"}) {
+		t.Errorf("the row after the long cell read back as %q", got)
+	}
+}
+
+func TestSQLiteRoundTripInteriorPages(t *testing.T) {
+	// Enough leaf pages for two levels of interior pages above them.
+	rows := 100000
+	var b strings.Builder
+	b.WriteString("id,label\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "%d,row %d\n", i, i)
+	}
+	records := sqliteRoundTrip(t, loadTestCSV(t, b.String()))
+	if len(records) != rows+1 {
+		t.Fatalf("read %d records, want %d", len(records), rows+1)
+	}
+	for i, record := range records[1:] {
+		if want := []string{fmt.Sprint(i), fmt.Sprint("row ", i)}; !reflect.DeepEqual(record, want) {
+			t.Fatalf("row %d = %q, want %q", i, record, want)
+		}
+	}
+}
+
+func TestSQLiteFileLoadsWithTheSameTypes(t *testing.T) {
+	original := loadTestCSV(t, "price,count,active,name,day\n1.5,3,true,Widget,2024-01-05\n2,4,false,Gadget,2024-02-01\n")
+	path := filepath.Join(t.TempDir(), "data.db")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := original.WriteSQLite(file, "data"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	loaded := NewCSVAnalyzerWithOptions(testOptions(t))
+	if err := loaded.LoadCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	for colIndex, header := range original.dataset.Headers {
+		if got, want := loaded.columnType(colIndex), original.columnType(colIndex); got != want {
+			t.Errorf("%s loaded as %s, written as %s", header, got, want)
+		}
+	}
+}
+
+func TestSQLiteFilePassesIntegrityCheck(t *testing.T) {
+	sqlite3, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	var b strings.Builder
+	b.WriteString("id,price,text\n")
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&b, "%d,%d.25,%s\n", i, i, strings.Repeat("x", i%3000))
+	}
+	path := filepath.Join(t.TempDir(), "data.db")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadTestCSV(t, b.String()).WriteSQLite(file, "data"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	output, err := exec.Command(sqlite3, path, "PRAGMA integrity_check; SELECT count(*), sum(id), typeof(price) FROM data;").CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3 failed: %v\n%s", err, output)
+	}
+	if want := "ok\n5000|12497500|real\n"; string(output) != want {
+		t.Errorf("sqlite3 printed %q, want %q", output, want)
+	}
+}
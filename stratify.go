@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StratumCount reports how one value of the stratification column is represented in a stratified sample
+type StratumCount struct {
+	Value      string  `json:"value"`
+	Population int     `json:"population"`
+	Share      float64 `json:"share"` // fraction of all rows in this stratum
+	Sampled    int     `json:"sampled"`
+}
+
+// The StratifiedSample method is part of the CSVAnalyzer struct. It draws n rows so that every value of the given
+// column (a stratum) keeps its share of the data. Each stratum gets its proportional quota rounded down, and the rows
+// left over go to the strata with the largest remainders, so the quotas add up to exactly n. Rows are then picked at
+// random within each stratum and returned in their original order. Strata too small for even one row at this sample
+// size end up with none, which the returned counts make visible.
+// StratifiedSample returns a proportional random sample of n rows and the per-stratum counts
+func (ca *CSVAnalyzer) StratifiedSample(column string, n int, seed int64) ([][]string, []StratumCount, error) {
+	colIndex := ca.columnIndex(column)
+	if colIndex < 0 {
+		return nil, nil, fmt.Errorf("stratification column %q not found", column)
+	}
+	// Groups the row positions by stratum value.
+	members := make(map[string][]int)
+	for rowIndex, row := range ca.dataset.Rows {
+		value := ""
+		if colIndex < len(row) {
+			value = strings.TrimSpace(row[colIndex])
+		}
+		members[value] = append(members[value], rowIndex)
+	}
+	total := len(ca.dataset.Rows)
+	if n > total {
+		n = total
+	}
+
+	// Works out each stratum's quota by the largest-remainder method.
+	var counts []StratumCount
+	remainders := make(map[string]float64)
+	allocated := 0
+	for value, rows := range members {
+		exact := float64(n) * float64(len(rows)) / float64(total)
+		quota := int(exact)
+		counts = append(counts, StratumCount{Value: value, Population: len(rows), Share: float64(len(rows)) / float64(total), Sampled: quota})
+		remainders[value] = exact - float64(quota)
+		allocated += quota
+	}
+	// Orders the strata by remainder (then by value, for reproducibility) to hand out the rows left over.
+	sort.Slice(counts, func(i, j int) bool {
+		if remainders[counts[i].Value] != remainders[counts[j].Value] {
+			return remainders[counts[i].Value] > remainders[counts[j].Value]
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	for i := 0; allocated < n; i++ {
+		counts[i%len(counts)].Sampled++
+		allocated++
+	}
+	// Reports the strata largest first.
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Population != counts[j].Population {
+			return counts[i].Population > counts[j].Population
+		}
+		return counts[i].Value < counts[j].Value
+	})
+
+	// Picks the quota of every stratum at random, in a fixed stratum order so a seed always gives the same sample.
+	rng := rand.New(rand.NewSource(seed))
+	var picked []int
+	for _, count := range counts {
+		rows := append([]int(nil), members[count.Value]...)
+		rng.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+		picked = append(picked, rows[:count.Sampled]...)
+	}
+	sort.Ints(picked)
+	sample := make([][]string, len(picked))
+	for i, rowIndex := range picked {
+		sample[i] = ca.dataset.Rows[rowIndex]
+	}
+	return sample, counts, nil
+}
+
+// runStratifiedSample implements `sample --stratify Column --n N <file>`: it writes a stratified sample of the input
+// as CSV and prints the per-stratum counts on stderr
+func runStratifiedSample(args []string) {
+	// Reuses the analyzer flags so --types, --drop and the other loading options apply too.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	stratify := fs.String("stratify", "", "`column` whose value proportions the sample preserves")
+	n := fs.Int("n", 100, "number of `rows` in the sample")
+	output := fs.String("output", "", "write the sample to `file` instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . sample --stratify <column> [--n rows] [--output file] [--sample-seed seed] [options] <csv-file>")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 1 || *stratify == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *n < 1 {
+		log.Fatal("Invalid options: --n must be at least 1")
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+	// The sampling seed flag doubles as the seed of the stratified draw.
+	seed := opts.Sampling.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	analyzer := NewCSVAnalyzerWithOptions(*opts)
+	if err := analyzer.LoadCSV(positional[0]); err != nil {
+		log.Fatal("Error loading CSV:", err)
+	}
+	analyzer.enforceStrict()
+	sample, counts, err := analyzer.StratifiedSample(*stratify, *n, seed)
+	if err != nil {
+		log.Fatal("Error sampling:", err)
+	}
+
+	// Writes the header and the sampled rows, compressed when --compress is set.
+	w := compressWriter(os.Stdout, opts.Compress)
+	if *output != "" {
+		if w, err = createOutputFile(*output, opts.Compress); err != nil {
+			log.Fatal("Error creating output file:", err)
+		}
+	}
+	err = writeCSVRecords(w, append([][]string{analyzer.dataset.Headers}, sample...))
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal("Error writing sample:", err)
+	}
+	printStrata(os.Stderr, *stratify, len(sample), seed, counts)
+}
+
+// writeCSVRecords writes records as CSV
+func writeCSVRecords(w io.Writer, records [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("error writing CSV: %v", err)
+	}
+	return nil
+}
+
+// printStrata shows the per-stratum counts of a stratified sample
+func printStrata(w io.Writer, column string, size int, seed int64, counts []StratumCount) {
+	fmt.Fprintf(w, "Stratified sample of %d rows by %s (seed %d):\n", size, column, seed)
+	fmt.Fprintf(w, "  %-24s %10s %8s %8s\n", "Stratum", "Population", "Share", "Sampled")
+	for _, count := range counts {
+		value := count.Value
+		if value == "" {
+			value = "(empty)"
+		}
+		fmt.Fprintf(w, "  %-24s %10d %7.1f%% %8d\n", value, count.Population, 100*count.Share, count.Sampled)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// streamChunkRows is how many rows a worker of the aggregation pool parses at a time
+const streamChunkRows = 4096
+
+// exactQuantileLimit is the number of values above which a column's median comes from its t-digest instead of a sort
+const exactQuantileLimit = 1000000
+
+// tDigestCompression bounds the number of centroids a t-digest keeps; higher is more accurate
+const tDigestCompression = 200
+
+// tDigestBuffer is how many values a t-digest collects before merging them into its centroids
+const tDigestBuffer = 20 * tDigestCompression
+
+// centroid is a cluster of values summarized by their mean and count
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// The tDigest type is a merging t-digest (Dunning): a sorted list of centroids whose sizes are kept small near the
+// tails and allowed to grow in the middle, so extreme quantiles stay accurate while memory stays bounded by the
+// compression. Values are buffered and merged in batches, and digests of separate chunks merge into one.
+type tDigest struct {
+	centroids []centroid
+	buffer    []float64
+	count     float64
+	min, max  float64
+}
+
+// newTDigest returns an empty digest
+func newTDigest() *tDigest {
+	return &tDigest{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// add records one value
+func (t *tDigest) add(x float64) {
+	t.buffer = append(t.buffer, x)
+	if len(t.buffer) >= tDigestBuffer {
+		t.compress()
+	}
+}
+
+// merge folds another digest into this one
+func (t *tDigest) merge(other *tDigest) {
+	t.compress()
+	other.compress()
+	t.centroids = t.combine(t.centroids, other.centroids, other.count)
+}
+
+// compress merges the buffered values into the centroids
+func (t *tDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	// Sorting plain numbers is much cheaper than sorting centroids, and the centroids are already in order.
+	slices.Sort(t.buffer)
+	values := make([]centroid, len(t.buffer))
+	for i, x := range t.buffer {
+		values[i] = centroid{mean: x, weight: 1}
+	}
+	t.centroids = t.combine(t.centroids, values, float64(len(t.buffer)))
+	t.buffer = t.buffer[:0]
+}
+
+// The combine method is part of the tDigest struct. It walks two sorted centroid lists in order, adding weight of
+// new values to the count, and merges neighbours greedily for as long as the merged centroid stays within the size
+// limit 4·n·q·(1-q)/compression at its quantile q: the k1 scale function, which keeps centroids near q=0 and q=1 tiny.
+// combine merges two sorted centroid lists into one compressed list
+func (t *tDigest) combine(a, b []centroid, added float64) []centroid {
+	if len(b) == 0 {
+		return a
+	}
+	t.count += added
+	t.min, t.max = math.Min(t.min, b[0].mean), math.Max(t.max, b[len(b)-1].mean)
+	merged := make([]centroid, 0, len(a)+tDigestCompression)
+	cumulative := 0.0
+	for len(a) > 0 || len(b) > 0 {
+		var c centroid
+		if len(b) == 0 || (len(a) > 0 && a[0].mean <= b[0].mean) {
+			c, a = a[0], a[1:]
+		} else {
+			c, b = b[0], b[1:]
+		}
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			q := (cumulative + (last.weight+c.weight)/2) / t.count
+			if last.weight+c.weight <= math.Max(1, 4*t.count*q*(1-q)/tDigestCompression) {
+				last.mean += (c.mean - last.mean) * c.weight / (last.weight + c.weight)
+				last.weight += c.weight
+				continue
+			}
+			cumulative += last.weight
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// quantile estimates the q-th quantile (0 to 1) by interpolating between centroid means
+func (t *tDigest) quantile(q float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 {
+		return 0
+	}
+	target := q * t.count
+	cumulative := 0.0
+	previousMean, previousCenter := t.min, 0.0
+	for _, c := range t.centroids {
+		center := cumulative + c.weight/2
+		if target < center {
+			if center == previousCenter {
+				return c.mean
+			}
+			return previousMean + (target-previousCenter)/(center-previousCenter)*(c.mean-previousMean)
+		}
+		cumulative += c.weight
+		previousMean, previousCenter = c.mean, center
+	}
+	// Beyond the last centroid's centre the estimate runs on to the maximum.
+	if t.count == previousCenter {
+		return t.max
+	}
+	return previousMean + (target-previousCenter)/(t.count-previousCenter)*(t.max-previousMean)
+}
+
+// The numericAccumulator type summarizes a stream of values in a single pass: count, compensated sum, minimum,
+// maximum, the mean and sum of squared deviations by Welford's online algorithm (which avoids the cancellation of the
+// textbook sum-of-squares formula), and for columns too large for an exact median, a t-digest for quantiles.
+// Accumulators of separate chunks merge exactly, with Chan's formula for the variance, which lets the worker pool
+// parse chunks independently.
+type numericAccumulator struct {
+	count    int
+	sum      compensatedSum
+	mean, m2 float64
+	min, max float64
+	digest   *tDigest
+}
+
+// newNumericAccumulator returns an empty accumulator, with a t-digest when quantiles will be estimated from it
+func newNumericAccumulator(withDigest bool) *numericAccumulator {
+	a := &numericAccumulator{min: math.Inf(1), max: math.Inf(-1)}
+	if withDigest {
+		a.digest = newTDigest()
+	}
+	return a
+}
+
+// add records one value
+func (a *numericAccumulator) add(x float64) {
+	a.count++
+	a.sum.add(x)
+	delta := x - a.mean
+	a.mean += delta / float64(a.count)
+	a.m2 += delta * (x - a.mean)
+	a.min, a.max = math.Min(a.min, x), math.Max(a.max, x)
+	if a.digest != nil {
+		a.digest.add(x)
+	}
+}
+
+// merge folds the accumulator of another chunk into this one
+func (a *numericAccumulator) merge(other *numericAccumulator) {
+	if other.count == 0 {
+		return
+	}
+	n1, n2 := float64(a.count), float64(other.count)
+	delta := other.mean - a.mean
+	a.mean += delta * n2 / (n1 + n2)
+	a.m2 += other.m2 + delta*delta*n1*n2/(n1+n2)
+	a.count += other.count
+	a.sum.merge(other.sum)
+	a.min, a.max = math.Min(a.min, other.min), math.Max(a.max, other.max)
+	if a.digest != nil && other.digest != nil {
+		a.digest.merge(other.digest)
+	}
+}
+
+// stdDev returns the sample standard deviation of the values seen
+func (a *numericAccumulator) stdDev() float64 {
+	if a.count < 2 {
+		return 0
+	}
+	return math.Sqrt(a.m2 / float64(a.count-1))
+}
+
+// numericColumn is the parsed content of a numeric column: its values in row order and their running summary
+type numericColumn struct {
+	values []float64
+	stats  *numericAccumulator
+}
+
+// The numericColumns method is part of the CSVAnalyzer struct. It parses every numeric column in one pass over the
+// rows, fanned out over a pool of GOMAXPROCS workers that each take chunks of streamChunkRows rows and feed
+// per-chunk accumulators. The chunks are merged back in row order, so values keep the file order and results do not
+// depend on scheduling. The outcome is cached until the rows change: every statistic, chart and export reads the
+// parsed numbers from here instead of parsing the strings of the column again.
+// numericColumns returns the parsed values and summary of every numeric column, keyed by column index
+func (ca *CSVAnalyzer) numericColumns() map[int]*numericColumn {
+	ca.numericOnce.Do(func() {
+		var columns []int
+		for colIndex := range ca.dataset.Headers {
+			if ca.dataset.NumericCols[colIndex] {
+				columns = append(columns, colIndex)
+			}
+		}
+		rows := ca.dataset.Rows
+		// Only columns that can exceed the exact limit need a digest; sorting is faster below it.
+		withDigest := len(rows) > exactQuantileLimit
+		chunks := make([][]*numericColumn, (len(rows)+streamChunkRows-1)/streamChunkRows)
+
+		// Parses the chunks concurrently; each worker only writes its own chunk's slot.
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for chunk := range jobs {
+					end := (chunk + 1) * streamChunkRows
+					if end > len(rows) {
+						end = len(rows)
+					}
+					parsed := make([]*numericColumn, len(columns))
+					for i := range parsed {
+						parsed[i] = &numericColumn{stats: newNumericAccumulator(withDigest)}
+					}
+					for _, row := range rows[chunk*streamChunkRows : end] {
+						for i, colIndex := range columns {
+							if colIndex >= len(row) {
+								continue
+							}
+							if value := strings.TrimSpace(row[colIndex]); value != "" {
+								if num, err := ca.parseNumber(value); err == nil {
+									parsed[i].values = append(parsed[i].values, num)
+									parsed[i].stats.add(num)
+								}
+							}
+						}
+					}
+					chunks[chunk] = parsed
+				}
+			}()
+		}
+		for chunk := range chunks {
+			jobs <- chunk
+		}
+		close(jobs)
+		wg.Wait()
+
+		// Merges the chunks in row order.
+		ca.numeric = make(map[int]*numericColumn, len(columns))
+		for i, colIndex := range columns {
+			column := &numericColumn{stats: newNumericAccumulator(withDigest)}
+			total := 0
+			for _, parsed := range chunks {
+				total += len(parsed[i].values)
+			}
+			column.values = make([]float64, 0, total)
+			for _, parsed := range chunks {
+				column.values = append(column.values, parsed[i].values...)
+				column.stats.merge(parsed[i].stats)
+			}
+			ca.numeric[colIndex] = column
+		}
+	})
+	return ca.numeric
+}
+
+// resetNumericColumns drops the parsed columns after the rows or column types changed
+func (ca *CSVAnalyzer) resetNumericColumns() {
+	ca.numericOnce = sync.Once{}
+	ca.numeric = nil
+}
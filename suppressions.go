@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// suppressionDateLayout is the format of suppression expiry dates
+const suppressionDateLayout = "2006-01-02"
+
+// Suppression silences a known, accepted alert until it expires.
+// It matches by rule name, by column, or both; a suppressed failure is still reported but never pages anyone.
+type Suppression struct {
+	Rule    string `json:"rule,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Expires string `json:"expires,omitempty"` // YYYY-MM-DD, inclusive; empty never expires
+	Reason  string `json:"reason,omitempty"`
+
+	expiresAt time.Time // parsed form of Expires (zero when it never expires)
+}
+
+// normalize validates a suppression and parses its expiry date
+func (s *Suppression) normalize() error {
+	// A suppression that matches nothing specific would silently hide every alert.
+	if s.Rule == "" && s.Column == "" {
+		return fmt.Errorf("a suppression needs a rule name or a column")
+	}
+	if s.Expires == "" {
+		return nil
+	}
+	// Parses the date; the suppression stays active until the end of that day.
+	expires, err := time.ParseInLocation(suppressionDateLayout, s.Expires, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid expiry date %q (expected YYYY-MM-DD)", s.Expires)
+	}
+	s.expiresAt = expires.AddDate(0, 0, 1)
+	return nil
+}
+
+// expired reports whether the suppression no longer applies at the given time
+func (s Suppression) expired(now time.Time) bool {
+	return !s.expiresAt.IsZero() && !now.Before(s.expiresAt)
+}
+
+// matches reports whether the suppression covers the given alert rule
+func (s Suppression) matches(rule AlertRule) bool {
+	if s.Rule != "" && s.Rule != rule.Name {
+		return false
+	}
+	if s.Column != "" && strings.TrimSpace(s.Column) != strings.TrimSpace(rule.Column) {
+		return false
+	}
+	return true
+}
+
+// applySuppressions marks failed results that are covered by an active suppression.
+// Expired suppressions are ignored, so the underlying issue starts alerting again on its own.
+func (ca *CSVAnalyzer) applySuppressions(results []AlertResult, now time.Time) {
+	for i := range results {
+		// Passing rules have nothing to suppress.
+		if results[i].Passed {
+			continue
+		}
+		for _, suppression := range ca.config.Suppressions {
+			if suppression.expired(now) || !suppression.matches(results[i].Rule) {
+				continue
+			}
+			results[i].Suppressed = true
+			results[i].SuppressionReason = suppression.Reason
+			break
+		}
+	}
+}
+
+// expiredSuppressions lists suppressions that have passed their expiry date, so they can be cleaned up
+func (ca *CSVAnalyzer) expiredSuppressions(now time.Time) []Suppression {
+	var expired []Suppression
+	for _, suppression := range ca.config.Suppressions {
+		if suppression.expired(now) {
+			expired = append(expired, suppression)
+		}
+	}
+	return expired
+}
+
+// describe renders a suppression for warnings, e.g. `rule "Price min >= 0" on column Price (expired 2025-01-31)`
+func (s Suppression) describe() string {
+	var parts []string
+	if s.Rule != "" {
+		parts = append(parts, fmt.Sprintf("rule %q", s.Rule))
+	}
+	if s.Column != "" {
+		parts = append(parts, "column "+s.Column)
+	}
+	return fmt.Sprintf("%s (expired %s)", strings.Join(parts, " on "), s.Expires)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// phaseRead is the phase in which LoadCSV reads the input files; throughput is measured over it
+const phaseRead = "read input"
+
+// telemetrySampleInterval is how often the memory sampler reads the heap size
+const telemetrySampleInterval = 20 * time.Millisecond
+
+// phaseTiming is the wall-clock duration of one phase of a run
+type phaseTiming struct {
+	name     string
+	duration time.Duration
+}
+
+// The telemetry struct records where a run spends its time and memory for --stats-internal. Phases are timed with
+// the monotonic clock. Go has no portable peak-memory counter, so a background goroutine samples the heap in use
+// every telemetrySampleInterval and keeps the maximum; short spikes between samples can be missed. A nil *telemetry
+// is valid and records nothing, so callers need no checks when the flag is off.
+type telemetry struct {
+	start    time.Time
+	mu       sync.Mutex
+	phases   []phaseTiming
+	peakHeap uint64
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// startTelemetry starts recording, or returns nil when enabled is false
+func startTelemetry(enabled bool) *telemetry {
+	if !enabled {
+		return nil
+	}
+	t := &telemetry{start: time.Now(), stop: make(chan struct{}), stopped: make(chan struct{})}
+	// Samples the heap until report is called.
+	go func() {
+		defer close(t.stopped)
+		ticker := time.NewTicker(telemetrySampleInterval)
+		defer ticker.Stop()
+		for {
+			t.sampleMemory()
+			select {
+			case <-ticker.C:
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// sampleMemory updates the peak heap size
+func (t *telemetry) sampleMemory() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	t.mu.Lock()
+	if stats.HeapInuse > t.peakHeap {
+		t.peakHeap = stats.HeapInuse
+	}
+	t.mu.Unlock()
+}
+
+// phase starts timing a named phase and returns the function that ends it
+func (t *telemetry) phase(name string) func() {
+	if t == nil {
+		return func() {}
+	}
+	begin := time.Now()
+	return func() {
+		t.mu.Lock()
+		t.phases = append(t.phases, phaseTiming{name: name, duration: time.Since(begin)})
+		t.mu.Unlock()
+	}
+}
+
+// report stops the sampler and writes the timings, throughput and memory figures; rowsRead is the number of data
+// rows read from the input files and readPhase the name of the phase that read them
+func (t *telemetry) report(w io.Writer, rowsRead int, readPhase string) {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	<-t.stopped
+	t.sampleMemory()
+	total := time.Since(t.start)
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	fmt.Fprintln(w, "\nInternal Statistics:")
+	fmt.Fprintln(w, "--------------------")
+	for _, phase := range t.phases {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(phase.duration) / float64(total)
+		}
+		fmt.Fprintf(w, "  %-16s %12s %6.1f%%\n", phase.name+":", phase.duration.Round(time.Microsecond), share)
+		// Throughput is measured over the phase that read the files.
+		if phase.name == readPhase && phase.duration > 0 {
+			fmt.Fprintf(w, "  %-16s %12.0f rows/s (%d rows)\n", "throughput:", float64(rowsRead)/phase.duration.Seconds(), rowsRead)
+		}
+	}
+	fmt.Fprintf(w, "  %-16s %12s\n", "total:", total.Round(time.Microsecond))
+	fmt.Fprintf(w, "  %-16s %12s (sampled every %s)\n", "peak heap:", formatBytes(int64(t.peakHeap)), telemetrySampleInterval)
+	fmt.Fprintf(w, "  %-16s %12s\n", "allocated:", formatBytes(int64(stats.TotalAlloc)))
+	fmt.Fprintf(w, "  %-16s %12s\n", "from OS:", formatBytes(int64(stats.Sys)))
+	fmt.Fprintf(w, "  %-16s %12d\n", "GC cycles:", stats.NumGC)
+}
+
+// rowsRead is the number of data rows read from all input files, before any sampling
+func (d *Dataset) rowsRead() int {
+	total := 0
+	for _, source := range d.Sources {
+		total += source.Rows
+	}
+	return total
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// reportTemplateFuncs are the functions available to report templates besides the built-in ones
+var reportTemplateFuncs = map[string]any{
+	"num":    func(v float64) string { return fmt.Sprintf("%.3f", v) },
+	"metric": formatMetric,
+	"date":   formatDate,
+	"join":   strings.Join,
+	"json": func(v any) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+}
+
+// ReportTemplate is a parsed --template file, rendered with text/template or, for .html and .htm files (also as
+// .html.tmpl), html/template, which escapes the values it inserts
+type ReportTemplate struct {
+	path     string
+	template interface {
+		Execute(w io.Writer, data any) error
+	}
+}
+
+// The LoadReportTemplate function parses a report template, so organizations can brand and restructure the report
+// without changing the analyzer. The template is executed with the Report structure of the JSON output as its data
+// (.Rows, .Columns with .Name, .Type, .Numeric.Mean and so on, .Warnings, .Correlations, ...), the field names being
+// the Go names rather than the JSON keys, and can call num (three decimals), metric (the text report's number
+// format), date, join and json (any value as indented JSON) besides the built-in functions.
+// LoadReportTemplate reads and parses the report template at path
+func LoadReportTemplate(path string) (*ReportTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %v", err)
+	}
+	name := filepath.Base(path)
+	loaded := &ReportTemplate{path: path}
+	if ext := strings.ToLower(loaded.extension()); ext == ".html" || ext == ".htm" {
+		loaded.template, err = htmltemplate.New(name).Funcs(reportTemplateFuncs).Parse(string(data))
+	} else {
+		loaded.template, err = template.New(name).Funcs(reportTemplateFuncs).Parse(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+	return loaded, nil
+}
+
+// extension is the file extension of the template without a trailing .tmpl, given to its output in --split-dir
+func (t *ReportTemplate) extension() string {
+	if ext := filepath.Ext(strings.TrimSuffix(t.path, ".tmpl")); ext != "" {
+		return ext
+	}
+	return ".txt"
+}
+
+// WriteTemplateReport renders the report through a template
+func (ca *CSVAnalyzer) WriteTemplateReport(w io.Writer, t *ReportTemplate) error {
+	if err := t.template.Execute(w, ca.BuildReport()); err != nil {
+		return fmt.Errorf("error executing template: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultTenant owns every upload when serve mode runs without API keys
+const defaultTenant = "default"
+
+// historyTimeLayout names history files; it sorts chronologically and is safe in file names
+const historyTimeLayout = "20060102T150405.000000000Z"
+
+// tenantKey maps one API key to the tenant it authenticates
+type tenantKey struct {
+	tenant string
+	key    string
+}
+
+// parseTenantKeys parses "tenant=key" pairs separated by commas or newlines, as given in CSV_ANALYZER_API_KEYS
+func parseTenantKeys(spec string) ([]tenantKey, error) {
+	var keys []tenantKey
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		tenant, key, ok := strings.Cut(entry, "=")
+		tenant, key = strings.TrimSpace(tenant), strings.TrimSpace(key)
+		if !ok || tenant == "" || key == "" {
+			return nil, fmt.Errorf("invalid API key entry %q (expected tenant=key)", entry)
+		}
+		// Tenant names become directory names, so they are restricted to file-name-safe characters.
+		if safeFileName(tenant) != tenant || tenant == "." || tenant == ".." {
+			return nil, fmt.Errorf("invalid tenant name %q (letters, digits, '-', '_' and '.' only)", tenant)
+		}
+		keys = append(keys, tenantKey{tenant: tenant, key: key})
+	}
+	return keys, nil
+}
+
+// authenticate returns the tenant of a request, from the bearer token or X-API-Key header. Without configured keys
+// every request belongs to defaultTenant. Keys are compared in constant time so response timing reveals nothing.
+func (s *analysisServer) authenticate(r *http.Request) (string, bool) {
+	if len(s.keys) == 0 {
+		return defaultTenant, true
+	}
+	presented := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		presented = strings.TrimSpace(bearer)
+	}
+	tenant := ""
+	for _, key := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(key.key)) == 1 {
+			tenant = key.tenant
+		}
+	}
+	return tenant, tenant != ""
+}
+
+// HistoryEntry summarizes one stored analysis of a dataset
+type HistoryEntry struct {
+	Time        time.Time `json:"time"`
+	Rows        int       `json:"rows"`
+	ColumnCount int       `json:"column_count"`
+	Warnings    int       `json:"warnings"`
+	Alerts      int       `json:"failed_alerts"`
+}
+
+// The tenantStore type keeps what serve mode receives when --data-dir is set, separately for every tenant:
+//
+//	<dir>/<tenant>/datasets/<name>              the latest upload of each dataset
+//	<dir>/<tenant>/history/<name>/<time>.json   the report of every analysis of it
+//
+// Dataset names come from the name parameter of the upload and are made file-name safe, and tenants only ever see
+// their own directory, so teams sharing one instance cannot read or overwrite each other's data.
+type tenantStore struct {
+	dir       string
+	retention Retention
+}
+
+// save keeps the upload as the dataset's latest version and adds the report to its history
+func (t tenantStore) save(tenant, dataset, uploadPath string, report []byte, at time.Time) error {
+	datasets := filepath.Join(t.dir, tenant, "datasets")
+	history := filepath.Join(t.dir, tenant, "history", dataset)
+	for _, dir := range []string{datasets, history} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return fmt.Errorf("error creating %s: %v", dir, err)
+		}
+	}
+	if err := copyFile(uploadPath, filepath.Join(datasets, dataset)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(history, at.UTC().Format(historyTimeLayout)+".json"), report, 0640); err != nil {
+		return fmt.Errorf("error writing history: %v", err)
+	}
+	// Applies the retention policy right away, so a busy dataset never grows past KeepRuns reports.
+	if _, err := t.pruneHistory(tenant, dataset, at); err != nil {
+		return err
+	}
+	return nil
+}
+
+// history lists the stored analyses of a tenant's dataset, oldest first
+func (t tenantStore) history(tenant, dataset string) ([]HistoryEntry, error) {
+	dir := filepath.Join(t.dir, tenant, "history", dataset)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []HistoryEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	entries := []HistoryEntry{}
+	for _, file := range files {
+		at, err := time.Parse(historyTimeLayout, strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading history: %v", err)
+		}
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("error reading history file %s: %v", file.Name(), err)
+		}
+		entries = append(entries, HistoryEntry{
+			Time: at, Rows: report.Rows, ColumnCount: report.ColumnCount,
+			Warnings: len(report.Warnings), Alerts: len(failedAlerts(report.Alerts)),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// datasetName turns the name parameter of a request into the file name the upload is stored and spooled under
+func datasetName(name string) string {
+	name = safeFileName(filepath.Base(name))
+	if name == "." || name == ".." || name == "_" {
+		return "upload.csv"
+	}
+	return name
+}
+
+// copyFile copies the file at src to dst, replacing dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", dst, err)
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error copying to %s: %v", dst, err)
+	}
+	return nil
+}
+
+// handleHistory answers GET /history?name=<dataset> with the calling tenant's analyses of that dataset
+func (s *analysisServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.authenticate(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "missing or unknown API key")
+		return
+	}
+	if s.store == nil {
+		writeJSONError(w, http.StatusNotFound, "history is not kept (start serve with --data-dir)")
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, "name parameter is required")
+		return
+	}
+	entries, err := s.store.history(tenant, datasetName(name))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
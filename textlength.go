@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StringStats describes the lengths, surrounding whitespace and letter case of a text column's values
+type StringStats struct {
+	// Lengths are in characters of the values without surrounding whitespace
+	MinLength  int     `json:"min_length"`
+	MaxLength  int     `json:"max_length"`
+	MeanLength float64 `json:"mean_length"`
+	// AtMaxLength counts the values exactly MaxLength long; many of them at a round length hint at truncation
+	AtMaxLength        int `json:"at_max_length"`
+	LeadingWhitespace  int `json:"leading_whitespace"`
+	TrailingWhitespace int `json:"trailing_whitespace"`
+	// Values by letter case; values without letters count in none of them
+	UpperCase int `json:"upper_case"`
+	LowerCase int `json:"lower_case"`
+	MixedCase int `json:"mixed_case"`
+}
+
+// The stringStats method is part of the CSVAnalyzer struct. It measures the non-empty values of a text column the
+// way truncation and formatting problems show: the shortest, longest and mean length, how many values are as long
+// as the longest (a pile-up at 255 or 50 characters usually means a field was cut off upstream), how many carry
+// leading or trailing whitespace that breaks joins and lookups, and how many are all upper-case, all lower-case or
+// mixed. The figures describe individual values, so they are withheld under differential privacy (see
+// privacyNoise.text).
+// stringStats returns the string statistics of a text column, or nil when it has no values
+func (ca *CSVAnalyzer) stringStats(colIndex int) *StringStats {
+	stats := &StringStats{}
+	count, total := 0, 0
+	for _, row := range ca.dataset.Rows {
+		if colIndex >= len(row) {
+			continue
+		}
+		raw := row[colIndex]
+		value := strings.TrimSpace(raw)
+		if value == "" {
+			continue
+		}
+		length := utf8.RuneCountInString(value)
+		if count == 0 || length < stats.MinLength {
+			stats.MinLength = length
+		}
+		switch {
+		case count == 0 || length > stats.MaxLength:
+			stats.MaxLength, stats.AtMaxLength = length, 1
+		case length == stats.MaxLength:
+			stats.AtMaxLength++
+		}
+		count++
+		total += length
+		if strings.TrimLeftFunc(raw, unicode.IsSpace) != raw {
+			stats.LeadingWhitespace++
+		}
+		if strings.TrimRightFunc(raw, unicode.IsSpace) != raw {
+			stats.TrailingWhitespace++
+		}
+		switch hasUpper, hasLower := letterCases(value); {
+		case hasUpper && hasLower:
+			stats.MixedCase++
+		case hasUpper:
+			stats.UpperCase++
+		case hasLower:
+			stats.LowerCase++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	stats.MeanLength = float64(total) / float64(count)
+	return stats
+}
+
+// letterCases reports whether a value has upper-case and lower-case letters; letters without case count as neither
+func letterCases(value string) (hasUpper, hasLower bool) {
+	for _, r := range value {
+		if unicode.IsUpper(r) {
+			hasUpper = true
+		} else if unicode.IsLower(r) {
+			hasLower = true
+		}
+	}
+	return hasUpper, hasLower
+}
+
+// printStringStats shows the string statistics of a text column below its counts in the text report
+func printStringStats(stats *StringStats) {
+	fmt.Printf("  Length:       min %d, max %d, mean %.1f", stats.MinLength, stats.MaxLength, stats.MeanLength)
+	if stats.AtMaxLength > 1 {
+		fmt.Printf(" (%d values at the maximum)", stats.AtMaxLength)
+	}
+	fmt.Println()
+	if stats.LeadingWhitespace > 0 || stats.TrailingWhitespace > 0 {
+		fmt.Printf("  Whitespace:   %d leading, %d trailing\n", stats.LeadingWhitespace, stats.TrailingWhitespace)
+	}
+	fmt.Printf("  Case:         %d upper, %d lower, %d mixed\n", stats.UpperCase, stats.LowerCase, stats.MixedCase)
+}
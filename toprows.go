@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// RankedRow is one row of a top or bottom list: its 1-based data row number, the value it was ranked by and the
+// cells of the context columns
+type RankedRow struct {
+	Row     int      `json:"row"`
+	Value   float64  `json:"value"`
+	Context []string `json:"context,omitempty"`
+}
+
+// ColumnExtremes holds the rows with the highest and lowest values of one numeric column
+type ColumnExtremes struct {
+	Column  string      `json:"column"`
+	Highest []RankedRow `json:"highest"`
+	Lowest  []RankedRow `json:"lowest"`
+}
+
+// TopRowsReport lists the top and bottom N rows of the ranked numeric columns
+type TopRowsReport struct {
+	N       int              `json:"n"`
+	Context []string         `json:"context_columns,omitempty"`
+	Columns []ColumnExtremes `json:"columns"`
+}
+
+// The TopRows method is part of the CSVAnalyzer struct. With --top N it ranks the rows by each numeric column, or
+// by the --top-by columns, and keeps the N with the highest and the N with the lowest values, so the best and worst
+// selling products or the largest orders can be read off the report. Each row comes with the cells of the --top-with
+// columns, by default the first text column, which usually names what the row is about. Rows without a number in
+// the column are left out and ties keep file order. The list is withheld under --dp-epsilon, since it publishes
+// single rows.
+// TopRows returns the top and bottom rows, or nil when --top is not set
+func (ca *CSVAnalyzer) TopRows() *TopRowsReport {
+	if ca.options.TopN <= 0 || ca.privacy != nil {
+		return nil
+	}
+	report := &TopRowsReport{N: ca.options.TopN, Context: ca.topRowsContext()}
+	contextIndexes := make([]int, len(report.Context))
+	for i, column := range report.Context {
+		contextIndexes[i] = ca.columnIndex(column)
+	}
+	columns := ca.options.TopBy
+	if len(columns) == 0 {
+		for colIndex, header := range ca.dataset.Headers {
+			if ca.columnType(colIndex) == TypeNumeric {
+				columns = append(columns, header)
+			}
+		}
+	}
+	for _, column := range columns {
+		values := ca.alignedNumericValues(ca.columnIndex(column))
+		var order []int
+		for rowIndex, value := range values {
+			if !math.IsNaN(value) {
+				order = append(order, rowIndex)
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool { return values[order[i]] > values[order[j]] })
+		ranked := func(rowIndex int) RankedRow {
+			row := RankedRow{Row: rowIndex + 1, Value: values[rowIndex]}
+			for _, colIndex := range contextIndexes {
+				cell := ""
+				if colIndex < len(ca.dataset.Rows[rowIndex]) {
+					cell = ca.dataset.Rows[rowIndex][colIndex]
+				}
+				row.Context = append(row.Context, cell)
+			}
+			return row
+		}
+		extremes := ColumnExtremes{Column: column, Highest: []RankedRow{}, Lowest: []RankedRow{}}
+		for i := 0; i < len(order) && i < report.N; i++ {
+			extremes.Highest = append(extremes.Highest, ranked(order[i]))
+		}
+		// The lowest rows are taken from the other end, keeping file order among ties there too.
+		sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+		for i := 0; i < len(order) && i < report.N; i++ {
+			extremes.Lowest = append(extremes.Lowest, ranked(order[i]))
+		}
+		report.Columns = append(report.Columns, extremes)
+	}
+	return report
+}
+
+// topRowsContext returns the columns shown next to ranked rows: the --top-with columns, or else the first text column
+func (ca *CSVAnalyzer) topRowsContext() []string {
+	if len(ca.options.TopWith) > 0 {
+		return ca.options.TopWith
+	}
+	for colIndex, header := range ca.dataset.Headers {
+		if ca.columnType(colIndex) == TypeText {
+			return []string{header}
+		}
+	}
+	return nil
+}
+
+// checkTopBy fails when a --top-by column is not numeric
+func (ca *CSVAnalyzer) checkTopBy() error {
+	for _, column := range ca.options.TopBy {
+		if ca.columnType(ca.columnIndex(column)) != TypeNumeric {
+			return fmt.Errorf("--top-by column %q is not numeric", column)
+		}
+	}
+	return nil
+}
+
+// printTopRows shows the highest and lowest rows of every ranked column in the text report
+func printTopRows(report *TopRowsReport) {
+	if report == nil {
+		return
+	}
+	title := fmt.Sprintf("Top and Bottom %d Rows", report.N)
+	if len(report.Context) > 0 {
+		title += " (with " + strings.Join(report.Context, ", ") + ")"
+	}
+	fmt.Printf("\n\n%s:\n", title)
+	fmt.Println(strings.Repeat("-", len(title)+1))
+	for _, column := range report.Columns {
+		fmt.Printf("\n%s:\n", column.Column)
+		for _, list := range []struct {
+			label string
+			rows  []RankedRow
+		}{{"Highest:", column.Highest}, {"Lowest:", column.Lowest}} {
+			for i, row := range list.rows {
+				label := ""
+				if i == 0 {
+					label = list.label
+				}
+				fmt.Printf("  %-9s %14s  row %-7d %s\n", label, reportMetric(row.Value), row.Row, strings.Join(row.Context, ", "))
+			}
+		}
+	}
+}
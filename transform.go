@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ColumnRename renames the column Old to New
+type ColumnRename struct {
+	Old string
+	New string
+}
+
+// ColumnRenames is the list of --rename values. It implements flag.Value, so the flag may be repeated or given
+// several comma-separated "old=new" pairs.
+type ColumnRenames []ColumnRename
+
+// String renders the renames in --rename syntax
+func (r ColumnRenames) String() string {
+	parts := make([]string, len(r))
+	for i, rename := range r {
+		parts[i] = rename.Old + "=" + rename.New
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a --rename value such as "Qty=Quantity,Cat=Category"
+func (r *ColumnRenames) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		old, new, ok := strings.Cut(entry, "=")
+		old, new = strings.TrimSpace(old), strings.TrimSpace(new)
+		if !ok || old == "" || new == "" {
+			return fmt.Errorf("invalid rename %q (expected old=new)", entry)
+		}
+		*r = append(*r, ColumnRename{Old: old, New: new})
+	}
+	return nil
+}
+
+// headerIndex returns the position of the named column in the dataset, or -1 when there is none.
+// Surrounding whitespace is ignored on both sides.
+func (d *Dataset) headerIndex(name string) int {
+	name = strings.TrimSpace(name)
+	for i, header := range d.Headers {
+		if strings.TrimSpace(header) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// disambiguateHeaders returns the header with repeated names made unique: the first column of a name keeps it and
+// the later ones get a numeric suffix (Amount, Amount_2, Amount_3), skipping suffixed names the header already has
+func disambiguateHeaders(headers []string) []string {
+	taken := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		taken[strings.TrimSpace(header)] = true
+	}
+	unique := make([]string, len(headers))
+	used := make(map[string]bool, len(headers))
+	for colIndex, header := range headers {
+		name := strings.TrimSpace(header)
+		if !used[name] {
+			used[name] = true
+			unique[colIndex] = header
+			continue
+		}
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s_%d", name, n)
+			if !taken[candidate] && !used[candidate] {
+				used[candidate] = true
+				unique[colIndex] = candidate
+				break
+			}
+		}
+	}
+	return unique
+}
+
+// RenameColumn changes the name of a column, refusing names that are already taken
+func (d *Dataset) RenameColumn(old, new string) error {
+	colIndex := d.headerIndex(old)
+	if colIndex < 0 {
+		return fmt.Errorf("cannot rename unknown column %q", old)
+	}
+	if other := d.headerIndex(new); other >= 0 && other != colIndex {
+		return fmt.Errorf("cannot rename %q to %q: a column with that name already exists", old, new)
+	}
+	// Copies the header row first, since it may be shared with the file it was read from.
+	d.Headers = append([]string(nil), d.Headers...)
+	d.Headers[colIndex] = new
+	return nil
+}
+
+// The DropColumns method is part of the Dataset struct. It removes the named columns from the header and from every
+// row, and renumbers the per-column type information so the remaining columns keep theirs. All names are checked
+// before anything is removed, so an unknown name leaves the dataset untouched.
+// DropColumns removes the named columns from the dataset
+func (d *Dataset) DropColumns(names ...string) error {
+	// Resolves every name up front.
+	drop := make(map[int]bool)
+	for _, name := range names {
+		colIndex := d.headerIndex(name)
+		if colIndex < 0 {
+			return fmt.Errorf("cannot drop unknown column %q", name)
+		}
+		drop[colIndex] = true
+	}
+	if len(drop) == 0 {
+		return nil
+	}
+
+	// Maps each kept column to its new position.
+	newIndex := make(map[int]int)
+	var headers []string
+	for colIndex, header := range d.Headers {
+		if !drop[colIndex] {
+			newIndex[colIndex] = len(headers)
+			headers = append(headers, header)
+		}
+	}
+	d.Headers = headers
+	// Rebuilds every row without the dropped cells.
+	for rowIndex, row := range d.Rows {
+		kept := make([]string, 0, len(headers))
+		for colIndex, cell := range row {
+			if !drop[colIndex] {
+				kept = append(kept, cell)
+			}
+		}
+		d.Rows[rowIndex] = kept
+	}
+	// Moves the type information along with the columns.
+	numericCols := make(map[int]bool)
+	for colIndex, numeric := range d.NumericCols {
+		if to, ok := newIndex[colIndex]; ok {
+			numericCols[to] = numeric
+		}
+	}
+	columnTypes := make(map[int]ColumnType)
+	for colIndex, colType := range d.ColumnTypes {
+		if to, ok := newIndex[colIndex]; ok {
+			columnTypes[to] = colType
+		}
+	}
+	d.NumericCols, d.ColumnTypes = numericCols, columnTypes
+	return nil
+}
+
+// applyColumnTransforms drops and renames columns as requested on the command line.
+// Both refer to the columns by their names in the file; drops are applied first.
+func (ca *CSVAnalyzer) applyColumnTransforms() error {
+	if err := ca.dataset.DropColumns(ca.options.Drop...); err != nil {
+		return err
+	}
+	for _, rename := range ca.options.Renames {
+		if err := ca.dataset.RenameColumn(rename.Old, rename.New); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CategoryRecode maps the values From of a column to the label To
+type CategoryRecode struct {
+	Column string
+	From   []string
+	To     string
+}
+
+// CategoryRecodes is the list of --recode values. It implements flag.Value, so the flag may be repeated for more
+// columns or given several ";"-separated groups, where a group without a column recodes the column of the one before.
+type CategoryRecodes []CategoryRecode
+
+// String renders the recodes in --recode syntax, each group with its column
+func (r CategoryRecodes) String() string {
+	parts := make([]string, len(r))
+	for i, recode := range r {
+		parts[i] = recode.Column + ": " + strings.Join(recode.From, ",") + "=" + recode.To
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Set parses a --recode value such as "Category: Kitchen,Accessories=Home; Pens,Paper=Office"
+func (r *CategoryRecodes) Set(value string) error {
+	column := ""
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		values, to, ok := strings.Cut(entry, "=")
+		// The column, when given, is what comes before the first colon of the values.
+		if name, rest, named := strings.Cut(values, ":"); named {
+			column, values = strings.TrimSpace(name), rest
+		}
+		to = strings.TrimSpace(to)
+		if !ok || column == "" || to == "" {
+			return fmt.Errorf("invalid recode %q (expected \"column: value,value=label\")", entry)
+		}
+		recode := CategoryRecode{Column: column, To: to}
+		for _, from := range strings.Split(values, ",") {
+			if from = strings.TrimSpace(from); from != "" {
+				recode.From = append(recode.From, from)
+			}
+		}
+		if len(recode.From) == 0 {
+			return fmt.Errorf("invalid recode %q: no values to recode", entry)
+		}
+		for _, earlier := range *r {
+			for _, from := range earlier.From {
+				for _, again := range recode.From {
+					if earlier.Column == column && from == again {
+						return fmt.Errorf("value %q of %s is recoded twice", again, column)
+					}
+				}
+			}
+		}
+		*r = append(*r, recode)
+	}
+	return nil
+}
+
+// columns returns the names of the recoded columns
+func (r CategoryRecodes) columns() []string {
+	var columns []string
+	for _, recode := range r {
+		columns = append(columns, recode.Column)
+	}
+	return columns
+}
+
+// The recodeCategories method is part of the CSVAnalyzer struct. It replaces the --recode values of every column
+// with their labels, so that messy spellings of a category ("Kitchen", "kitchenware", "Home & Kitchen") are counted,
+// grouped on and exported as one. Values are matched exactly, ignoring surrounding whitespace; values no recode
+// names are left as they are. It runs after the drops and renames, on the final column names.
+// recodeCategories applies the --recode mappings
+func (ca *CSVAnalyzer) recodeCategories() error {
+	if err := ca.checkColumnsExist("--recode", ca.options.Recode.columns()); err != nil {
+		return err
+	}
+	labels := make(map[int]map[string]string)
+	for _, recode := range ca.options.Recode {
+		colIndex := ca.columnIndex(recode.Column)
+		if labels[colIndex] == nil {
+			labels[colIndex] = make(map[string]string)
+		}
+		for _, from := range recode.From {
+			labels[colIndex][from] = recode.To
+		}
+	}
+	for _, row := range ca.dataset.Rows {
+		for colIndex, mapping := range labels {
+			if colIndex >= len(row) {
+				continue
+			}
+			if label, ok := mapping[strings.TrimSpace(row[colIndex])]; ok {
+				row[colIndex] = label
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ColumnType is the detected kind of data held in a column
+type ColumnType string
+
+const (
+	TypeText    ColumnType = "Text"
+	TypeNumeric ColumnType = "Numeric"
+	TypeBoolean ColumnType = "Boolean"
+	TypeDate    ColumnType = "Date"
+)
+
+// typeNames maps the spellings accepted by --types to column types
+var typeNames = map[string]ColumnType{
+	"string": TypeText, "text": TypeText, "str": TypeText,
+	"float": TypeNumeric, "double": TypeNumeric, "number": TypeNumeric, "numeric": TypeNumeric,
+	"int": TypeNumeric, "integer": TypeNumeric,
+	"bool": TypeBoolean, "boolean": TypeBoolean,
+	"date": TypeDate, "datetime": TypeDate, "timestamp": TypeDate,
+}
+
+// TypeOverrides forces the type of named columns, bypassing automatic detection
+type TypeOverrides map[string]ColumnType
+
+// String renders the overrides in --types syntax, sorted by column name
+func (t TypeOverrides) String() string {
+	var parts []string
+	for column, colType := range t {
+		parts = append(parts, column+":"+strings.ToLower(string(colType)))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Set parses a --types value such as "ZipCode:string,OrderDate:date,Price:float".
+// It implements flag.Value, so the flag may also be repeated.
+func (t *TypeOverrides) Set(spec string) error {
+	if *t == nil {
+		*t = make(TypeOverrides)
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Splits on the last colon so column names containing colons still work.
+		sep := strings.LastIndex(entry, ":")
+		if sep <= 0 {
+			return fmt.Errorf("invalid type override %q (expected Column:type)", entry)
+		}
+		column := strings.TrimSpace(entry[:sep])
+		colType, ok := typeNames[strings.ToLower(strings.TrimSpace(entry[sep+1:]))]
+		if !ok {
+			return fmt.Errorf("unknown type %q for column %s (expected string, float, int, bool or date)", entry[sep+1:], column)
+		}
+		(*t)[column] = colType
+	}
+	return nil
+}
+
+// detectColumnTypes classifies every column as Numeric, Boolean or Text, then applies the user's type overrides.
+// Boolean detection runs after numeric detection and wins, so 0/1 flags are not summarized as numbers.
+func (ca *CSVAnalyzer) detectColumnTypes() error {
+	// Numeric detection works from a sample of the first rows.
+	ca.detectNumericColumns()
+	// Assigns each column its detected type.
+	for colIndex := range ca.dataset.Headers {
+		colType := TypeText
+		if ca.dataset.NumericCols[colIndex] {
+			colType = TypeNumeric
+		}
+		// Boolean-ish columns are taken out of the numeric set.
+		if ca.isBooleanColumn(colIndex) {
+			colType = TypeBoolean
+			ca.dataset.NumericCols[colIndex] = false
+		}
+		ca.dataset.ColumnTypes[colIndex] = colType
+	}
+	// Forced types replace whatever detection decided.
+	return ca.applyTypeOverrides()
+}
+
+// applyTypeOverrides sets the forced type of every overridden column, failing on unknown column names
+func (ca *CSVAnalyzer) applyTypeOverrides() error {
+	// Sorts the names so errors are reported deterministically.
+	var columns []string
+	for column := range ca.options.TypeOverrides {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		colIndex := ca.columnIndex(column)
+		if colIndex < 0 {
+			return fmt.Errorf("type override for unknown column %q", column)
+		}
+		colType := ca.options.TypeOverrides[column]
+		ca.dataset.ColumnTypes[colIndex] = colType
+		// NumericCols mirrors the numeric type so the numeric statistics follow the override.
+		ca.dataset.NumericCols[colIndex] = colType == TypeNumeric
+	}
+	return nil
+}
+
+// columnType returns the detected type of a column, defaulting to Text
+func (ca *CSVAnalyzer) columnType(colIndex int) ColumnType {
+	if colType, ok := ca.dataset.ColumnTypes[colIndex]; ok {
+		return colType
+	}
+	return TypeText
+}
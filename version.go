@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata, set at link time with
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// When they are left empty, the VCS details the Go toolchain embeds are used instead.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo identifies the binary that produced a report
+type BuildInfo struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	BuildDate string   `json:"build_date,omitempty"`
+	GoVersion string   `json:"go_version"`
+	Features  []string `json:"features"`
+}
+
+// currentBuildInfo returns the version, commit, build date and compiled-in features of this binary
+func currentBuildInfo() BuildInfo {
+	info := BuildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	// Falls back to the revision and commit time recorded by `go build` inside a git checkout.
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range embedded.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			case setting.Key == "vcs.modified" && setting.Value == "true" && info.Commit != "" && !strings.HasSuffix(info.Commit, "-dirty"):
+				info.Commit += "-dirty"
+			}
+		}
+	}
+	// Lists what this build can read and write, so a report can be traced to the capabilities that produced it.
+	for _, format := range inputFormats {
+		info.Features = append(info.Features, "input:"+format)
+	}
+	for _, format := range outputFormats {
+		info.Features = append(info.Features, "format:"+format)
+	}
+	info.Features = append(info.Features, "compress:"+CompressGzip)
+	return info
+}
+
+// String renders the build information on a single line
+func (info BuildInfo) String() string {
+	text := "csv-analyzer " + info.Version
+	if info.Commit != "" {
+		text += " (commit " + info.Commit
+		if info.BuildDate != "" {
+			text += ", built " + info.BuildDate
+		}
+		text += ")"
+	}
+	return text
+}
+
+// runVersion implements the version subcommand; --json prints the build information as JSON
+func runVersion(args []string) {
+	info := currentBuildInfo()
+	if len(args) > 0 && (args[0] == "--json" || args[0] == "-json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(info)
+		return
+	}
+	fmt.Println(info)
+	fmt.Printf("Go:       %s\n", info.GoVersion)
+	fmt.Printf("Features: %s\n", strings.Join(info.Features, ", "))
+}
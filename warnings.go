@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// exitDataWarnings is the exit code used when --strict turns data warnings into errors
+const exitDataWarnings = 4
+
+// maxWarningExamples is how many example rows a warning lists
+const maxWarningExamples = 5
+
+// Kinds of data warning
+const (
+	WarningRaggedRows      = "ragged_rows"
+	WarningDuplicateHeader = "duplicate_header"
+	WarningCoercion        = "coercion"
+	WarningMixedTypes      = "mixed_types"
+)
+
+// DataWarning is a problem with the input that the analyzer worked around, such as a row with the wrong number of
+// fields or a value it had to leave out of a column's statistics
+type DataWarning struct {
+	Kind    string `json:"kind"`
+	Column  string `json:"column,omitempty"`
+	File    string `json:"file,omitempty"`
+	Count   int    `json:"count"`
+	Message string `json:"message"`
+}
+
+// raggedRows tracks the rows of one file whose field count differs from the header's
+type raggedRows struct {
+	count    int
+	examples []string
+}
+
+// add records a ragged row at the given line of the file
+func (r *raggedRows) add(line, fields int) {
+	r.count++
+	if len(r.examples) < maxWarningExamples {
+		r.examples = append(r.examples, fmt.Sprintf("line %d has %d", line, fields))
+	}
+}
+
+// The recordLoadWarnings method is part of the CSVAnalyzer struct. It is called by LoadCSV once a file has been read
+// and keeps the problems only visible while reading: rows whose number of fields differs from the header (short rows
+// are treated as having empty trailing cells, extra fields are ignored) and header names used more than once, which
+// LoadCSV then makes unique with disambiguateHeaders; the warning names the columns' new names.
+// recordLoadWarnings keeps the ragged-row and duplicate-header warnings of one input file
+func (ca *CSVAnalyzer) recordLoadWarnings(path string, headers []string, ragged raggedRows) {
+	if ragged.count > 0 {
+		ca.loadWarnings = append(ca.loadWarnings, DataWarning{
+			Kind: WarningRaggedRows, File: path, Count: ragged.count,
+			Message: fmt.Sprintf("%d rows do not have %d fields (%s)", ragged.count, len(headers), strings.Join(ragged.examples, "; ")),
+		})
+	}
+	// Only the first file's header is checked, since later files must repeat it exactly.
+	if len(ca.dataset.Sources) > 0 {
+		return
+	}
+	seen := make(map[string]int)
+	renamed := make(map[string][]string)
+	unique := disambiguateHeaders(headers)
+	for colIndex, header := range headers {
+		name := strings.TrimSpace(header)
+		if seen[name]++; seen[name] > 1 {
+			renamed[name] = append(renamed[name], unique[colIndex])
+		}
+	}
+	for _, header := range headers {
+		name := strings.TrimSpace(header)
+		if seen[name] > 1 {
+			ca.loadWarnings = append(ca.loadWarnings, DataWarning{
+				Kind: WarningDuplicateHeader, Column: name, File: path, Count: seen[name],
+				Message: fmt.Sprintf("header %q appears %d times; the repeats are renamed %s", name, seen[name], strings.Join(renamed[name], ", ")),
+			})
+			// Reports each duplicated name once.
+			seen[name] = 0
+		}
+	}
+}
+
+// The DataWarnings method is part of the CSVAnalyzer struct. It returns the warnings recorded while loading followed
+// by those found in the typed data: values of numeric or date columns that could not be converted and were left out
+// of the statistics (coercions), and text columns in which most values are numbers or dates, which usually means a
+// few stray strings pushed a typed column to text (mixed types, see TypeMixture).
+// DataWarnings lists every data problem the analyzer worked around
+func (ca *CSVAnalyzer) DataWarnings() []DataWarning {
+	warnings := append([]DataWarning(nil), ca.loadWarnings...)
+	// The warnings about the values of a spilled dataset come from the passes that profiled its columns.
+	if ca.spilled != nil {
+		return append(warnings, ca.spilled.warnings...)
+	}
+	for colIndex, header := range ca.dataset.Headers {
+		colType := ca.columnType(colIndex)
+		if colType == TypeText {
+			if warning, ok := ca.mixedTypeWarning(colIndex); ok {
+				warnings = append(warnings, warning)
+			}
+			continue
+		}
+		numbers, others := 0, 0
+		var examples []string
+		for rowIndex, row := range ca.dataset.Rows {
+			if colIndex >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[colIndex])
+			if value == "" {
+				continue
+			}
+			isNumber := false
+			switch colType {
+			case TypeDate:
+				_, isNumber = ca.parseDate(value)
+			default:
+				_, err := ca.parseNumber(value)
+				isNumber = err == nil
+			}
+			if isNumber {
+				numbers++
+			} else {
+				others++
+				// The exceptions are shown, unless they would publish values under differential privacy.
+				if len(examples) < maxWarningExamples && ca.privacy == nil {
+					examples = append(examples, fmt.Sprintf("row %d %q", rowIndex+1, value))
+				}
+			}
+		}
+		listed := ""
+		if len(examples) > 0 {
+			listed = ": " + strings.Join(examples, "; ")
+		}
+		switch {
+		case colType == TypeNumeric && others > 0:
+			warnings = append(warnings, DataWarning{
+				Kind: WarningCoercion, Column: header, Count: others,
+				Message: fmt.Sprintf("%d of %d values are not numbers and were left out of the statistics (see --audit-parsing)%s", others, numbers+others, listed),
+			})
+		case colType == TypeDate && others > 0:
+			warnings = append(warnings, DataWarning{
+				Kind: WarningCoercion, Column: header, Count: others,
+				Message: fmt.Sprintf("%d of %d values are not dates and were left out of the date range%s", others, numbers+others, listed),
+			})
+		}
+	}
+	return warnings
+}
+
+// mixedTypeWarning reports a text column whose dominant type is not text, naming the cells that kept it from that type
+func (ca *CSVAnalyzer) mixedTypeWarning(colIndex int) (DataWarning, bool) {
+	mixture := ca.TypeMixture(colIndex)
+	if mixture == nil || mixture.Dominant == string(TypeText) {
+		return DataWarning{}, false
+	}
+	message := fmt.Sprintf("values are %s, so the column is treated as text; %d cells are not %s", mixture.describe(), mixture.Inconsistent, mixture.Dominant)
+	// The offending values are left out under differential privacy.
+	if ca.privacy == nil {
+		message += " (" + mixture.describeExamples() + ")"
+	}
+	return DataWarning{Kind: WarningMixedTypes, Column: ca.dataset.Headers[colIndex], Count: mixture.Inconsistent, Message: message}, true
+}
+
+// describe renders a warning on one line
+func (w DataWarning) describe() string {
+	var where []string
+	if w.File != "" {
+		where = append(where, w.File)
+	}
+	if w.Column != "" {
+		where = append(where, "column "+w.Column)
+	}
+	if len(where) == 0 {
+		return fmt.Sprintf("%s: %s", w.Kind, w.Message)
+	}
+	return fmt.Sprintf("%s (%s): %s", w.Kind, strings.Join(where, ", "), w.Message)
+}
+
+// printDataWarnings writes every warning to w, prefixed with label ("Warning" or "Error")
+func printDataWarnings(w io.Writer, label string, warnings []DataWarning) {
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "%s: %s\n", label, warning.describe())
+	}
+}
+
+// enforceStrict stops the run when --strict is set and the loaded data has any warning;
+// without --strict it reports the warnings on stderr and carries on
+func (ca *CSVAnalyzer) enforceStrict() {
+	warnings := ca.DataWarnings()
+	if len(warnings) == 0 {
+		return
+	}
+	if !ca.options.Strict {
+		printDataWarnings(os.Stderr, "Warning", warnings)
+		return
+	}
+	printDataWarnings(os.Stderr, "Error", warnings)
+	fmt.Fprintf(os.Stderr, "%d data warnings treated as errors (--strict)\n", len(warnings))
+	os.Exit(exitDataWarnings)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// watchSettle is how long a file must go unmodified before it is analyzed, so files still being copied into the
+// directory are picked up on a later scan instead of half-written
+const watchSettle = 2 * time.Second
+
+// watchSummaryFile is the name of the rolling summary inside the reports directory
+const watchSummaryFile = "summary.json"
+
+// watchSummaryLimit is how many of the latest analyses the rolling summary keeps
+const watchSummaryLimit = 100
+
+// WatchEntry is the outcome of analyzing one version of a watched file
+type WatchEntry struct {
+	File         string    `json:"file"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"modified"`
+	AnalyzedAt   time.Time `json:"analyzed_at"`
+	Report       string    `json:"report,omitempty"` // path of the per-file JSON report
+	Rows         int       `json:"rows"`
+	Columns      int       `json:"columns"`
+	Warnings     int       `json:"warnings"`
+	FailedAlerts int       `json:"failed_alerts"`
+	Critical     bool      `json:"critical"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// WatchSummary is the rolling summary of a watched directory, latest analyses first
+type WatchSummary struct {
+	Directory string       `json:"directory"`
+	Updated   time.Time    `json:"updated"`
+	Files     int          `json:"files_analyzed"`
+	Failed    int          `json:"files_failed"`
+	Entries   []WatchEntry `json:"entries"`
+}
+
+// watcher keeps track of the files of a directory that were analyzed and in which version
+type watcher struct {
+	dir        string
+	pattern    string
+	reportsDir string
+	opts       *Options
+	seen       map[string]WatchEntry // by file name
+	summary    WatchSummary
+}
+
+// restore picks up the files analyzed by an earlier run from its rolling summary, so a restart does not analyze
+// every file again; a missing or unreadable summary starts afresh
+func (w *watcher) restore() {
+	data, err := os.ReadFile(filepath.Join(w.reportsDir, watchSummaryFile))
+	if err != nil {
+		return
+	}
+	var summary WatchSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		log.Printf("Ignoring unreadable %s: %v", watchSummaryFile, err)
+		return
+	}
+	w.summary = summary
+	// Entries are latest first, so the first one seen of every file is its current version.
+	for _, entry := range summary.Entries {
+		if _, ok := w.seen[entry.File]; !ok {
+			w.seen[entry.File] = entry
+		}
+	}
+}
+
+// The scan method is part of the watcher struct. It lists the directory once and analyzes every file matching the
+// pattern that is new or whose size or modification time changed since it was last analyzed, writing a JSON report
+// per file into the reports directory and the rolling summary after them. Hidden files, editor temporaries and
+// files modified within the last watchSettle are left for a later scan. A file that cannot be analyzed is recorded
+// with its error and not retried until it changes.
+// scan analyzes the new and changed files of the directory and returns their entries
+func (w *watcher) scan() ([]WatchEntry, error) {
+	dirEntries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory: %v", err)
+	}
+	var analyzed []WatchEntry
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		if ok, _ := filepath.Match(w.pattern, name); !ok {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) < watchSettle {
+			continue
+		}
+		if previous, ok := w.seen[name]; ok && previous.Size == info.Size() && previous.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		entry := w.analyze(name, info)
+		w.seen[name] = entry
+		analyzed = append(analyzed, entry)
+	}
+	if len(analyzed) == 0 {
+		return nil, nil
+	}
+
+	// The analyses of this scan go first, by file name, and the oldest beyond the limit are dropped.
+	sort.SliceStable(analyzed, func(i, j int) bool { return analyzed[i].File < analyzed[j].File })
+	for _, entry := range analyzed {
+		w.summary.Files++
+		if entry.Error != "" {
+			w.summary.Failed++
+		}
+	}
+	w.summary.Entries = append(append([]WatchEntry(nil), analyzed...), w.summary.Entries...)
+	if len(w.summary.Entries) > watchSummaryLimit {
+		w.summary.Entries = w.summary.Entries[:watchSummaryLimit]
+	}
+	w.summary.Directory = w.dir
+	w.summary.Updated = time.Now().UTC()
+	return analyzed, w.writeSummary()
+}
+
+// analyze builds the report of one file and writes it into the reports directory
+func (w *watcher) analyze(name string, info os.FileInfo) WatchEntry {
+	entry := WatchEntry{File: name, Size: info.Size(), ModTime: info.ModTime(), AnalyzedAt: time.Now().UTC()}
+	report, err := analyzeToReport(filepath.Join(w.dir, name), w.opts)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.Rows, entry.Columns, entry.Warnings = report.Rows, report.ColumnCount, len(report.Warnings)
+	entry.FailedAlerts = len(activeFailures(report.Alerts))
+	entry.Critical = hasCriticalFailure(report.Alerts)
+
+	path := filepath.Join(w.reportsDir, strings.TrimSuffix(name, filepath.Ext(name))+".json")
+	out, err := createOutputFile(path, w.opts.Compress)
+	if err != nil {
+		entry.Error = fmt.Sprintf("error creating report: %v", err)
+		return entry
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(report)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		entry.Error = fmt.Sprintf("error writing report: %v", err)
+		return entry
+	}
+	entry.Report = compressedPath(path, w.opts.Compress)
+	return entry
+}
+
+// writeSummary replaces the rolling summary, through a temporary file so readers never see half of it
+func (w *watcher) writeSummary() error {
+	data, err := json.MarshalIndent(w.summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding summary: %v", err)
+	}
+	path := filepath.Join(w.reportsDir, watchSummaryFile)
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing summary: %v", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// logWatchEntry prints one line per analyzed file
+func logWatchEntry(entry WatchEntry) {
+	switch {
+	case entry.Error != "":
+		log.Printf("%s: failed: %s", entry.File, entry.Error)
+	case entry.Critical:
+		log.Printf("%s: %d rows, %d columns, %d warnings, %d failed alerts (critical)", entry.File, entry.Rows, entry.Columns, entry.Warnings, entry.FailedAlerts)
+	default:
+		log.Printf("%s: %d rows, %d columns, %d warnings, %d failed alerts", entry.File, entry.Rows, entry.Columns, entry.Warnings, entry.FailedAlerts)
+	}
+}
+
+// runWatch implements the watch subcommand: a lightweight ingestion monitor that scans a directory every interval,
+// analyzes the CSV files that appear or change in it and keeps a report per file and a rolling summary
+func runWatch(args []string) {
+	// Reuses the analyzer flags so --config, --rules and the quick checks apply to every file.
+	opts := &Options{}
+	fs := newOptionsFlagSet(opts)
+	every := fs.Duration("every", 5*time.Minute, "how often the directory is scanned (e.g. 30s, 5m)")
+	reportsDir := fs.String("reports-dir", "", "`directory` the per-file reports and summary.json go into (default <directory>/reports)")
+	pattern := fs.String("pattern", "*.csv", "file name `glob` of the files to analyze")
+	once := fs.Bool("once", false, "scan once and exit, with status 3 when a file fails a critical alert")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . watch [--every 5m] [--reports-dir <dir>] [--pattern '*.csv'] [--once] [options] <directory>")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal("Invalid options: ", err)
+	}
+	if *every <= 0 {
+		log.Fatal("Invalid options: --every must be positive")
+	}
+	if _, err := filepath.Match(*pattern, ""); err != nil {
+		log.Fatal("Invalid options: ", fmt.Errorf("invalid --pattern %q: %v", *pattern, err))
+	}
+	dir := positional[0]
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		log.Fatal("Error watching directory: ", fmt.Errorf("%s is not a directory", dir))
+	}
+	if *reportsDir == "" {
+		*reportsDir = filepath.Join(dir, "reports")
+	}
+	if err := os.MkdirAll(*reportsDir, 0o755); err != nil {
+		log.Fatal("Error creating reports directory:", err)
+	}
+
+	w := &watcher{dir: dir, pattern: *pattern, reportsDir: *reportsDir, opts: opts, seen: make(map[string]WatchEntry)}
+	w.restore()
+	scan := func() bool {
+		entries, err := w.scan()
+		if err != nil {
+			log.Printf("Scan failed: %v", err)
+		}
+		critical := false
+		for _, entry := range entries {
+			logWatchEntry(entry)
+			critical = critical || entry.Critical
+		}
+		return critical
+	}
+
+	if *once {
+		if scan() {
+			os.Exit(exitCriticalAlert)
+		}
+		return
+	}
+
+	// Stops between scans on the signals container runtimes send.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	ticker := time.NewTicker(*every)
+	defer ticker.Stop()
+	log.Printf("Watching %s every %s, reports in %s", dir, *every, *reportsDir)
+	scan()
+	for {
+		select {
+		case <-ticker.C:
+			scan()
+		case sig := <-stop:
+			log.Printf("Received %v, stopping", sig)
+			return
+		}
+	}
+}
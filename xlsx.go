@@ -0,0 +1,243 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Limits of the Excel workbook
+const (
+	xlsxFrequencyValues = 50    // most frequent values listed per column on the Frequencies sheet
+	xlsxPreviewRows     = 100   // data rows copied onto the Preview sheet
+	xlsxMaxCellText     = 32767 // characters Excel accepts in one cell
+)
+
+// xlsxCell is one cell of a worksheet: a number when IsNumber is set, otherwise text (empty text leaves it blank)
+type xlsxCell struct {
+	Text     string
+	Number   float64
+	IsNumber bool
+}
+
+// xlsxSheet is a named worksheet whose first row is a bold, frozen header
+type xlsxSheet struct {
+	Name string
+	Rows [][]xlsxCell
+}
+
+// textCell makes a text cell, cut to the length Excel accepts
+func textCell(text string) xlsxCell {
+	if runes := []rune(text); len(runes) > xlsxMaxCellText {
+		text = string(runes[:xlsxMaxCellText])
+	}
+	return xlsxCell{Text: text}
+}
+
+// numberCell makes a numeric cell; NaN and infinities, which Excel cannot store, become text
+func numberCell(value float64) xlsxCell {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return xlsxCell{Text: strconv.FormatFloat(value, 'g', -1, 64)}
+	}
+	return xlsxCell{Number: value, IsNumber: true}
+}
+
+// optionalNumberCell makes a numeric cell, or an empty one when the figure is missing
+func optionalNumberCell(value *float64) xlsxCell {
+	if value == nil {
+		return xlsxCell{}
+	}
+	return numberCell(*value)
+}
+
+// headerRow turns column titles into a row of text cells
+func headerRow(titles ...string) []xlsxCell {
+	row := make([]xlsxCell, len(titles))
+	for i, title := range titles {
+		row[i] = textCell(title)
+	}
+	return row
+}
+
+// The xlsxSheets method is part of the CSVAnalyzer struct. It lays the report out as the four sheets of the workbook:
+// Columns (type, completeness and cardinality of every column), Numeric (the statistics of numeric columns),
+// Frequencies (the most frequent values of text and boolean columns, with their counts and shares) and Preview (the
+// first rows of the data, numbers typed). The figures come from the same report as --format json, so sampling and
+// privacy noise apply; the frequency tables and the preview copy raw values, so they are withheld under differential
+// privacy.
+// xlsxSheets returns the worksheets of the Excel report
+func (ca *CSVAnalyzer) xlsxSheets() []xlsxSheet {
+	report := ca.BuildReport()
+
+	columns := xlsxSheet{Name: "Columns", Rows: [][]xlsxCell{headerRow("Column", "Type", "Description", "Unit", "Non-empty", "Missing", "Missing %", "Distinct", "Uniqueness", "Primary key candidate")}}
+	for _, column := range report.Columns {
+		row := []xlsxCell{textCell(column.Name), textCell(column.Type), textCell(column.Description), textCell(column.Unit)}
+		if card := column.Cardinality; card != nil {
+			missing := report.Rows - card.NonEmpty
+			missingPct := 0.0
+			if report.Rows > 0 {
+				missingPct = 100 * float64(missing) / float64(report.Rows)
+			}
+			row = append(row, numberCell(float64(card.NonEmpty)), numberCell(float64(missing)), numberCell(missingPct),
+				numberCell(float64(card.Distinct)), numberCell(card.UniquenessRatio), textCell(strconv.FormatBool(card.PrimaryKey)))
+		}
+		columns.Rows = append(columns.Rows, row)
+	}
+
+	numeric := xlsxSheet{Name: "Numeric", Rows: [][]xlsxCell{headerRow("Column", "Count", "Sum", "Mean", "Median", "Std dev", "Min", "Max", "Trimmed mean", "Geometric mean", "Harmonic mean")}}
+	for _, column := range report.Columns {
+		if stats := column.Numeric; stats != nil {
+			numeric.Rows = append(numeric.Rows, []xlsxCell{
+				textCell(column.Name), numberCell(float64(stats.Count)), numberCell(stats.Sum), numberCell(stats.Mean),
+				numberCell(stats.Median), numberCell(stats.StdDev), numberCell(stats.Min), numberCell(stats.Max),
+				numberCell(stats.TrimmedMean), optionalNumberCell(stats.GeometricMean), optionalNumberCell(stats.HarmonicMean),
+			})
+		}
+	}
+
+	frequencies := xlsxSheet{Name: "Frequencies", Rows: [][]xlsxCell{headerRow("Column", "Value", "Count", "Share")}}
+	preview := xlsxSheet{Name: "Preview", Rows: [][]xlsxCell{headerRow(ca.dataset.Headers...)}}
+	if ca.privacy != nil {
+		frequencies.Rows = append(frequencies.Rows, []xlsxCell{textCell("withheld under --dp-epsilon")})
+		preview.Rows = append(preview.Rows, []xlsxCell{textCell("withheld under --dp-epsilon")})
+		return []xlsxSheet{columns, numeric, frequencies, preview}
+	}
+	for colIndex, header := range ca.dataset.Headers {
+		if colType := ca.columnType(colIndex); colType != TypeText && colType != TypeBoolean {
+			continue
+		}
+		nonEmpty := ca.countNonEmptyValues(colIndex)
+		values, counts := ca.topValues(colIndex, xlsxFrequencyValues)
+		for i, value := range values {
+			frequencies.Rows = append(frequencies.Rows, []xlsxCell{
+				textCell(header), textCell(value), numberCell(float64(counts[i])), numberCell(float64(counts[i]) / float64(nonEmpty)),
+			})
+		}
+	}
+	for rowIndex, record := range ca.dataset.Rows {
+		if rowIndex == xlsxPreviewRows {
+			break
+		}
+		row := make([]xlsxCell, len(record))
+		for colIndex, value := range record {
+			row[colIndex] = textCell(value)
+			// Numbers are stored as numbers so they can be summed and sorted in Excel.
+			if ca.columnType(colIndex) == TypeNumeric {
+				if num, err := ca.parseNumber(value); err == nil {
+					row[colIndex] = numberCell(num)
+				}
+			}
+		}
+		preview.Rows = append(preview.Rows, row)
+	}
+	return []xlsxSheet{columns, numeric, frequencies, preview}
+}
+
+// xlsxColumnName converts a zero-based column index into its spreadsheet letters, e.g. 0 -> A, 27 -> AB
+func xlsxColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// xmlText escapes text for an XML element; characters XML cannot hold become the replacement character
+func xmlText(text string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// worksheetXML renders a worksheet in SpreadsheetML, with text as inline strings so no shared string table is needed
+func worksheetXML(sheet xlsxSheet) string {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// Freezes the header row so it stays visible while scrolling.
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<sheetData>`)
+	for rowIndex, row := range sheet.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, rowIndex+1)
+		for colIndex, cell := range row {
+			ref := xlsxColumnName(colIndex) + strconv.Itoa(rowIndex+1)
+			// Style 1 is the bold header font (see xlsxStyles).
+			style := ""
+			if rowIndex == 0 {
+				style = ` s="1"`
+			}
+			switch {
+			case cell.IsNumber:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(cell.Number, 'g', -1, 64))
+			case cell.Text != "":
+				fmt.Fprintf(&b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlText(cell.Text))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxStyles defines the regular cell style (0) and the bold header style (1)
+const xlsxStyles = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+// The WriteXLSXReport method is part of the CSVAnalyzer struct. It writes the report as an Excel workbook (Office Open
+// XML): a zip archive of the package parts Excel, LibreOffice and Google Sheets expect, with one worksheet per part of
+// the report (see xlsxSheets). The parts are written directly, so no spreadsheet library is needed.
+// WriteXLSXReport writes the report as an .xlsx workbook
+func (ca *CSVAnalyzer) WriteXLSXReport(w io.Writer) error {
+	sheets := ca.xlsxSheets()
+	var contentTypes, workbookSheets, workbookRels bytes.Buffer
+	for i, sheet := range sheets {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&workbookSheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlText(sheet.Name), i+1, i+1)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			contentTypes.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + workbookSheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			workbookRels.String() + `</Relationships>`},
+		{"xl/styles.xml", xml.Header + xlsxStyles},
+	}
+	for i, sheet := range sheets {
+		parts = append(parts, struct{ name, content string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheetXML(sheet)})
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range parts {
+		entry, err := archive.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("error writing Excel report: %v", err)
+		}
+		if _, err := io.WriteString(entry, part.content); err != nil {
+			return fmt.Errorf("error writing Excel report: %v", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error writing Excel report: %v", err)
+	}
+	return nil
+}
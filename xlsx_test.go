@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// xlsxTestCell is a worksheet cell as SpreadsheetML stores it
+type xlsxTestCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  string `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+// readTestWorkbook writes the Excel report of analyzer and unpacks it, failing the test on a missing or malformed
+// part; it returns the sheet names in order and the cells of each sheet by reference
+func readTestWorkbook(t *testing.T, analyzer *CSVAnalyzer) (names []string, sheets map[string]map[string]xlsxTestCell) {
+	t.Helper()
+	var workbook bytes.Buffer
+	if err := analyzer.WriteXLSXReport(&workbook); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(workbook.Bytes()), int64(workbook.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string][]byte)
+	for _, file := range archive.File {
+		entry, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(entry)
+		entry.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for decoder := xml.NewDecoder(bytes.NewReader(content)); ; {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed XML: %v", file.Name, err)
+			}
+		}
+		parts[file.Name] = content
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if parts[name] == nil {
+			t.Fatalf("the workbook has no %s part", name)
+		}
+	}
+
+	var book struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &book); err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(parts["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		t.Fatal(err)
+	}
+	targets := make(map[string]string)
+	for _, rel := range rels.Relationships {
+		targets[rel.ID] = "xl/" + rel.Target
+	}
+	sheets = make(map[string]map[string]xlsxTestCell)
+	for _, sheet := range book.Sheets {
+		content, ok := parts[targets[sheet.ID]]
+		if !ok {
+			t.Fatalf("sheet %s points at a missing part %q", sheet.Name, targets[sheet.ID])
+		}
+		if !strings.Contains(string(parts["[Content_Types].xml"]), `PartName="/`+targets[sheet.ID]+`"`) {
+			t.Errorf("sheet %s has no content type", sheet.Name)
+		}
+		var worksheet struct {
+			Rows []struct {
+				Cells []xlsxTestCell `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		if err := xml.Unmarshal(content, &worksheet); err != nil {
+			t.Fatal(err)
+		}
+		cells := make(map[string]xlsxTestCell)
+		for _, row := range worksheet.Rows {
+			for _, cell := range row.Cells {
+				cells[cell.Ref] = cell
+			}
+		}
+		names = append(names, sheet.Name)
+		sheets[sheet.Name] = cells
+	}
+	return names, sheets
+}
+
+func TestXLSXReportSheets(t *testing.T) {
+	analyzer := loadTestCSV(t, "price,name\n1.5,Widget\n2.5,\"<Gadget> & \"\"co\"\"\"\n,Widget\n")
+	names, sheets := readTestWorkbook(t, analyzer)
+	if want := []string{"Columns", "Numeric", "Frequencies", "Preview"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("sheets %q, want %q", names, want)
+	}
+
+	columns := sheets["Columns"]
+	if cell := columns["A1"]; cell.Inline != "Column" || cell.Style != "1" {
+		t.Errorf("Columns A1 = %+v, want the bold Column header", cell)
+	}
+	if got := columns["A2"].Inline + "/" + columns["B2"].Inline; got != "price/Numeric" {
+		t.Errorf("Columns row 2 = %s, want price/Numeric", got)
+	}
+	if cell := columns["F2"]; cell.Type != "" || cell.Value != "1" {
+		t.Errorf("missing count of price = %+v, want the number 1", cell)
+	}
+
+	numeric := sheets["Numeric"]
+	if got := numeric["A2"].Inline; got != "price" {
+		t.Errorf("Numeric A2 = %q, want price", got)
+	}
+	if got := numeric["D2"].Value; got != "2" {
+		t.Errorf("mean of price = %q, want 2", got)
+	}
+	if _, ok := numeric["A3"]; ok {
+		t.Error("the text column has a row on the Numeric sheet")
+	}
+
+	frequencies := sheets["Frequencies"]
+	if got := []string{frequencies["B2"].Inline, frequencies["C2"].Value, frequencies["D2"].Value}; !reflect.DeepEqual(got, []string{"Widget", "2", strconv.FormatFloat(2.0/3, 'g', -1, 64)}) {
+		t.Errorf("top value of name = %q", got)
+	}
+
+	preview := sheets["Preview"]
+	if cell := preview["A2"]; cell.Value != "1.5" || cell.Type != "" {
+		t.Errorf("Preview A2 = %+v, want the number 1.5", cell)
+	}
+	// Markup in cells is escaped and reads back as the original text.
+	if got := preview["B3"].Inline; got != `<Gadget> & "co"` {
+		t.Errorf("Preview B3 = %q", got)
+	}
+	// Empty cells are left out of the sheet.
+	if _, ok := preview["A4"]; ok {
+		t.Error("the empty price cell was written")
+	}
+}
+
+func TestXLSXReportWithholdsValuesUnderPrivacy(t *testing.T) {
+	_, sheets := readTestWorkbook(t, loadTestCSV(t, "name\nsecret\n", "--dp-epsilon", "1"))
+	for _, name := range []string{"Frequencies", "Preview"} {
+		if got := sheets[name]["A2"].Inline; got != "withheld under --dp-epsilon" {
+			t.Errorf("%s A2 = %q, want the withheld notice", name, got)
+		}
+		for ref, cell := range sheets[name] {
+			if cell.Inline == "secret" {
+				t.Errorf("%s %s holds a raw value", name, ref)
+			}
+		}
+	}
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA", 16383: "XFD"} {
+		if got := xlsxColumnName(index); got != want {
+			t.Errorf("xlsxColumnName(%d) = %s, want %s", index, got, want)
+		}
+	}
+}
+
+func TestXLSXCells(t *testing.T) {
+	if cell := textCell(strings.Repeat("é", xlsxMaxCellText+10)); len([]rune(cell.Text)) != xlsxMaxCellText {
+		t.Errorf("text cut to %d characters, want %d", len([]rune(cell.Text)), xlsxMaxCellText)
+	}
+	for value, want := range map[float64]string{math.Inf(1): "+Inf", math.Inf(-1): "-Inf"} {
+		if cell := numberCell(value); cell.IsNumber || cell.Text != want {
+			t.Errorf("numberCell(%v) = %+v, want the text %s", value, cell, want)
+		}
+	}
+	if cell := numberCell(math.NaN()); cell.IsNumber || cell.Text != "NaN" {
+		t.Errorf("numberCell(NaN) = %+v, want the text NaN", cell)
+	}
+	if cell := optionalNumberCell(nil); cell != (xlsxCell{}) {
+		t.Errorf("optionalNumberCell(nil) = %+v, want an empty cell", cell)
+	}
+	// Control characters XML cannot hold must not break the worksheet.
+	sheet := worksheetXML(xlsxSheet{Name: "Test", Rows: [][]xlsxCell{headerRow("a\x00b", "c\x1fd")}})
+	for decoder := xml.NewDecoder(strings.NewReader(sheet)); ; {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("worksheet with control characters is not well-formed: %v", err)
+		}
+	}
+}